@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"elastic-logger-app/common/logger"
+	"elastic-logger-app/common/module"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// healthTimeout bounds a /health request; checks still running then are reported as failed.
+const healthTimeout = 2 * time.Second
+
+// handleHealth runs every module health check concurrently. It answers 200 when all pass and
+// 503 otherwise, with "ok" or "unavailable" per check; failures are only detailed in the log,
+// since their messages name hosts and ports.
+func handleHealth(checks []module.HealthCheck) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), healthTimeout)
+		defer cancel()
+
+		var (
+			mu      sync.Mutex
+			wg      sync.WaitGroup
+			results = make(map[string]string, len(checks))
+			healthy = true
+		)
+		for _, check := range checks {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				result := "ok"
+				if err := check.Check(ctx); err != nil {
+					result = "unavailable"
+					logger.FromContext(ctx).Warn("health check failed", zap.String("check", check.Name), zap.Error(err))
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+				results[check.Name] = result
+				healthy = healthy && result == "ok"
+			}()
+		}
+		wg.Wait()
+
+		status, code := "ok", http.StatusOK
+		if !healthy {
+			status, code = "unavailable", http.StatusServiceUnavailable
+		}
+		c.JSON(code, gin.H{"status": status, "checks": results})
+	}
+}
@@ -1,63 +1,145 @@
 package server
 
 import (
+	"context"
 	"database/sql"
-	"elastic-logger-app/builder"
-	accounthttp "elastic-logger-app/modules/account/infras/http"
-	accountcommands "elastic-logger-app/modules/account/usecase/commands"
-	accountqueries "elastic-logger-app/modules/account/usecase/queries"
-	"log"
+	"elastic-logger-app/common"
+	"elastic-logger-app/common/i18n"
+	"elastic-logger-app/common/idempotency"
+	"elastic-logger-app/common/logger"
+	"elastic-logger-app/common/metrics"
+	"elastic-logger-app/common/module"
+	"elastic-logger-app/common/tracing"
+	"elastic-logger-app/configs"
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
 	"github.com/olivere/elastic/v7"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
 )
 
 type server struct {
-	port    string
+	config  *configs.Config
 	mysql   *sql.DB
 	mongo   *mongo.Client
 	elastic *elastic.Client
+	modules *module.Registry
 }
 
-func InitServer(port string, mysql *sql.DB, mongo *mongo.Client, elastic *elastic.Client) *server {
+// InitServer returns a server for the registered modules. Clients no module needs may be nil.
+func InitServer(config *configs.Config, mysql *sql.DB, mongo *mongo.Client, elastic *elastic.Client, modules *module.Registry) *server {
 	return &server{
-		port:    port,
+		config:  config,
 		mysql:   mysql,
 		mongo:   mongo,
 		elastic: elastic,
+		modules: modules,
 	}
 }
 
-func (server *server) RunApp() error {
+// shutdownTimeout bounds how long in-flight requests may take once shutdown starts.
+const shutdownTimeout = 15 * time.Second
+
+// RunApp serves HTTP until ctx is cancelled, then stops accepting connections and waits for
+// in-flight requests to finish.
+func (server *server) RunApp(ctx context.Context) error {
+	router, err := server.Handler()
+	if err != nil {
+		return err
+	}
+
+	log := logger.Named("server")
+	port := ":" + server.config.HTTP.Port
+	httpServer := &http.Server{Addr: port, Handler: router}
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+		log.Info("server shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+		defer cancel()
+		shutdownErr <- httpServer.Shutdown(shutdownCtx)
+	}()
+
+	log.Info("server start listening", zap.String("port", port))
+	if err := httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return <-shutdownErr
+}
+
+// Handler starts the modules and returns the router serving the shared endpoints and every
+// module's routes.
+func (server *server) Handler() (http.Handler, error) {
+	common.UseProblemJSON(server.config.HTTP.ErrorFormat == "problem")
+	common.SetErrorPolicy(common.ErrorPolicy{
+		ExposeLocation:   server.config.Errors.ExposeLocation,
+		ExposeReason:     server.config.Errors.ExposeReason,
+		PublicDetailKeys: server.config.Errors.PublicDetails,
+	})
+	common.SetCaptureStack(server.config.Errors.CaptureStack)
+	common.SetMaxBodyBytes(server.config.HTTP.MaxBodyBytes)
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		if err := common.SetupValidator(v); err != nil {
+			return nil, err
+		}
+	}
+
 	router := gin.New()
+	// Let handlers that receive *gin.Context as a context.Context see values stored on the request context.
+	router.ContextWithFallback = true
 
-	router.Use(gin.Logger())
-	router.Use(gin.Recovery())
+	log := logger.Named("server")
+
+	router.Use(logger.GinMiddleware(log))
+	router.Use(common.RequestID())
+	router.Use(i18n.Middleware())
+	router.Use(tracing.GinMiddleware(tracing.InstrumentationName)...)
+	router.Use(metrics.HTTPMiddleware())
+	router.Use(common.Recovery())
 
 	configcors := cors.DefaultConfig()
 	configcors.AllowAllOrigins = true
 	configcors.AllowMethods = []string{"POST", "GET", "PUT", "DELETE", "PATCH", "OPTIONS"}
-	configcors.AllowHeaders = []string{"Origin", "Content-Type", "Authorization", "Accept", "User-Agent", "Cache-Control", "Pragma"}
-	configcors.ExposeHeaders = []string{"Content-Length"}
+	configcors.AllowHeaders = []string{"Origin", "Content-Type", "Authorization", "Accept", "User-Agent", "Cache-Control", "Pragma", common.HeaderRequestID}
+	configcors.ExposeHeaders = []string{"Content-Length", common.HeaderRequestID}
 	configcors.AllowCredentials = true
 	configcors.MaxAge = 12 * time.Hour
 
 	router.Use(cors.New(configcors))
 	router.GET("/ping", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"message": "elastic-logger-app response: pong"}) })
+	router.GET("/metrics", metrics.Handler())
 
-	account_builder := builder.NewAccountBuilder(server.mysql, server.mongo)
-	acc_cmd_builder := accountcommands.NewAccountCmdWithBuilder(account_builder)
-	acc_query_builder := accountqueries.NewAccountQueryWithBuilder(account_builder)
+	deps := module.Deps{
+		Config:  server.config,
+		MySQL:   server.mysql,
+		Mongo:   server.mongo,
+		Elastic: server.elastic,
+	}
+	if server.mysql != nil {
+		if err := metrics.RegisterDBStats(server.mysql, "mysql"); err != nil {
+			log.Warn("cannot register MySQL pool metrics", zap.Error(err))
+		}
+		deps.Idempotency = idempotency.Middleware(idempotency.NewMySQLKeyStore(server.mysql), server.config.Idempotency.KeyTTL, server.config.HTTP.MaxBodyBytes)
+	}
+
+	if err := server.modules.Start(deps); err != nil {
+		return nil, err
+	}
+	router.GET("/health", handleHealth(server.modules.HealthChecks()))
 
 	api := router.Group("/api/v1")
 	{
-		accounthttp.NewAccountHTTP(acc_cmd_builder, acc_query_builder).Routes(api)
+		api.GET("/errors", common.HandleErrorCatalog())
+		api.GET("/errors/:code", common.HandleErrorCode())
+		server.modules.Routes(api)
 	}
 
-	log.Println("server start listening at port: ", server.port)
-	return router.Run(server.port)
+	return router, nil
 }
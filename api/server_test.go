@@ -0,0 +1,129 @@
+package server
+
+import (
+	"context"
+	"elastic-logger-app/builder"
+	"elastic-logger-app/common/module"
+	"elastic-logger-app/configs"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pingModule needs no infrastructure: it serves GET /api/v1/<name>/ping and has one health
+// check returning healthErr.
+type pingModule struct {
+	name      string
+	healthErr error
+	started   bool
+}
+
+func (m *pingModule) Name() string                { return m.name }
+func (m *pingModule) Needs() []module.Need        { return nil }
+func (m *pingModule) Init(deps module.Deps) error { m.started = true; return nil }
+
+func (m *pingModule) Routes(api *gin.RouterGroup) {
+	api.GET("/"+m.name+"/ping", func(c *gin.Context) { c.String(http.StatusOK, m.name) })
+}
+
+func (m *pingModule) HealthChecks() []module.HealthCheck {
+	return []module.HealthCheck{{Name: "self", Check: func(ctx context.Context) error { return m.healthErr }}}
+}
+
+func bootServer(t *testing.T, modules ...module.Module) (*httptest.Server, error) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	registry, err := module.NewRegistry(modules...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := configs.Defaults()
+	handler, err := InitServer(&config, nil, nil, nil, registry).Handler()
+	if err != nil {
+		return nil, err
+	}
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return srv, nil
+}
+
+func get(t *testing.T, url string) (int, string) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var body strings.Builder
+	buf := make([]byte, 4096)
+	for {
+		n, err := resp.Body.Read(buf)
+		body.Write(buf[:n])
+		if err != nil {
+			break
+		}
+	}
+	return resp.StatusCode, body.String()
+}
+
+func TestServerServesOnlyRegisteredModules(t *testing.T) {
+	alpha := &pingModule{name: "alpha"}
+	srv, err := bootServer(t, alpha)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !alpha.started {
+		t.Fatal("module was not started")
+	}
+	if code, body := get(t, srv.URL+"/api/v1/alpha/ping"); code != http.StatusOK || body != "alpha" {
+		t.Fatalf("alpha: %d %q", code, body)
+	}
+	if code, _ := get(t, srv.URL+"/api/v1/beta/ping"); code != http.StatusNotFound {
+		t.Fatalf("beta is not registered but answered %d", code)
+	}
+	if code, _ := get(t, srv.URL+"/api/v1/errors"); code != http.StatusOK {
+		t.Fatalf("shared routes missing: %d", code)
+	}
+}
+
+func TestServerHealthReportsModuleChecks(t *testing.T) {
+	srv, err := bootServer(t, &pingModule{name: "alpha"}, &pingModule{name: "beta", healthErr: errors.New("dial tcp 10.0.0.1:3306: refused")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, body := get(t, srv.URL+"/health")
+	if code != http.StatusServiceUnavailable {
+		t.Fatalf("health = %d", code)
+	}
+
+	var got struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks"`
+	}
+	if err := json.Unmarshal([]byte(body), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != "unavailable" || got.Checks["alpha.self"] != "ok" || got.Checks["beta.self"] != "unavailable" {
+		t.Fatalf("health body = %s", body)
+	}
+	if strings.Contains(body, "10.0.0.1") {
+		t.Fatalf("health leaks check errors: %s", body)
+	}
+}
+
+func TestServerRefusesModuleWithoutItsNeeds(t *testing.T) {
+	_, err := bootServer(t, &pingModule{name: "alpha"}, builder.NewAccountModule())
+	if err == nil || !strings.Contains(err.Error(), "module account needs mysql") {
+		t.Fatalf("err = %v", err)
+	}
+}
@@ -2,21 +2,25 @@ package builder
 
 import (
 	"database/sql"
+	"elastic-logger-app/common"
+	"elastic-logger-app/common/outbox"
 	accountcommandrepo "elastic-logger-app/modules/account/infras/commandrepo"
 	accountqueryrepo "elastic-logger-app/modules/account/infras/queryrepo"
 	accountcommands "elastic-logger-app/modules/account/usecase/commands"
+	accountprojections "elastic-logger-app/modules/account/usecase/projections"
 	accountqueries "elastic-logger-app/modules/account/usecase/queries"
 
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type accountBuilder struct {
-	db    *sql.DB
-	mongo *mongo.Client
+	db            *sql.DB
+	mongo         *mongo.Client
+	mongoDatabase string
 }
 
-func NewAccountBuilder(db *sql.DB, mongo *mongo.Client) accountBuilder {
-	return accountBuilder{db: db, mongo: mongo}
+func NewAccountBuilder(db *sql.DB, mongo *mongo.Client, mongoDatabase string) accountBuilder {
+	return accountBuilder{db: db, mongo: mongo, mongoDatabase: mongoDatabase}
 }
 
 func (s accountBuilder) BuildAccountCommandRepo() accountcommands.AccountCommandRepo {
@@ -24,5 +28,21 @@ func (s accountBuilder) BuildAccountCommandRepo() accountcommands.AccountCommand
 }
 
 func (s accountBuilder) BuildAccountQueryRepo() accountqueries.AccountQueryRepo {
-	return accountqueryrepo.NewAccountQueryRepo(s.mongo)
+	return accountqueryrepo.NewAccountQueryRepo(s.mongo, s.mongoDatabase)
+}
+
+func (s accountBuilder) BuildAccountSource() accountprojections.AccountSource {
+	return accountcommandrepo.NewAccountCommandRepo(s.db)
+}
+
+func (s accountBuilder) BuildAccountProjection() accountprojections.AccountProjection {
+	return accountqueryrepo.NewAccountQueryRepo(s.mongo, s.mongoDatabase)
+}
+
+func (s accountBuilder) BuildTransactor() accountcommands.Transactor {
+	return common.NewTransactor(s.db)
+}
+
+func (s accountBuilder) BuildAccountOutbox() accountcommands.AccountOutbox {
+	return outbox.NewMySQLStore(s.db)
 }
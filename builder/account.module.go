@@ -0,0 +1,87 @@
+package builder
+
+import (
+	"context"
+	"elastic-logger-app/common/idempotency"
+	"elastic-logger-app/common/module"
+	"elastic-logger-app/common/rabbitmq"
+	"elastic-logger-app/migration"
+	accountconsumer "elastic-logger-app/modules/account/infras/consumer"
+	accounthttp "elastic-logger-app/modules/account/infras/http"
+	accountqueryrepo "elastic-logger-app/modules/account/infras/queryrepo"
+	accountcommands "elastic-logger-app/modules/account/usecase/commands"
+	accountprojections "elastic-logger-app/modules/account/usecase/projections"
+	accountqueries "elastic-logger-app/modules/account/usecase/queries"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+// accountModule plugs the account bounded context into the server: commands on MySQL,
+// queries on the Mongo read model, which the worker keeps up to date from account events.
+type accountModule struct {
+	builder    accountBuilder
+	commands   accountcommands.Commands
+	queries    accountqueries.Queries
+	idempotent gin.HandlerFunc
+	// projection is only built when RabbitMQ is connected, i.e. in the worker.
+	projection *rabbitmq.Consumer
+}
+
+func NewAccountModule() *accountModule {
+	return &accountModule{}
+}
+
+func (m *accountModule) Name() string {
+	return "account"
+}
+
+func (m *accountModule) Needs() []module.Need {
+	return []module.Need{module.NeedMySQL, module.NeedMongo}
+}
+
+func (m *accountModule) Init(deps module.Deps) error {
+	m.builder = NewAccountBuilder(deps.MySQL, deps.Mongo, deps.Config.Mongo.Database)
+	m.commands = accountcommands.NewAccountCmdWithBuilder(m.builder)
+	m.queries = accountqueries.NewAccountQueryWithBuilder(m.builder)
+
+	m.idempotent = deps.Idempotency
+	if m.idempotent == nil {
+		m.idempotent = func(c *gin.Context) { c.Next() }
+	}
+
+	if deps.RabbitMQ != nil {
+		cfg := rabbitmq.DefaultConsumerConfig(deps.Config.RabbitMQ.Exchange, accountconsumer.ProjectionQueue)
+		cfg.Prefetch = deps.Config.RabbitMQ.Prefetch
+		cfg.Workers = deps.Config.RabbitMQ.Workers
+		guard := idempotency.NewGuard(idempotency.NewMySQLMessageStore(deps.MySQL, deps.Config.Idempotency.MessageTTL), accountconsumer.ProjectionQueue)
+		if err := guard.RegisterMetrics(); err != nil {
+			zap.L().Named("account").Warn("Cannot register idempotency metrics", zap.String("consumer", accountconsumer.ProjectionQueue), zap.Error(err))
+		}
+		m.projection = accountconsumer.NewProjectionConsumer(deps.RabbitMQ, cfg, guard, accountprojections.NewAccountProjectionWithBuilder(m.builder))
+	}
+	return nil
+}
+
+func (m *accountModule) Routes(api *gin.RouterGroup) {
+	accounthttp.NewAccountHTTP(m.commands, m.queries, m.idempotent).Routes(api)
+}
+
+func (m *accountModule) Consumers() []*rabbitmq.Consumer {
+	if m.projection == nil {
+		return nil
+	}
+	return []*rabbitmq.Consumer{m.projection}
+}
+
+func (m *accountModule) MongoSteps(db *mongo.Database) []migration.Step {
+	return accountqueryrepo.MongoSteps(db)
+}
+
+func (m *accountModule) HealthChecks() []module.HealthCheck {
+	return []module.HealthCheck{
+		{Name: "mysql", Check: m.builder.db.PingContext},
+		{Name: "mongo", Check: func(ctx context.Context) error { return m.builder.mongo.Ping(ctx, nil) }},
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"elastic-logger-app/builder"
+	"elastic-logger-app/common"
+	"elastic-logger-app/common/i18n"
+	"elastic-logger-app/configs"
+	"elastic-logger-app/migration"
+	accountcommands "elastic-logger-app/modules/account/usecase/commands"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
+)
+
+// runAccountCreateAdmin creates an activated account for an operator through the same use case
+// as POST /api/v1/accounts. Accounts have no roles yet, so the account gets no extra rights.
+// It uses MySQL and the Mongo read model.
+func runAccountCreateAdmin(args []string) int {
+	fset := newFlagSet("account create-admin")
+	dto := &accountcommands.CreateAccountCmdDTO{}
+	fset.StringVar(&dto.Name, "name", "", "account name")
+	fset.StringVar(&dto.Email, "email", "", "account email")
+	passwordStdin := fset.Bool("password-stdin", false, "read the password from the first line of stdin instead of -password")
+	fset.StringVar(&dto.Password, "password", "", "account password; prefer -password-stdin, flags are visible to other users")
+	a, code := start(fset, args)
+	if a == nil {
+		return code
+	}
+	defer a.close()
+
+	if *passwordStdin {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			fmt.Fprintln(os.Stderr, "cannot read password from stdin:", err)
+			return 1
+		}
+		dto.Password = strings.TrimRight(line, "\r\n")
+	}
+
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		if err := common.SetupValidator(v); err != nil {
+			a.log.Error("Cannot set up validator", zap.Error(err))
+			return 1
+		}
+	}
+	if err := binding.Validator.ValidateStruct(dto); err != nil {
+		printAppError(common.AsAppError(err))
+		return 2
+	}
+
+	clients := storeClients{
+		mysql: configs.ConnectMysql(a.config),
+		mongo: configs.ConnectMongodb(a.ctx, a.config),
+	}
+	defer clients.mysql.Close()
+	defer clients.mongo.Disconnect(context.WithoutCancel(a.ctx))
+	a.checkSchemas(clients, migration.StoreMySQL, migration.StoreMongo)
+
+	commands := accountcommands.NewAccountCmdWithBuilder(builder.NewAccountBuilder(clients.mysql, clients.mongo, a.config.Mongo.Database))
+	resp, err := commands.CreateAccount.Handle(a.ctx, dto)
+	if err != nil {
+		var appErr *common.AppError
+		if errors.As(err, &appErr) {
+			printAppError(appErr)
+		} else {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return 1
+	}
+
+	fmt.Fprintf(os.Stdout, "created account %s (%s)\n", resp.Id, dto.Email)
+	return 0
+}
+
+// printAppError writes the localized message of err and, for validation errors, one line per
+// invalid field.
+func printAppError(err *common.AppError) {
+	err = err.Localize(i18n.DefaultLocale)
+	fmt.Fprintln(os.Stderr, err.Message)
+	if fields, ok := err.Details["fields"].([]common.FieldError); ok {
+		for _, f := range fields {
+			fmt.Fprintf(os.Stderr, "  -%s: %s\n", f.Field, f.Message)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"elastic-logger-app/configs"
+	"fmt"
+	"os"
+)
+
+// runConfigCheck loads and validates the configuration without connecting anything, so a
+// deployment can be checked before it rolls out.
+func runConfigCheck(args []string) int {
+	config, err := configs.Load(args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	fmt.Fprintf(os.Stdout, "configuration is valid (profile %s)\n", config.App.Profile)
+	if secrets := config.DefaultSecrets(); len(secrets) > 0 {
+		fmt.Fprintf(os.Stdout, "warning: development default secrets in use: %v\n", secrets)
+	}
+	return 0
+}
+
+// runConfigPrint writes the effective configuration with secrets redacted, or the list of
+// problems when it is invalid.
+func runConfigPrint(args []string) int {
+	config, err := configs.Load(args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := config.Print(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"elastic-logger-app/configs"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/olivere/elastic/v7"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// logTimeLayout is how zapcore.ISO8601TimeEncoder writes @timestamp.
+const logTimeLayout = "2006-01-02T15:04:05.000Z0700"
+
+// logsTailBatch is the most entries fetched per poll.
+const logsTailBatch = 500
+
+// runLogsTail prints the entries the log sink indexed into log.elastic_index, then polls for new
+// ones until SIGINT or SIGTERM. It uses Elasticsearch only.
+func runLogsTail(args []string) int {
+	fset := newFlagSet("logs tail")
+	since := fset.Duration("since", time.Minute, "start with entries newer than this")
+	interval := fset.Duration("interval", 2*time.Second, "poll interval")
+	level := fset.String("level", "", "only entries at this level or above (debug, info, warn, error)")
+	loggerName := fset.String("logger", "", "only entries of this logger, e.g. server or account")
+	asJSON := fset.Bool("json", false, "print the raw JSON documents")
+	a, code := start(fset, args)
+	if a == nil {
+		return code
+	}
+	defer a.close()
+
+	var filters []elastic.Query
+	if *level != "" {
+		levels, err := levelsFrom(*level)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		filters = append(filters, elastic.NewTermsQueryFromStrings("level", levels...))
+	}
+	if *loggerName != "" {
+		filters = append(filters, elastic.NewTermQuery("logger", *loggerName))
+	}
+
+	client := configs.ConnectElasticsearch(a.config)
+	defer client.Stop()
+
+	t := &logTail{
+		client:  client,
+		index:   a.config.Log.ElasticIndex,
+		filters: filters,
+		out:     os.Stdout,
+		json:    *asJSON,
+		after:   time.Now().Add(-*since).UTC(),
+	}
+	if err := t.run(a.ctx, *interval); err != nil {
+		a.log.Error("Cannot tail logs", zap.String("index", t.index), zap.Error(err))
+		return 1
+	}
+	return 0
+}
+
+// levelsFrom returns the zap level names at min or above, as stored in the level field.
+func levelsFrom(min string) ([]string, error) {
+	minLevel, err := zapcore.ParseLevel(min)
+	if err != nil {
+		return nil, err
+	}
+
+	var levels []string
+	for l := minLevel; l <= zapcore.FatalLevel; l++ {
+		levels = append(levels, l.String())
+	}
+	return levels, nil
+}
+
+// logTail polls the log index for entries newer than the last one printed. Entries sharing the
+// last timestamp are remembered by id, so none is printed twice.
+type logTail struct {
+	client  *elastic.Client
+	index   string
+	filters []elastic.Query
+	out     io.Writer
+	json    bool
+
+	after   time.Time
+	seenIDs []string
+}
+
+func (t *logTail) run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := t.poll(ctx); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll prints every entry since the last poll, fetching in batches while full ones come back.
+func (t *logTail) poll(ctx context.Context) error {
+	for {
+		res, err := t.client.Search(t.index).
+			Query(t.pollQuery()).
+			Sort("@timestamp", true).
+			Size(logsTailBatch).
+			Do(ctx)
+		if err != nil {
+			return err
+		}
+
+		printed := 0
+		for _, hit := range res.Hits.Hits {
+			if t.print(hit) {
+				printed++
+			}
+		}
+		if len(res.Hits.Hits) < logsTailBatch || printed == 0 {
+			return nil
+		}
+	}
+}
+
+func (t *logTail) pollQuery() elastic.Query {
+	return elastic.NewBoolQuery().
+		Filter(t.filters...).
+		Filter(elastic.NewRangeQuery("@timestamp").Gte(t.after.Format(time.RFC3339Nano)))
+}
+
+// print writes hit unless it was already printed, and moves the tail position past it.
+func (t *logTail) print(hit *elastic.SearchHit) bool {
+	if slices.Contains(t.seenIDs, hit.Id) {
+		return false
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(hit.Source, &doc); err != nil {
+		return false
+	}
+
+	if at, err := time.Parse(logTimeLayout, fmt.Sprint(doc["@timestamp"])); err == nil {
+		if at.After(t.after) {
+			t.after, t.seenIDs = at, nil
+		}
+		t.seenIDs = append(t.seenIDs, hit.Id)
+	}
+
+	if t.json {
+		fmt.Fprintln(t.out, string(hit.Source))
+	} else {
+		fmt.Fprintln(t.out, formatLogEntry(doc))
+	}
+	return true
+}
+
+// formatLogEntry renders an entry like the console logger: time, level, logger, message, then
+// the other fields as key=value in name order.
+func formatLogEntry(doc map[string]any) string {
+	var b strings.Builder
+	for _, key := range []string{"@timestamp", "level", "logger", "message"} {
+		if v, ok := doc[key]; ok {
+			fmt.Fprintf(&b, "%v\t", v)
+		}
+	}
+
+	keys := make([]string, 0, len(doc))
+	for k := range doc {
+		switch k {
+		case "@timestamp", "level", "logger", "message", "caller", "stacktrace":
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v, _ := json.Marshal(doc[k])
+		fmt.Fprintf(&b, "%s=%s ", k, v)
+	}
+	return strings.TrimRight(b.String(), "\t ")
+}
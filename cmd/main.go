@@ -2,44 +2,194 @@ package main
 
 import (
 	"context"
-	server "elastic-logger-app/api"
+	"elastic-logger-app/builder"
+	"elastic-logger-app/common/logger"
+	"elastic-logger-app/common/metrics"
+	"elastic-logger-app/common/module"
+	"elastic-logger-app/common/tracing"
 	"elastic-logger-app/configs"
-	"log"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/olivere/elastic/v7"
+	"go.uber.org/zap"
 )
 
+// command is one subcommand of the binary. Names of nested commands have several words,
+// e.g. "projection rebuild". run gets the arguments after the name and returns the exit code.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+// commands lists every subcommand in the order usage shows them. Each one connects only to the
+// stores it uses.
+var commands = []command{
+	{"serve", "serve the HTTP API (MySQL, Mongo, Elasticsearch)", runServe},
+	{"worker", "run the message consumers of the modules (RabbitMQ and what they need)", runWorker},
+	{"relay", "publish the outbox messages to RabbitMQ (MySQL, RabbitMQ)", runRelay},
+	{"migrate", "migrate [mysql|mongo|elastic] up|down|status|redo", runMigrate},
+	{"projection rebuild", "rebuild the Mongo read models from MySQL", runProjectionRebuild},
+	{"logs tail", "stream new log entries from Elasticsearch", runLogsTail},
+	{"account create-admin", "create an activated account for an operator (MySQL, Mongo)", runAccountCreateAdmin},
+	{"config check", "validate the configuration and report every problem", runConfigCheck},
+	{"config print", "print the effective configuration with secrets redacted", runConfigPrint},
+}
+
 func main() {
-	// This context is used for all initialization steps (DB connection, services...)
-	// and will be cancelled automatically when main() exits.
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	// Load environment configuration
-	config := configs.LoadConfig()
-
-	// MySQL connection will live until the application exits.
-	mysqlClient := configs.ConnectMysql(config)
-
-	// ConnectMongodb should accept context so timeout/cancel is controlled by main.
-	mongodbClient := configs.ConnectMongodb(ctx, config)
-	// Defer MongoDB disconnection for clean shutdown.
-	defer func() {
-		if err := mongodbClient.Disconnect(ctx); err != nil {
-			log.Fatal(err)
+	os.Exit(run(os.Args[1:]))
+}
+
+// run dispatches args to a command. Without a command, or when the first argument is a flag,
+// the binary serves HTTP as it always did.
+func run(args []string) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return runServe(args)
+	}
+	if args[0] == "help" {
+		usage(os.Stdout)
+		return 0
+	}
+
+	cmd, rest, ok := findCommand(args)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", strings.Join(args[:min(len(args), 2)], " "))
+		usage(os.Stderr)
+		return 2
+	}
+	return cmd.run(rest)
+}
+
+// findCommand returns the command whose name words start args, and the arguments after them.
+func findCommand(args []string) (command, []string, bool) {
+	for _, cmd := range commands {
+		words := strings.Fields(cmd.name)
+		if len(args) >= len(words) && slices.Equal(args[:len(words)], words) {
+			return cmd, args[len(words):], true
 		}
-	}()
+	}
+	return command{}, nil, false
+}
 
-	// Connect to Elasticsearch
-	elasticSearchClient := configs.ConnectElasticsearch(config)
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "usage: %s <command> [flags]\n\ncommands:\n", filepath.Base(os.Args[0]))
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-22s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w, "\nEvery command accepts the configuration flags; see <command> -h.")
+}
 
-	// Connect to RabbitMQ
-	rabbitConn := configs.ConnectRabbitMQ(config)
-	defer rabbitConn.Close()
+// app is what every command starts from: the configuration, the logger and a context that is
+// cancelled on SIGINT or SIGTERM.
+type app struct {
+	ctx     context.Context
+	config  *configs.Config
+	log     *zap.Logger
+	modules *module.Registry
+	stop    context.CancelFunc
+}
 
-	// Initialize HTTP server
-	server := server.InitServer(":"+config.APP_PORT, mysqlClient, mongodbClient, elasticSearchClient)
+// appModules registers the bounded contexts of the binary, in the order they start.
+func appModules() (*module.Registry, error) {
+	return module.NewRegistry(
+		builder.NewAccountModule(),
+	)
+}
 
-	// Run HTTP server (blocking call)
-	if err_run_server := server.RunApp(); err_run_server != nil {
-		log.Fatal("Cannot run app: ", err_run_server)
+// start adds the configuration flags to fset, parses args, loads the configuration and
+// installs the logger. Commands register their own flags on fset before calling it. When it
+// returns a nil app, the returned code is the exit code.
+func start(fset *flag.FlagSet, args []string) (*app, int) {
+	opts, flags := configs.RegisterFlags(fset)
+	if err := fset.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, 0
+		}
+		return nil, 2
 	}
+
+	config, err := configs.LoadWith(*opts, flags)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return nil, 1
+	}
+
+	appLogger, err := logger.New(logger.Config{Level: config.Log.Level, Format: config.Log.Format, RedactKeys: config.Log.RedactKeys})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Cannot create logger:", err)
+		return nil, 1
+	}
+	zap.ReplaceGlobals(appLogger)
+
+	if secrets := config.DefaultSecrets(); len(secrets) > 0 {
+		appLogger.Warn("Using development default secrets", zap.String("profile", config.App.Profile), zap.Strings("keys", secrets))
+	}
+
+	modules, err := appModules()
+	if err != nil {
+		appLogger.Error("Cannot register modules", zap.Error(err))
+		return nil, 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	return &app{ctx: ctx, config: config, log: appLogger, modules: modules, stop: stop}, 0
+}
+
+// close stops listening for signals and flushes the logger.
+func (a *app) close() {
+	a.stop()
+	_ = a.log.Sync()
+}
+
+// newFlagSet returns the flag set of a command, reporting parse errors itself.
+func newFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet(name, flag.ContinueOnError)
+}
+
+// initTracing installs the tracer provider; clients created afterwards pick it up. The
+// returned func flushes pending spans.
+func (a *app) initTracing() func() {
+	shutdownTracing, err := tracing.Init(a.ctx, tracing.Config{
+		ServiceName:  tracing.InstrumentationName,
+		Exporter:     a.config.Tracing.Exporter,
+		OTLPEndpoint: a.config.Tracing.OTLPEndpoint,
+		SampleRatio:  a.config.Tracing.SampleRatio,
+	})
+	if err != nil {
+		a.log.Fatal("Cannot initialize tracing", zap.Error(err))
+	}
+	return func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			a.log.Error("Cannot flush traces", zap.Error(err))
+		}
+	}
+}
+
+// shipLogs sends log entries to Elasticsearch in addition to stdout when log.elastic_enabled
+// is set. The returned func flushes the sink.
+func (a *app) shipLogs(client *elastic.Client) func() {
+	if !a.config.Log.ElasticEnabled {
+		return func() {}
+	}
+
+	sink, err := logger.NewElasticsearchSink(a.ctx, client, a.config.Log.ElasticIndex)
+	if err != nil {
+		a.log.Fatal("Cannot start Elasticsearch log sink", zap.Error(err))
+	}
+	if err := metrics.RegisterBulkProcessor("logger", sink); err != nil {
+		a.log.Warn("Cannot register log sink metrics", zap.Error(err))
+	}
+
+	a.log = logger.Tee(a.log, logger.RedactCore(sink.Core(a.log.Level()), a.config.Log.RedactKeys))
+	zap.ReplaceGlobals(a.log)
+	return func() { _ = sink.Close() }
 }
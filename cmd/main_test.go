@@ -0,0 +1,62 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFindCommand(t *testing.T) {
+	cases := []struct {
+		args []string
+		name string
+		rest []string
+	}{
+		{[]string{"serve", "-http.port", "9000"}, "serve", []string{"-http.port", "9000"}},
+		{[]string{"projection", "rebuild", "-batch", "10"}, "projection rebuild", []string{"-batch", "10"}},
+		{[]string{"config", "check"}, "config check", []string{}},
+		{[]string{"relay", "-batch", "50"}, "relay", []string{"-batch", "50"}},
+		{[]string{"migrate", "mongo", "up"}, "migrate", []string{"mongo", "up"}},
+	}
+	for _, tc := range cases {
+		cmd, rest, ok := findCommand(tc.args)
+		if !ok || cmd.name != tc.name || !slices.Equal(rest, tc.rest) {
+			t.Errorf("findCommand(%v) = %q %v %v", tc.args, cmd.name, rest, ok)
+		}
+	}
+
+	for _, args := range [][]string{{"projection"}, {"config", "edit"}, {"serve-now"}} {
+		if cmd, _, ok := findCommand(args); ok {
+			t.Errorf("findCommand(%v) matched %q", args, cmd.name)
+		}
+	}
+}
+
+func TestLevelsFrom(t *testing.T) {
+	levels, err := levelsFrom("warn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(levels, ","); got != "warn,error,dpanic,panic,fatal" {
+		t.Fatalf("levels = %s", got)
+	}
+	if _, err := levelsFrom("loud"); err == nil {
+		t.Fatal("want error for unknown level")
+	}
+}
+
+func TestFormatLogEntry(t *testing.T) {
+	doc := map[string]any{
+		"@timestamp": "2025-01-02T03:04:05.000Z",
+		"level":      "info",
+		"logger":     "server",
+		"message":    "request completed",
+		"caller":     "logger/gin.go:40",
+		"status":     200.0,
+		"path":       "/ping",
+	}
+	want := "2025-01-02T03:04:05.000Z\tinfo\tserver\trequest completed\tpath=\"/ping\" status=200"
+	if got := formatLogEntry(doc); got != want {
+		t.Fatalf("got  %q\nwant %q", got, want)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"elastic-logger-app/configs"
+	"elastic-logger-app/migration"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/olivere/elastic/v7"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+// storeClients are the connections a command opened; the others stay nil.
+type storeClients struct {
+	mysql   *sql.DB
+	mongo   *mongo.Client
+	elastic *elastic.Client
+}
+
+// runMigrate implements "migrate [mysql|mongo|elastic] up|down|status|redo [flags]". Without a
+// store, up and status go through every store in order; down and redo need one.
+func runMigrate(args []string) int {
+	stores := migration.Stores
+	if len(args) > 0 && migration.IsStore(args[0]) {
+		stores, args = []string{args[0]}, args[1:]
+	}
+	if len(args) == 0 || !migration.IsCommand(args[0]) {
+		fmt.Fprintf(os.Stderr, "usage: migrate [%s] %s|%s|%s|%s [flags]\n",
+			strings.Join(migration.Stores, "|"), migration.CommandUp, migration.CommandDown, migration.CommandStatus, migration.CommandRedo)
+		return 2
+	}
+	command := args[0]
+	if len(stores) > 1 && (command == migration.CommandDown || command == migration.CommandRedo) {
+		fmt.Fprintf(os.Stderr, "migrate %s needs a store: %s\n", command, strings.Join(migration.Stores, ", "))
+		return 2
+	}
+
+	a, code := start(newFlagSet("migrate "+command), args[1:])
+	if a == nil {
+		return code
+	}
+	defer a.close()
+
+	for _, store := range stores {
+		if len(stores) > 1 {
+			fmt.Fprintf(os.Stdout, "== %s ==\n", store)
+		}
+		if err := a.migrateStore(store, command); err != nil {
+			a.log.Error("Migration failed", zap.String("store", store), zap.String("command", command), zap.Error(err))
+			return 1
+		}
+	}
+	return 0
+}
+
+// migrateStore connects to store only and runs command on its migrations.
+func (a *app) migrateStore(store, command string) error {
+	var clients storeClients
+	switch store {
+	case migration.StoreMySQL:
+		clients.mysql = configs.ConnectMysql(a.config)
+		defer clients.mysql.Close()
+	case migration.StoreMongo:
+		clients.mongo = configs.ConnectMongodb(a.ctx, a.config)
+		defer clients.mongo.Disconnect(context.WithoutCancel(a.ctx))
+	case migration.StoreElastic:
+		clients.elastic = configs.ConnectElasticsearch(a.config)
+		defer clients.elastic.Stop()
+	}
+
+	target, err := a.migrationTarget(store, clients)
+	if err != nil {
+		return err
+	}
+	return target.Run(a.ctx, command, os.Stdout)
+}
+
+// migrationTarget returns the migrations of store over its client in clients. Mongo steps come
+// from the registered modules.
+func (a *app) migrationTarget(store string, clients storeClients) (migration.Target, error) {
+	config := a.config
+	lockTimeout := config.Migrations.LockTimeout
+	switch store {
+	case migration.StoreMySQL:
+		provider, err := migration.NewMySQLProvider(clients.mysql, lockTimeout)
+		if err != nil {
+			return nil, err
+		}
+		return migration.MySQL(provider), nil
+	case migration.StoreMongo:
+		db := clients.mongo.Database(config.Mongo.Database)
+		return migration.NewMongoRunner(db, lockTimeout, a.modules.MongoSteps(db))
+	case migration.StoreElastic:
+		return migration.NewElasticRunner(clients.elastic, config.Log.ElasticIndex, lockTimeout)
+	default:
+		return nil, fmt.Errorf("unknown store %q", store)
+	}
+}
+
+// checkSchemas compares each of stores with its migrations before the command starts working.
+// Pending migrations stop the process, or only log a warning when migrations.schema_check is
+// warn; off skips the check.
+func (a *app) checkSchemas(clients storeClients, stores ...string) {
+	mode := a.config.Migrations.SchemaCheck
+	if mode == configs.SchemaCheckOff {
+		return
+	}
+
+	for _, store := range stores {
+		target, err := a.migrationTarget(store, clients)
+		if err != nil {
+			a.log.Fatal("Cannot load migrations", zap.String("store", store), zap.Error(err))
+		}
+
+		current, latest, err := target.Check(a.ctx)
+		fields := []zap.Field{zap.String("store", store), zap.Int64("current_version", current), zap.Int64("latest_version", latest)}
+		switch {
+		case errors.Is(err, migration.ErrSchemaBehind) && mode == configs.SchemaCheckWarn:
+			a.log.Warn("Schema is behind, run \"migrate up\"", fields...)
+		case errors.Is(err, migration.ErrSchemaBehind):
+			a.log.Fatal("Schema is behind, run \"migrate up\"", fields...)
+		case err != nil:
+			a.log.Fatal("Cannot check schema version", zap.String("store", store), zap.Error(err))
+		default:
+			a.log.Info("Schema is up to date", fields...)
+		}
+	}
+}
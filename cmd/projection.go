@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"elastic-logger-app/builder"
+	"elastic-logger-app/configs"
+	"elastic-logger-app/migration"
+	accountprojections "elastic-logger-app/modules/account/usecase/projections"
+	"fmt"
+	"os"
+
+	"go.uber.org/zap"
+)
+
+// runProjectionRebuild rewrites the Mongo read models from MySQL, e.g. after a projection
+// bug was fixed or the read database was restored. It uses MySQL and Mongo.
+func runProjectionRebuild(args []string) int {
+	fset := newFlagSet("projection rebuild")
+	batchSize := fset.Int("batch", accountprojections.DefaultRebuildBatchSize, "accounts read and written per round-trip")
+	a, code := start(fset, args)
+	if a == nil {
+		return code
+	}
+	defer a.close()
+
+	clients := storeClients{
+		mysql: configs.ConnectMysql(a.config),
+		mongo: configs.ConnectMongodb(a.ctx, a.config),
+	}
+	defer clients.mysql.Close()
+	defer clients.mongo.Disconnect(context.WithoutCancel(a.ctx))
+	a.checkSchemas(clients, migration.StoreMySQL, migration.StoreMongo)
+
+	accountBuilder := builder.NewAccountBuilder(clients.mysql, clients.mongo, a.config.Mongo.Database)
+	projections := accountprojections.NewAccountProjectionWithBuilder(accountBuilder)
+
+	result, err := projections.Rebuild.Handle(a.ctx, *batchSize)
+	if err != nil {
+		a.log.Error("Cannot rebuild account projection", zap.Error(err))
+		return 1
+	}
+	fmt.Fprintf(os.Stdout, "accounts: %d projected, %d stale removed\n", result.Projected, result.Removed)
+	return 0
+}
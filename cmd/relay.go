@@ -0,0 +1,55 @@
+package main
+
+import (
+	"elastic-logger-app/common/outbox"
+	"elastic-logger-app/common/rabbitmq"
+	"elastic-logger-app/configs"
+	"elastic-logger-app/migration"
+
+	"go.uber.org/zap"
+)
+
+// runRelay publishes the messages commands wrote to the MySQL outbox to the RabbitMQ exchange
+// until SIGINT or SIGTERM. It connects MySQL and RabbitMQ, plus Elasticsearch when logs are
+// shipped there. Several relays may run side by side; each message goes out through one.
+func runRelay(args []string) int {
+	fset := newFlagSet("relay")
+	defaults := outbox.DefaultRelayConfig()
+	batchSize := fset.Int("batch", defaults.BatchSize, "outbox messages published per transaction")
+	interval := fset.Duration("interval", defaults.PollInterval, "wait between polls of an empty outbox")
+	retention := fset.Duration("retention", defaults.Retention, "how long sent messages are kept; 0 keeps them")
+	a, code := start(fset, args)
+	if a == nil {
+		return code
+	}
+	defer a.close()
+	defer a.initTracing()()
+
+	db := configs.ConnectMysql(a.config)
+	defer db.Close()
+	if a.config.Log.ElasticEnabled {
+		defer a.shipLogs(configs.ConnectElasticsearch(a.config))()
+	}
+	a.checkSchemas(storeClients{mysql: db}, migration.StoreMySQL)
+
+	conn := configs.ConnectRabbitMQ(a.config)
+	defer conn.Close()
+	publisher, err := rabbitmq.NewPublisher(rabbitmq.NewConnection(conn), a.config.RabbitMQ.Exchange)
+	if err != nil {
+		a.log.Error("Cannot open RabbitMQ publisher", zap.Error(err))
+		return 1
+	}
+	defer publisher.Close()
+
+	relay := outbox.NewRelay(outbox.NewMySQLStore(db), publisher, outbox.RelayConfig{
+		BatchSize:    *batchSize,
+		PollInterval: *interval,
+		Retention:    *retention,
+	})
+	a.log.Info("Relaying outbox messages", zap.String("exchange", a.config.RabbitMQ.Exchange))
+	if err := relay.Run(a.ctx); err != nil {
+		a.log.Error("Relay stopped", zap.Error(err))
+		return 1
+	}
+	return 0
+}
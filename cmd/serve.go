@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	server "elastic-logger-app/api"
+	"elastic-logger-app/configs"
+	"elastic-logger-app/migration"
+
+	"go.uber.org/zap"
+)
+
+// runServe serves the HTTP API until SIGINT or SIGTERM. It uses MySQL and Mongo, and
+// Elasticsearch for the log sink and the schema check of its indices.
+func runServe(args []string) int {
+	a, code := start(newFlagSet("serve"), args)
+	if a == nil {
+		return code
+	}
+	defer a.close()
+
+	// Tracing must be installed before clients are created so their instrumentation picks it up.
+	defer a.initTracing()()
+
+	// MySQL connection will live until the application exits.
+	mysqlClient := configs.ConnectMysql(a.config)
+	defer mysqlClient.Close()
+
+	// ConnectMongodb should accept context so timeout/cancel is controlled by main.
+	mongodbClient := configs.ConnectMongodb(a.ctx, a.config)
+	// Defer MongoDB disconnection for clean shutdown.
+	defer func() {
+		if err := mongodbClient.Disconnect(context.WithoutCancel(a.ctx)); err != nil {
+			a.log.Error("Cannot disconnect MongoDB", zap.Error(err))
+		}
+	}()
+
+	// Connect to Elasticsearch
+	elasticSearchClient := configs.ConnectElasticsearch(a.config)
+
+	// Refuse to serve against stores whose schema is older than this build expects.
+	a.checkSchemas(storeClients{mysql: mysqlClient, mongo: mongodbClient, elastic: elasticSearchClient},
+		migration.StoreMySQL, migration.StoreMongo, migration.StoreElastic)
+
+	// Ship logs to Elasticsearch in addition to stdout once the cluster is reachable.
+	defer a.shipLogs(elasticSearchClient)()
+
+	// Initialize HTTP server
+	server := server.InitServer(a.config, mysqlClient, mongodbClient, elasticSearchClient, a.modules)
+
+	// Run HTTP server until SIGINT or SIGTERM
+	if err_run_server := server.RunApp(a.ctx); err_run_server != nil {
+		a.log.Error("Cannot run app", zap.Error(err_run_server))
+		return 1
+	}
+	return 0
+}
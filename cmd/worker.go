@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"elastic-logger-app/common/module"
+	"elastic-logger-app/common/rabbitmq"
+	"elastic-logger-app/configs"
+	"elastic-logger-app/migration"
+	"slices"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// runWorker runs the RabbitMQ consumers of the registered modules until SIGINT or SIGTERM. It
+// connects RabbitMQ plus what the modules need, and Elasticsearch when logs are shipped there;
+// it opens no HTTP port.
+func runWorker(args []string) int {
+	a, code := start(newFlagSet("worker"), args)
+	if a == nil {
+		return code
+	}
+	defer a.close()
+
+	if !a.modules.HasConsumers() {
+		a.log.Error("No module registers a consumer, nothing to run")
+		return 1
+	}
+
+	defer a.initTracing()()
+
+	needs := a.modules.Needs()
+	deps := module.Deps{Config: a.config}
+	var checked []string
+	if slices.Contains(needs, module.NeedMySQL) {
+		deps.MySQL = configs.ConnectMysql(a.config)
+		defer deps.MySQL.Close()
+		checked = append(checked, migration.StoreMySQL)
+	}
+	if slices.Contains(needs, module.NeedMongo) {
+		deps.Mongo = configs.ConnectMongodb(a.ctx, a.config)
+		defer func() {
+			if err := deps.Mongo.Disconnect(context.WithoutCancel(a.ctx)); err != nil {
+				a.log.Error("Cannot disconnect MongoDB", zap.Error(err))
+			}
+		}()
+		checked = append(checked, migration.StoreMongo)
+	}
+	if slices.Contains(needs, module.NeedElastic) || a.config.Log.ElasticEnabled {
+		deps.Elastic = configs.ConnectElasticsearch(a.config)
+		defer a.shipLogs(deps.Elastic)()
+	}
+	a.checkSchemas(storeClients{mysql: deps.MySQL, mongo: deps.Mongo}, checked...)
+
+	conn := configs.ConnectRabbitMQ(a.config)
+	defer conn.Close()
+	deps.RabbitMQ = rabbitmq.NewConnection(conn)
+
+	if err := a.modules.Start(deps); err != nil {
+		a.log.Error("Cannot start modules", zap.Error(err))
+		return 1
+	}
+
+	consumers := a.modules.Consumers()
+	if len(consumers) == 0 {
+		a.log.Error("The consumer modules registered no consumer, nothing to run")
+		return 1
+	}
+
+	if err := runConsumers(a.ctx, consumers); err != nil {
+		a.log.Error("Consumer stopped", zap.Error(err))
+		return 1
+	}
+	return 0
+}
+
+// runConsumers runs every consumer until ctx is cancelled. When one fails, the others are
+// stopped and its error is returned.
+func runConsumers(ctx context.Context, consumers []*rabbitmq.Consumer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	for _, c := range consumers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.Run(ctx); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	return firstErr
+}
@@ -0,0 +1,123 @@
+package common
+
+import (
+	"elastic-logger-app/common/i18n"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// DefaultMaxBodyBytes is the largest JSON body BindJSON accepts unless changed with SetMaxBodyBytes.
+const DefaultMaxBodyBytes int64 = 1 << 20
+
+var maxBodyBytes atomic.Int64
+
+func init() {
+	maxBodyBytes.Store(DefaultMaxBodyBytes)
+}
+
+// SetMaxBodyBytes changes the body size limit applied by BindJSON.
+func SetMaxBodyBytes(n int64) {
+	if n > 0 {
+		maxBodyBytes.Store(n)
+	}
+}
+
+// setupValidators remembers the validators SetupValidator already prepared; translations
+// cannot be registered twice on the same validator.
+var (
+	setupMu         sync.Mutex
+	setupValidators = map[*validator.Validate]bool{}
+)
+
+// SetupValidator prepares the validator used by gin binding: field errors are reported with
+// the DTO's JSON names and their messages are translated into every supported locale. Calling
+// it again with the same validator does nothing.
+func SetupValidator(v *validator.Validate) error {
+	setupMu.Lock()
+	defer setupMu.Unlock()
+	if setupValidators[v] {
+		return nil
+	}
+
+	v.RegisterTagNameFunc(jsonFieldName)
+	if err := i18n.RegisterValidatorTranslations(v); err != nil {
+		return err
+	}
+	setupValidators[v] = true
+	return nil
+}
+
+// jsonFieldName names a struct field after its json tag, so clients see the keys they sent.
+func jsonFieldName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return f.Name
+	}
+	return name
+}
+
+// BindJSON decodes the JSON body of c into dst and validates it with the `binding` tags on dst.
+// Unknown fields, trailing data and bodies larger than the configured limit are rejected.
+// Every failure is returned as an *AppError ready for ResponseError; validation failures carry
+// one FieldError per invalid field under Details["fields"].
+func BindJSON(c *gin.Context, dst any) error {
+	limit := maxBodyBytes.Load()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+
+	dec := json.NewDecoder(c.Request.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		if field, ok := unknownField(err); ok {
+			params := map[string]any{"field": field}
+			return NewErrorFromCode(CodeValidationFailed, nil).
+				WithReason("request body has an unknown field").WithInner(err).
+				WithDetail("fields", []FieldError{{
+					Field:   field,
+					Rule:    "unknown",
+					Message: i18n.Render(unknownFieldMessage, params),
+					key:     unknownFieldKey,
+					params:  params,
+				}})
+		}
+		return AsAppError(err)
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return NewErrorFromCode(CodeMalformedBody, nil).WithReason("request body has data after the JSON value")
+	}
+
+	if err := binding.Validator.ValidateStruct(dst); err != nil {
+		return AsAppError(err)
+	}
+	return nil
+}
+
+// unknownFieldKey is the bundle key of the unknown field message; unknownFieldMessage is its
+// default-locale fallback.
+const (
+	unknownFieldKey     = "validation.unknown_field"
+	unknownFieldMessage = "{field} is not a recognized field"
+)
+
+// unknownField extracts the field name from the error encoding/json returns for
+// DisallowUnknownFields, which has no dedicated type.
+func unknownField(err error) (string, bool) {
+	name, ok := strings.CutPrefix(err.Error(), "json: unknown field ")
+	if !ok {
+		return "", false
+	}
+	return strings.Trim(name, `"`), true
+}
@@ -0,0 +1,88 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+type bindTestDTO struct {
+	Email string `json:"email" binding:"required,email"`
+	Age   int    `json:"age" binding:"gte=18"`
+}
+
+func bindRequest(t *testing.T, body string) error {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+
+	var dto bindTestDTO
+	return BindJSON(c, &dto)
+}
+
+func TestBindJSON(t *testing.T) {
+	if err := SetupValidator(binding.Validator.Engine().(*validator.Validate)); err != nil {
+		t.Fatalf("SetupValidator: %v", err)
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		if err := bindRequest(t, `{"email":"a@b.co","age":20}`); err != nil {
+			t.Fatalf("BindJSON = %v, want nil", err)
+		}
+	})
+
+	t.Run("field errors use json names", func(t *testing.T) {
+		apperr := AsAppError(bindRequest(t, `{"email":"nope","age":3}`))
+		if apperr.ErrorCode != CodeValidationFailed {
+			t.Fatalf("code = %s, want %s", apperr.ErrorCode, CodeValidationFailed)
+		}
+		fields := apperr.Details["fields"].([]FieldError)
+		if len(fields) != 2 || fields[0].Field != "email" || fields[0].Rule != "email" ||
+			fields[1].Field != "age" || fields[1].Rule != "gte" || fields[1].Param != "18" {
+			t.Fatalf("fields = %+v", fields)
+		}
+
+		vi := apperr.Localize("vi").Details["fields"].([]FieldError)
+		if vi[1].Message == fields[1].Message || vi[1].Message == "" {
+			t.Fatalf("vi message was not translated: %q", vi[1].Message)
+		}
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		apperr := AsAppError(bindRequest(t, `{"email":"a@b.co","age":20,"admin":true}`))
+		fields, _ := apperr.Details["fields"].([]FieldError)
+		if apperr.ErrorCode != CodeValidationFailed || len(fields) != 1 || fields[0].Field != "admin" {
+			t.Fatalf("got %s %+v, want unknown field admin", apperr.ErrorCode, fields)
+		}
+		if fields[0].Message != "admin is not a recognized field" {
+			t.Fatalf("message = %q", fields[0].Message)
+		}
+
+		vi := apperr.Localize("vi").Details["fields"].([]FieldError)
+		if vi[0].Message != "admin không phải là trường hợp lệ" {
+			t.Fatalf("vi message = %q", vi[0].Message)
+		}
+	})
+
+	t.Run("trailing data", func(t *testing.T) {
+		if got := AsAppError(bindRequest(t, `{"email":"a@b.co","age":20}{}`)).ErrorCode; got != CodeMalformedBody {
+			t.Fatalf("code = %s, want %s", got, CodeMalformedBody)
+		}
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		SetMaxBodyBytes(16)
+		defer SetMaxBodyBytes(DefaultMaxBodyBytes)
+
+		if got := AsAppError(bindRequest(t, `{"email":"a@b.co","age":20}`)).Code; got != http.StatusRequestEntityTooLarge {
+			t.Fatalf("status = %d, want %d", got, http.StatusRequestEntityTooLarge)
+		}
+	})
+}
@@ -0,0 +1,143 @@
+package common
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorCode is a stable, machine-readable identifier clients can branch on.
+// Codes never change once published; messages may.
+type ErrorCode string
+
+// CatalogEntry documents an error code with its default HTTP status and message template.
+// Templates use {name} placeholders filled from the params passed to NewErrorFromCode.
+// Message is the English text; other languages come from the i18n bundles keyed by Code.
+type CatalogEntry struct {
+	Code        ErrorCode `json:"code"`
+	Status      int       `json:"status"`
+	Message     string    `json:"message"`
+	Description string    `json:"description,omitempty"`
+}
+
+// Generic codes used by the error helpers and request handling.
+const (
+	CodeBadRequest               ErrorCode = "BAD_REQUEST"
+	CodeValidationFailed         ErrorCode = "VALIDATION_FAILED"
+	CodeMalformedBody            ErrorCode = "MALFORMED_BODY"
+	CodeBodyTooLarge             ErrorCode = "BODY_TOO_LARGE"
+	CodeUnauthorized             ErrorCode = "UNAUTHORIZED"
+	CodeForbidden                ErrorCode = "FORBIDDEN"
+	CodeNotFound                 ErrorCode = "NOT_FOUND"
+	CodeConflict                 ErrorCode = "CONFLICT"
+	CodeUnprocessable            ErrorCode = "UNPROCESSABLE"
+	CodeInternal                 ErrorCode = "INTERNAL_ERROR"
+	CodeAuthInvalidCredentials   ErrorCode = "AUTH_INVALID_CREDENTIALS"
+	CodeIdempotencyKeyReused     ErrorCode = "IDEMPOTENCY_KEY_REUSED"
+	CodeIdempotencyKeyInProgress ErrorCode = "IDEMPOTENCY_KEY_IN_PROGRESS"
+)
+
+var (
+	catalogMu sync.RWMutex
+	catalog   = map[ErrorCode]CatalogEntry{}
+)
+
+func init() {
+	RegisterErrorCodes(
+		CatalogEntry{CodeBadRequest, http.StatusBadRequest, "The request is invalid.", "Generic client error."},
+		CatalogEntry{CodeValidationFailed, http.StatusBadRequest, "Request validation failed.", "One or more fields are invalid; see the field errors."},
+		CatalogEntry{CodeMalformedBody, http.StatusBadRequest, "The request body is malformed.", "The body is not valid JSON or does not match the expected shape."},
+		CatalogEntry{CodeBodyTooLarge, http.StatusRequestEntityTooLarge, "The request body is too large.", "The body exceeds the maximum accepted size."},
+		CatalogEntry{CodeUnauthorized, http.StatusUnauthorized, "Authentication is required.", "The request has no valid credentials."},
+		CatalogEntry{CodeForbidden, http.StatusForbidden, "You are not allowed to access this resource.", "The credentials do not grant access."},
+		CatalogEntry{CodeNotFound, http.StatusNotFound, "The resource was not found.", "Generic not-found error."},
+		CatalogEntry{CodeConflict, http.StatusConflict, "The request conflicts with the current state.", "Generic conflict error."},
+		CatalogEntry{CodeUnprocessable, http.StatusUnprocessableEntity, "The request cannot be processed.", "Generic semantic error."},
+		CatalogEntry{CodeInternal, http.StatusInternalServerError, "An unexpected error occurred.", "The server failed to handle the request."},
+		CatalogEntry{CodeAuthInvalidCredentials, http.StatusUnauthorized, "The email or password is incorrect.", "Login failed because the credentials do not match an account."},
+		CatalogEntry{CodeIdempotencyKeyReused, http.StatusUnprocessableEntity, "Idempotency key {key} was already used with a different request.", "The Idempotency-Key header was reused with another method, path or body."},
+		CatalogEntry{CodeIdempotencyKeyInProgress, http.StatusConflict, "A request with idempotency key {key} is still in progress.", "The first request with this Idempotency-Key has not finished yet."},
+	)
+}
+
+// RegisterErrorCodes adds entries to the catalog. Registering the same code twice panics,
+// since it would make the code ambiguous for clients.
+func RegisterErrorCodes(entries ...CatalogEntry) {
+	catalogMu.Lock()
+	defer catalogMu.Unlock()
+
+	for _, e := range entries {
+		if _, exists := catalog[e.Code]; exists {
+			panic(fmt.Sprintf("common: error code %s registered twice", e.Code))
+		}
+		catalog[e.Code] = e
+	}
+}
+
+// LookupErrorCode returns the catalog entry for code.
+func LookupErrorCode(code ErrorCode) (CatalogEntry, bool) {
+	catalogMu.RLock()
+	defer catalogMu.RUnlock()
+
+	e, ok := catalog[code]
+	return e, ok
+}
+
+// ErrorCatalog returns every registered entry sorted by code.
+func ErrorCatalog() []CatalogEntry {
+	catalogMu.RLock()
+	defer catalogMu.RUnlock()
+
+	entries := make([]CatalogEntry, 0, len(catalog))
+	for _, e := range catalog {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Code < entries[j].Code })
+	return entries
+}
+
+// codeForStatus picks the generic code for errors built without an explicit code.
+func codeForStatus(status int) ErrorCode {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusUnprocessableEntity:
+		return CodeUnprocessable
+	case http.StatusRequestEntityTooLarge:
+		return CodeBodyTooLarge
+	}
+	if status >= http.StatusInternalServerError {
+		return CodeInternal
+	}
+	return CodeBadRequest
+}
+
+// HandleErrorCatalog lists every error code so frontend teams can map them.
+func HandleErrorCatalog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ResponseSuccess(c, ErrorCatalog())
+	}
+}
+
+// HandleErrorCode describes a single error code; problem+json "type" URIs point here.
+func HandleErrorCode() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		entry, ok := LookupErrorCode(ErrorCode(c.Param("code")))
+		if !ok {
+			ResponseError(c, NewErrorFromCode(CodeNotFound, nil).WithReason("unknown error code"))
+			return
+		}
+		ResponseSuccess(c, entry)
+	}
+}
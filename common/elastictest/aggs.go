@@ -0,0 +1,182 @@
+package elastictest
+
+import (
+	"fmt"
+	"sort"
+)
+
+// aggregate computes the aggregations in specs over hits: terms buckets, with nested
+// aggregations, and the value_count and cardinality metrics. It returns nil without specs.
+func aggregate(specs map[string]any, hits []hit) (map[string]any, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+
+	out := map[string]any{}
+	for name, raw := range specs {
+		spec, ok := raw.(map[string]any)
+		if !ok {
+			return nil, parsingError("aggregation [%s] malformed", name)
+		}
+
+		sub, _ := spec["aggs"].(map[string]any)
+		if sub == nil {
+			sub, _ = spec["aggregations"].(map[string]any)
+		}
+		var kind string
+		var opts map[string]any
+		for k, v := range spec {
+			if k == "aggs" || k == "aggregations" || k == "meta" {
+				continue
+			}
+			if kind != "" {
+				return nil, parsingError("found two aggregation type definitions in [%s]: [%s] and [%s]", name, kind, k)
+			}
+			kind, opts = k, asObject(v)
+		}
+
+		field, _ := opts["field"].(string)
+		if field == "" {
+			return nil, parsingError("aggregation [%s] needs a [field]", name)
+		}
+		if err := checkAggregatable(hits, field); err != nil {
+			return nil, err
+		}
+
+		var err error
+		switch kind {
+		case "terms":
+			out[name], err = termsAggregation(opts, field, sub, hits)
+		case "value_count":
+			n := 0
+			for _, h := range hits {
+				n += len(lookupField(h.doc.fields, field))
+			}
+			out[name] = map[string]any{"value": n}
+		case "cardinality":
+			distinct := map[any]bool{}
+			for _, h := range hits {
+				for _, v := range lookupField(h.doc.fields, field) {
+					if _, ok := v.(map[string]any); !ok {
+						distinct[v] = true
+					}
+				}
+			}
+			out[name] = map[string]any{"value": len(distinct)}
+		default:
+			return nil, parsingError("Unknown aggregation type [%s] did you mean [terms]?", kind)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func asObject(v any) map[string]any {
+	m, _ := v.(map[string]any)
+	if m == nil {
+		m = map[string]any{}
+	}
+	return m
+}
+
+// checkAggregatable refuses text fields, which Elasticsearch cannot aggregate without
+// fielddata.
+func checkAggregatable(hits []hit, field string) error {
+	seen := map[*index]bool{}
+	for _, h := range hits {
+		if seen[h.idx] {
+			continue
+		}
+		seen[h.idx] = true
+		if h.idx.fieldType(field) == "text" {
+			return badRequest("illegal_argument_exception", fmt.Sprintf(
+				"Text fields are not optimised for operations that require per-document field data like aggregations and sorting, so these operations are disabled by default. Please use a keyword field instead. Alternatively, set fielddata=true on [%s] in order to load field data by uninverting the inverted index. Note that this can use significant memory.", field))
+		}
+	}
+	return nil
+}
+
+func termsAggregation(opts map[string]any, field string, sub map[string]any, hits []hit) (map[string]any, error) {
+	size, minCount := 10, 1
+	if v, ok := opts["size"].(float64); ok {
+		size = int(v)
+	}
+	if v, ok := opts["min_doc_count"].(float64); ok {
+		minCount = int(v)
+	}
+	missing, hasMissing := opts["missing"]
+
+	type bucket struct {
+		key  any
+		hits []hit
+	}
+	buckets := map[any]*bucket{}
+	var keys []any
+	for _, h := range hits {
+		values := lookupField(h.doc.fields, field)
+		if len(values) == 0 && hasMissing {
+			values = []any{missing}
+		}
+		seen := map[any]bool{}
+		for _, v := range values {
+			if _, ok := v.(map[string]any); ok || seen[v] {
+				continue
+			}
+			seen[v] = true
+			b, ok := buckets[v]
+			if !ok {
+				b = &bucket{key: v}
+				buckets[v] = b
+				keys = append(keys, v)
+			}
+			b.hits = append(b.hits, h)
+		}
+	}
+
+	byKey, desc := false, true
+	if order, ok := opts["order"].(map[string]any); ok {
+		for k, dir := range order {
+			byKey, desc = k == "_key", dir == "desc"
+		}
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		a, b := buckets[keys[i]], buckets[keys[j]]
+		if !byKey && len(a.hits) != len(b.hits) {
+			return (len(a.hits) > len(b.hits)) == desc
+		}
+		c, _ := compareAs("", a.key, b.key)
+		if byKey && desc {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	var out []any
+	other := 0
+	for _, k := range keys {
+		b := buckets[k]
+		if len(b.hits) < minCount {
+			continue
+		}
+		if len(out) >= size {
+			other += len(b.hits)
+			continue
+		}
+		entry := map[string]any{"key": b.key, "doc_count": len(b.hits)}
+		nested, err := aggregate(sub, b.hits)
+		if err != nil {
+			return nil, err
+		}
+		for name, result := range nested {
+			entry[name] = result
+		}
+		out = append(out, entry)
+	}
+	if out == nil {
+		out = []any{}
+	}
+
+	return map[string]any{"doc_count_error_upper_bound": 0, "sum_other_doc_count": other, "buckets": out}, nil
+}
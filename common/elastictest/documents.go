@@ -0,0 +1,446 @@
+package elastictest
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// primaryTerm is the primary term of every shard; the server never fails over.
+const primaryTerm = 1
+
+// writeOptions are the optimistic concurrency and op_type parameters of a write.
+type writeOptions struct {
+	create        bool
+	ifSeqNo       *int64
+	ifPrimaryTerm *int64
+}
+
+func optionsFromParams(op string, params url.Values) (writeOptions, error) {
+	opts := writeOptions{create: op == "create"}
+	for name, dst := range map[string]**int64{"if_seq_no": &opts.ifSeqNo, "if_primary_term": &opts.ifPrimaryTerm} {
+		if raw := params.Get(name); raw != "" {
+			n, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil {
+				return opts, badRequest("illegal_argument_exception", "failed to parse ["+name+"]: "+raw)
+			}
+			*dst = &n
+		}
+	}
+	return opts, nil
+}
+
+// check reports a version conflict of a write over current, which is nil for a new document.
+func (o writeOptions) check(id string, current *document) error {
+	if o.create && current != nil {
+		return versionConflict(id, "document already exists (current version ["+strconv.FormatInt(current.version, 10)+"])")
+	}
+	if o.ifSeqNo == nil && o.ifPrimaryTerm == nil {
+		return nil
+	}
+	if current == nil {
+		return versionConflict(id, "required seqNo ["+strconv.FormatInt(deref(o.ifSeqNo), 10)+"], but no document was found")
+	}
+	if deref(o.ifSeqNo) != current.seqNo || deref(o.ifPrimaryTerm) != primaryTerm {
+		return versionConflict(id, "required seqNo ["+strconv.FormatInt(deref(o.ifSeqNo), 10)+"], primary term ["+
+			strconv.FormatInt(deref(o.ifPrimaryTerm), 10)+"]. current document has seqNo ["+strconv.FormatInt(current.seqNo, 10)+"] and primary term [1]")
+	}
+	return nil
+}
+
+func deref(n *int64) int64 {
+	if n == nil {
+		return -1
+	}
+	return *n
+}
+
+func newID() string {
+	b := make([]byte, 15)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// put writes source as document id of idx, generating the id when empty.
+func (idx *index) put(id string, source json.RawMessage, opts writeOptions) (*document, bool, error) {
+	var fields map[string]any
+	if err := json.Unmarshal(source, &fields); err != nil || fields == nil {
+		return nil, false, badRequest("mapper_parsing_exception", "failed to parse: the document is not a JSON object")
+	}
+	if err := idx.checkWritable(); err != nil {
+		return nil, false, err
+	}
+	if id == "" {
+		id = newID()
+	}
+
+	current := idx.docs[id]
+	if err := opts.check(id, current); err != nil {
+		return nil, false, err
+	}
+
+	idx.seqNo++
+	doc := &document{id: id, source: append(json.RawMessage(nil), source...), fields: fields, version: 1, seqNo: idx.seqNo - 1}
+	if current != nil {
+		doc.version = current.version + 1
+	} else {
+		idx.order = append(idx.order, id)
+	}
+	idx.docs[id] = doc
+	return doc, current == nil, nil
+}
+
+func (idx *index) remove(id string, opts writeOptions) (*document, error) {
+	if err := idx.checkWritable(); err != nil {
+		return nil, err
+	}
+	current, ok := idx.docs[id]
+	if !ok {
+		if opts.ifSeqNo != nil {
+			return nil, opts.check(id, nil)
+		}
+		return nil, nil
+	}
+	if err := opts.check(id, current); err != nil {
+		return nil, err
+	}
+
+	delete(idx.docs, id)
+	for i, other := range idx.order {
+		if other == id {
+			idx.order = append(idx.order[:i], idx.order[i+1:]...)
+			break
+		}
+	}
+	idx.seqNo++
+	return &document{id: id, version: current.version + 1, seqNo: idx.seqNo - 1}, nil
+}
+
+// update merges partial into the document, creating it from partial when upsert is set.
+func (idx *index) update(id string, partial map[string]any, upsert bool) (*document, bool, error) {
+	current, ok := idx.docs[id]
+	if !ok && !upsert {
+		return nil, false, &apiError{status: http.StatusNotFound, typ: "document_missing_exception", reason: "[" + id + "]: document missing",
+			extra: map[string]any{"index": idx.name, "shard": "0"}}
+	}
+
+	merged := map[string]any{}
+	if ok {
+		merged = current.fields
+	}
+	merged = mergeObjects(merged, partial)
+	source, err := json.Marshal(merged)
+	if err != nil {
+		return nil, false, err
+	}
+	return idx.put(id, source, writeOptions{})
+}
+
+func mergeObjects(dst, src map[string]any) map[string]any {
+	out := make(map[string]any, len(dst)+len(src))
+	for k, v := range dst {
+		out[k] = v
+	}
+	for k, v := range src {
+		if sub, ok := v.(map[string]any); ok {
+			if cur, ok := out[k].(map[string]any); ok {
+				out[k] = mergeObjects(cur, sub)
+				continue
+			}
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// writeResult is the body of a single document write, as in the index, delete and bulk APIs.
+func writeResult(idx *index, doc *document, result string) map[string]any {
+	return map[string]any{
+		"_index":        idx.name,
+		"_type":         "_doc",
+		"_id":           doc.id,
+		"_version":      doc.version,
+		"result":        result,
+		"_shards":       map[string]any{"total": 1, "successful": 1, "failed": 0},
+		"_seq_no":       doc.seqNo,
+		"_primary_term": primaryTerm,
+	}
+}
+
+func createdOrUpdated(created bool) (int, string) {
+	if created {
+		return http.StatusCreated, "created"
+	}
+	return http.StatusOK, "updated"
+}
+
+func (s *Server) indexRequest(name, id, op string, params url.Values, body []byte) (int, any, error) {
+	opts, err := optionsFromParams(op, params)
+	if err != nil {
+		return 0, nil, err
+	}
+	idx, err := s.writeIndex(name, true)
+	if err != nil {
+		return 0, nil, err
+	}
+	doc, created, err := idx.put(id, body, opts)
+	if err != nil {
+		return 0, nil, err
+	}
+	status, result := createdOrUpdated(created)
+	return status, writeResult(idx, doc, result), nil
+}
+
+func (s *Server) updateRequest(name, id string, raw []byte) (int, any, error) {
+	var body struct {
+		Doc         map[string]any `json:"doc"`
+		DocAsUpsert bool           `json:"doc_as_upsert"`
+	}
+	if err := decodeBody(raw, &body); err != nil {
+		return 0, nil, err
+	}
+	if body.Doc == nil {
+		return 0, nil, badRequest("action_request_validation_exception", "Validation Failed: 1: script or doc is missing;")
+	}
+
+	idx, err := s.writeIndex(name, body.DocAsUpsert)
+	if err != nil {
+		return 0, nil, err
+	}
+	doc, created, err := idx.update(id, body.Doc, body.DocAsUpsert)
+	if err != nil {
+		return 0, nil, err
+	}
+	status, result := createdOrUpdated(created)
+	return status, writeResult(idx, doc, result), nil
+}
+
+func (s *Server) getDocument(name, id string) (int, any, error) {
+	idx, err := s.writeIndex(name, false)
+	if err != nil {
+		return 0, nil, err
+	}
+	doc, ok := idx.docs[id]
+	if !ok {
+		return http.StatusNotFound, map[string]any{"_index": idx.name, "_type": "_doc", "_id": id, "found": false}, nil
+	}
+	return http.StatusOK, map[string]any{
+		"_index":        idx.name,
+		"_type":         "_doc",
+		"_id":           id,
+		"_version":      doc.version,
+		"_seq_no":       doc.seqNo,
+		"_primary_term": primaryTerm,
+		"found":         true,
+		"_source":       doc.source,
+	}, nil
+}
+
+func (s *Server) deleteDocument(name, id string, params url.Values) (int, any, error) {
+	opts, err := optionsFromParams("", params)
+	if err != nil {
+		return 0, nil, err
+	}
+	idx, err := s.writeIndex(name, false)
+	if err != nil {
+		return 0, nil, err
+	}
+	doc, err := idx.remove(id, opts)
+	if err != nil {
+		return 0, nil, err
+	}
+	if doc == nil {
+		return http.StatusNotFound, writeResult(idx, &document{id: id, version: 1, seqNo: idx.seqNo}, "not_found"), nil
+	}
+	return http.StatusOK, writeResult(idx, doc, "deleted"), nil
+}
+
+// bulk runs the index, create, update and delete actions of a _bulk body in order. Each
+// action succeeds or fails on its own, as in Elasticsearch.
+func (s *Server) bulk(defaultIndex string, body []byte) (int, any, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64<<10), 100<<20)
+
+	var items []any
+	hasErrors := false
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var action map[string]struct {
+			Index         string `json:"_index"`
+			ID            string `json:"_id"`
+			IfSeqNo       *int64 `json:"if_seq_no"`
+			IfPrimaryTerm *int64 `json:"if_primary_term"`
+		}
+		if err := json.Unmarshal(line, &action); err != nil || len(action) != 1 {
+			return 0, nil, badRequest("illegal_argument_exception", "Malformed action/metadata line ["+string(line)+"]")
+		}
+
+		for kind, meta := range action {
+			var source []byte
+			if kind != "delete" {
+				if !scanner.Scan() {
+					return 0, nil, badRequest("illegal_argument_exception", "The bulk request must be terminated by a newline [\\n]")
+				}
+				source = append([]byte(nil), scanner.Bytes()...)
+			}
+			name := meta.Index
+			if name == "" {
+				name = defaultIndex
+			}
+			opts := writeOptions{create: kind == "create", ifSeqNo: meta.IfSeqNo, ifPrimaryTerm: meta.IfPrimaryTerm}
+
+			item, err := s.bulkItem(kind, name, meta.ID, source, opts)
+			if err != nil {
+				hasErrors = true
+				e, ok := err.(*apiError)
+				if !ok {
+					e = &apiError{status: http.StatusInternalServerError, typ: "exception", reason: err.Error()}
+				}
+				item = map[string]any{"_index": name, "_type": "_doc", "_id": meta.ID, "status": e.status, "error": e.body()}
+			}
+			items = append(items, map[string]any{kind: item})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, nil, badRequest("illegal_argument_exception", err.Error())
+	}
+
+	return http.StatusOK, map[string]any{"took": 0, "errors": hasErrors, "items": items}, nil
+}
+
+func (s *Server) bulkItem(kind, name, id string, source []byte, opts writeOptions) (map[string]any, error) {
+	if name == "" {
+		return nil, badRequest("action_request_validation_exception", "Validation Failed: 1: index is missing;")
+	}
+
+	switch kind {
+	case "index", "create":
+		idx, err := s.writeIndex(name, true)
+		if err != nil {
+			return nil, err
+		}
+		doc, created, err := idx.put(id, source, opts)
+		if err != nil {
+			return nil, err
+		}
+		status, result := createdOrUpdated(created)
+		item := writeResult(idx, doc, result)
+		item["status"] = status
+		return item, nil
+	case "update":
+		var body struct {
+			Doc         map[string]any `json:"doc"`
+			DocAsUpsert bool           `json:"doc_as_upsert"`
+		}
+		if err := json.Unmarshal(source, &body); err != nil || body.Doc == nil {
+			return nil, badRequest("action_request_validation_exception", "Validation Failed: 1: script or doc is missing;")
+		}
+		idx, err := s.writeIndex(name, body.DocAsUpsert)
+		if err != nil {
+			return nil, err
+		}
+		doc, created, err := idx.update(id, body.Doc, body.DocAsUpsert)
+		if err != nil {
+			return nil, err
+		}
+		status, result := createdOrUpdated(created)
+		item := writeResult(idx, doc, result)
+		item["status"] = status
+		return item, nil
+	case "delete":
+		idx, err := s.writeIndex(name, false)
+		if err != nil {
+			return nil, err
+		}
+		doc, err := idx.remove(id, opts)
+		if err != nil {
+			return nil, err
+		}
+		if doc == nil {
+			item := writeResult(idx, &document{id: id, version: 1, seqNo: idx.seqNo}, "not_found")
+			item["status"] = http.StatusNotFound
+			return item, nil
+		}
+		item := writeResult(idx, doc, "deleted")
+		item["status"] = http.StatusOK
+		return item, nil
+	}
+	return nil, badRequest("illegal_argument_exception", "Unknown action ["+kind+"]")
+}
+
+// reindex copies the documents matching source.query from source.index into dest.index,
+// keeping their ids.
+func (s *Server) reindex(raw []byte) (int, any, error) {
+	var body struct {
+		Source struct {
+			Index any `json:"index"`
+			Query any `json:"query"`
+		} `json:"source"`
+		Dest struct {
+			Index  string `json:"index"`
+			OpType string `json:"op_type"`
+		} `json:"dest"`
+	}
+	if err := decodeBody(raw, &body); err != nil {
+		return 0, nil, err
+	}
+
+	match, err := compileQuery(body.Source.Query)
+	if err != nil {
+		return 0, nil, err
+	}
+	var sources []*index
+	for _, expr := range stringList(body.Source.Index) {
+		indices, err := s.resolve(expr, false)
+		if err != nil {
+			return 0, nil, err
+		}
+		sources = append(sources, indices...)
+	}
+	if len(sources) == 0 || body.Dest.Index == "" {
+		return 0, nil, badRequest("action_request_validation_exception", "Validation Failed: 1: use _all if you really want to copy from all existing indexes;")
+	}
+	dest, err := s.writeIndex(body.Dest.Index, true)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	created, updated, total := 0, 0, 0
+	var failures []any
+	opts := writeOptions{create: body.Dest.OpType == "create"}
+	for _, src := range sources {
+		if src == dest {
+			return 0, nil, badRequest("action_request_validation_exception", "reindex cannot write into an index its reading from ["+dest.name+"]")
+		}
+		for _, doc := range src.documents() {
+			if !match(src, doc) {
+				continue
+			}
+			total++
+			_, isNew, err := dest.put(doc.id, doc.source, opts)
+			switch {
+			case err != nil:
+				e, _ := err.(*apiError)
+				failures = append(failures, map[string]any{"index": dest.name, "id": doc.id, "status": e.status, "cause": e.body()})
+			case isNew:
+				created++
+			default:
+				updated++
+			}
+		}
+	}
+
+	return http.StatusOK, map[string]any{
+		"took": 0, "timed_out": false, "total": total, "created": created, "updated": updated,
+		"deleted": 0, "batches": 1, "version_conflicts": len(failures), "noops": 0, "failures": failures,
+	}, nil
+}
@@ -0,0 +1,557 @@
+package elastictest
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// matcher reports whether a document of idx matches a compiled query.
+type matcher func(idx *index, doc *document) bool
+
+func matchAll(*index, *document) bool { return true }
+
+func parsingError(format string, args ...any) error {
+	return badRequest("parsing_exception", fmt.Sprintf(format, args...))
+}
+
+// compileQuery compiles the query DSL object raw; nil matches every document.
+func compileQuery(raw any) (matcher, error) {
+	if raw == nil {
+		return matchAll, nil
+	}
+	q, ok := raw.(map[string]any)
+	if !ok || len(q) != 1 {
+		return nil, parsingError("query malformed, must be an object with a single query type")
+	}
+
+	for typ, body := range q {
+		switch typ {
+		case "match_all":
+			return matchAll, nil
+		case "match_none":
+			return func(*index, *document) bool { return false }, nil
+		case "bool":
+			return compileBool(body)
+		case "term":
+			field, value, _, err := fieldQuery(typ, body, "value")
+			if err != nil {
+				return nil, err
+			}
+			return func(idx *index, doc *document) bool {
+				return anyValue(doc, field, func(v any) bool { return termMatches(idx, field, v, value) })
+			}, nil
+		case "terms":
+			return compileTerms(body)
+		case "range":
+			return compileRange(body)
+		case "match":
+			return compileMatch(body)
+		case "exists":
+			opts, _ := body.(map[string]any)
+			field, ok := opts["field"].(string)
+			if !ok {
+				return nil, parsingError("[exists] must be provided with a [field]")
+			}
+			return func(idx *index, doc *document) bool { return len(lookupField(doc.fields, field)) > 0 }, nil
+		case "wildcard", "prefix":
+			return compilePattern(typ, body)
+		case "ids":
+			opts, _ := body.(map[string]any)
+			ids := map[string]bool{}
+			for _, id := range stringList(opts["values"]) {
+				ids[id] = true
+			}
+			return func(idx *index, doc *document) bool { return ids[doc.id] }, nil
+		default:
+			return nil, parsingError("unknown query [%s]", typ)
+		}
+	}
+	return nil, nil
+}
+
+func compileBool(body any) (matcher, error) {
+	opts, ok := body.(map[string]any)
+	if !ok {
+		return nil, parsingError("[bool] query malformed")
+	}
+
+	clauses := map[string][]matcher{}
+	for _, occur := range []string{"must", "filter", "should", "must_not"} {
+		var list []any
+		switch v := opts[occur].(type) {
+		case nil:
+			continue
+		case []any:
+			list = v
+		default:
+			list = []any{v}
+		}
+		for _, raw := range list {
+			m, err := compileQuery(raw)
+			if err != nil {
+				return nil, err
+			}
+			clauses[occur] = append(clauses[occur], m)
+		}
+	}
+
+	minShould := 0
+	if len(clauses["should"]) > 0 && len(clauses["must"]) == 0 && len(clauses["filter"]) == 0 {
+		minShould = 1
+	}
+	switch v := opts["minimum_should_match"].(type) {
+	case float64:
+		minShould = int(v)
+	case string:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, parsingError("elastictest supports only a number for [minimum_should_match], got [%s]", v)
+		}
+		minShould = n
+	}
+
+	return func(idx *index, doc *document) bool {
+		for _, m := range append(clauses["must"], clauses["filter"]...) {
+			if !m(idx, doc) {
+				return false
+			}
+		}
+		for _, m := range clauses["must_not"] {
+			if m(idx, doc) {
+				return false
+			}
+		}
+		matched := 0
+		for _, m := range clauses["should"] {
+			if m(idx, doc) {
+				matched++
+			}
+		}
+		return matched >= minShould
+	}, nil
+}
+
+// fieldQuery splits the body of a single-field query such as {"status": 200} or
+// {"status": {"value": 200}} into the field, its value under key and the other options.
+func fieldQuery(typ string, body any, key string) (string, any, map[string]any, error) {
+	opts, ok := body.(map[string]any)
+	if !ok {
+		return "", nil, nil, parsingError("[%s] query malformed", typ)
+	}
+	var fields []string
+	for k := range opts {
+		if k != "boost" && k != "_name" {
+			fields = append(fields, k)
+		}
+	}
+	if len(fields) != 1 {
+		return "", nil, nil, parsingError("[%s] query doesn't support multiple fields, found %v", typ, fields)
+	}
+
+	field := fields[0]
+	if sub, ok := opts[field].(map[string]any); ok {
+		value, ok := sub[key]
+		if !ok {
+			return "", nil, nil, parsingError("[%s] query on [%s] is missing [%s]", typ, field, key)
+		}
+		return field, value, sub, nil
+	}
+	return field, opts[field], map[string]any{}, nil
+}
+
+func compileTerms(body any) (matcher, error) {
+	opts, ok := body.(map[string]any)
+	if !ok {
+		return nil, parsingError("[terms] query malformed")
+	}
+	var field string
+	var values []any
+	for k, v := range opts {
+		if k == "boost" || k == "_name" {
+			continue
+		}
+		list, ok := v.([]any)
+		if !ok || field != "" {
+			return nil, parsingError("[terms] query malformed, expected one field with an array of values")
+		}
+		field, values = k, list
+	}
+
+	return func(idx *index, doc *document) bool {
+		return anyValue(doc, field, func(v any) bool {
+			for _, want := range values {
+				if termMatches(idx, field, v, want) {
+					return true
+				}
+			}
+			return false
+		})
+	}, nil
+}
+
+func compileRange(body any) (matcher, error) {
+	opts, ok := body.(map[string]any)
+	if !ok || len(opts) != 1 {
+		return nil, parsingError("[range] query malformed")
+	}
+
+	type bound struct {
+		value     any
+		inclusive bool
+	}
+	var field string
+	var lower, upper *bound
+	for k, v := range opts {
+		field = k
+		params, ok := v.(map[string]any)
+		if !ok {
+			return nil, parsingError("[range] query malformed, no start_object after query name")
+		}
+		includeLower, includeUpper := true, true
+		if b, ok := params["include_lower"].(bool); ok {
+			includeLower = b
+		}
+		if b, ok := params["include_upper"].(bool); ok {
+			includeUpper = b
+		}
+		if v := params["from"]; v != nil {
+			lower = &bound{v, includeLower}
+		}
+		if v := params["to"]; v != nil {
+			upper = &bound{v, includeUpper}
+		}
+		if v := params["gt"]; v != nil {
+			lower = &bound{v, false}
+		}
+		if v := params["gte"]; v != nil {
+			lower = &bound{v, true}
+		}
+		if v := params["lt"]; v != nil {
+			upper = &bound{v, false}
+		}
+		if v := params["lte"]; v != nil {
+			upper = &bound{v, true}
+		}
+	}
+
+	return func(idx *index, doc *document) bool {
+		typ := idx.fieldType(field)
+		return anyValue(doc, field, func(v any) bool {
+			if lower != nil {
+				c, ok := compareAs(typ, v, lower.value)
+				if !ok || c < 0 || (c == 0 && !lower.inclusive) {
+					return false
+				}
+			}
+			if upper != nil {
+				c, ok := compareAs(typ, v, upper.value)
+				if !ok || c > 0 || (c == 0 && !upper.inclusive) {
+					return false
+				}
+			}
+			return true
+		})
+	}, nil
+}
+
+func compileMatch(body any) (matcher, error) {
+	field, value, opts, err := fieldQuery("match", body, "query")
+	if err != nil {
+		return nil, err
+	}
+	and := strings.EqualFold(fmt.Sprint(opts["operator"]), "and")
+	text, isText := value.(string)
+	want := tokenize(text)
+
+	return func(idx *index, doc *document) bool {
+		if !isText || idx.fieldType(field) == "keyword" {
+			return anyValue(doc, field, func(v any) bool { return termMatches(idx, field, v, value) })
+		}
+		if len(want) == 0 {
+			return false
+		}
+
+		have := map[string]bool{}
+		for _, v := range lookupField(doc.fields, field) {
+			for _, token := range tokenize(fmt.Sprint(v)) {
+				have[token] = true
+			}
+		}
+		found := 0
+		for _, token := range want {
+			if have[token] {
+				found++
+			}
+		}
+		if and {
+			return found == len(want)
+		}
+		return found > 0
+	}, nil
+}
+
+func compilePattern(typ string, body any) (matcher, error) {
+	field, value, opts, err := fieldQuery(typ, body, "value")
+	if err != nil {
+		if field, value, opts, err = fieldQuery(typ, body, "wildcard"); err != nil {
+			return nil, err
+		}
+	}
+	pattern, ok := value.(string)
+	if !ok {
+		return nil, parsingError("[%s] query on [%s] needs a string", typ, field)
+	}
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	if ci, _ := opts["case_insensitive"].(bool); ci {
+		expr.WriteString("(?i)")
+	}
+	if typ == "prefix" {
+		expr.WriteString(regexp.QuoteMeta(pattern) + ".*")
+	} else {
+		escaped := false
+		for _, r := range pattern {
+			switch {
+			case escaped:
+				expr.WriteString(regexp.QuoteMeta(string(r)))
+				escaped = false
+			case r == '\\':
+				escaped = true
+			case r == '*':
+				expr.WriteString(".*")
+			case r == '?':
+				expr.WriteString(".")
+			default:
+				expr.WriteString(regexp.QuoteMeta(string(r)))
+			}
+		}
+	}
+	expr.WriteString("$")
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil, parsingError("[%s] query on [%s]: %v", typ, field, err)
+	}
+
+	return func(idx *index, doc *document) bool {
+		text := idx.fieldType(field) == "text"
+		return anyValue(doc, field, func(v any) bool {
+			s, ok := v.(string)
+			if !ok {
+				return false
+			}
+			if !text {
+				return re.MatchString(s)
+			}
+			for _, token := range tokenize(s) {
+				if re.MatchString(token) {
+					return true
+				}
+			}
+			return false
+		})
+	}, nil
+}
+
+// anyValue reports whether one of the values of field satisfies ok; arrays match when any
+// element does.
+func anyValue(doc *document, field string, ok func(v any) bool) bool {
+	for _, v := range lookupField(doc.fields, field) {
+		if ok(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupField returns the values of a dotted field, reading both flattened keys such as
+// "http.method" and nested objects, with arrays expanded. A ".keyword" suffix falls back to the
+// field itself, like the keyword sub-field of a dynamically mapped string.
+func lookupField(fields map[string]any, field string) []any {
+	values := lookup(fields, field)
+	if values == nil && strings.HasSuffix(field, ".keyword") {
+		values = lookup(fields, strings.TrimSuffix(field, ".keyword"))
+	}
+	return values
+}
+
+func lookup(fields map[string]any, field string) []any {
+	if v, ok := fields[field]; ok {
+		return flatten(v)
+	}
+	for i := strings.Index(field, "."); i >= 0; i = nextDot(field, i) {
+		var out []any
+		for _, parent := range flatten(fields[field[:i]]) {
+			if sub, ok := parent.(map[string]any); ok {
+				out = append(out, lookup(sub, field[i+1:])...)
+			}
+		}
+		if out != nil {
+			return out
+		}
+	}
+	return nil
+}
+
+func flatten(v any) []any {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case []any:
+		var out []any
+		for _, item := range v {
+			out = append(out, flatten(item)...)
+		}
+		return out
+	default:
+		return []any{v}
+	}
+}
+
+// termMatches compares a document value with the value of a term query: exact for keywords,
+// numbers, dates and booleans, and against the tokens of text fields.
+func termMatches(idx *index, field string, v, want any) bool {
+	typ := idx.fieldType(field)
+	if typ == "text" {
+		s, ok := v.(string)
+		if !ok {
+			return false
+		}
+		for _, token := range tokenize(s) {
+			if token == fmt.Sprint(want) {
+				return true
+			}
+		}
+		return false
+	}
+	c, ok := compareAs(typ, v, want)
+	return ok && c == 0
+}
+
+// tokenize splits text into lowercase runs of letters and digits, like the standard analyzer
+// does for the text this application stores.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+var numericTypes = map[string]bool{
+	"long": true, "integer": true, "short": true, "byte": true,
+	"double": true, "float": true, "half_float": true, "scaled_float": true, "unsigned_long": true,
+}
+
+// compareAs orders two values of a field of the mapped type typ, coercing strings to numbers on
+// numeric fields and both sides to times on date fields. Unmapped fields compare numbers as
+// numbers, strings that both parse as times as times, and other strings lexically.
+func compareAs(typ string, a, b any) (int, bool) {
+	switch {
+	case numericTypes[typ]:
+		x, okA := toNumber(a)
+		y, okB := toNumber(b)
+		return compareNumbers(x, y), okA && okB
+	case typ == "date" || typ == "date_nanos":
+		x, okA := toTime(a)
+		y, okB := toTime(b)
+		return x.Compare(y), okA && okB
+	}
+
+	if x, ok := number(a); ok {
+		if y, ok := toNumber(b); ok {
+			return compareNumbers(x, y), true
+		}
+	}
+	switch x := a.(type) {
+	case string:
+		y, ok := b.(string)
+		if !ok {
+			y = fmt.Sprint(b)
+		}
+		if tx, ok := parseTime(x); ok {
+			if ty, ok := parseTime(y); ok {
+				return tx.Compare(ty), true
+			}
+		}
+		return strings.Compare(x, y), true
+	case bool:
+		y, ok := b.(bool)
+		if !ok {
+			y, _ = strconv.ParseBool(fmt.Sprint(b))
+		}
+		if x == y {
+			return 0, true
+		}
+		return 1, true
+	}
+	return 0, false
+}
+
+func compareNumbers(x, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+// toNumber converts numbers and numeric strings.
+func toNumber(v any) (float64, bool) {
+	if s, ok := v.(string); ok {
+		f, err := strconv.ParseFloat(s, 64)
+		return f, err == nil
+	}
+	return number(v)
+}
+
+// number converts the numbers of decoded JSON and of query values.
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// toTime converts date strings and epoch milliseconds.
+func toTime(v any) (time.Time, bool) {
+	if s, ok := v.(string); ok {
+		if t, ok := parseTime(s); ok {
+			return t, true
+		}
+	}
+	if ms, ok := toNumber(v); ok {
+		return time.UnixMilli(int64(ms)).UTC(), true
+	}
+	return time.Time{}, false
+}
+
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.000Z0700",
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	time.DateOnly,
+}
+
+func parseTime(s string) (time.Time, bool) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,573 @@
+package elastictest
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+)
+
+// index is one index with its documents in indexing order.
+type index struct {
+	name     string
+	settings map[string]any
+	mappings map[string]any
+	// aliases maps the alias names of the index to whether it is their write index.
+	aliases map[string]bool
+	docs    map[string]*document
+	order   []string
+	seqNo   int64
+}
+
+type document struct {
+	id      string
+	source  json.RawMessage
+	fields  map[string]any
+	version int64
+	seqNo   int64
+}
+
+func (idx *index) documents() []*document {
+	docs := make([]*document, 0, len(idx.order))
+	for _, id := range idx.order {
+		docs = append(docs, idx.docs[id])
+	}
+	return docs
+}
+
+// fieldType returns the mapped type of a dotted field name, following object properties and
+// multi-fields such as message.keyword, or "" when the field is not mapped.
+func (idx *index) fieldType(field string) string {
+	props, _ := idx.mappings["properties"].(map[string]any)
+	return mappedType(props, field)
+}
+
+func mappedType(props map[string]any, field string) string {
+	if def, ok := props[field].(map[string]any); ok {
+		if typ, ok := def["type"].(string); ok {
+			return typ
+		}
+		return "object"
+	}
+	for i := strings.Index(field, "."); i >= 0; i = nextDot(field, i) {
+		def, ok := props[field[:i]].(map[string]any)
+		if !ok {
+			continue
+		}
+		if sub, ok := def["properties"].(map[string]any); ok {
+			if typ := mappedType(sub, field[i+1:]); typ != "" {
+				return typ
+			}
+		}
+		if sub, ok := def["fields"].(map[string]any); ok {
+			if typ := mappedType(sub, field[i+1:]); typ != "" {
+				return typ
+			}
+		}
+	}
+	return ""
+}
+
+func nextDot(s string, after int) int {
+	if i := strings.Index(s[after+1:], "."); i >= 0 {
+		return after + 1 + i
+	}
+	return -1
+}
+
+// resolve expands a comma-separated list of index names, aliases and patterns such as
+// "logs-*" or "_all". A name that matches nothing is index_not_found_exception unless
+// allowMissing is set.
+func (s *Server) resolve(expr string, allowMissing bool) ([]*index, error) {
+	seen := map[string]bool{}
+	var out []*index
+	add := func(idx *index) {
+		if !seen[idx.name] {
+			seen[idx.name] = true
+			out = append(out, idx)
+		}
+	}
+
+	for _, name := range strings.Split(expr, ",") {
+		name = strings.TrimSpace(name)
+		switch {
+		case name == "" || name == "_all":
+			for _, idx := range s.indices {
+				add(idx)
+			}
+		case strings.ContainsAny(name, "*?"):
+			for _, idx := range s.indices {
+				if ok, _ := path.Match(name, idx.name); ok {
+					add(idx)
+				}
+				for alias := range idx.aliases {
+					if ok, _ := path.Match(name, alias); ok {
+						add(idx)
+					}
+				}
+			}
+		default:
+			if idx, ok := s.indices[name]; ok {
+				add(idx)
+				continue
+			}
+			members := s.aliasMembers(name)
+			if len(members) == 0 && !allowMissing {
+				return nil, indexNotFound(name)
+			}
+			for _, idx := range members {
+				add(idx)
+			}
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+	return out, nil
+}
+
+func (s *Server) aliasMembers(alias string) []*index {
+	var members []*index
+	for _, idx := range s.indices {
+		if _, ok := idx.aliases[alias]; ok {
+			members = append(members, idx)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].name < members[j].name })
+	return members
+}
+
+// writeIndex returns the index a write to name goes to: the index itself, the write index of an
+// alias, or a new index created from the templates when create is set.
+func (s *Server) writeIndex(name string, create bool) (*index, error) {
+	if idx, ok := s.indices[name]; ok {
+		return idx, nil
+	}
+	if members := s.aliasMembers(name); len(members) > 0 {
+		for _, idx := range members {
+			if idx.aliases[name] {
+				return idx, nil
+			}
+		}
+		if len(members) == 1 {
+			return members[0], nil
+		}
+		return nil, badRequest("illegal_argument_exception",
+			"no write index is defined for alias ["+name+"]. The write index may be explicitly disabled using is_write_index=false or the alias points to multiple indices without one being designated as a write index")
+	}
+	if !create {
+		return nil, indexNotFound(name)
+	}
+	return s.createIndex(name, nil)
+}
+
+// createIndex creates name from the matching templates overlaid with body, which may hold
+// settings, mappings and aliases.
+func (s *Server) createIndex(name string, body map[string]any) (*index, error) {
+	if _, ok := s.indices[name]; ok {
+		return nil, &apiError{status: http.StatusBadRequest, typ: "resource_already_exists_exception",
+			reason: "index [" + name + "] already exists", extra: map[string]any{"index": name}}
+	}
+	if len(s.aliasMembers(name)) > 0 {
+		return nil, badRequest("invalid_index_name_exception", "Invalid index name ["+name+"], already exists as alias")
+	}
+	if name != strings.ToLower(name) || strings.ContainsAny(name, `*?"<>|, /\#`) || strings.HasPrefix(name, "_") {
+		return nil, badRequest("invalid_index_name_exception", "Invalid index name ["+name+"]")
+	}
+
+	idx := &index{name: name, settings: map[string]any{}, mappings: map[string]any{}, aliases: map[string]bool{}, docs: map[string]*document{}}
+	for _, layer := range append(s.matchingTemplates(name), body) {
+		idx.apply(layer)
+	}
+	for alias := range idx.aliases {
+		if _, ok := s.indices[alias]; ok {
+			return nil, badRequest("invalid_alias_name_exception", "Invalid alias name ["+alias+"]: an index exists with the same name as the alias")
+		}
+	}
+
+	s.indices[name] = idx
+	return idx, nil
+}
+
+// apply merges the settings, mappings and aliases of one template or request body into idx;
+// later layers win field by field.
+func (idx *index) apply(layer map[string]any) {
+	if settings, ok := layer["settings"].(map[string]any); ok {
+		flattenSettings("", settings, idx.settings)
+	}
+	if mappings, ok := layer["mappings"].(map[string]any); ok {
+		for k, v := range mappings {
+			if k != "properties" {
+				idx.mappings[k] = v
+				continue
+			}
+			props, _ := idx.mappings["properties"].(map[string]any)
+			if props == nil {
+				props = map[string]any{}
+				idx.mappings["properties"] = props
+			}
+			add, _ := v.(map[string]any)
+			for field, def := range add {
+				props[field] = def
+			}
+		}
+	}
+	if aliases, ok := layer["aliases"].(map[string]any); ok {
+		for alias, opts := range aliases {
+			o, _ := opts.(map[string]any)
+			write, _ := o["is_write_index"].(bool)
+			idx.aliases[alias] = write
+		}
+	}
+}
+
+// matchingTemplates returns the template layers for a new index: the composable template with
+// the highest priority matching name or, when none does, every matching legacy template by
+// ascending order.
+func (s *Server) matchingTemplates(name string) []map[string]any {
+	var best map[string]any
+	bestPriority := -1.0
+	for _, tmpl := range s.templates {
+		if !patternsMatch(tmpl["index_patterns"], name) {
+			continue
+		}
+		priority, _ := tmpl["priority"].(float64)
+		if priority > bestPriority {
+			best, bestPriority = tmpl, priority
+		}
+	}
+	if best != nil {
+		layer, _ := best["template"].(map[string]any)
+		return []map[string]any{layer}
+	}
+
+	var legacy []map[string]any
+	for _, tmpl := range s.legacyTemplates {
+		if patternsMatch(tmpl["index_patterns"], name) {
+			legacy = append(legacy, tmpl)
+		}
+	}
+	sort.SliceStable(legacy, func(i, j int) bool {
+		oi, _ := legacy[i]["order"].(float64)
+		oj, _ := legacy[j]["order"].(float64)
+		return oi < oj
+	})
+	return legacy
+}
+
+func patternsMatch(patterns any, name string) bool {
+	var list []any
+	switch p := patterns.(type) {
+	case string:
+		list = []any{p}
+	case []any:
+		list = p
+	}
+	for _, p := range list {
+		if pattern, ok := p.(string); ok {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *Server) createIndexRequest(name string, raw []byte) (int, any, error) {
+	var body map[string]any
+	if err := decodeBody(raw, &body); err != nil {
+		return 0, nil, err
+	}
+	if _, err := s.createIndex(name, body); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, map[string]any{"acknowledged": true, "shards_acknowledged": true, "index": name}, nil
+}
+
+func (s *Server) deleteIndex(expr string) (int, any, error) {
+	indices, err := s.resolve(expr, false)
+	if err != nil {
+		return 0, nil, err
+	}
+	for _, idx := range indices {
+		delete(s.indices, idx.name)
+	}
+	return http.StatusOK, map[string]any{"acknowledged": true}, nil
+}
+
+func (s *Server) getIndex(expr string, mappingOnly bool) (int, any, error) {
+	indices, err := s.resolve(expr, false)
+	if err != nil {
+		return 0, nil, err
+	}
+	out := map[string]any{}
+	for _, idx := range indices {
+		if mappingOnly {
+			out[idx.name] = map[string]any{"mappings": idx.mappings}
+			continue
+		}
+		out[idx.name] = map[string]any{
+			"aliases":  aliasBody(idx, ""),
+			"mappings": idx.mappings,
+			"settings": map[string]any{"index": idx.settings},
+		}
+	}
+	return http.StatusOK, out, nil
+}
+
+// aliasBody renders the aliases of idx matching pattern, or all of them when it is empty.
+func aliasBody(idx *index, pattern string) map[string]any {
+	out := map[string]any{}
+	for alias, write := range idx.aliases {
+		if pattern != "" && !aliasMatches(pattern, alias) {
+			continue
+		}
+		opts := map[string]any{}
+		if write {
+			opts["is_write_index"] = true
+		}
+		out[alias] = opts
+	}
+	return out
+}
+
+func aliasMatches(patterns, alias string) bool {
+	for _, p := range strings.Split(patterns, ",") {
+		if ok, _ := path.Match(p, alias); ok || p == "_all" {
+			return true
+		}
+	}
+	return false
+}
+
+// getAliases answers GET [/{index}]/_alias[/{alias}] and GET /_aliases.
+func (s *Server) getAliases(expr, aliases string) (int, any, error) {
+	indices, err := s.resolve(expr, false)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	out := map[string]any{}
+	found := false
+	for _, idx := range indices {
+		body := aliasBody(idx, aliases)
+		if aliases != "" && len(body) == 0 {
+			continue
+		}
+		found = found || len(body) > 0
+		out[idx.name] = map[string]any{"aliases": body}
+	}
+	if aliases != "" && !found {
+		return http.StatusNotFound, map[string]any{"error": "alias [" + aliases + "] missing", "status": http.StatusNotFound}, nil
+	}
+	return http.StatusOK, out, nil
+}
+
+// updateAliases applies the add, remove and remove_index actions of POST /_aliases at once:
+// when one fails, none is applied.
+func (s *Server) updateAliases(raw []byte) (int, any, error) {
+	var body struct {
+		Actions []map[string]map[string]any `json:"actions"`
+	}
+	if err := decodeBody(raw, &body); err != nil {
+		return 0, nil, err
+	}
+
+	aliases := map[string]map[string]bool{}
+	for name, idx := range s.indices {
+		aliases[name] = map[string]bool{}
+		for alias, write := range idx.aliases {
+			aliases[name][alias] = write
+		}
+	}
+	removed := map[string]bool{}
+
+	for _, action := range body.Actions {
+		for kind, opts := range action {
+			names, err := s.actionIndices(opts)
+			if err != nil {
+				return 0, nil, err
+			}
+			targets := stringList(opts["alias"], opts["aliases"])
+
+			switch kind {
+			case "add":
+				write, _ := opts["is_write_index"].(bool)
+				for _, name := range names {
+					for _, alias := range targets {
+						if write {
+							for _, other := range aliases {
+								if _, ok := other[alias]; ok {
+									other[alias] = false
+								}
+							}
+						}
+						aliases[name][alias] = write
+					}
+				}
+			case "remove":
+				for _, name := range names {
+					for _, alias := range targets {
+						if _, ok := aliases[name][alias]; !ok {
+							return 0, nil, &apiError{status: http.StatusNotFound, typ: "aliases_not_found_exception", reason: "aliases [" + alias + "] missing"}
+						}
+						delete(aliases[name], alias)
+					}
+				}
+			case "remove_index":
+				for _, name := range names {
+					removed[name] = true
+				}
+			default:
+				return 0, nil, badRequest("parsing_exception", "unknown alias action ["+kind+"]")
+			}
+		}
+	}
+
+	// Indices removed by the request no longer clash with an alias of the same name, which is
+	// how a concrete index is swapped for an alias in one call.
+	for name, set := range aliases {
+		if removed[name] {
+			continue
+		}
+		for alias := range set {
+			if _, ok := s.indices[alias]; ok && !removed[alias] {
+				return 0, nil, badRequest("invalid_alias_name_exception", "Invalid alias name ["+alias+"]: an index exists with the same name as the alias")
+			}
+		}
+	}
+
+	for name, idx := range s.indices {
+		idx.aliases = aliases[name]
+		if removed[name] {
+			delete(s.indices, name)
+		}
+	}
+	return http.StatusOK, map[string]any{"acknowledged": true}, nil
+}
+
+// actionIndices resolves the index or indices of an alias action to concrete index names.
+func (s *Server) actionIndices(opts map[string]any) ([]string, error) {
+	var names []string
+	for _, expr := range stringList(opts["index"], opts["indices"]) {
+		if idx, ok := s.indices[expr]; ok {
+			names = append(names, idx.name)
+			continue
+		}
+		if !strings.ContainsAny(expr, "*?") {
+			return nil, indexNotFound(expr)
+		}
+		indices, _ := s.resolve(expr, true)
+		for _, idx := range indices {
+			names = append(names, idx.name)
+		}
+	}
+	return names, nil
+}
+
+// stringList flattens string and string array values.
+func stringList(values ...any) []string {
+	var out []string
+	for _, v := range values {
+		switch v := v.(type) {
+		case string:
+			out = append(out, v)
+		case []any:
+			for _, item := range v {
+				if s, ok := item.(string); ok {
+					out = append(out, s)
+				}
+			}
+		}
+	}
+	return out
+}
+
+// template answers PUT, GET, HEAD and DELETE on /_index_template/{name} and, when legacy is
+// set, /_template/{name}.
+func (s *Server) template(method string, legacy bool, name string, raw []byte) (int, any, error) {
+	store := s.templates
+	if legacy {
+		store = s.legacyTemplates
+	}
+	missing := &apiError{status: http.StatusNotFound, typ: "resource_not_found_exception", reason: "index template matching [" + name + "] not found"}
+
+	switch method {
+	case http.MethodPut, http.MethodPost:
+		var body map[string]any
+		if err := decodeBody(raw, &body); err != nil {
+			return 0, nil, err
+		}
+		if body["index_patterns"] == nil {
+			return 0, nil, badRequest("action_request_validation_exception", "Validation Failed: 1: index patterns are missing;")
+		}
+		store[name] = body
+		return http.StatusOK, map[string]any{"acknowledged": true}, nil
+	case http.MethodHead:
+		if _, ok := store[name]; !ok {
+			return http.StatusNotFound, nil, nil
+		}
+		return http.StatusOK, nil, nil
+	case http.MethodGet:
+		tmpl, ok := store[name]
+		if !ok {
+			return 0, nil, missing
+		}
+		if legacy {
+			return http.StatusOK, map[string]any{name: tmpl}, nil
+		}
+		return http.StatusOK, map[string]any{"index_templates": []any{map[string]any{"name": name, "index_template": tmpl}}}, nil
+	case http.MethodDelete:
+		if _, ok := store[name]; !ok {
+			return 0, nil, missing
+		}
+		delete(store, name)
+		return http.StatusOK, map[string]any{"acknowledged": true}, nil
+	}
+	return 0, nil, badRequest("unsupported_operation_exception", "elastictest does not implement "+method+" on templates")
+}
+
+// flattenSettings copies settings into out under dotted keys without the "index." prefix, so
+// {"index": {"blocks": {"write": true}}} and {"index.blocks.write": true} both set
+// "blocks.write".
+func flattenSettings(prefix string, settings map[string]any, out map[string]any) {
+	for k, v := range settings {
+		key := prefix + k
+		if nested, ok := v.(map[string]any); ok {
+			flattenSettings(key+".", nested, out)
+			continue
+		}
+		out[strings.TrimPrefix(key, "index.")] = v
+	}
+}
+
+// putSettings updates the settings of the indices expr resolves to.
+func (s *Server) putSettings(expr string, raw []byte) (int, any, error) {
+	var body map[string]any
+	if err := decodeBody(raw, &body); err != nil {
+		return 0, nil, err
+	}
+	if settings, ok := body["settings"].(map[string]any); ok {
+		body = settings
+	}
+	indices, err := s.resolve(expr, false)
+	if err != nil {
+		return 0, nil, err
+	}
+	for _, idx := range indices {
+		flattenSettings("", body, idx.settings)
+	}
+	return http.StatusOK, map[string]any{"acknowledged": true}, nil
+}
+
+// checkWritable fails with the cluster block error of Elasticsearch when index.blocks.write
+// or index.blocks.read_only is set on idx.
+func (idx *index) checkWritable() error {
+	for _, block := range []string{"blocks.write", "blocks.read_only"} {
+		if v := idx.settings[block]; v == true || v == "true" {
+			return &apiError{status: http.StatusForbidden, typ: "cluster_block_exception",
+				reason: "index [" + idx.name + "] blocked by: [FORBIDDEN/8/index write (api)];"}
+		}
+	}
+	return nil
+}
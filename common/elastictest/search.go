@@ -0,0 +1,251 @@
+package elastictest
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// hit is a matching document with the index it was found in.
+type hit struct {
+	idx  *index
+	doc  *document
+	sort []any
+}
+
+// searchRequest is the part of the _search body the server understands.
+type searchRequest struct {
+	Query            any            `json:"query"`
+	From             *int           `json:"from"`
+	Size             *int           `json:"size"`
+	Sort             any            `json:"sort"`
+	SearchAfter      []any          `json:"search_after"`
+	Aggs             map[string]any `json:"aggs"`
+	Aggregations     map[string]any `json:"aggregations"`
+	SeqNoPrimaryTerm bool           `json:"seq_no_primary_term"`
+	Version          bool           `json:"version"`
+}
+
+// sortKey is one entry of the sort of a search.
+type sortKey struct {
+	field        string
+	desc         bool
+	missingFirst bool
+}
+
+// matching returns the documents of the indices behind expr that match query, in index name
+// and then indexing order.
+func (s *Server) matching(expr string, query any) ([]hit, error) {
+	match, err := compileQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	indices, err := s.resolve(expr, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var hits []hit
+	for _, idx := range indices {
+		for _, doc := range idx.documents() {
+			if match(idx, doc) {
+				hits = append(hits, hit{idx: idx, doc: doc})
+			}
+		}
+	}
+	return hits, nil
+}
+
+func (s *Server) count(expr string, raw []byte) (int, any, error) {
+	var body searchRequest
+	if err := decodeBody(raw, &body); err != nil {
+		return 0, nil, err
+	}
+	hits, err := s.matching(expr, body.Query)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, map[string]any{"count": len(hits), "_shards": map[string]any{"total": 1, "successful": 1, "skipped": 0, "failed": 0}}, nil
+}
+
+func (s *Server) search(expr string, raw []byte) (int, any, error) {
+	var body searchRequest
+	if err := decodeBody(raw, &body); err != nil {
+		return 0, nil, err
+	}
+	keys, err := parseSort(body.Sort)
+	if err != nil {
+		return 0, nil, err
+	}
+	aggs := body.Aggs
+	if aggs == nil {
+		aggs = body.Aggregations
+	}
+
+	hits, err := s.matching(expr, body.Query)
+	if err != nil {
+		return 0, nil, err
+	}
+	aggResults, err := aggregate(aggs, hits)
+	if err != nil {
+		return 0, nil, err
+	}
+	total := len(hits)
+
+	if len(keys) > 0 {
+		for i := range hits {
+			hits[i].sort = sortValues(hits[i], keys)
+		}
+		sort.SliceStable(hits, func(i, j int) bool { return compareSortValues(hits[i], hits[j].sort, keys) < 0 })
+	}
+	if body.SearchAfter != nil {
+		if len(body.SearchAfter) != len(keys) {
+			return 0, nil, badRequest("illegal_argument_exception", "search_after has "+fmt.Sprint(len(body.SearchAfter))+" value(s) but sort has "+fmt.Sprint(len(keys)))
+		}
+		after := hits[:0]
+		for _, h := range hits {
+			if compareSortValues(h, body.SearchAfter, keys) > 0 {
+				after = append(after, h)
+			}
+		}
+		hits = after
+	}
+
+	from, size := 0, 10
+	if body.From != nil {
+		from = *body.From
+	}
+	if body.Size != nil {
+		size = *body.Size
+	}
+	if from < 0 || size < 0 {
+		return 0, nil, badRequest("illegal_argument_exception", "[from] and [size] must not be negative")
+	}
+	hits = hits[min(from, len(hits)):min(from+size, len(hits))]
+
+	out := make([]any, 0, len(hits))
+	for _, h := range hits {
+		item := map[string]any{"_index": h.idx.name, "_type": "_doc", "_id": h.doc.id, "_score": 1.0, "_source": h.doc.source}
+		if len(keys) > 0 {
+			item["_score"] = nil
+			item["sort"] = h.sort
+		}
+		if body.SeqNoPrimaryTerm {
+			item["_seq_no"], item["_primary_term"] = h.doc.seqNo, primaryTerm
+		}
+		if body.Version {
+			item["_version"] = h.doc.version
+		}
+		out = append(out, item)
+	}
+
+	resp := map[string]any{
+		"took":      0,
+		"timed_out": false,
+		"_shards":   map[string]any{"total": 1, "successful": 1, "skipped": 0, "failed": 0},
+		"hits": map[string]any{
+			"total":     map[string]any{"value": total, "relation": "eq"},
+			"max_score": maxScore(len(out) > 0 && len(keys) == 0),
+			"hits":      out,
+		},
+	}
+	if aggResults != nil {
+		resp["aggregations"] = aggResults
+	}
+	return http.StatusOK, resp, nil
+}
+
+func maxScore(scored bool) any {
+	if scored {
+		return 1.0
+	}
+	return nil
+}
+
+// parseSort reads the forms "field", {"field": "desc"} and {"field": {"order": "desc"}}, alone or
+// in an array. _score is ignored, as every hit scores the same, and _doc keeps indexing order.
+func parseSort(raw any) ([]sortKey, error) {
+	var list []any
+	switch v := raw.(type) {
+	case nil:
+		return nil, nil
+	case []any:
+		list = v
+	default:
+		list = []any{v}
+	}
+
+	var keys []sortKey
+	for _, entry := range list {
+		switch e := entry.(type) {
+		case string:
+			keys = append(keys, sortKey{field: e})
+		case map[string]any:
+			for field, opts := range e {
+				key := sortKey{field: field}
+				switch o := opts.(type) {
+				case string:
+					key.desc = o == "desc"
+				case map[string]any:
+					key.desc = o["order"] == "desc"
+					key.missingFirst = o["missing"] == "_first"
+				default:
+					return nil, parsingError("[sort] malformed for [%s]", field)
+				}
+				keys = append(keys, key)
+			}
+		default:
+			return nil, parsingError("[sort] malformed")
+		}
+	}
+
+	out := keys[:0]
+	for _, key := range keys {
+		if key.field != "_score" && key.field != "_doc" {
+			out = append(out, key)
+		}
+	}
+	return out, nil
+}
+
+func sortValues(h hit, keys []sortKey) []any {
+	values := make([]any, len(keys))
+	for i, key := range keys {
+		if v := lookupField(h.doc.fields, key.field); len(v) > 0 {
+			values[i] = v[0]
+		}
+	}
+	return values
+}
+
+// compareSortValues orders h against the sort values other; missing values go last unless
+// the key asks for them first.
+func compareSortValues(h hit, other []any, keys []sortKey) int {
+	for i, key := range keys {
+		a, b := h.sort[i], other[i]
+		var c int
+		switch {
+		case a == nil && b == nil:
+			continue
+		case a == nil:
+			if key.missingFirst {
+				return -1
+			}
+			return 1
+		case b == nil:
+			if key.missingFirst {
+				return 1
+			}
+			return -1
+		default:
+			c, _ = compareAs(h.idx.fieldType(key.field), a, b)
+		}
+		if key.desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
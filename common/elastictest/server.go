@@ -0,0 +1,326 @@
+// Package elastictest is an in-process stand-in for Elasticsearch, served over httptest, so
+// code built on *elastic.Client can be tested offline.
+//
+// It implements the subset of the REST API this application uses: index, get and delete of
+// documents (with optimistic concurrency on _seq_no), _bulk, _search and _count with the term,
+// terms, range, match, exists, wildcard, prefix, ids and bool queries, sorting, paging and
+// terms aggregations, index creation with mappings, composable and legacy index templates,
+// aliases with a write index, settings updates with write blocks, and _reindex. Writes are
+// visible to searches at once.
+//
+// Fields mapped as text are tokenized on letters and digits and lowercased; every other
+// string field compares as a keyword, including fields that have no mapping. Relevance is not
+// scored: hits come back in indexing order unless a sort is given. Anything else is answered
+// with a 400 naming the request, so a test never passes against a feature that is missing.
+package elastictest
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/olivere/elastic/v7"
+)
+
+// version is the Elasticsearch version the server reports.
+const version = "7.17.0"
+
+// Server is a fake Elasticsearch cluster with one node and one shard per index.
+type Server struct {
+	// URL is the base URL of the server, for elastic.SetURL or config.Elastic.URLs.
+	URL string
+
+	t    testing.TB
+	http *httptest.Server
+
+	mu              sync.Mutex
+	indices         map[string]*index
+	templates       map[string]map[string]any
+	legacyTemplates map[string]map[string]any
+}
+
+// NewServer starts a server that is closed when the test ends.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+	s := &Server{
+		t:               t,
+		indices:         map[string]*index{},
+		templates:       map[string]map[string]any{},
+		legacyTemplates: map[string]map[string]any{},
+	}
+	s.http = httptest.NewServer(s)
+	s.URL = s.http.URL
+	t.Cleanup(s.http.Close)
+	return s
+}
+
+// Client returns a client for the server, configured like configs.ConnectElasticsearch does:
+// no sniffing, as the server cannot be reached through the node addresses it would report.
+func (s *Server) Client(opts ...elastic.ClientOptionFunc) *elastic.Client {
+	s.t.Helper()
+	opts = append([]elastic.ClientOptionFunc{
+		elastic.SetURL(s.URL),
+		elastic.SetSniff(false),
+		elastic.SetHealthcheck(false),
+	}, opts...)
+
+	client, err := elastic.NewClient(opts...)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return client
+}
+
+// Documents returns the sources of the documents reachable through name, an index, an alias
+// or a pattern, in indexing order. It is empty when name matches nothing.
+func (s *Server) Documents(name string) []json.RawMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	indices, err := s.resolve(name, true)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	var out []json.RawMessage
+	for _, idx := range indices {
+		for _, doc := range idx.documents() {
+			out = append(out, doc.source)
+		}
+	}
+	return out
+}
+
+// ServeHTTP routes a REST request to its handler. Handlers run one at a time.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := readBody(r)
+	if err != nil {
+		writeError(w, badRequest("parse_exception", err.Error()))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status, resp, err := s.route(r, strings.Split(strings.Trim(r.URL.Path, "/"), "/"), body)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, r, status, resp)
+}
+
+func (s *Server) route(r *http.Request, seg []string, body []byte) (int, any, error) {
+	method, params := r.Method, r.URL.Query()
+	unsupported := badRequest("unsupported_operation_exception",
+		fmt.Sprintf("elastictest does not implement %s %s", method, r.URL.Path))
+
+	if seg[0] == "" {
+		return http.StatusOK, map[string]any{
+			"name":         "elastictest",
+			"cluster_name": "elastictest",
+			"version":      map[string]any{"number": version},
+			"tagline":      "You Know, for Search",
+		}, nil
+	}
+
+	switch seg[0] {
+	case "_bulk":
+		return s.bulk("", body)
+	case "_search":
+		return s.search("", body)
+	case "_count":
+		return s.count("", body)
+	case "_refresh":
+		return http.StatusOK, shardsOK(), nil
+	case "_reindex":
+		if method != http.MethodPost {
+			return 0, nil, unsupported
+		}
+		return s.reindex(body)
+	case "_aliases":
+		switch method {
+		case http.MethodPost:
+			return s.updateAliases(body)
+		case http.MethodGet:
+			return s.getAliases("", "")
+		}
+		return 0, nil, unsupported
+	case "_alias":
+		if method != http.MethodGet {
+			return 0, nil, unsupported
+		}
+		return s.getAliases("", segment(seg, 1))
+	case "_index_template", "_template":
+		if len(seg) != 2 {
+			return 0, nil, unsupported
+		}
+		return s.template(method, seg[0] == "_template", seg[1], body)
+	}
+	if strings.HasPrefix(seg[0], "_") {
+		return 0, nil, unsupported
+	}
+
+	name := seg[0]
+	if len(seg) == 1 {
+		switch method {
+		case http.MethodPut:
+			return s.createIndexRequest(name, body)
+		case http.MethodDelete:
+			return s.deleteIndex(name)
+		case http.MethodHead:
+			if _, err := s.resolve(name, false); err != nil {
+				return http.StatusNotFound, nil, nil
+			}
+			return http.StatusOK, nil, nil
+		case http.MethodGet:
+			return s.getIndex(name, false)
+		}
+		return 0, nil, unsupported
+	}
+
+	switch seg[1] {
+	case "_doc", "_create":
+		op := params.Get("op_type")
+		if seg[1] == "_create" {
+			op = "create"
+		}
+		switch {
+		case len(seg) == 2 && method == http.MethodPost:
+			return s.indexRequest(name, "", op, params, body)
+		case len(seg) != 3:
+		case method == http.MethodPut || method == http.MethodPost:
+			return s.indexRequest(name, seg[2], op, params, body)
+		case method == http.MethodGet || method == http.MethodHead:
+			return s.getDocument(name, seg[2])
+		case method == http.MethodDelete:
+			return s.deleteDocument(name, seg[2], params)
+		}
+	case "_update":
+		if len(seg) == 3 && method == http.MethodPost {
+			return s.updateRequest(name, seg[2], body)
+		}
+	case "_bulk":
+		return s.bulk(name, body)
+	case "_search":
+		return s.search(name, body)
+	case "_count":
+		return s.count(name, body)
+	case "_refresh":
+		if _, err := s.resolve(name, false); err != nil {
+			return 0, nil, err
+		}
+		return http.StatusOK, shardsOK(), nil
+	case "_mapping":
+		if method == http.MethodGet {
+			return s.getIndex(name, true)
+		}
+	case "_settings":
+		if method == http.MethodPut {
+			return s.putSettings(name, body)
+		}
+	case "_alias":
+		if method == http.MethodGet {
+			return s.getAliases(name, segment(seg, 2))
+		}
+	}
+	return 0, nil, unsupported
+}
+
+func segment(seg []string, i int) string {
+	if i < len(seg) {
+		return seg[i]
+	}
+	return ""
+}
+
+// readBody returns the request body, which the client may gzip.
+func readBody(r *http.Request) ([]byte, error) {
+	var reader io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+	return io.ReadAll(reader)
+}
+
+// decodeBody unmarshals a JSON request body; an empty body leaves v untouched.
+func decodeBody(body []byte, v any) error {
+	if len(strings.TrimSpace(string(body))) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return badRequest("parse_exception", "request body is not valid JSON: "+err.Error())
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	if r.Method == http.MethodHead || v == nil {
+		return
+	}
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func shardsOK() map[string]any {
+	return map[string]any{"_shards": map[string]any{"total": 1, "successful": 1, "failed": 0}}
+}
+
+// apiError is an error response in the shape Elasticsearch uses, which elastic.IsNotFound and
+// elastic.IsConflict recognise.
+type apiError struct {
+	status int
+	typ    string
+	reason string
+	extra  map[string]any
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s: %s", e.typ, e.reason)
+}
+
+func badRequest(typ, reason string) *apiError {
+	return &apiError{status: http.StatusBadRequest, typ: typ, reason: reason}
+}
+
+func indexNotFound(name string) *apiError {
+	return &apiError{status: http.StatusNotFound, typ: "index_not_found_exception", reason: "no such index [" + name + "]",
+		extra: map[string]any{"index": name, "resource.id": name, "resource.type": "index_or_alias"}}
+}
+
+func versionConflict(id, reason string) *apiError {
+	return &apiError{status: http.StatusConflict, typ: "version_conflict_engine_exception", reason: "[" + id + "]: version conflict, " + reason}
+}
+
+func (e *apiError) body() map[string]any {
+	cause := map[string]any{"type": e.typ, "reason": e.reason}
+	for k, v := range e.extra {
+		cause[k] = v
+	}
+	detail := map[string]any{"root_cause": []any{cause}}
+	for k, v := range cause {
+		detail[k] = v
+	}
+	return detail
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	e, ok := err.(*apiError)
+	if !ok {
+		e = &apiError{status: http.StatusInternalServerError, typ: "exception", reason: err.Error()}
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(e.status)
+	_ = json.NewEncoder(w).Encode(map[string]any{"error": e.body(), "status": e.status})
+}
@@ -0,0 +1,314 @@
+package elastictest
+
+import (
+	"context"
+	"elastic-logger-app/configs"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/olivere/elastic/v7"
+)
+
+type entry struct {
+	Timestamp string `json:"@timestamp"`
+	Level     string `json:"level"`
+	Logger    string `json:"logger"`
+	Message   string `json:"message"`
+	Status    int    `json:"status,omitempty"`
+}
+
+var entries = []entry{
+	{"2025-01-01T10:00:00.000Z", "info", "server", "server start listening", 0},
+	{"2025-01-01T10:00:01.000Z", "info", "http", "GET /api/v1/accounts", 200},
+	{"2025-01-01T10:00:02.000Z", "error", "http", "POST /api/v1/accounts failed", 500},
+	{"2025-01-01T10:00:03.000Z", "warn", "http", "GET /api/v1/accounts slow", 200},
+	{"2025-01-01T10:00:04.000Z", "error", "account", "cannot insert account", 0},
+}
+
+const logsMapping = `{"mappings": {"properties": {
+	"@timestamp": {"type": "date"}, "level": {"type": "keyword"}, "logger": {"type": "keyword"},
+	"message": {"type": "text"}, "status": {"type": "integer"}}}}`
+
+func seed(t *testing.T, client *elastic.Client, index string) {
+	t.Helper()
+	bulk := client.Bulk().Index(index)
+	for _, e := range entries {
+		bulk.Add(elastic.NewBulkIndexRequest().Doc(e))
+	}
+	res, err := bulk.Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Errors || len(res.Items) != len(entries) {
+		t.Fatalf("bulk errors = %v, items = %d", res.Errors, len(res.Items))
+	}
+}
+
+func messages(t *testing.T, res *elastic.SearchResult) []string {
+	t.Helper()
+	var out []string
+	for _, hit := range res.Hits.Hits {
+		var e entry
+		if err := json.Unmarshal(hit.Source, &e); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, e.Message)
+	}
+	return out
+}
+
+func TestDocumentsWithConcurrencyControl(t *testing.T) {
+	ctx := context.Background()
+	client := NewServer(t).Client()
+
+	created, err := client.Index().Index("state").Id("lock").OpType("create").BodyJson(map[string]string{"owner": "a"}).Do(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Index().Index("state").Id("lock").OpType("create").BodyJson(map[string]string{"owner": "b"}).Do(ctx); !elastic.IsConflict(err) {
+		t.Fatalf("second create: %v", err)
+	}
+
+	got, err := client.Get().Index("state").Id("lock").Do(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got.SeqNo != created.SeqNo || string(got.Source) != `{"owner":"a"}` {
+		t.Fatalf("get = seq %d source %s", *got.SeqNo, got.Source)
+	}
+
+	if _, err := client.Index().Index("state").Id("lock").IfSeqNo(*got.SeqNo).IfPrimaryTerm(*got.PrimaryTerm).
+		BodyJson(map[string]string{"owner": "c"}).Do(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Delete().Index("state").Id("lock").IfSeqNo(*got.SeqNo).IfPrimaryTerm(*got.PrimaryTerm).Do(ctx); !elastic.IsConflict(err) {
+		t.Fatalf("delete with a stale seq_no: %v", err)
+	}
+
+	if _, err := client.Delete().Index("state").Id("lock").Do(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Get().Index("state").Id("lock").Do(ctx); !elastic.IsNotFound(err) {
+		t.Fatalf("get after delete: %v", err)
+	}
+	if _, err := client.Get().Index("missing").Id("lock").Do(ctx); !elastic.IsNotFound(err) {
+		t.Fatalf("get from a missing index: %v", err)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+	client := NewServer(t).Client()
+	if _, err := client.CreateIndex("logs").BodyString(logsMapping).Do(ctx); err != nil {
+		t.Fatal(err)
+	}
+	seed(t, client, "logs")
+
+	cases := []struct {
+		name  string
+		query elastic.Query
+		want  []string
+	}{
+		{"term on keyword", elastic.NewTermQuery("level", "error"),
+			[]string{"cannot insert account", "POST /api/v1/accounts failed"}},
+		{"terms and range", elastic.NewBoolQuery().
+			Filter(elastic.NewTermsQueryFromStrings("level", "info", "warn")).
+			Filter(elastic.NewRangeQuery("@timestamp").Gt("2025-01-01T10:00:01Z")),
+			[]string{"GET /api/v1/accounts slow"}},
+		{"match on text", elastic.NewMatchQuery("message", "Accounts GET"),
+			[]string{"GET /api/v1/accounts slow", "POST /api/v1/accounts failed", "GET /api/v1/accounts"}},
+		{"match all words", elastic.NewMatchQuery("message", "accounts get").Operator("and"),
+			[]string{"GET /api/v1/accounts slow", "GET /api/v1/accounts"}},
+		{"range on integer", elastic.NewRangeQuery("status").Gte(500), []string{"POST /api/v1/accounts failed"}},
+		{"must not and exists", elastic.NewBoolQuery().
+			Must(elastic.NewExistsQuery("status")).
+			MustNot(elastic.NewTermQuery("logger", "http")), nil},
+	}
+	for _, tc := range cases {
+		res, err := client.Search("logs").Query(tc.query).Sort("@timestamp", false).Do(ctx)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got := messages(t, res); strings.Join(got, "|") != strings.Join(tc.want, "|") || res.TotalHits() != int64(len(tc.want)) {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+
+	res, err := client.Search("logs").Sort("@timestamp", true).From(1).Size(2).Do(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := messages(t, res); res.TotalHits() != 5 || strings.Join(got, "|") != "GET /api/v1/accounts|POST /api/v1/accounts failed" {
+		t.Fatalf("page = %q of %d", got, res.TotalHits())
+	}
+
+	if n, err := client.Count("logs").Query(elastic.NewTermQuery("logger", "http")).Do(ctx); err != nil || n != 3 {
+		t.Fatalf("count = %d, %v", n, err)
+	}
+	if _, err := client.Search("nope").Do(ctx); !elastic.IsNotFound(err) {
+		t.Fatalf("search of a missing index: %v", err)
+	}
+}
+
+func TestTermsAggregation(t *testing.T) {
+	ctx := context.Background()
+	client := NewServer(t).Client()
+	if _, err := client.CreateIndex("logs").BodyString(logsMapping).Do(ctx); err != nil {
+		t.Fatal(err)
+	}
+	seed(t, client, "logs")
+
+	res, err := client.Search("logs").Size(0).
+		Aggregation("levels", elastic.NewTermsAggregation().Field("level").
+			SubAggregation("loggers", elastic.NewCardinalityAggregation().Field("logger"))).
+		Do(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	levels, ok := res.Aggregations.Terms("levels")
+	if !ok {
+		t.Fatal("no levels aggregation")
+	}
+	var got []string
+	for _, b := range levels.Buckets {
+		loggers, _ := b.Cardinality("loggers")
+		got = append(got, b.Key.(string)+"="+strings.Repeat("#", int(b.DocCount))+"/"+strings.Repeat("l", int(*loggers.Value)))
+	}
+	if want := "error=##/ll info=##/ll warn=#/l"; strings.Join(got, " ") != want {
+		t.Fatalf("buckets = %s, want %s", strings.Join(got, " "), want)
+	}
+
+	_, err = client.Search("logs").Aggregation("words", elastic.NewTermsAggregation().Field("message")).Do(ctx)
+	if e, ok := err.(*elastic.Error); !ok || e.Status != http.StatusBadRequest {
+		t.Fatalf("terms on a text field: %v", err)
+	}
+}
+
+func TestTemplatesAliasesAndReindex(t *testing.T) {
+	ctx := context.Background()
+	srv := NewServer(t)
+	client := srv.Client()
+
+	_, err := client.IndexPutIndexTemplate("logs").BodyString(`{
+		"index_patterns": ["logs-*"],
+		"template": {"mappings": {"properties": {"message": {"type": "text"}}}, "aliases": {"logs": {}}}
+	}`).Do(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seed(t, client, "logs-2025.01")
+
+	mapping, err := client.GetMapping().Index("logs-2025.01").Do(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(toJSON(t, mapping), `"message":{"type":"text"}`) {
+		t.Fatalf("template mapping not applied: %s", toJSON(t, mapping))
+	}
+
+	// The template put the new index behind the logs alias; the text mapping tokenizes messages.
+	res, err := client.Search("logs").Query(elastic.NewTermQuery("message", "insert")).Do(ctx)
+	if err != nil || res.TotalHits() != 1 {
+		t.Fatalf("term on text through alias = %v, %v", res, err)
+	}
+
+	if _, err := client.CreateIndex("archive").Do(ctx); err != nil {
+		t.Fatal(err)
+	}
+	reindexed, err := client.Reindex().SourceIndex("logs").DestinationIndex("archive").Refresh("true").Do(ctx)
+	if err != nil || reindexed.Created != int64(len(entries)) {
+		t.Fatalf("reindex = %+v, %v", reindexed, err)
+	}
+
+	_, err = client.Alias().Action(
+		elastic.NewAliasRemoveAction("logs").Index("logs-2025.01"),
+		elastic.NewAliasAddAction("logs").Index("archive").IsWriteIndex(true),
+	).Do(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aliases, err := client.Aliases().Alias("logs").Do(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := aliases.IndicesByAlias("logs"); len(got) != 1 || got[0] != "archive" {
+		t.Fatalf("logs alias points at %v", got)
+	}
+	if _, err := client.Aliases().Alias("nope").Do(ctx); !elastic.IsNotFound(err) {
+		t.Fatalf("missing alias: %v", err)
+	}
+
+	if _, err := client.Index().Index("logs").BodyJson(entries[0]).Do(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(srv.Documents("archive")); n != len(entries)+1 {
+		t.Fatalf("write through the alias did not reach the write index: %d documents", n)
+	}
+}
+
+func TestWriteBlock(t *testing.T) {
+	ctx := context.Background()
+	srv := NewServer(t)
+	client := srv.Client()
+	seed(t, client, "logs")
+
+	if _, err := client.IndexPutSettings("logs").BodyJson(map[string]any{"index.blocks.write": true}).Do(ctx); err != nil {
+		t.Fatal(err)
+	}
+	_, err := client.Index().Index("logs").BodyJson(entries[0]).Do(ctx)
+	if e, ok := err.(*elastic.Error); !ok || e.Status != http.StatusForbidden || e.Details.Type != "cluster_block_exception" {
+		t.Fatalf("write to a blocked index = %v", err)
+	}
+	// Reads and reindexing out of the index still work.
+	if _, err := client.Reindex().SourceIndex("logs").DestinationIndex("copy").Refresh("true").Do(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(srv.Documents("copy")); n != len(entries) {
+		t.Fatalf("copied %d documents, want %d", n, len(entries))
+	}
+
+	if _, err := client.IndexPutSettings("logs").BodyJson(map[string]any{"index": map[string]any{"blocks": map[string]any{"write": false}}}).Do(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Index().Index("logs").BodyJson(entries[0]).Do(ctx); err != nil {
+		t.Fatalf("write after lifting the block: %v", err)
+	}
+}
+
+func TestConnectElasticsearchAcceptsServer(t *testing.T) {
+	srv := NewServer(t)
+	config := configs.Defaults()
+	config.Elastic.URLs = []string{srv.URL}
+
+	client := configs.ConnectElasticsearch(&config)
+	defer client.Stop()
+
+	info, code, err := client.Ping(srv.URL).Do(context.Background())
+	if err != nil || code != http.StatusOK || info.Version.Number != version {
+		t.Fatalf("ping = %+v, %d, %v", info, code, err)
+	}
+}
+
+func TestUnsupportedRequestFails(t *testing.T) {
+	client := NewServer(t).Client()
+	_, err := client.PerformRequest(context.Background(), elastic.PerformRequestOptions{Method: "POST", Path: "/logs/_delete_by_query"})
+	if e, ok := err.(*elastic.Error); !ok || e.Status != http.StatusBadRequest || !strings.Contains(e.Details.Reason, "_delete_by_query") {
+		t.Fatalf("err = %v", err)
+	}
+
+	_, err = client.Search().Query(elastic.NewFuzzyQuery("message", "acount")).Do(context.Background())
+	if e, ok := err.(*elastic.Error); !ok || e.Details.Type != "parsing_exception" {
+		t.Fatalf("unknown query: %v", err)
+	}
+}
+
+func toJSON(t *testing.T, v any) string {
+	t.Helper()
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(raw)
+}
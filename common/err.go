@@ -1,12 +1,67 @@
 package common
 
 import (
+	"elastic-logger-app/common/i18n"
 	"fmt"
 	"net/http"
 	"runtime"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
+// ErrorKind classifies an AppError independently of its message, so callers can test it
+// with errors.Is(err, common.KindNotFound) through any amount of wrapping.
+type ErrorKind string
+
+func (k ErrorKind) Error() string {
+	return string(k)
+}
+
+const (
+	KindBadRequest    ErrorKind = "bad_request"
+	KindUnauthorized  ErrorKind = "unauthorized"
+	KindForbidden     ErrorKind = "forbidden"
+	KindNotFound      ErrorKind = "not_found"
+	KindConflict      ErrorKind = "conflict"
+	KindUnprocessable ErrorKind = "unprocessable"
+	KindTooLarge      ErrorKind = "too_large"
+	KindInternal      ErrorKind = "internal"
+)
+
+// kindForStatus derives the kind of an error from its HTTP status code.
+func kindForStatus(code int) ErrorKind {
+	switch code {
+	case http.StatusBadRequest:
+		return KindBadRequest
+	case http.StatusUnauthorized:
+		return KindUnauthorized
+	case http.StatusForbidden:
+		return KindForbidden
+	case http.StatusNotFound:
+		return KindNotFound
+	case http.StatusConflict:
+		return KindConflict
+	case http.StatusUnprocessableEntity:
+		return KindUnprocessable
+	case http.StatusRequestEntityTooLarge:
+		return KindTooLarge
+	}
+	if code >= http.StatusInternalServerError {
+		return KindInternal
+	}
+	return KindBadRequest
+}
+
+var captureStack atomic.Bool
+
+// SetCaptureStack makes every new AppError record the full stack trace of its creation.
+// Stack capture costs an allocation per error, so it is off by default; WithStack captures
+// a trace for a single error.
+func SetCaptureStack(enabled bool) {
+	captureStack.Store(enabled)
+}
+
 // AppError represents a structured error for the application.
 // It includes fields for client response, logging, and debugging.
 type AppError struct {
@@ -36,6 +91,17 @@ type AppError struct {
 	// ErrorID: A unique identifier for this specific error instance.
 	// Useful for tracking and searching logs.
 	ErrorID string `json:"error_id,omitempty"`
+	// ErrorCode: The stable catalog code clients can branch on (see catalog.go).
+	// Derived from Code unless the error was built with NewErrorFromCode.
+	ErrorCode ErrorCode `json:"error_code"`
+	// Kind: The sentinel this error matches with errors.Is. Derived from Code unless set with WithKind.
+	Kind ErrorKind `json:"-"`
+	// params: Template parameters of a catalog error, kept to render the message in other locales.
+	params map[string]any
+	// fromCatalog: Whether Message was rendered from the catalog and can be localized.
+	fromCatalog bool
+	// stack: Program counters of the call stack where the error was created, if captured.
+	stack []uintptr
 }
 
 // NewAppError creates a new AppError instance.
@@ -47,6 +113,8 @@ func NewAppError(code int, message string, reason string, flag_location bool) *A
 		ReasonField: reason,
 		Details:     make(map[string]any), // Initialize the map
 		Timestamp:   time.Now().UTC(),
+		ErrorCode:   codeForStatus(code),
+		Kind:        kindForStatus(code),
 	}
 
 	if flag_location {
@@ -56,6 +124,27 @@ func NewAppError(code int, message string, reason string, flag_location bool) *A
 		err.Function = fn
 	}
 
+	if captureStack.Load() {
+		err.stack = callers()
+	}
+
+	return err
+}
+
+// NewErrorFromCode creates an AppError from a catalog entry, with the message rendered from the
+// entry's template and params. The location is always captured. Unknown codes produce an
+// internal error so a typo never reaches clients as a bogus code.
+func NewErrorFromCode(code ErrorCode, params map[string]any) *AppError {
+	entry, ok := LookupErrorCode(code)
+	if !ok {
+		return NewAppError(http.StatusInternalServerError, "An unexpected error occurred.",
+			fmt.Sprintf("unregistered error code %s", code), true)
+	}
+
+	err := NewAppError(entry.Status, i18n.Render(entry.Message, params), string(code), true)
+	err.ErrorCode = code
+	err.params = params
+	err.fromCatalog = true
 	return err
 }
 
@@ -85,9 +174,10 @@ func (e *AppError) StatusCode() int {
 	return e.Code
 }
 
-// WithMessage sets a new message for the error.
+// WithMessage sets a new message for the error. A custom message is not localized.
 func (e *AppError) WithMessage(message string) *AppError {
 	e.Message = message
+	e.fromCatalog = false
 	return e
 }
 
@@ -112,6 +202,44 @@ func (e *AppError) WithDetail(key string, value any) *AppError {
 	return e
 }
 
+// WithKind overrides the kind derived from the status code.
+func (e *AppError) WithKind(kind ErrorKind) *AppError {
+	e.Kind = kind
+	return e
+}
+
+// WithStack records the stack trace at the call site, regardless of SetCaptureStack.
+func (e *AppError) WithStack() *AppError {
+	e.stack = callers()
+	return e
+}
+
+// Unwrap returns the underlying error, so errors.Is and errors.As see through the AppError
+// (e.g. errors.Is(err, sql.ErrNoRows)).
+func (e *AppError) Unwrap() error {
+	return e.Inner
+}
+
+// Is reports whether target is the ErrorKind of this error.
+func (e *AppError) Is(target error) bool {
+	kind, ok := target.(ErrorKind)
+	return ok && e.Kind == kind
+}
+
+// StackTrace formats the captured stack, one "function\n\tfile:line" entry per frame.
+// It returns an empty string when no stack was captured.
+func (e *AppError) StackTrace() string {
+	if len(e.stack) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	for _, frame := range externalFrames(e.stack) {
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+	}
+	return b.String()
+}
+
 // WithErrorID sets a unique identifier for the error instance.
 func (e *AppError) WithErrorID(id string) *AppError {
 	e.ErrorID = id
@@ -122,7 +250,7 @@ func (e *AppError) WithErrorID(id string) *AppError {
 // It provides a string representation of the error, primarily for logging.
 // It includes the underlying error if present.
 func (e *AppError) Error() string {
-	baseMsg := fmt.Sprintf("Code: %d, Message: '%s', Reason: '%s'", e.Code, e.Message, e.ReasonField)
+	baseMsg := fmt.Sprintf("Code: %d, ErrorCode: %s, Message: '%s', Reason: '%s'", e.Code, e.ErrorCode, e.Message, e.ReasonField)
 	if e.Inner != nil {
 		baseMsg += fmt.Sprintf(", Inner: %v", e.Inner)
 	}
@@ -146,23 +274,44 @@ func (e *AppError) FullErrorString() string {
 	return baseMsg
 }
 
-// getCallerInfo retrieves the file, line, and function name of the caller.
-// The 'skip' value is 3 because:
-// 0: getCallerInfo
-// 1: NewAppError (or helper like NewInternalServerError)
-// 2: The function that called NewAppError/NewInternalServerError
-// 3: The actual line in the code where the error was initiated
+// errFile is the path of this file, used to skip the constructor frames when locating the caller.
+var errFile = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return file
+}()
+
+// getCallerInfo retrieves the file, line, and function name of the code that created the error.
+// Frames inside this file (NewAppError and helpers such as NewInternalServerError) are skipped,
+// so the result is the same whether NewAppError is called directly or through a helper.
 func getCallerInfo() (string, int, string) {
-	pc, file, line, ok := runtime.Caller(3) // Adjusted skip
-	if !ok {
+	frames := externalFrames(callers())
+	if len(frames) == 0 {
 		return "unknown", 0, "unknown"
 	}
-	fn := runtime.FuncForPC(pc)
-	var funcName string
-	if fn != nil {
-		funcName = fn.Name()
-		// Optionally, simplify the function name (remove package path)
-		// funcName = filepath.Base(funcName)
+	return frames[0].File, frames[0].Line, frames[0].Function
+}
+
+// callers returns the program counters of the current call stack.
+func callers() []uintptr {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(2, pcs)
+	return pcs[:n]
+}
+
+// externalFrames resolves pcs into frames, dropping the leading frames that belong to this file.
+// Working on resolved frames rather than raw counters keeps inlined constructors from shifting
+// the result.
+func externalFrames(pcs []uintptr) []runtime.Frame {
+	var out []runtime.Frame
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		if len(out) > 0 || frame.File != errFile {
+			out = append(out, frame)
+		}
+		if !more {
+			break
+		}
 	}
-	return file, line, funcName
+	return out
 }
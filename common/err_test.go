@@ -0,0 +1,163 @@
+package common
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestAppErrorUnwrap(t *testing.T) {
+	err := NewInternalServerError("cannot load account", "query failed").WithInner(sql.ErrNoRows)
+	wrapped := fmt.Errorf("repository: %w", err)
+
+	if !errors.Is(wrapped, sql.ErrNoRows) {
+		t.Fatalf("errors.Is(%v, sql.ErrNoRows) = false, want true", wrapped)
+	}
+
+	var apperr *AppError
+	if !errors.As(wrapped, &apperr) {
+		t.Fatalf("errors.As did not find *AppError in %v", wrapped)
+	}
+	if apperr != err {
+		t.Errorf("errors.As returned %p, want %p", apperr, err)
+	}
+}
+
+func TestAppErrorIsKind(t *testing.T) {
+	tests := []struct {
+		name string
+		err  *AppError
+		want ErrorKind
+	}{
+		{"bad request", NewBadRequestError("bad", "bad"), KindBadRequest},
+		{"unauthorized", NewUnauthorizedError("no"), KindUnauthorized},
+		{"forbidden", NewForbiddenError("no"), KindForbidden},
+		{"not found", NewNotFoundError("missing", "missing"), KindNotFound},
+		{"internal", NewInternalServerError("boom", "boom"), KindInternal},
+		{"conflict", NewAppError(http.StatusConflict, "taken", "taken", false), KindConflict},
+		{"explicit kind", NewBadRequestError("bad", "bad").WithKind(KindUnprocessable), KindUnprocessable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wrapped := fmt.Errorf("handler: %w", tt.err)
+			if !errors.Is(wrapped, tt.want) {
+				t.Errorf("errors.Is(err, %s) = false, want true", tt.want)
+			}
+			if tt.want != KindInternal && errors.Is(wrapped, KindInternal) {
+				t.Errorf("errors.Is(err, %s) = true, want false", KindInternal)
+			}
+		})
+	}
+}
+
+func TestAppErrorCallerLocation(t *testing.T) {
+	_, file, line, _ := runtime.Caller(0)
+	direct := NewAppError(http.StatusBadRequest, "direct", "direct", true)
+	helper := NewBadRequestError("helper", "helper")
+
+	tests := []struct {
+		name string
+		err  *AppError
+		line int
+	}{
+		{"direct", direct, line + 1},
+		{"helper", helper, line + 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.err.File != file || tt.err.Line != tt.line {
+				t.Errorf("location = %s:%d, want %s:%d", tt.err.File, tt.err.Line, file, tt.line)
+			}
+			if !strings.HasSuffix(tt.err.Function, "TestAppErrorCallerLocation") {
+				t.Errorf("function = %q, want TestAppErrorCallerLocation", tt.err.Function)
+			}
+		})
+	}
+}
+
+func TestAppErrorStackTrace(t *testing.T) {
+	if st := NewBadRequestError("no stack", "no stack").StackTrace(); st != "" {
+		t.Errorf("stack captured without being enabled:\n%s", st)
+	}
+
+	st := NewBadRequestError("stack", "stack").WithStack().StackTrace()
+	if !strings.HasPrefix(st, "elastic-logger-app/common.TestAppErrorStackTrace") {
+		t.Errorf("stack does not start at the caller:\n%s", st)
+	}
+
+	SetCaptureStack(true)
+	defer SetCaptureStack(false)
+
+	st = NewInternalServerError("global", "global").StackTrace()
+	if !strings.HasPrefix(st, "elastic-logger-app/common.TestAppErrorStackTrace") {
+		t.Errorf("stack does not start at the caller:\n%s", st)
+	}
+	if strings.Contains(st, "common.NewAppError") {
+		t.Errorf("stack includes constructor frames:\n%s", st)
+	}
+}
+
+func TestAsAppErrorClassifiesUntypedErrors(t *testing.T) {
+	if got := AsAppError(errors.New("boom")).StatusCode(); got != http.StatusInternalServerError {
+		t.Errorf("untyped error status = %d, want %d", got, http.StatusInternalServerError)
+	}
+
+	inner := NewNotFoundError("missing", "missing")
+	if got := AsAppError(fmt.Errorf("wrap: %w", inner)); got != inner {
+		t.Errorf("AsAppError did not unwrap to the original AppError")
+	}
+}
+
+func TestNewErrorFromCode(t *testing.T) {
+	err := NewErrorFromCode(CodeIdempotencyKeyReused, map[string]any{"key": "abc"})
+
+	if err.ErrorCode != CodeIdempotencyKeyReused || err.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("got code %s status %d, want %s status %d", err.ErrorCode, err.Code, CodeIdempotencyKeyReused, http.StatusUnprocessableEntity)
+	}
+	if want := "Idempotency key abc was already used with a different request."; err.Message != want {
+		t.Fatalf("Message = %q, want %q", err.Message, want)
+	}
+	if !strings.HasSuffix(err.File, "err_test.go") {
+		t.Fatalf("File = %q, want the caller in err_test.go", err.File)
+	}
+
+	unknown := NewErrorFromCode("NO_SUCH_CODE", nil)
+	if unknown.ErrorCode != CodeInternal {
+		t.Fatalf("unknown code produced %s, want %s", unknown.ErrorCode, CodeInternal)
+	}
+}
+
+func TestHelpersCarryGenericCodes(t *testing.T) {
+	if got := NewNotFoundError("missing", "no row").ErrorCode; got != CodeNotFound {
+		t.Fatalf("NewNotFoundError code = %s, want %s", got, CodeNotFound)
+	}
+	if got := NewInternalServerError("boom", "boom").ErrorCode; got != CodeInternal {
+		t.Fatalf("NewInternalServerError code = %s, want %s", got, CodeInternal)
+	}
+}
+
+func TestAppErrorLocalize(t *testing.T) {
+	err := NewErrorFromCode(CodeIdempotencyKeyReused, map[string]any{"key": "abc"})
+
+	vi := err.Localize("vi")
+	if want := "Idempotency key abc đã được dùng cho một yêu cầu khác."; vi.Message != want {
+		t.Fatalf("vi Message = %q, want %q", vi.Message, want)
+	}
+	if vi.ErrorCode != CodeIdempotencyKeyReused {
+		t.Fatalf("localizing changed the code to %s", vi.ErrorCode)
+	}
+	if err.Message == vi.Message {
+		t.Fatal("Localize modified the original error")
+	}
+
+	custom := NewBadRequestError("custom text", "reason")
+	if got := custom.Localize("vi").Message; got != "custom text" {
+		t.Fatalf("hand-written message was translated to %q", got)
+	}
+}
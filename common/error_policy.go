@@ -0,0 +1,66 @@
+package common
+
+import "sync"
+
+// ErrorPolicy decides which parts of an AppError are sent to clients. The full error,
+// including location and the Inner chain, is always kept for the log sink.
+type ErrorPolicy struct {
+	// ExposeLocation sends File, Line and Function to clients. Enable only in development.
+	ExposeLocation bool
+	// ExposeReason sends ReasonField, which often carries driver or parser messages, to
+	// clients. Enable only in development.
+	ExposeReason bool
+	// PublicDetailKeys lists the Details keys clients may see; every other key is dropped.
+	PublicDetailKeys []string
+}
+
+// DefaultErrorPolicy hides location and reason and exposes only field-level validation errors and
+// the idempotency key the client sent.
+var DefaultErrorPolicy = ErrorPolicy{
+	ExposeLocation:   false,
+	ExposeReason:     false,
+	PublicDetailKeys: []string{"fields", "idempotency_key"},
+}
+
+var (
+	errorPolicyMu sync.RWMutex
+	errorPolicy   = DefaultErrorPolicy
+)
+
+// SetErrorPolicy replaces the policy applied by ResponseError.
+func SetErrorPolicy(p ErrorPolicy) {
+	errorPolicyMu.Lock()
+	defer errorPolicyMu.Unlock()
+	errorPolicy = p
+}
+
+func currentErrorPolicy() ErrorPolicy {
+	errorPolicyMu.RLock()
+	defer errorPolicyMu.RUnlock()
+	return errorPolicy
+}
+
+// Redact returns a copy of e that is safe to send to clients under p. e itself is left
+// untouched so it can still be logged in full.
+func (e *AppError) Redact(p ErrorPolicy) *AppError {
+	out := *e
+	out.Inner = nil
+
+	if !p.ExposeLocation {
+		out.File = ""
+		out.Line = 0
+		out.Function = ""
+	}
+	if !p.ExposeReason {
+		out.ReasonField = ""
+	}
+
+	out.Details = make(map[string]any, len(p.PublicDetailKeys))
+	for _, key := range p.PublicDetailKeys {
+		if v, ok := e.Details[key]; ok {
+			out.Details[key] = v
+		}
+	}
+
+	return &out
+}
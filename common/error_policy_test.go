@@ -0,0 +1,59 @@
+package common
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAppErrorRedact(t *testing.T) {
+	inner := errors.New("Error 1146: Table 'app.account' doesn't exist")
+	apperr := NewInternalServerError("Something went wrong.", inner.Error()).
+		WithInner(inner).
+		WithDetail("fields", []FieldError{{Field: "email"}}).
+		WithDetail("query", "SELECT * FROM account")
+
+	t.Run("default policy", func(t *testing.T) {
+		public := apperr.Redact(DefaultErrorPolicy)
+
+		if public.Inner != nil {
+			t.Errorf("inner = %v, want nil", public.Inner)
+		}
+		if public.File != "" || public.Line != 0 || public.Function != "" {
+			t.Errorf("location leaked: %s:%d %s", public.File, public.Line, public.Function)
+		}
+		if public.ReasonField != "" {
+			t.Errorf("reason leaked: %q", public.ReasonField)
+		}
+		if _, ok := public.Details["query"]; ok {
+			t.Errorf("non-public detail leaked: %v", public.Details)
+		}
+		if _, ok := public.Details["fields"]; !ok {
+			t.Errorf("public detail dropped: %v", public.Details)
+		}
+		if public.Message != apperr.Message || public.ErrorCode != apperr.ErrorCode {
+			t.Errorf("message or code changed: %q %s", public.Message, public.ErrorCode)
+		}
+	})
+
+	t.Run("development policy", func(t *testing.T) {
+		public := apperr.Redact(ErrorPolicy{ExposeLocation: true, ExposeReason: true})
+
+		if public.File == "" || public.Line == 0 {
+			t.Errorf("location dropped")
+		}
+		if public.ReasonField != apperr.ReasonField {
+			t.Errorf("reason = %q, want %q", public.ReasonField, apperr.ReasonField)
+		}
+		if public.Inner != nil || len(public.Details) != 0 {
+			t.Errorf("inner or details leaked: %v %v", public.Inner, public.Details)
+		}
+	})
+
+	t.Run("original untouched", func(t *testing.T) {
+		apperr.Redact(DefaultErrorPolicy)
+
+		if apperr.Inner != inner || apperr.ReasonField == "" || apperr.File == "" || len(apperr.Details) != 2 {
+			t.Errorf("Redact modified the original: %+v", apperr)
+		}
+	})
+}
@@ -16,25 +16,27 @@ func ResponseSuccess(c *gin.Context, data any) {
 func ResponseCreated(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{
 		"success": true,
-		"message": "Created successfully",
+		"message": LocalizedMessage(c.Request.Context(), "success.created", "Created successfully"),
 	})
 }
 
 func ResponseUpdated(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"message": "Updated successfully",
+		"message": LocalizedMessage(c.Request.Context(), "success.updated", "Updated successfully"),
 	})
 }
 
 func ResponseDeleted(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"message": "Deleted successfully",
+		"message": LocalizedMessage(c.Request.Context(), "success.deleted", "Deleted successfully"),
 	})
 }
 
-func ResponseGetWithPagination(c *gin.Context, data, paging, filters any) {
+// ResponseGetWithPagination responds with one page of data, its paging information and the
+// filters that were applied.
+func ResponseGetWithPagination(c *gin.Context, data any, paging Paging, filters any) {
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data":    data,
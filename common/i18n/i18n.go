@@ -0,0 +1,172 @@
+package i18n
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Supported locales. Messages missing from a locale's bundle fall back to DefaultLocale.
+const (
+	LocaleEN = "en"
+	LocaleVI = "vi"
+
+	DefaultLocale = LocaleEN
+)
+
+//go:embed messages/*.json
+var messageFiles embed.FS
+
+var (
+	bundlesMu sync.RWMutex
+	bundles   = map[string]map[string]string{}
+)
+
+func init() {
+	entries, err := messageFiles.ReadDir("messages")
+	if err != nil {
+		panic(fmt.Sprintf("i18n: read embedded bundles: %v", err))
+	}
+	for _, entry := range entries {
+		raw, err := messageFiles.ReadFile(path.Join("messages", entry.Name()))
+		if err != nil {
+			panic(fmt.Sprintf("i18n: read %s: %v", entry.Name(), err))
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(raw, &messages); err != nil {
+			panic(fmt.Sprintf("i18n: parse %s: %v", entry.Name(), err))
+		}
+		Register(strings.TrimSuffix(entry.Name(), ".json"), messages)
+	}
+}
+
+// Register merges messages into the bundle of locale, overriding existing keys.
+func Register(locale string, messages map[string]string) {
+	bundlesMu.Lock()
+	defer bundlesMu.Unlock()
+
+	bundle, ok := bundles[locale]
+	if !ok {
+		bundle = make(map[string]string, len(messages))
+		bundles[locale] = bundle
+	}
+	for k, v := range messages {
+		bundle[k] = v
+	}
+}
+
+// Lookup returns the message for key in locale, falling back to DefaultLocale.
+func Lookup(locale, key string) (string, bool) {
+	bundlesMu.RLock()
+	defer bundlesMu.RUnlock()
+
+	if msg, ok := bundles[locale][key]; ok {
+		return msg, true
+	}
+	msg, ok := bundles[DefaultLocale][key]
+	return msg, ok
+}
+
+// Translate renders the message for key in locale, filling {name} placeholders from params.
+// It returns fallback when no bundle has the key.
+func Translate(locale, key, fallback string, params map[string]any) string {
+	msg, ok := Lookup(locale, key)
+	if !ok {
+		msg = fallback
+	}
+	return Render(msg, params)
+}
+
+// Render fills {name} placeholders in tmpl from params.
+func Render(tmpl string, params map[string]any) string {
+	if len(params) == 0 {
+		return tmpl
+	}
+
+	pairs := make([]string, 0, len(params)*2)
+	for k, v := range params {
+		pairs = append(pairs, "{"+k+"}", fmt.Sprint(v))
+	}
+	return strings.NewReplacer(pairs...).Replace(tmpl)
+}
+
+// Supported reports whether a bundle exists for locale.
+func Supported(locale string) bool {
+	bundlesMu.RLock()
+	defer bundlesMu.RUnlock()
+
+	_, ok := bundles[locale]
+	return ok
+}
+
+// Negotiate picks the supported locale preferred by an Accept-Language header such as
+// "vi-VN,vi;q=0.9,en;q=0.8". Region subtags are ignored; DefaultLocale is used when
+// nothing matches.
+func Negotiate(acceptLanguage string) string {
+	type candidate struct {
+		locale string
+		q      float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+
+		base, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if q > 0 && Supported(base) {
+			candidates = append(candidates, candidate{base, q})
+		}
+	}
+
+	if len(candidates) == 0 {
+		return DefaultLocale
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	return candidates[0].locale
+}
+
+type localeKey struct{}
+
+// ContextWithLocale returns ctx carrying locale.
+func ContextWithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeKey{}, locale)
+}
+
+// FromContext returns the locale carried by ctx, or DefaultLocale.
+func FromContext(ctx context.Context) string {
+	if locale, ok := ctx.Value(localeKey{}).(string); ok && locale != "" {
+		return locale
+	}
+	return DefaultLocale
+}
+
+// Middleware negotiates the response locale from Accept-Language, stores it in the request
+// context and reports it in Content-Language.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		locale := Negotiate(c.GetHeader("Accept-Language"))
+		c.Request = c.Request.WithContext(ContextWithLocale(c.Request.Context(), locale))
+		c.Header("Content-Language", locale)
+		c.Next()
+	}
+}
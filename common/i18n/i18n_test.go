@@ -0,0 +1,35 @@
+package i18n
+
+import "testing"
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", DefaultLocale},
+		{"vi", LocaleVI},
+		{"vi-VN,vi;q=0.9,en;q=0.8", LocaleVI},
+		{"fr-FR,en;q=0.5,vi;q=0.7", LocaleVI},
+		{"fr, de", DefaultLocale},
+		{"vi;q=0, en", LocaleEN},
+	}
+
+	for _, tt := range tests {
+		if got := Negotiate(tt.header); got != tt.want {
+			t.Errorf("Negotiate(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestTranslateFallsBack(t *testing.T) {
+	if got := Translate(LocaleVI, "success.created", "", nil); got != "Tạo thành công" {
+		t.Fatalf("vi success.created = %q", got)
+	}
+	if got := Translate("fr", "success.created", "", nil); got != "Created successfully" {
+		t.Fatalf("unknown locale did not fall back to %s: %q", DefaultLocale, got)
+	}
+	if got := Translate(LocaleEN, "NO_SUCH_KEY", "hello {name}", map[string]any{"name": "an"}); got != "hello an" {
+		t.Fatalf("fallback template = %q", got)
+	}
+}
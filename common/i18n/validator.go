@@ -0,0 +1,35 @@
+package i18n
+
+import (
+	"fmt"
+
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/vi"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	entranslations "github.com/go-playground/validator/v10/translations/en"
+	vitranslations "github.com/go-playground/validator/v10/translations/vi"
+)
+
+var universal = ut.New(en.New(), en.New(), vi.New())
+
+// RegisterValidatorTranslations installs the en and vi field error messages on v, so
+// validator.FieldError.Translate can render them with ValidationTranslator.
+func RegisterValidatorTranslations(v *validator.Validate) error {
+	enTrans, _ := universal.GetTranslator(LocaleEN)
+	if err := entranslations.RegisterDefaultTranslations(v, enTrans); err != nil {
+		return fmt.Errorf("register en validator translations: %w", err)
+	}
+
+	viTrans, _ := universal.GetTranslator(LocaleVI)
+	if err := vitranslations.RegisterDefaultTranslations(v, viTrans); err != nil {
+		return fmt.Errorf("register vi validator translations: %w", err)
+	}
+	return nil
+}
+
+// ValidationTranslator returns the validator translator for locale, falling back to English.
+func ValidationTranslator(locale string) ut.Translator {
+	trans, _ := universal.GetTranslator(locale)
+	return trans
+}
@@ -0,0 +1,176 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"elastic-logger-app/common"
+	"elastic-logger-app/common/logger"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	HeaderIdempotencyKey = "Idempotency-Key"
+	HeaderReplayed       = "Idempotent-Replayed"
+)
+
+// MaxKeyLength is the longest Idempotency-Key accepted, the width of the idem_key column.
+const MaxKeyLength = 255
+
+// StoredResponse is the first response recorded for an idempotency key.
+type StoredResponse struct {
+	RequestHash string
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	// Completed is false while the first request is still being handled.
+	Completed bool
+}
+
+// KeyStore persists idempotency keys and the response of the request that first used them.
+type KeyStore interface {
+	// Reserve claims key for a request with the given hash. When the key is already taken
+	// and not expired, it returns reserved=false with the stored record.
+	Reserve(ctx context.Context, key, requestHash string, ttl time.Duration) (reserved bool, existing *StoredResponse, err error)
+	// Complete stores the response for a reserved key.
+	Complete(ctx context.Context, key string, statusCode int, contentType string, body []byte) error
+	// Release drops a reserved key so the request can be retried.
+	Release(ctx context.Context, key string) error
+}
+
+// Middleware honors the Idempotency-Key header. The first response for a key is stored
+// against a hash of the request and replayed for later requests with the same key and body.
+// Reusing a key with a different request is rejected with 422, and a key whose first request
+// is still running is rejected with 409. Server errors and panics are not stored so the
+// client can retry. Bodies larger than maxBodyBytes are rejected with 413 before they are read
+// in full, and empty, oversized or non-printable keys with 400 before the body is read at all.
+func Middleware(store KeyStore, ttl time.Duration, maxBodyBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		values := c.Request.Header.Values(HeaderIdempotencyKey)
+		if len(values) == 0 {
+			c.Next()
+			return
+		}
+		key := values[0]
+		if len(values) > 1 || !validKey(key) {
+			common.ResponseError(c, common.NewErrorFromCode(common.CodeBadRequest, nil).
+				WithReason("Idempotency-Key must be a single value of 1 to 255 printable ASCII characters"))
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBodyBytes))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				common.ResponseError(c, common.AsAppError(err))
+			} else {
+				common.ResponseError(c, common.NewBadRequestError("cannot read request body", err.Error()))
+			}
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := requestHash(c, body)
+
+		reserved, existing, err := store.Reserve(c, key, hash, ttl)
+		if err != nil {
+			common.ResponseError(c, common.NewInternalServerError("cannot process idempotency key", "cannot reserve idempotency key").WithInner(err))
+			c.Abort()
+			return
+		}
+
+		if !reserved {
+			switch {
+			case existing.RequestHash != hash:
+				common.ResponseError(c, common.NewErrorFromCode(common.CodeIdempotencyKeyReused, map[string]any{"key": key}).
+					WithReason("request hash does not match the stored request").WithDetail("idempotency_key", key))
+			case !existing.Completed:
+				common.ResponseError(c, common.NewErrorFromCode(common.CodeIdempotencyKeyInProgress, map[string]any{"key": key}).
+					WithReason("idempotency key is reserved but has no stored response").WithDetail("idempotency_key", key))
+			default:
+				c.Header(HeaderReplayed, "true")
+				c.Data(existing.StatusCode, existing.ContentType, existing.Body)
+			}
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+
+		// The key is settled even when a handler panics; the panic keeps unwinding to
+		// common.Recovery, and the key is released so the client can retry.
+		finished := false
+		defer func() {
+			// The request context may be cancelled once the response is written; the key must still be settled.
+			ctx := context.WithoutCancel(c.Request.Context())
+			if !finished || recorder.Status() >= http.StatusInternalServerError {
+				release(ctx, store, key)
+				return
+			}
+			if err := store.Complete(ctx, key, recorder.Status(), recorder.Header().Get("Content-Type"), recorder.body.Bytes()); err != nil {
+				logger.FromContext(ctx).Error("cannot store idempotent response, releasing key", zap.String("idempotency_key", key), zap.Error(err))
+				release(ctx, store, key)
+			}
+		}()
+
+		c.Next()
+		finished = true
+	}
+}
+
+// release drops a reserved key, logging failures: a key left reserved rejects every retry
+// with 409 until it expires.
+func release(ctx context.Context, store KeyStore, key string) {
+	if err := store.Release(ctx, key); err != nil {
+		logger.FromContext(ctx).Error("cannot release idempotency key", zap.String("idempotency_key", key), zap.Error(err))
+	}
+}
+
+// requestHash identifies a request by method, route and body.
+func requestHash(c *gin.Context, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(c.Request.Method + " " + c.Request.URL.Path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder copies everything written to the client so it can be stored.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// validKey rejects keys the stores cannot hold or that would inject arbitrary content into
+// logs and error details.
+func validKey(key string) bool {
+	if key == "" || len(key) > MaxKeyLength {
+		return false
+	}
+	for _, r := range key {
+		if r > unicode.MaxASCII || !unicode.IsPrint(r) || unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
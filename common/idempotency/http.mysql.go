@@ -0,0 +1,83 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// MySQLKeyStore keeps idempotency keys in the idempotency_key table.
+type MySQLKeyStore struct {
+	db *sql.DB
+}
+
+func NewMySQLKeyStore(db *sql.DB) *MySQLKeyStore {
+	return &MySQLKeyStore{db: db}
+}
+
+// Expired keys are taken over in place, resetting the stored response.
+const reserveKey = `INSERT INTO idempotency_key (idem_key, request_hash, created_at, expires_at)
+VALUES (?, ?, ?, ?)
+ON DUPLICATE KEY UPDATE
+    request_hash = IF(expires_at < VALUES(created_at), VALUES(request_hash), request_hash),
+    status_code = IF(expires_at < VALUES(created_at), NULL, status_code),
+    content_type = IF(expires_at < VALUES(created_at), NULL, content_type),
+    response_body = IF(expires_at < VALUES(created_at), NULL, response_body),
+    created_at = IF(expires_at < VALUES(created_at), VALUES(created_at), created_at),
+    expires_at = IF(expires_at < VALUES(created_at), VALUES(expires_at), expires_at)`
+
+func (s *MySQLKeyStore) Reserve(ctx context.Context, key, requestHash string, ttl time.Duration) (bool, *StoredResponse, error) {
+	now := time.Now().UTC()
+	res, err := s.db.ExecContext(ctx, reserveKey, key, requestHash, now, now.Add(ttl))
+	if err != nil {
+		return false, nil, fmt.Errorf("reserve idempotency key: %w", err)
+	}
+
+	// 1 row affected for a new key, 2 for a reclaimed expired one, 0 when a live key exists.
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, nil, fmt.Errorf("reserve idempotency key: %w", err)
+	}
+	if affected > 0 {
+		return true, nil, nil
+	}
+
+	var (
+		stored      StoredResponse
+		statusCode  sql.NullInt64
+		contentType sql.NullString
+	)
+	err = s.db.QueryRowContext(ctx,
+		`SELECT request_hash, status_code, content_type, response_body FROM idempotency_key WHERE idem_key = ?`, key,
+	).Scan(&stored.RequestHash, &statusCode, &contentType, &stored.Body)
+	if err != nil {
+		return false, nil, fmt.Errorf("load idempotency key: %w", err)
+	}
+
+	stored.Completed = statusCode.Valid
+	stored.StatusCode = int(statusCode.Int64)
+	stored.ContentType = contentType.String
+	return false, &stored, nil
+}
+
+func (s *MySQLKeyStore) Complete(ctx context.Context, key string, statusCode int, contentType string, body []byte) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE idempotency_key SET status_code = ?, content_type = ?, response_body = ? WHERE idem_key = ?`,
+		statusCode, contentType, body, key)
+	return err
+}
+
+func (s *MySQLKeyStore) Release(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM idempotency_key WHERE idem_key = ? AND status_code IS NULL`, key)
+	return err
+}
+
+// PurgeExpired deletes keys whose window has passed and returns how many were removed.
+func (s *MySQLKeyStore) PurgeExpired(ctx context.Context) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM idempotency_key WHERE expires_at < ?`, time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,331 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"elastic-logger-app/common"
+	"elastic-logger-app/migration"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-sql-driver/mysql"
+)
+
+// Set to run the key store contract against MySQL as well. It must point at a throwaway
+// database: the contract deletes every idempotency key.
+const envKeyStoreMySQL = "IDEMPOTENCY_MYSQL_DSN"
+
+// memoryKeyStore is a KeyStore kept in a map. failComplete makes Complete fail.
+type memoryKeyStore struct {
+	mu           sync.Mutex
+	keys         map[string]*memoryKey
+	failComplete bool
+}
+
+type memoryKey struct {
+	stored    StoredResponse
+	expiresAt time.Time
+}
+
+func newMemoryKeyStore() *memoryKeyStore {
+	return &memoryKeyStore{keys: map[string]*memoryKey{}}
+}
+
+func (s *memoryKeyStore) Reserve(ctx context.Context, key, requestHash string, ttl time.Duration) (bool, *StoredResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if k, ok := s.keys[key]; ok && now.Before(k.expiresAt) {
+		stored := k.stored
+		return false, &stored, nil
+	}
+	s.keys[key] = &memoryKey{stored: StoredResponse{RequestHash: requestHash}, expiresAt: now.Add(ttl)}
+	return true, nil, nil
+}
+
+func (s *memoryKeyStore) Complete(ctx context.Context, key string, statusCode int, contentType string, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failComplete {
+		return errors.New("store unavailable")
+	}
+	if k, ok := s.keys[key]; ok {
+		k.stored = StoredResponse{RequestHash: k.stored.RequestHash, StatusCode: statusCode, ContentType: contentType, Body: body, Completed: true}
+	}
+	return nil
+}
+
+func (s *memoryKeyStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if k, ok := s.keys[key]; ok && !k.stored.Completed {
+		delete(s.keys, key)
+	}
+	return nil
+}
+
+// handlerFunc lets each test decide what the guarded route does.
+type handlerFunc func(c *gin.Context)
+
+func newRouter(store KeyStore, handle *handlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(common.Recovery())
+	r.POST("/accounts", Middleware(store, time.Hour, 64), func(c *gin.Context) { (*handle)(c) })
+	return r
+}
+
+func post(r http.Handler, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/accounts", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(HeaderIdempotencyKey, key)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestMiddlewareStoresAndReplays(t *testing.T) {
+	calls := 0
+	handle := handlerFunc(func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusCreated, gin.H{"call": calls})
+	})
+	r := newRouter(newMemoryKeyStore(), &handle)
+
+	first := post(r, "k1", `{"email":"a@example.com"}`)
+	if first.Code != http.StatusCreated || first.Header().Get(HeaderReplayed) != "" {
+		t.Fatalf("first = %d %q", first.Code, first.Header().Get(HeaderReplayed))
+	}
+
+	replay := post(r, "k1", `{"email":"a@example.com"}`)
+	if replay.Code != http.StatusCreated || replay.Header().Get(HeaderReplayed) != "true" || replay.Body.String() != first.Body.String() {
+		t.Fatalf("replay = %d %q %s", replay.Code, replay.Header().Get(HeaderReplayed), replay.Body)
+	}
+	if replay.Header().Get("Content-Type") != first.Header().Get("Content-Type") {
+		t.Fatalf("replayed content type = %q", replay.Header().Get("Content-Type"))
+	}
+
+	reused := post(r, "k1", `{"email":"b@example.com"}`)
+	if reused.Code != http.StatusUnprocessableEntity || !strings.Contains(reused.Body.String(), string(common.CodeIdempotencyKeyReused)) {
+		t.Fatalf("reused key = %d %s", reused.Code, reused.Body)
+	}
+
+	if w := post(r, "", `{}`); w.Code != http.StatusCreated || calls != 2 {
+		t.Fatalf("without a key = %d, calls = %d", w.Code, calls)
+	}
+}
+
+func TestMiddlewareRejectsKeyInFlight(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	handle := handlerFunc(func(c *gin.Context) {
+		close(started)
+		<-release
+		c.Status(http.StatusNoContent)
+	})
+	r := newRouter(newMemoryKeyStore(), &handle)
+
+	done := make(chan int)
+	go func() { done <- post(r, "k1", `{}`).Code }()
+	<-started
+
+	w := post(r, "k1", `{}`)
+	close(release)
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), string(common.CodeIdempotencyKeyInProgress)) {
+		t.Fatalf("in flight = %d %s", w.Code, w.Body)
+	}
+	if code := <-done; code != http.StatusNoContent {
+		t.Fatalf("first = %d", code)
+	}
+}
+
+func TestMiddlewareReleasesKeyAfterFailure(t *testing.T) {
+	cases := map[string]handlerFunc{
+		"server error": func(c *gin.Context) { c.Status(http.StatusServiceUnavailable) },
+		"panic":        func(c *gin.Context) { panic("boom") },
+	}
+	for name, fail := range cases {
+		t.Run(name, func(t *testing.T) {
+			handle := fail
+			r := newRouter(newMemoryKeyStore(), &handle)
+
+			if w := post(r, "k1", `{}`); w.Code < http.StatusInternalServerError {
+				t.Fatalf("first = %d", w.Code)
+			}
+
+			handle = func(c *gin.Context) { c.Status(http.StatusCreated) }
+			w := post(r, "k1", `{}`)
+			if w.Code != http.StatusCreated || w.Header().Get(HeaderReplayed) != "" {
+				t.Fatalf("retry = %d %q", w.Code, w.Header().Get(HeaderReplayed))
+			}
+		})
+	}
+}
+
+func TestMiddlewareReleasesKeyWhenCompleteFails(t *testing.T) {
+	store := newMemoryKeyStore()
+	store.failComplete = true
+	calls := 0
+	handle := handlerFunc(func(c *gin.Context) {
+		calls++
+		c.Status(http.StatusCreated)
+	})
+	r := newRouter(store, &handle)
+
+	post(r, "k1", `{}`)
+	if w := post(r, "k1", `{}`); w.Code != http.StatusCreated || calls != 2 {
+		t.Fatalf("retry = %d, calls = %d", w.Code, calls)
+	}
+}
+
+// unreadBody fails the test when the middleware reads it.
+type unreadBody struct{ t *testing.T }
+
+func (b unreadBody) Read(p []byte) (int, error) {
+	b.t.Error("request body was read")
+	return 0, io.EOF
+}
+
+func TestMiddlewareRejectsInvalidKeys(t *testing.T) {
+	cases := map[string][]string{
+		"empty":          {""},
+		"too long":       {strings.Repeat("k", MaxKeyLength+1)},
+		"space":          {"k 1"},
+		"control":        {"k\x001"},
+		"non-ascii":      {"clé"},
+		"several values": {"k1", "k2"},
+	}
+	for name, keys := range cases {
+		t.Run(name, func(t *testing.T) {
+			store := newMemoryKeyStore()
+			handle := handlerFunc(func(c *gin.Context) { t.Error("handler ran") })
+			r := newRouter(store, &handle)
+
+			req := httptest.NewRequest(http.MethodPost, "/accounts", unreadBody{t})
+			req.Header[HeaderIdempotencyKey] = keys
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), string(common.CodeBadRequest)) {
+				t.Fatalf("status = %d %s", w.Code, w.Body)
+			}
+			if len(store.keys) != 0 {
+				t.Fatalf("reserved %d keys", len(store.keys))
+			}
+		})
+	}
+
+	handle := handlerFunc(func(c *gin.Context) { c.Status(http.StatusCreated) })
+	if w := post(newRouter(newMemoryKeyStore(), &handle), strings.Repeat("k", MaxKeyLength), `{}`); w.Code != http.StatusCreated {
+		t.Fatalf("longest key = %d", w.Code)
+	}
+}
+
+func TestMiddlewareLimitsBody(t *testing.T) {
+	handle := handlerFunc(func(c *gin.Context) { c.Status(http.StatusCreated) })
+	r := newRouter(newMemoryKeyStore(), &handle)
+
+	w := post(r, "k1", `{"email":"`+strings.Repeat("a", 100)+`"}`)
+	if w.Code != http.StatusRequestEntityTooLarge || !strings.Contains(w.Body.String(), string(common.CodeBodyTooLarge)) {
+		t.Fatalf("large body = %d %s", w.Code, w.Body)
+	}
+	// Nothing was reserved, so the key is still usable.
+	if w := post(r, "k1", `{}`); w.Code != http.StatusCreated {
+		t.Fatalf("after a rejected body = %d", w.Code)
+	}
+}
+
+// runKeyStoreContract checks the behaviour Middleware relies on from a KeyStore.
+func runKeyStoreContract(t *testing.T, store KeyStore) {
+	ctx := context.Background()
+
+	reserved, _, err := store.Reserve(ctx, "k1", "hash-1", time.Hour)
+	if err != nil || !reserved {
+		t.Fatalf("reserve new key = %v, %v", reserved, err)
+	}
+	reserved, existing, err := store.Reserve(ctx, "k1", "hash-2", time.Hour)
+	if err != nil || reserved || existing.RequestHash != "hash-1" || existing.Completed {
+		t.Fatalf("reserve taken key = %v, %+v, %v", reserved, existing, err)
+	}
+
+	if err := store.Complete(ctx, "k1", http.StatusCreated, "application/json", []byte(`{"ok":true}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Release(ctx, "k1"); err != nil {
+		t.Fatal(err)
+	}
+	_, existing, err = store.Reserve(ctx, "k1", "hash-1", time.Hour)
+	if err != nil || !existing.Completed || existing.StatusCode != http.StatusCreated ||
+		existing.ContentType != "application/json" || string(existing.Body) != `{"ok":true}` {
+		t.Fatalf("completed key = %+v, %v (release must keep completed keys)", existing, err)
+	}
+
+	if reserved, _, err := store.Reserve(ctx, "k2", "hash", time.Hour); err != nil || !reserved {
+		t.Fatalf("reserve k2 = %v, %v", reserved, err)
+	}
+	if err := store.Release(ctx, "k2"); err != nil {
+		t.Fatal(err)
+	}
+	if reserved, _, err := store.Reserve(ctx, "k2", "other", time.Hour); err != nil || !reserved {
+		t.Fatalf("reserve released key = %v, %v", reserved, err)
+	}
+
+	if reserved, _, err := store.Reserve(ctx, "k3", "hash", -time.Second); err != nil || !reserved {
+		t.Fatalf("reserve k3 = %v, %v", reserved, err)
+	}
+	if reserved, _, err := store.Reserve(ctx, "k3", "other", time.Hour); err != nil || !reserved {
+		t.Fatalf("expired key not taken over: %v, %v", reserved, err)
+	}
+}
+
+func TestKeyStoreContractMemory(t *testing.T) {
+	runKeyStoreContract(t, newMemoryKeyStore())
+}
+
+func TestKeyStoreContractMySQL(t *testing.T) {
+	dsn := os.Getenv(envKeyStoreMySQL)
+	if dsn == "" {
+		t.Skipf("set %s to run the key store contract against MySQL", envKeyStoreMySQL)
+	}
+
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ParseTime = true
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	provider, err := migration.NewMySQLProvider(db, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := migration.Run(context.Background(), provider, migration.CommandUp, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("DELETE FROM idempotency_key"); err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewMySQLKeyStore(db)
+	runKeyStoreContract(t, store)
+
+	if n, err := store.PurgeExpired(context.Background()); err != nil || n != 0 {
+		t.Fatalf("purge = %d, %v", n, err)
+	}
+}
@@ -0,0 +1,77 @@
+package idempotency
+
+import (
+	"context"
+	"elastic-logger-app/common/metrics"
+	"elastic-logger-app/common/rabbitmq"
+	"sync/atomic"
+
+	"github.com/streadway/amqp"
+)
+
+// MessageStore remembers which messages a consumer has already processed.
+type MessageStore interface {
+	// Process runs fn unless messageID was already processed by consumer, and records it as processed
+	// when fn succeeds. It reports duplicate=true without calling fn when the message was seen before.
+	Process(ctx context.Context, consumer, messageID string, fn func(ctx context.Context) error) (duplicate bool, err error)
+}
+
+// Stats counts how often a guard skipped a duplicate (hit) or ran the handler (miss).
+type Stats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+}
+
+// Guard deduplicates deliveries for a single consumer by message ID.
+type Guard struct {
+	store    MessageStore
+	consumer string
+	hits     atomic.Int64
+	misses   atomic.Int64
+}
+
+func NewGuard(store MessageStore, consumer string) *Guard {
+	return &Guard{
+		store:    store,
+		consumer: consumer,
+	}
+}
+
+// Wrap returns a handler that runs h at most once per message ID. Duplicates return nil
+// so the consumer acknowledges them without re-running h. Messages without an ID cannot be
+// deduplicated and are always handled.
+func (g *Guard) Wrap(h rabbitmq.HandlerFunc) rabbitmq.HandlerFunc {
+	return func(ctx context.Context, d amqp.Delivery) error {
+		if d.MessageId == "" {
+			g.misses.Add(1)
+			return h(ctx, d)
+		}
+
+		duplicate, err := g.store.Process(ctx, g.consumer, d.MessageId, func(ctx context.Context) error {
+			return h(ctx, d)
+		})
+		if duplicate {
+			g.hits.Add(1)
+			return nil
+		}
+
+		g.misses.Add(1)
+		return err
+	}
+}
+
+// RegisterMetrics exposes the guard's Stats as the idempotency hit and miss counters of its
+// consumer. Call it once per consumer when the guard is built.
+func (g *Guard) RegisterMetrics() error {
+	return metrics.RegisterIdempotencyStats(g.consumer, func() (hits, misses int64) {
+		stats := g.Stats()
+		return stats.Hits, stats.Misses
+	})
+}
+
+func (g *Guard) Stats() Stats {
+	return Stats{
+		Hits:   g.hits.Load(),
+		Misses: g.misses.Load(),
+	}
+}
@@ -0,0 +1,151 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const processedMessageCollection = "processed_messages"
+
+type processedMessage struct {
+	ID          string    `bson:"_id"`
+	Consumer    string    `bson:"consumer"`
+	MessageID   string    `bson:"message_id"`
+	ProcessedAt time.Time `bson:"processed_at"`
+	ExpiresAt   time.Time `bson:"expires_at"`
+}
+
+// MongoMessageStore records processed messages in a collection with a TTL index on expires_at.
+//
+// When transactional is set (replica sets and sharded clusters), the marker is inserted in the
+// same transaction as fn, using the session carried by the context passed to fn. Otherwise the
+// marker is inserted first and removed again if fn fails.
+type MongoMessageStore struct {
+	client        *mongo.Client
+	coll          *mongo.Collection
+	ttl           time.Duration
+	transactional bool
+}
+
+func NewMongoMessageStore(client *mongo.Client, database string, ttl time.Duration, transactional bool) *MongoMessageStore {
+	return &MongoMessageStore{
+		client:        client,
+		coll:          client.Database(database).Collection(processedMessageCollection),
+		ttl:           ttl,
+		transactional: transactional,
+	}
+}
+
+// EnsureIndexes creates the TTL index that lets Mongo expire old markers on its own.
+func (s *MongoMessageStore) EnsureIndexes(ctx context.Context) error {
+	_, err := s.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+// errClaimedInTxn aborts a transaction whose marker insert lost to a concurrent one: the
+// failed insert already aborted it on the server, so it must not be committed or retried.
+var errClaimedInTxn = errors.New("message claimed by a concurrent transaction")
+
+func (s *MongoMessageStore) Process(ctx context.Context, consumer, messageID string, fn func(ctx context.Context) error) (bool, error) {
+	if !s.transactional {
+		return s.process(ctx, consumer, messageID, fn)
+	}
+
+	session, err := s.client.StartSession()
+	if err != nil {
+		return false, fmt.Errorf("start idempotency session: %w", err)
+	}
+	defer session.EndSession(ctx)
+
+	var duplicate bool
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (any, error) {
+		var txErr error
+		duplicate, txErr = s.processInTxn(sc, consumer, messageID, fn)
+		return nil, txErr
+	})
+	if errors.Is(err, errClaimedInTxn) {
+		return true, nil
+	}
+	return duplicate, err
+}
+
+// process claims the marker, then runs fn and removes the marker again if fn fails.
+func (s *MongoMessageStore) process(ctx context.Context, consumer, messageID string, fn func(ctx context.Context) error) (bool, error) {
+	id := consumer + ":" + messageID
+	now := time.Now().UTC()
+
+	// The TTL monitor runs about once a minute, so expired markers are taken over explicitly.
+	res, err := s.coll.UpdateOne(ctx,
+		bson.M{"_id": id, "expires_at": bson.M{"$lt": now}},
+		bson.M{"$set": bson.M{"processed_at": now, "expires_at": now.Add(s.ttl)}},
+	)
+	if err != nil {
+		return false, fmt.Errorf("claim message %s: %w", messageID, err)
+	}
+
+	if res.ModifiedCount == 0 {
+		_, err = s.coll.InsertOne(ctx, s.marker(id, consumer, messageID, now))
+		if mongo.IsDuplicateKeyError(err) {
+			return true, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("claim message %s: %w", messageID, err)
+		}
+	}
+
+	if err := fn(ctx); err != nil {
+		if _, delErr := s.coll.DeleteOne(context.WithoutCancel(ctx), bson.M{"_id": id}); delErr != nil {
+			return false, errors.Join(err, fmt.Errorf("release message %s: %w", messageID, delErr))
+		}
+		return false, err
+	}
+	return false, nil
+}
+
+// processInTxn looks the marker up before writing it, inside the transaction of ctx: a
+// duplicate key error would abort the transaction, so the insert is only tried for markers
+// that do not exist yet. fn's writes and the marker commit or abort together.
+func (s *MongoMessageStore) processInTxn(ctx context.Context, consumer, messageID string, fn func(ctx context.Context) error) (bool, error) {
+	id := consumer + ":" + messageID
+	now := time.Now().UTC()
+
+	var existing processedMessage
+	err := s.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&existing)
+	switch {
+	case err == nil && existing.ExpiresAt.After(now):
+		return true, nil
+	case err == nil:
+		// Expired but not yet removed by the TTL monitor.
+		_, err = s.coll.UpdateOne(ctx, bson.M{"_id": id},
+			bson.M{"$set": bson.M{"processed_at": now, "expires_at": now.Add(s.ttl)}})
+	case errors.Is(err, mongo.ErrNoDocuments):
+		_, err = s.coll.InsertOne(ctx, s.marker(id, consumer, messageID, now))
+		if mongo.IsDuplicateKeyError(err) {
+			return false, errClaimedInTxn
+		}
+	}
+	if err != nil {
+		return false, fmt.Errorf("claim message %s: %w", messageID, err)
+	}
+
+	return false, fn(ctx)
+}
+
+func (s *MongoMessageStore) marker(id, consumer, messageID string, now time.Time) processedMessage {
+	return processedMessage{
+		ID:          id,
+		Consumer:    consumer,
+		MessageID:   messageID,
+		ProcessedAt: now,
+		ExpiresAt:   now.Add(s.ttl),
+	}
+}
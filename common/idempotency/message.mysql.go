@@ -0,0 +1,76 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"elastic-logger-app/common"
+	"fmt"
+	"time"
+)
+
+// MySQLMessageStore records processed messages in the processed_message table.
+//
+// The marker row is written in the same transaction fn runs in (available through
+// common.TxFromContext), so the duplicate check commits or rolls back together with
+// the handler's own writes. A concurrent delivery of the same message blocks on the
+// row lock until the first one finishes.
+type MySQLMessageStore struct {
+	db  *sql.DB
+	ttl time.Duration
+}
+
+func NewMySQLMessageStore(db *sql.DB, ttl time.Duration) *MySQLMessageStore {
+	return &MySQLMessageStore{
+		db:  db,
+		ttl: ttl,
+	}
+}
+
+// Expired markers are taken over in place, so a message is only treated as a duplicate
+// while its marker is still within the TTL window.
+const claimMessage = `INSERT INTO processed_message (consumer, message_id, processed_at, expires_at)
+VALUES (?, ?, ?, ?)
+ON DUPLICATE KEY UPDATE
+    processed_at = IF(expires_at < VALUES(processed_at), VALUES(processed_at), processed_at),
+    expires_at = IF(expires_at < VALUES(processed_at), VALUES(expires_at), expires_at)`
+
+func (s *MySQLMessageStore) Process(ctx context.Context, consumer, messageID string, fn func(ctx context.Context) error) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("begin idempotency tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	res, err := tx.ExecContext(ctx, claimMessage, consumer, messageID, now, now.Add(s.ttl))
+	if err != nil {
+		return false, fmt.Errorf("claim message %s: %w", messageID, err)
+	}
+
+	// 1 row affected for a new marker, 2 for a reclaimed expired one, 0 when a live marker exists.
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("claim message %s: %w", messageID, err)
+	}
+	if affected == 0 {
+		return true, nil
+	}
+
+	if err := fn(common.ContextWithTx(ctx, tx)); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("commit idempotency tx: %w", err)
+	}
+	return false, nil
+}
+
+// PurgeExpired deletes markers whose TTL has passed and returns how many were removed.
+func (s *MySQLMessageStore) PurgeExpired(ctx context.Context) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM processed_message WHERE expires_at < ?`, time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,187 @@
+package idempotency
+
+import (
+	"context"
+	"elastic-logger-app/common/metrics"
+	"elastic-logger-app/common/rabbitmq"
+	"elastic-logger-app/common/rabbitmqtest"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/streadway/amqp"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Set to run the message store contract against Mongo as well, in a throwaway database. The
+// transactional mode needs a replica set.
+const envMessageStoreMongo = "IDEMPOTENCY_MONGO_URI"
+
+// memoryStore is a MessageStore kept in a map.
+type memoryStore struct {
+	mu        sync.Mutex
+	processed map[string]bool
+}
+
+func (s *memoryStore) Process(ctx context.Context, consumer, messageID string, fn func(ctx context.Context) error) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := consumer + "/" + messageID
+	if s.processed[key] {
+		return true, nil
+	}
+	if err := fn(ctx); err != nil {
+		return false, err
+	}
+	s.processed[key] = true
+	return false, nil
+}
+
+func TestGuardSkipsRedeliveredMessages(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	b := rabbitmqtest.NewBroker()
+	guard := NewGuard(&memoryStore{processed: map[string]bool{}}, "projection")
+	if err := guard.RegisterMetrics(); err != nil {
+		t.Fatal(err)
+	}
+
+	var handled []string
+	cfg := rabbitmq.DefaultConsumerConfig("events", "projection")
+	cfg.Workers = 1
+	c := rabbitmq.NewConsumer(b, cfg)
+	c.Handle("account.created", guard.Wrap(func(ctx context.Context, d amqp.Delivery) error {
+		handled = append(handled, string(d.Body))
+		if len(handled) == 1 {
+			return errors.New("mongo unavailable")
+		}
+		return nil
+	}))
+
+	runCtx, stop := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() { done <- c.Run(runCtx) }()
+	if err := b.WaitConsumers(ctx, "projection", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	ch, err := b.Channel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The same event published twice, e.g. by a relay that crashed before recording it as sent.
+	for range 2 {
+		msg := amqp.Publishing{MessageId: "event-1", Body: []byte("created")}
+		if err := ch.Publish("events", "account.created", false, false, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := b.WaitIdle(ctx); err != nil {
+		t.Fatal(err)
+	}
+	b.Advance(time.Second)
+	if err := b.WaitIdle(ctx); err != nil {
+		t.Fatal(err)
+	}
+	stop()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// The failed first attempt was not recorded, so the copy ran the handler and the retry
+	// of the first delivery was skipped as a duplicate.
+	if len(handled) != 2 {
+		t.Fatalf("handled %d times, want 2", len(handled))
+	}
+	if stats := guard.Stats(); stats.Hits != 1 || stats.Misses != 2 {
+		t.Fatalf("stats = %+v", stats)
+	}
+
+	want := `
+# HELP elastic_logger_app_idempotency_hits_total Duplicate messages acknowledged without running the handler.
+# TYPE elastic_logger_app_idempotency_hits_total counter
+elastic_logger_app_idempotency_hits_total{consumer="projection"} 1
+# HELP elastic_logger_app_idempotency_misses_total Messages handled for the first time.
+# TYPE elastic_logger_app_idempotency_misses_total counter
+elastic_logger_app_idempotency_misses_total{consumer="projection"} 2
+`
+	if err := testutil.GatherAndCompare(metrics.Registry, strings.NewReader(want),
+		"elastic_logger_app_idempotency_hits_total", "elastic_logger_app_idempotency_misses_total"); err != nil {
+		t.Fatal(err)
+	}
+	if dead := b.Messages("projection.dlq"); len(dead) != 0 {
+		t.Fatalf("%d messages dead-lettered", len(dead))
+	}
+}
+
+// runMessageStoreContract checks the behaviour Guard relies on from a MessageStore.
+func runMessageStoreContract(t *testing.T, store MessageStore) {
+	ctx := context.Background()
+	calls := 0
+	count := func(ctx context.Context) error {
+		calls++
+		return nil
+	}
+
+	if duplicate, err := store.Process(ctx, "projection", "m1", count); err != nil || duplicate {
+		t.Fatalf("first delivery = %v, %v", duplicate, err)
+	}
+	if duplicate, err := store.Process(ctx, "projection", "m1", count); err != nil || !duplicate {
+		t.Fatalf("redelivery = %v, %v", duplicate, err)
+	}
+	if calls != 1 {
+		t.Fatalf("handler ran %d times, want 1", calls)
+	}
+	// Markers are per consumer.
+	if duplicate, err := store.Process(ctx, "audit", "m1", count); err != nil || duplicate {
+		t.Fatalf("other consumer = %v, %v", duplicate, err)
+	}
+
+	failure := errors.New("mongo unavailable")
+	if _, err := store.Process(ctx, "projection", "m2", func(ctx context.Context) error { return failure }); !errors.Is(err, failure) {
+		t.Fatalf("failing handler = %v", err)
+	}
+	if duplicate, err := store.Process(ctx, "projection", "m2", count); err != nil || duplicate {
+		t.Fatalf("retry after a failure = %v, %v", duplicate, err)
+	}
+}
+
+func TestMessageStoreContractMemory(t *testing.T) {
+	runMessageStoreContract(t, &memoryStore{processed: map[string]bool{}})
+}
+
+func TestMessageStoreContractMongo(t *testing.T) {
+	uri := os.Getenv(envMessageStoreMongo)
+	if uri == "" {
+		t.Skipf("set %s to run the message store contract against Mongo", envMessageStoreMongo)
+	}
+
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = client.Disconnect(ctx) })
+
+	for name, transactional := range map[string]bool{"compensating": false, "transactional": true} {
+		t.Run(name, func(t *testing.T) {
+			db := "idempotency_contract_" + name
+			if err := client.Database(db).Drop(ctx); err != nil {
+				t.Fatal(err)
+			}
+			store := NewMongoMessageStore(client, db, time.Hour, transactional)
+			if err := store.EnsureIndexes(ctx); err != nil {
+				t.Fatal(err)
+			}
+			runMessageStoreContract(t, store)
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package common
+
+import (
+	"context"
+	"elastic-logger-app/common/i18n"
+)
+
+// Localize returns a copy of e with its message and field errors rendered in locale.
+// Only messages that came from the catalog are translated; a message set by hand with
+// WithMessage is kept as is. The catalog code never changes with the locale.
+func (e *AppError) Localize(locale string) *AppError {
+	out := *e
+
+	if e.fromCatalog {
+		if entry, ok := LookupErrorCode(e.ErrorCode); ok {
+			out.Message = i18n.Translate(locale, string(e.ErrorCode), entry.Message, e.params)
+		}
+	}
+
+	if fields, ok := e.Details["fields"].([]FieldError); ok {
+		translated := make([]FieldError, len(fields))
+		for i, f := range fields {
+			translated[i] = f.localize(locale)
+		}
+		out.Details = make(map[string]any, len(e.Details))
+		for k, v := range e.Details {
+			out.Details[k] = v
+		}
+		out.Details["fields"] = translated
+	}
+
+	return &out
+}
+
+// localize renders the field error message in locale when it came from the validator or
+// carries a bundle key.
+func (f FieldError) localize(locale string) FieldError {
+	switch {
+	case f.err != nil:
+		f.Message = f.err.Translate(i18n.ValidationTranslator(locale))
+	case f.key != "":
+		f.Message = i18n.Translate(locale, f.key, f.Message, f.params)
+	}
+	return f
+}
+
+// LocalizedMessage renders the bundle message for key in the locale of ctx, or fallback
+// when no bundle has it.
+func LocalizedMessage(ctx context.Context, key, fallback string) string {
+	return i18n.Translate(i18n.FromContext(ctx), key, fallback, nil)
+}
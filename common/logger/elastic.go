@@ -0,0 +1,92 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/olivere/elastic/v7"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// ElasticsearchSink indexes log entries into Elasticsearch through a bulk processor,
+// so logging never blocks on a round-trip to the cluster.
+type ElasticsearchSink struct {
+	processor *elastic.BulkProcessor
+	index     string
+	closed    atomic.Bool
+	dropped   atomic.Int64
+}
+
+func NewElasticsearchSink(ctx context.Context, client *elastic.Client, index string) (*ElasticsearchSink, error) {
+	processor, err := client.BulkProcessor().
+		Name("logger").
+		Workers(1).
+		BulkActions(500).
+		BulkSize(5 << 20).
+		FlushInterval(2 * time.Second).
+		Stats(true).
+		Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("start elasticsearch log processor: %w", err)
+	}
+
+	return &ElasticsearchSink{
+		processor: processor,
+		index:     index,
+	}, nil
+}
+
+// Write receives one JSON-encoded entry per call from the zap core.
+func (s *ElasticsearchSink) Write(p []byte) (int, error) {
+	if s.closed.Load() {
+		s.dropped.Add(1)
+		return len(p), nil
+	}
+
+	doc := make(json.RawMessage, len(p))
+	copy(doc, p)
+	s.processor.Add(elastic.NewBulkIndexRequest().Index(s.index).Doc(doc))
+	return len(p), nil
+}
+
+func (s *ElasticsearchSink) Sync() error {
+	if s.closed.Load() {
+		return nil
+	}
+	return s.processor.Flush()
+}
+
+// Close flushes pending entries and stops the bulk processor. Entries written after
+// Close are dropped.
+func (s *ElasticsearchSink) Close() error {
+	if s.closed.Swap(true) {
+		return nil
+	}
+	return s.processor.Close()
+}
+
+// Stats exposes the bulk processor counters, e.g. for queue depth and failures.
+func (s *ElasticsearchSink) Stats() elastic.BulkProcessorStats {
+	return s.processor.Stats()
+}
+
+// Dropped returns how many entries were discarded because the sink was already closed.
+func (s *ElasticsearchSink) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Core returns a zap core that encodes entries as JSON documents into the sink.
+func (s *ElasticsearchSink) Core(level zapcore.LevelEnabler) zapcore.Core {
+	return zapcore.NewCore(zapcore.NewJSONEncoder(jsonEncoderConfig()), s, level)
+}
+
+// Tee returns a logger that writes to l's outputs and to the extra core.
+func Tee(l *zap.Logger, core zapcore.Core) *zap.Logger {
+	return l.WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return zapcore.NewTee(c, core)
+	}))
+}
@@ -0,0 +1,53 @@
+package logger
+
+import (
+	"context"
+	"elastic-logger-app/common/elastictest"
+	"encoding/json"
+	"testing"
+
+	"github.com/olivere/elastic/v7"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestElasticsearchSinkIndexesEntries(t *testing.T) {
+	ctx := context.Background()
+	srv := elastictest.NewServer(t)
+	client := srv.Client()
+
+	sink, err := NewElasticsearchSink(ctx, client, "app-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := zap.New(sink.Core(zapcore.InfoLevel)).Named("account")
+	log.Debug("not indexed")
+	log.Info("account created", zap.String("trace_id", "t-1"))
+	log.Error("cannot insert account", zap.Int("status", 500))
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	log.Info("after close")
+	if sink.Dropped() != 1 {
+		t.Fatalf("dropped = %d, want 1", sink.Dropped())
+	}
+	if docs := srv.Documents("app-logs"); len(docs) != 2 {
+		t.Fatalf("indexed %d documents, want 2", len(docs))
+	}
+
+	res, err := client.Search("app-logs").Query(elastic.NewTermQuery("level", "error")).Do(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.TotalHits() != 1 {
+		t.Fatalf("error entries = %d, want 1", res.TotalHits())
+	}
+	var entry map[string]any
+	if err := json.Unmarshal(res.Hits.Hits[0].Source, &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["message"] != "cannot insert account" || entry["logger"] != "account" || entry["@timestamp"] == nil {
+		t.Fatalf("entry = %v", entry)
+	}
+}
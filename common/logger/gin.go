@@ -0,0 +1,46 @@
+package logger
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// GinMiddleware stores a request-scoped child of l in the request context and writes
+// one access log line per request, replacing gin.Logger.
+func GinMiddleware(l *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		reqLogger := l.With(
+			zap.String("method", c.Request.Method),
+			zap.String("path", c.Request.URL.Path),
+		)
+		c.Request = c.Request.WithContext(WithContext(c.Request.Context(), reqLogger))
+
+		c.Next()
+
+		fields := []zap.Field{
+			zap.String("route", c.FullPath()),
+			zap.Int("status", c.Writer.Status()),
+			zap.Duration("latency", time.Since(start)),
+			zap.String("client_ip", c.ClientIP()),
+			zap.Int("size", c.Writer.Size()),
+		}
+		if errs := c.Errors.ByType(gin.ErrorTypePrivate).String(); errs != "" {
+			fields = append(fields, zap.String("errors", errs))
+		}
+
+		// Handlers may have enriched the logger, so read it back from the request context.
+		accessLogger := FromContext(c.Request.Context())
+		switch status := c.Writer.Status(); {
+		case status >= 500:
+			accessLogger.Error("request completed", fields...)
+		case status >= 400:
+			accessLogger.Warn("request completed", fields...)
+		default:
+			accessLogger.Info("request completed", fields...)
+		}
+	}
+}
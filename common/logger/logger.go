@@ -0,0 +1,95 @@
+package logger
+
+import (
+	"context"
+	"elastic-logger-app/common/redact"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Output formats for the stdout sink.
+const (
+	FormatConsole = "console"
+	FormatJSON    = "json"
+)
+
+type Config struct {
+	// Level is the minimum level written: debug, info, warn or error.
+	Level string
+	// Format selects a human-readable console encoder for development or JSON for log shippers.
+	Format string
+	// RedactKeys are the field keys whose values are masked; nil means redact.DefaultKeys.
+	RedactKeys []string
+}
+
+// New builds the root application logger writing to stdout.
+func New(cfg Config) (*zap.Logger, error) {
+	level, err := zapcore.ParseLevel(strings.TrimSpace(cfg.Level))
+	if err != nil {
+		return nil, fmt.Errorf("parse log level %q: %w", cfg.Level, err)
+	}
+
+	var encoder zapcore.Encoder
+	switch strings.ToLower(strings.TrimSpace(cfg.Format)) {
+	case FormatConsole, "":
+		encCfg := zap.NewDevelopmentEncoderConfig()
+		encCfg.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		encCfg.EncodeTime = zapcore.ISO8601TimeEncoder
+		encoder = zapcore.NewConsoleEncoder(encCfg)
+	case FormatJSON:
+		encoder = zapcore.NewJSONEncoder(jsonEncoderConfig())
+	default:
+		return nil, fmt.Errorf("unknown log format %q", cfg.Format)
+	}
+
+	keys := cfg.RedactKeys
+	if keys == nil {
+		keys = redact.DefaultKeys
+	}
+
+	core := RedactCore(zapcore.NewCore(encoder, zapcore.Lock(os.Stdout), level), keys)
+	return zap.New(core, zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel)), nil
+}
+
+// jsonEncoderConfig uses field names that line up with Elasticsearch conventions,
+// so stdout JSON and the Elasticsearch sink produce the same documents.
+func jsonEncoderConfig() zapcore.EncoderConfig {
+	encCfg := zap.NewProductionEncoderConfig()
+	encCfg.TimeKey = "@timestamp"
+	encCfg.MessageKey = "message"
+	encCfg.EncodeTime = zapcore.ISO8601TimeEncoder
+	return encCfg
+}
+
+// Named returns a child of the global logger for the given module, e.g. "account" or "configs".
+func Named(name string) *zap.Logger {
+	return zap.L().Named(name)
+}
+
+type contextKey struct{}
+
+// WithContext returns a copy of ctx carrying l.
+func WithContext(ctx context.Context, l *zap.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger carried by ctx, falling back to the global logger.
+// Loggers stored by the HTTP middleware and the consumers already hold the request's
+// correlation fields.
+func FromContext(ctx context.Context) *zap.Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(contextKey{}).(*zap.Logger); ok {
+			return l
+		}
+	}
+	return zap.L()
+}
+
+// With returns a copy of ctx whose logger has the given fields added.
+func With(ctx context.Context, fields ...zap.Field) context.Context {
+	return WithContext(ctx, FromContext(ctx).With(fields...))
+}
@@ -0,0 +1,57 @@
+package logger
+
+import (
+	"elastic-logger-app/common/redact"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// RedactCore wraps core so fields whose key names a secret (see redact.IsSensitiveKey) are
+// written as redact.Mask, e.g. zap.String("password", ...) or zap.String("authorization", ...).
+// Only top-level field keys are checked; values nested in objects are written as is.
+func RedactCore(core zapcore.Core, keys []string) zapcore.Core {
+	if len(keys) == 0 {
+		return core
+	}
+	return &redactCore{Core: core, keys: keys}
+}
+
+type redactCore struct {
+	zapcore.Core
+	keys []string
+}
+
+func (c *redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactCore{Core: c.Core.With(c.redact(fields)), keys: c.keys}
+}
+
+func (c *redactCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *redactCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(ent, c.redact(fields))
+}
+
+// redact returns fields with sensitive values masked, copying only when something changes.
+func (c *redactCore) redact(fields []zapcore.Field) []zapcore.Field {
+	var out []zapcore.Field
+	for i, f := range fields {
+		if !redact.IsSensitiveKey(f.Key, c.keys) {
+			continue
+		}
+		if out == nil {
+			out = make([]zapcore.Field, len(fields))
+			copy(out, fields)
+		}
+		out[i] = zap.String(f.Key, redact.Mask)
+	}
+	if out == nil {
+		return fields
+	}
+	return out
+}
@@ -0,0 +1,24 @@
+package logger
+
+import (
+	"elastic-logger-app/common/redact"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRedactCore(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	l := zap.New(RedactCore(core, redact.DefaultKeys)).With(zap.String("api_key", "k"))
+
+	l.Info("login", zap.String("user", "an"), zap.String("password", "p"), zap.String("Authorization", "Bearer t"))
+
+	fields := logs.All()[0].ContextMap()
+	for key, want := range map[string]string{"user": "an", "password": redact.Mask, "Authorization": redact.Mask, "api_key": redact.Mask} {
+		if fields[key] != want {
+			t.Errorf("%s = %v, want %q", key, fields[key], want)
+		}
+	}
+}
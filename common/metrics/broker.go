@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Outcomes recorded for consumed messages.
+const (
+	OutcomeAck        = "ack"
+	OutcomeRetry      = "retry"
+	OutcomeDeadLetter = "dead_letter"
+	OutcomeRequeue    = "requeue"
+)
+
+var (
+	rabbitPublished = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rabbitmq_published_total",
+		Help:      "Messages published, by exchange and routing key.",
+	}, []string{"exchange", "routing_key"})
+
+	rabbitConfirms = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rabbitmq_publish_confirms_total",
+		Help:      "Publisher confirms received from the broker, by exchange and result (ack or nack).",
+	}, []string{"exchange", "result"})
+
+	rabbitConsumed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rabbitmq_consumed_total",
+		Help:      "Messages consumed, by queue and outcome (ack, retry, dead_letter, requeue).",
+	}, []string{"queue", "outcome"})
+
+	rabbitRedelivered = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rabbitmq_redelivered_total",
+		Help:      "Messages received again after a broker redelivery or a scheduled retry, by queue.",
+	}, []string{"queue"})
+)
+
+func ObservePublish(exchange, routingKey string) {
+	rabbitPublished.WithLabelValues(exchange, routingKey).Inc()
+}
+
+func ObserveConfirm(exchange string, ack bool) {
+	result := "ack"
+	if !ack {
+		result = "nack"
+	}
+	rabbitConfirms.WithLabelValues(exchange, result).Inc()
+}
+
+func ObserveConsume(queue, outcome string) {
+	rabbitConsumed.WithLabelValues(queue, outcome).Inc()
+}
+
+func ObserveRedelivery(queue string) {
+	rabbitRedelivered.WithLabelValues(queue).Inc()
+}
+
+// RegisterIdempotencyStats exposes duplicate hits and misses of a consumer's idempotency guard.
+func RegisterIdempotencyStats(consumer string, stats func() (hits, misses int64)) error {
+	labels := prometheus.Labels{"consumer": consumer}
+
+	hits := prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace:   namespace,
+		Name:        "idempotency_hits_total",
+		Help:        "Duplicate messages acknowledged without running the handler.",
+		ConstLabels: labels,
+	}, func() float64 {
+		h, _ := stats()
+		return float64(h)
+	})
+	misses := prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace:   namespace,
+		Name:        "idempotency_misses_total",
+		Help:        "Messages handled for the first time.",
+		ConstLabels: labels,
+	}, func() float64 {
+		_, m := stats()
+		return float64(m)
+	})
+
+	if err := Registry.Register(hits); err != nil {
+		return err
+	}
+	return Registry.Register(misses)
+}
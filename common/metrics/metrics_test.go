@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestHTTPMiddlewareLabelsByRouteTemplate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(HTTPMiddleware())
+	r.GET("/accounts/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for _, path := range []string{"/accounts/1", "/accounts/2", "/missing"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if got := testutil.ToFloat64(httpRequests.WithLabelValues("GET", "/accounts/:id", "200")); got != 2 {
+		t.Fatalf("requests on the route template = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(httpRequests.WithLabelValues("GET", "unmatched", "404")); got != 1 {
+		t.Fatalf("unmatched requests = %v, want 1", got)
+	}
+}
+
+func TestObserveAppError(t *testing.T) {
+	ObserveAppError(http.StatusConflict, "EMAIL_TAKEN")
+	ObserveAppError(http.StatusConflict, "EMAIL_TAKEN")
+
+	if got := testutil.ToFloat64(appErrors.WithLabelValues("409", "EMAIL_TAKEN")); got != 2 {
+		t.Fatalf("app errors = %v, want 2", got)
+	}
+}
+
+func TestBrokerMetrics(t *testing.T) {
+	ObservePublish("events", "account.created")
+	ObserveConfirm("events", true)
+	ObserveConfirm("events", false)
+
+	if got := testutil.ToFloat64(rabbitPublished.WithLabelValues("events", "account.created")); got != 1 {
+		t.Fatalf("published = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(rabbitConfirms.WithLabelValues("events", "nack")); got != 1 {
+		t.Fatalf("nacks = %v, want 1", got)
+	}
+}
+
+func TestRegistryLints(t *testing.T) {
+	problems, err := testutil.GatherAndLint(Registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range problems {
+		if strings.HasPrefix(p.Metric, namespace) {
+			t.Errorf("%s: %s", p.Metric, p.Text)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"database/sql"
+
+	"github.com/olivere/elastic/v7"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+var (
+	mongoPoolEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "mongo_pool_events_total",
+		Help:      "MongoDB connection pool events, by event type.",
+	}, []string{"type"})
+
+	mongoConnectionsInUse = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "mongo_pool_connections_in_use",
+		Help:      "MongoDB connections currently checked out of the pool.",
+	})
+)
+
+// RegisterDBStats exposes sql.DB.Stats() pool gauges for db under the given name.
+func RegisterDBStats(db *sql.DB, name string) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// MongoPoolMonitor returns a pool monitor counting every pool event and tracking
+// how many connections are checked out.
+func MongoPoolMonitor() *event.PoolMonitor {
+	return &event.PoolMonitor{
+		Event: func(e *event.PoolEvent) {
+			mongoPoolEvents.WithLabelValues(e.Type).Inc()
+			switch e.Type {
+			case event.GetSucceeded:
+				mongoConnectionsInUse.Inc()
+			case event.ConnectionReturned:
+				mongoConnectionsInUse.Dec()
+			}
+		},
+	}
+}
+
+// BulkStatsSource is implemented by components that index through an Elasticsearch bulk processor.
+type BulkStatsSource interface {
+	Stats() elastic.BulkProcessorStats
+	// Dropped returns how many documents were discarded without reaching the processor.
+	Dropped() int64
+}
+
+type bulkCollector struct {
+	source    BulkStatsSource
+	queued    *prometheus.Desc
+	succeeded *prometheus.Desc
+	failed    *prometheus.Desc
+	dropped   *prometheus.Desc
+	flushed   *prometheus.Desc
+}
+
+// RegisterBulkProcessor exposes queue depth, successes, failures and drops of an
+// Elasticsearch bulk processor, read from source at scrape time.
+func RegisterBulkProcessor(name string, source BulkStatsSource) error {
+	labels := prometheus.Labels{"processor": name}
+	desc := func(metric, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "elasticsearch_bulk", metric), help, nil, labels)
+	}
+
+	return Registry.Register(&bulkCollector{
+		source:    source,
+		queued:    desc("queue_depth", "Requests queued in the bulk processor workers."),
+		succeeded: desc("succeeded_total", "Bulk requests Elasticsearch reported as successful."),
+		failed:    desc("failed_total", "Bulk requests Elasticsearch reported as failed."),
+		dropped:   desc("dropped_total", "Documents discarded before reaching the bulk processor."),
+		flushed:   desc("flushed_total", "Times the bulk processor flushed."),
+	})
+}
+
+func (c *bulkCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.queued
+	ch <- c.succeeded
+	ch <- c.failed
+	ch <- c.dropped
+	ch <- c.flushed
+}
+
+func (c *bulkCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.source.Stats()
+
+	var queued int64
+	for _, w := range stats.Workers {
+		queued += w.Queued
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.queued, prometheus.GaugeValue, float64(queued))
+	ch <- prometheus.MustNewConstMetric(c.succeeded, prometheus.CounterValue, float64(stats.Succeeded))
+	ch <- prometheus.MustNewConstMetric(c.failed, prometheus.CounterValue, float64(stats.Failed))
+	ch <- prometheus.MustNewConstMetric(c.dropped, prometheus.CounterValue, float64(c.source.Dropped()))
+	ch <- prometheus.MustNewConstMetric(c.flushed, prometheus.CounterValue, float64(stats.Flushed))
+}
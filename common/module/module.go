@@ -0,0 +1,95 @@
+// Package module lets bounded contexts plug into the processes of the binary. A module says
+// which shared infrastructure it needs, builds its use cases from it in Init, and contributes
+// routes, consumers, Mongo migrations and health checks through the optional interfaces below.
+package module
+
+import (
+	"context"
+	"database/sql"
+	"elastic-logger-app/common/rabbitmq"
+	"elastic-logger-app/configs"
+	"elastic-logger-app/migration"
+
+	"github.com/gin-gonic/gin"
+	"github.com/olivere/elastic/v7"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Need names a piece of shared infrastructure a module uses.
+type Need string
+
+const (
+	NeedMySQL    Need = "mysql"
+	NeedMongo    Need = "mongo"
+	NeedElastic  Need = "elastic"
+	NeedRabbitMQ Need = "rabbitmq"
+)
+
+// Deps is the shared infrastructure injected into modules. A process only connects what it
+// uses, so fields no registered module needs may be nil.
+type Deps struct {
+	Config   *configs.Config
+	MySQL    *sql.DB
+	Mongo    *mongo.Client
+	Elastic  *elastic.Client
+	RabbitMQ rabbitmq.Connection
+	// Idempotency guards non-idempotent routes with the Idempotency-Key header. It is only set
+	// when serving HTTP.
+	Idempotency gin.HandlerFunc
+}
+
+func (d Deps) has(n Need) bool {
+	switch n {
+	case NeedMySQL:
+		return d.MySQL != nil
+	case NeedMongo:
+		return d.Mongo != nil
+	case NeedElastic:
+		return d.Elastic != nil
+	case NeedRabbitMQ:
+		return d.RabbitMQ != nil
+	}
+	return false
+}
+
+// Module is a bounded context, e.g. account.
+type Module interface {
+	// Name identifies the module in logs, errors and health checks.
+	Name() string
+	// Needs lists the infrastructure Init expects to find in Deps.
+	Needs() []Need
+	// Init builds the module from deps. It is called once, in registration order, before
+	// Routes, Consumers and HealthChecks.
+	Init(deps Deps) error
+}
+
+// RouteModule adds HTTP routes under /api/v1.
+type RouteModule interface {
+	Module
+	Routes(api *gin.RouterGroup)
+}
+
+// ConsumerModule provides the RabbitMQ consumers run by the worker, with handlers registered.
+type ConsumerModule interface {
+	Module
+	Consumers() []*rabbitmq.Consumer
+}
+
+// MigrationModule provides the Mongo migrations of the collections the module owns. It only
+// uses db, so it may be called without Init.
+type MigrationModule interface {
+	Module
+	MongoSteps(db *mongo.Database) []migration.Step
+}
+
+// HealthModule reports whether what the module depends on is usable.
+type HealthModule interface {
+	Module
+	HealthChecks() []HealthCheck
+}
+
+// HealthCheck is one named probe. Check returns nil when healthy.
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
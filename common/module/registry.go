@@ -0,0 +1,115 @@
+package module
+
+import (
+	"elastic-logger-app/common/rabbitmq"
+	"elastic-logger-app/migration"
+	"fmt"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Registry holds the modules of the binary in the order they start.
+type Registry struct {
+	modules []Module
+}
+
+func NewRegistry(modules ...Module) (*Registry, error) {
+	seen := make(map[string]bool, len(modules))
+	for _, m := range modules {
+		if seen[m.Name()] {
+			return nil, fmt.Errorf("module %q is registered twice", m.Name())
+		}
+		seen[m.Name()] = true
+	}
+	return &Registry{modules: modules}, nil
+}
+
+func (r *Registry) Modules() []Module {
+	return r.modules
+}
+
+// Needs is the union of the modules' needs, in first-seen order.
+func (r *Registry) Needs() []Need {
+	var needs []Need
+	for _, m := range r.modules {
+		for _, n := range m.Needs() {
+			if !slices.Contains(needs, n) {
+				needs = append(needs, n)
+			}
+		}
+	}
+	return needs
+}
+
+// Start initializes every module in order with deps, and stops at the first module whose
+// needs are missing or whose Init fails.
+func (r *Registry) Start(deps Deps) error {
+	for _, m := range r.modules {
+		for _, n := range m.Needs() {
+			if !deps.has(n) {
+				return fmt.Errorf("module %s needs %s, which is not connected", m.Name(), n)
+			}
+		}
+		if err := m.Init(deps); err != nil {
+			return fmt.Errorf("init module %s: %w", m.Name(), err)
+		}
+	}
+	return nil
+}
+
+// Routes adds the routes of every RouteModule to api.
+func (r *Registry) Routes(api *gin.RouterGroup) {
+	for _, m := range r.modules {
+		if rm, ok := m.(RouteModule); ok {
+			rm.Routes(api)
+		}
+	}
+}
+
+// HasConsumers reports whether a module provides consumers. Unlike Consumers it needs no
+// Start, so the worker can give up before connecting anything.
+func (r *Registry) HasConsumers() bool {
+	for _, m := range r.modules {
+		if _, ok := m.(ConsumerModule); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Consumers collects the consumers of every ConsumerModule.
+func (r *Registry) Consumers() []*rabbitmq.Consumer {
+	var consumers []*rabbitmq.Consumer
+	for _, m := range r.modules {
+		if cm, ok := m.(ConsumerModule); ok {
+			consumers = append(consumers, cm.Consumers()...)
+		}
+	}
+	return consumers
+}
+
+// MongoSteps collects the Mongo migrations of every MigrationModule.
+func (r *Registry) MongoSteps(db *mongo.Database) []migration.Step {
+	var steps []migration.Step
+	for _, m := range r.modules {
+		if mm, ok := m.(MigrationModule); ok {
+			steps = append(steps, mm.MongoSteps(db)...)
+		}
+	}
+	return steps
+}
+
+// HealthChecks collects the checks of every HealthModule, named "<module>.<check>".
+func (r *Registry) HealthChecks() []HealthCheck {
+	var checks []HealthCheck
+	for _, m := range r.modules {
+		if hm, ok := m.(HealthModule); ok {
+			for _, c := range hm.HealthChecks() {
+				checks = append(checks, HealthCheck{Name: m.Name() + "." + c.Name, Check: c.Check})
+			}
+		}
+	}
+	return checks
+}
@@ -0,0 +1,137 @@
+package module
+
+import (
+	"context"
+	"elastic-logger-app/common/rabbitmq"
+	"elastic-logger-app/configs"
+	"elastic-logger-app/migration"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// fakeModule records its Init calls into a shared log and contributes one of each extension.
+type fakeModule struct {
+	name    string
+	needs   []Need
+	initErr error
+	log     *[]string
+}
+
+func (m *fakeModule) Name() string  { return m.name }
+func (m *fakeModule) Needs() []Need { return m.needs }
+
+func (m *fakeModule) Init(deps Deps) error {
+	*m.log = append(*m.log, "init "+m.name)
+	return m.initErr
+}
+
+func (m *fakeModule) Routes(api *gin.RouterGroup) {
+	api.GET("/"+m.name, func(c *gin.Context) {})
+}
+
+func (m *fakeModule) MongoSteps(db *mongo.Database) []migration.Step {
+	return []migration.Step{{Version: int64(len(m.name)), Description: m.name}}
+}
+
+func (m *fakeModule) HealthChecks() []HealthCheck {
+	return []HealthCheck{{Name: "ping", Check: func(ctx context.Context) error { return nil }}}
+}
+
+func TestNewRegistryRejectsDuplicateNames(t *testing.T) {
+	var log []string
+	_, err := NewRegistry(&fakeModule{name: "a", log: &log}, &fakeModule{name: "a", log: &log})
+	if err == nil {
+		t.Fatal("want error for duplicate module")
+	}
+}
+
+func TestRegistryStartsInOrder(t *testing.T) {
+	var log []string
+	r, err := NewRegistry(&fakeModule{name: "b", log: &log}, &fakeModule{name: "a", log: &log})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Start(Deps{Config: &configs.Config{}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(log, ","); got != "init b,init a" {
+		t.Fatalf("log = %s", got)
+	}
+}
+
+func TestRegistryStartChecksNeeds(t *testing.T) {
+	var log []string
+	r, err := NewRegistry(
+		&fakeModule{name: "free", log: &log},
+		&fakeModule{name: "store", needs: []Need{NeedMongo}, log: &log},
+		&fakeModule{name: "late", log: &log},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = r.Start(Deps{})
+	if err == nil || !strings.Contains(err.Error(), "module store needs mongo") {
+		t.Fatalf("err = %v", err)
+	}
+	if got := strings.Join(log, ","); got != "init free" {
+		t.Fatalf("modules after the failing one were started: %s", got)
+	}
+}
+
+func TestRegistryStartWrapsInitError(t *testing.T) {
+	var log []string
+	boom := errors.New("boom")
+	r, _ := NewRegistry(&fakeModule{name: "a", initErr: boom, log: &log})
+
+	if err := r.Start(Deps{}); !errors.Is(err, boom) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestRegistryCollectsExtensions(t *testing.T) {
+	var log []string
+	r, _ := NewRegistry(&fakeModule{name: "a", needs: []Need{NeedMySQL}, log: &log}, &fakeModule{name: "bb", needs: []Need{NeedMySQL, NeedMongo}, log: &log})
+
+	if got := r.Needs(); len(got) != 2 || got[0] != NeedMySQL || got[1] != NeedMongo {
+		t.Fatalf("needs = %v", got)
+	}
+
+	checks := r.HealthChecks()
+	if len(checks) != 2 || checks[0].Name != "a.ping" || checks[1].Name != "bb.ping" {
+		t.Fatalf("checks = %+v", checks)
+	}
+
+	if r.HasConsumers() {
+		t.Fatal("registry without consumer modules has consumers")
+	}
+
+	steps := r.MongoSteps(nil)
+	if len(steps) != 2 || steps[0].Description != "a" || steps[1].Description != "bb" {
+		t.Fatalf("steps = %+v", steps)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	r.Routes(router.Group("/api"))
+	if routes := router.Routes(); len(routes) != 2 {
+		t.Fatalf("routes = %+v", routes)
+	}
+}
+
+type consumerModule struct{ fakeModule }
+
+func (m *consumerModule) Consumers() []*rabbitmq.Consumer { return nil }
+
+func TestRegistryHasConsumersBeforeStart(t *testing.T) {
+	var log []string
+	r, _ := NewRegistry(&fakeModule{name: "a", log: &log}, &consumerModule{fakeModule{name: "worker", log: &log}})
+	if !r.HasConsumers() || len(log) != 0 {
+		t.Fatalf("has consumers = %v, init log = %v", r.HasConsumers(), log)
+	}
+}
@@ -0,0 +1,77 @@
+// Package outbox publishes messages written in the same database transaction as the change
+// they announce. Use cases Add a message with the ctx of their transaction, so it commits or
+// rolls back with their writes, and a Relay publishes committed messages to RabbitMQ.
+//
+// Delivery is at least once: a relay that stops between the broker's confirm and marking the
+// message sent publishes it again with the same message ID, which consumers deduplicate with
+// an idempotency.Guard.
+package outbox
+
+import (
+	"context"
+	"elastic-logger-app/common"
+	"elastic-logger-app/common/tracing"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// Message is one message waiting in the outbox.
+type Message struct {
+	ID         string
+	RoutingKey string
+	Body       []byte
+	// Headers carries the request ID and trace context of the request that added the
+	// message, so its publication joins the same trace.
+	Headers   map[string]string
+	CreatedAt time.Time
+}
+
+// Store holds the outbox.
+type Store interface {
+	// Add writes a message in the transaction carried by ctx, if any.
+	Add(ctx context.Context, routingKey string, body []byte) error
+	// Dispatch passes up to limit unsent messages, oldest first, to send, and marks as sent
+	// those it accepted. It stops at the first error send returns and returns it with the
+	// number of messages sent. Messages being dispatched by another relay are skipped.
+	Dispatch(ctx context.Context, limit int, send func(ctx context.Context, msg Message) error) (int, error)
+	// PurgeSent deletes the messages sent before t and returns how many were removed.
+	PurgeSent(ctx context.Context, before time.Time) (int64, error)
+}
+
+// newMessage builds the message Add stores, with a fresh ID and the headers of ctx.
+func newMessage(ctx context.Context, routingKey string, body []byte) Message {
+	carrier := amqp.Table{}
+	tracing.InjectAMQP(ctx, carrier)
+
+	headers := make(map[string]string, len(carrier)+1)
+	for k, v := range carrier {
+		if s, ok := v.(string); ok {
+			headers[k] = s
+		}
+	}
+	if requestID := common.RequestIDFromContext(ctx); requestID != "" {
+		headers[common.HeaderRequestID] = requestID
+	}
+
+	return Message{
+		ID:         common.GenUUID().String(),
+		RoutingKey: routingKey,
+		Body:       body,
+		Headers:    headers,
+		CreatedAt:  time.Now().UTC().Truncate(time.Millisecond),
+	}
+}
+
+// restoreContext returns ctx with the request ID and trace context of msg.
+func restoreContext(ctx context.Context, msg Message) context.Context {
+	carrier := make(amqp.Table, len(msg.Headers))
+	for k, v := range msg.Headers {
+		carrier[k] = v
+	}
+	ctx = tracing.ExtractAMQP(ctx, carrier)
+	if requestID := msg.Headers[common.HeaderRequestID]; requestID != "" {
+		ctx = common.ContextWithRequestID(ctx, requestID)
+	}
+	return ctx
+}
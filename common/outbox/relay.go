@@ -0,0 +1,99 @@
+package outbox
+
+import (
+	"context"
+	"elastic-logger-app/common/logger"
+	"time"
+
+	"github.com/streadway/amqp"
+	"go.uber.org/zap"
+)
+
+// Publisher sends a message under a given ID and returns once the broker confirmed it.
+// *rabbitmq.Publisher implements it.
+type Publisher interface {
+	PublishWithID(ctx context.Context, messageID, routingKey string, body []byte, headers amqp.Table) error
+}
+
+// RelayConfig controls how often a Relay polls the outbox and how long sent messages stay.
+type RelayConfig struct {
+	// BatchSize is the number of messages taken from the outbox at a time.
+	BatchSize int
+	// PollInterval is the wait between polls once the outbox is empty, and after an error.
+	PollInterval time.Duration
+	// Retention is how long sent messages are kept before they are purged.
+	Retention time.Duration
+}
+
+// publishTimeout bounds the wait for the broker to confirm one message, during which its
+// outbox row stays locked.
+const publishTimeout = 30 * time.Second
+
+// purgeInterval is how often a relay purges sent messages.
+const purgeInterval = time.Hour
+
+func DefaultRelayConfig() RelayConfig {
+	return RelayConfig{
+		BatchSize:    100,
+		PollInterval: time.Second,
+		Retention:    7 * 24 * time.Hour,
+	}
+}
+
+// Relay publishes the messages of an outbox in the order they were added.
+type Relay struct {
+	store     Store
+	publisher Publisher
+	cfg       RelayConfig
+}
+
+func NewRelay(store Store, publisher Publisher, cfg RelayConfig) *Relay {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 1
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	return &Relay{store: store, publisher: publisher, cfg: cfg}
+}
+
+// Run relays messages until ctx is cancelled, and then returns nil. Failures are logged and
+// retried after the poll interval, so a broker or database outage only delays messages.
+func (r *Relay) Run(ctx context.Context) error {
+	log := logger.FromContext(ctx).Named("outbox")
+	var lastPurge time.Time
+	for {
+		sent, err := r.RelayOnce(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			log.Warn("cannot relay outbox messages, retrying", zap.Int("sent", sent), zap.Error(err))
+		}
+		if err == nil && sent == r.cfg.BatchSize {
+			continue
+		}
+
+		if r.cfg.Retention > 0 && time.Since(lastPurge) >= purgeInterval {
+			lastPurge = time.Now()
+			if _, err := r.store.PurgeSent(ctx, time.Now().UTC().Add(-r.cfg.Retention)); err != nil && ctx.Err() == nil {
+				log.Warn("cannot purge sent outbox messages", zap.Error(err))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(r.cfg.PollInterval):
+		}
+	}
+}
+
+// RelayOnce publishes one batch of pending messages and returns how many were sent.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	return r.store.Dispatch(ctx, r.cfg.BatchSize, func(ctx context.Context, msg Message) error {
+		ctx, cancel := context.WithTimeout(restoreContext(ctx, msg), publishTimeout)
+		defer cancel()
+		return r.publisher.PublishWithID(ctx, msg.ID, msg.RoutingKey, msg.Body, nil)
+	})
+}
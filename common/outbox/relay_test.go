@@ -0,0 +1,111 @@
+package outbox_test
+
+import (
+	"context"
+	"elastic-logger-app/common"
+	"elastic-logger-app/common/outbox"
+	"elastic-logger-app/common/rabbitmq"
+	"elastic-logger-app/common/rabbitmqtest"
+	"testing"
+	"time"
+)
+
+// setup returns a relay from store to the "events" exchange of a broker, whose "audit" queue
+// receives every message.
+func setup(t *testing.T, store outbox.Store, batchSize int) (*rabbitmqtest.Broker, *outbox.Relay) {
+	t.Helper()
+	b := rabbitmqtest.NewBroker()
+	p, err := rabbitmq.NewPublisher(b, "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Close() })
+
+	ch, err := b.Channel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ch.QueueDeclare("audit", true, false, false, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := ch.QueueBind("audit", "#", "events", false, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := outbox.DefaultRelayConfig()
+	cfg.BatchSize = batchSize
+	cfg.PollInterval = 10 * time.Millisecond
+	return b, outbox.NewRelay(store, p, cfg)
+}
+
+func TestRelayPublishesInOrderWithStableIDs(t *testing.T) {
+	store := outbox.NewMemoryStore()
+	b, relay := setup(t, store, 2)
+
+	ctx := common.ContextWithRequestID(context.Background(), "req-1")
+	for _, key := range []string{"account.created", "account.status_changed", "account.created"} {
+		if err := store.Add(ctx, key, []byte(`{"id":"1"}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	added := store.Pending()
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- relay.Run(runCtx) }()
+
+	deadline := time.Now().Add(time.Second)
+	for len(store.Pending()) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d messages still pending", len(store.Pending()))
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("run after cancel: %v", err)
+	}
+
+	got := b.Messages("audit")
+	if len(got) != len(added) {
+		t.Fatalf("published %d messages, want %d", len(got), len(added))
+	}
+	for i, d := range got {
+		if d.MessageId != added[i].ID || d.RoutingKey != added[i].RoutingKey || string(d.Body) != `{"id":"1"}` {
+			t.Errorf("message %d = %s %s %s, want %s %s", i, d.MessageId, d.RoutingKey, d.Body, added[i].ID, added[i].RoutingKey)
+		}
+		if d.CorrelationId != "req-1" || d.Headers[common.HeaderRequestID] != "req-1" {
+			t.Errorf("message %d lost the request ID: %q %v", i, d.CorrelationId, d.Headers)
+		}
+	}
+}
+
+func TestRelayKeepsMessagesTheBrokerRefused(t *testing.T) {
+	store := outbox.NewMemoryStore()
+	b, relay := setup(t, store, 10)
+	for range 2 {
+		if err := store.Add(context.Background(), "account.created", []byte(`{}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b.NackPublishes()
+	if sent, err := relay.RelayOnce(context.Background()); err == nil || sent != 0 {
+		t.Fatalf("sent = %d, err = %v while the broker nacks", sent, err)
+	}
+	if len(store.Pending()) != 2 {
+		t.Fatalf("pending = %d after a nack, want 2", len(store.Pending()))
+	}
+
+	b.AckPublishes()
+	if sent, err := relay.RelayOnce(context.Background()); err != nil || sent != 2 {
+		t.Fatalf("sent = %d, err = %v", sent, err)
+	}
+	if len(store.Pending()) != 0 || len(b.Messages("audit")) != 2 {
+		t.Fatalf("pending = %d, published = %d", len(store.Pending()), len(b.Messages("audit")))
+	}
+
+	if purged, err := store.PurgeSent(context.Background(), time.Now().Add(time.Second)); err != nil || purged != 2 {
+		t.Fatalf("purged = %d, err = %v", purged, err)
+	}
+}
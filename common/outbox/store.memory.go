@@ -0,0 +1,97 @@
+package outbox
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store kept in memory, for tests. It has no transactions: Add stores the
+// message at once, whatever ctx carries.
+type MemoryStore struct {
+	mu       sync.Mutex
+	messages []*memoryMessage
+}
+
+type memoryMessage struct {
+	Message
+	sentAt time.Time
+	// locked is set while a Dispatch holds the message.
+	locked bool
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+func (s *MemoryStore) Add(ctx context.Context, routingKey string, body []byte) error {
+	msg := newMessage(ctx, routingKey, slices.Clone(body))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, &memoryMessage{Message: msg})
+	return nil
+}
+
+func (s *MemoryStore) Dispatch(ctx context.Context, limit int, send func(ctx context.Context, msg Message) error) (int, error) {
+	s.mu.Lock()
+	var batch []*memoryMessage
+	for _, m := range s.messages {
+		if len(batch) == limit {
+			break
+		}
+		if m.sentAt.IsZero() && !m.locked {
+			m.locked = true
+			batch = append(batch, m)
+		}
+	}
+	s.mu.Unlock()
+
+	sent := 0
+	var sendErr error
+	for _, m := range batch {
+		if sendErr = send(ctx, m.Message); sendErr != nil {
+			break
+		}
+		sent++
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC()
+	for i, m := range batch {
+		m.locked = false
+		if i < sent {
+			m.sentAt = now
+		}
+	}
+	return sent, sendErr
+}
+
+func (s *MemoryStore) PurgeSent(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := len(s.messages)
+	s.messages = slices.DeleteFunc(s.messages, func(m *memoryMessage) bool {
+		return !m.sentAt.IsZero() && m.sentAt.Before(before)
+	})
+	return int64(n - len(s.messages)), nil
+}
+
+// Pending returns the messages not sent yet, oldest first.
+func (s *MemoryStore) Pending() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var out []Message
+	for _, m := range s.messages {
+		if m.sentAt.IsZero() {
+			out = append(out, m.Message)
+		}
+	}
+	return out
+}
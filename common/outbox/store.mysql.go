@@ -0,0 +1,133 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"elastic-logger-app/common"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MySQLStore keeps the outbox in the outbox table.
+type MySQLStore struct {
+	db *sql.DB
+}
+
+func NewMySQLStore(db *sql.DB) *MySQLStore {
+	return &MySQLStore{db: db}
+}
+
+// execer is what Add needs from either the database or the transaction of ctx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func (s *MySQLStore) Add(ctx context.Context, routingKey string, body []byte) error {
+	msg := newMessage(ctx, routingKey, body)
+	headers, err := json.Marshal(msg.Headers)
+	if err != nil {
+		return fmt.Errorf("encode outbox headers: %w", err)
+	}
+
+	var db execer = s.db
+	if tx, ok := common.TxFromContext(ctx); ok {
+		db = tx
+	}
+	_, err = db.ExecContext(ctx,
+		`INSERT INTO outbox (message_id, routing_key, body, headers, created_at) VALUES (?, ?, ?, ?, ?)`,
+		msg.ID, msg.RoutingKey, msg.Body, headers, msg.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("add outbox message %s: %w", routingKey, err)
+	}
+	return nil
+}
+
+// SKIP LOCKED lets several relays run: each takes the rows the others have not locked.
+const pendingMessages = `SELECT seq, message_id, routing_key, body, headers, created_at
+FROM outbox
+WHERE sent_at IS NULL
+ORDER BY seq
+LIMIT ?
+FOR UPDATE SKIP LOCKED`
+
+// Dispatch keeps the rows locked while send publishes them, and marks them sent in the same
+// transaction.
+func (s *MySQLStore) Dispatch(ctx context.Context, limit int, send func(ctx context.Context, msg Message) error) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin outbox tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	seqs, messages, err := pending(ctx, tx, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	var sendErr error
+	for _, msg := range messages {
+		if sendErr = send(ctx, msg); sendErr != nil {
+			break
+		}
+		sent++
+	}
+	if sent == 0 {
+		return 0, sendErr
+	}
+
+	args := []any{time.Now().UTC()}
+	for _, seq := range seqs[:sent] {
+		args = append(args, seq)
+	}
+	query := `UPDATE outbox SET sent_at = ? WHERE seq IN (?` + strings.Repeat(", ?", sent-1) + `)`
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return 0, fmt.Errorf("mark outbox messages sent: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit outbox tx: %w", err)
+	}
+	return sent, sendErr
+}
+
+func pending(ctx context.Context, tx *sql.Tx, limit int) ([]uint64, []Message, error) {
+	rows, err := tx.QueryContext(ctx, pendingMessages, limit)
+	if err != nil {
+		return nil, nil, fmt.Errorf("select outbox messages: %w", err)
+	}
+	defer rows.Close()
+
+	var (
+		seqs     []uint64
+		messages []Message
+	)
+	for rows.Next() {
+		var (
+			seq     uint64
+			msg     Message
+			headers []byte
+		)
+		if err := rows.Scan(&seq, &msg.ID, &msg.RoutingKey, &msg.Body, &headers, &msg.CreatedAt); err != nil {
+			return nil, nil, fmt.Errorf("scan outbox message: %w", err)
+		}
+		if err := json.Unmarshal(headers, &msg.Headers); err != nil {
+			return nil, nil, fmt.Errorf("decode headers of outbox message %s: %w", msg.ID, err)
+		}
+		seqs = append(seqs, seq)
+		messages = append(messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("select outbox messages: %w", err)
+	}
+	return seqs, messages, nil
+}
+
+func (s *MySQLStore) PurgeSent(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM outbox WHERE sent_at < ?`, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
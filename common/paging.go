@@ -0,0 +1,11 @@
+package common
+
+// Paging describes one page of a list response. Page-based requests fill Page; cursor-based
+// requests leave it zero and continue from NextCursor.
+type Paging struct {
+	Page       int    `json:"page,omitempty"`
+	Limit      int    `json:"limit"`
+	Total      int64  `json:"total"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
@@ -0,0 +1,135 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// ContentTypeProblemJSON is the media type defined by RFC 7807.
+const ContentTypeProblemJSON = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// Extensions carries members beyond the standard ones, such as error_id and field errors.
+	Extensions map[string]any `json:"extensions,omitempty"`
+}
+
+// FieldError describes a single invalid input field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message,omitempty"`
+	// err is the validator error, kept to translate Message into the request locale.
+	err validator.FieldError
+	// key and params render Message from the i18n bundles for errors raised outside the
+	// validator, such as unknown fields.
+	key    string
+	params map[string]any
+}
+
+var problemJSONEnabled atomic.Bool
+
+// UseProblemJSON makes ResponseError render every error as application/problem+json.
+// When disabled, problem+json is only used for clients that ask for it in Accept.
+func UseProblemJSON(enabled bool) {
+	problemJSONEnabled.Store(enabled)
+}
+
+func wantsProblemJSON(c *gin.Context) bool {
+	return problemJSONEnabled.Load() || strings.Contains(c.GetHeader("Accept"), ContentTypeProblemJSON)
+}
+
+// AsAppError finds the *AppError in err's chain. Errors that are not AppErrors are classified:
+// malformed or invalid request bodies become 400, anything else becomes 500.
+func AsAppError(err error) *AppError {
+	var apperr *AppError
+	if errors.As(err, &apperr) {
+		return apperr
+	}
+
+	var (
+		validationErrs validator.ValidationErrors
+		syntaxErr      *json.SyntaxError
+		typeErr        *json.UnmarshalTypeError
+		maxBytesErr    *http.MaxBytesError
+	)
+	switch {
+	case errors.As(err, &validationErrs):
+		fields := make([]FieldError, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			fields = append(fields, FieldError{Field: fe.Field(), Rule: fe.Tag(), Param: fe.Param(), Message: fe.Error(), err: fe})
+		}
+		return NewErrorFromCode(CodeValidationFailed, nil).
+			WithReason("invalid request fields").WithInner(err).WithDetail("fields", fields)
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return NewErrorFromCode(CodeMalformedBody, nil).WithReason(err.Error()).WithInner(err)
+	case errors.As(err, &maxBytesErr):
+		return NewErrorFromCode(CodeBodyTooLarge, nil).WithReason(err.Error()).WithInner(err)
+	default:
+		return NewErrorFromCode(CodeInternal, nil).
+			WithReason("The error type is not a structured AppError.").WithInner(err)
+	}
+}
+
+// NewProblem converts apperr to problem details for the request in c. apperr should
+// already be redacted, since every detail and location field it carries is included.
+func NewProblem(c *gin.Context, apperr *AppError) *Problem {
+	p := &Problem{
+		Type:       ErrorTypeURI(apperr.ErrorCode),
+		Title:      http.StatusText(apperr.StatusCode()),
+		Status:     apperr.StatusCode(),
+		Detail:     apperr.Message,
+		Instance:   c.Request.URL.Path,
+		Extensions: map[string]any{},
+	}
+
+	p.Extensions["error_code"] = apperr.ErrorCode
+	if apperr.ErrorID != "" {
+		p.Extensions["error_id"] = apperr.ErrorID
+	}
+	if fields, ok := apperr.Details["fields"]; ok {
+		p.Extensions["errors"] = fields
+	}
+	for key, value := range apperr.Details {
+		if key != "fields" {
+			p.Extensions[key] = value
+		}
+	}
+	if apperr.File != "" {
+		p.Extensions["file"] = apperr.File
+		p.Extensions["line"] = apperr.Line
+		p.Extensions["function"] = apperr.Function
+	}
+
+	return p
+}
+
+// ErrorTypeURI is the problem "type" for a catalog code, pointing at its catalog entry.
+func ErrorTypeURI(code ErrorCode) string {
+	if code == "" {
+		return "about:blank"
+	}
+	return "/api/v1/errors/" + string(code)
+}
+
+func responseProblem(c *gin.Context, apperr *AppError) {
+	body, err := json.Marshal(NewProblem(c, apperr))
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.Data(apperr.StatusCode(), ContentTypeProblemJSON, body)
+}
@@ -0,0 +1,107 @@
+package common
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func serveError(t *testing.T, accept string, err error) *httptest.ResponseRecorder {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestID())
+	r.POST("/accounts", func(c *gin.Context) { ResponseError(c, err) })
+
+	req := httptest.NewRequest(http.MethodPost, "/accounts", nil)
+	req.Header.Set(HeaderRequestID, "req-7")
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func decodeProblem(t *testing.T, w *httptest.ResponseRecorder) Problem {
+	t.Helper()
+
+	if ct := w.Header().Get("Content-Type"); ct != ContentTypeProblemJSON {
+		t.Fatalf("content type = %q, want %q", ct, ContentTypeProblemJSON)
+	}
+	var p Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestResponseErrorProblemJSON(t *testing.T) {
+	validation := NewErrorFromCode(CodeValidationFailed, nil).
+		WithDetail("fields", []FieldError{{Field: "email", Rule: "email", Message: "email is invalid"}}).
+		WithDetail("sql", "SELECT 1")
+
+	t.Run("requested in accept", func(t *testing.T) {
+		w := serveError(t, "application/json, "+ContentTypeProblemJSON, validation)
+		p := decodeProblem(t, w)
+
+		if w.Code != http.StatusBadRequest || p.Status != http.StatusBadRequest {
+			t.Fatalf("status = %d / %d, want 400", w.Code, p.Status)
+		}
+		if p.Type != ErrorTypeURI(CodeValidationFailed) || p.Title != "Bad Request" || p.Instance != "/accounts" || p.Detail == "" {
+			t.Fatalf("problem = %+v", p)
+		}
+		if p.Extensions["error_code"] != string(CodeValidationFailed) || p.Extensions["error_id"] != "req-7" {
+			t.Fatalf("extensions = %v", p.Extensions)
+		}
+		if fields, _ := p.Extensions["errors"].([]any); len(fields) != 1 {
+			t.Fatalf("errors = %v", p.Extensions["errors"])
+		}
+		for _, key := range []string{"sql", "file", "line", "function", "fields"} {
+			if _, ok := p.Extensions[key]; ok {
+				t.Errorf("extension %q should not be rendered", key)
+			}
+		}
+	})
+
+	t.Run("envelope by default", func(t *testing.T) {
+		w := serveError(t, "", validation)
+
+		if ct := w.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+			t.Fatalf("content type = %q", ct)
+		}
+		var body struct {
+			Success bool     `json:"success"`
+			Error   AppError `json:"error"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Success || body.Error.ErrorCode != CodeValidationFailed {
+			t.Fatalf("body = %s", w.Body)
+		}
+	})
+
+	t.Run("enabled for every response", func(t *testing.T) {
+		UseProblemJSON(true)
+		t.Cleanup(func() { UseProblemJSON(false) })
+
+		p := decodeProblem(t, serveError(t, "", NewErrorFromCode(CodeNotFound, nil)))
+		if p.Status != http.StatusNotFound || p.Type != ErrorTypeURI(CodeNotFound) {
+			t.Fatalf("problem = %+v", p)
+		}
+	})
+}
+
+func TestErrorTypeURI(t *testing.T) {
+	if got := ErrorTypeURI(""); got != "about:blank" {
+		t.Errorf("ErrorTypeURI(\"\") = %q", got)
+	}
+	if got := ErrorTypeURI(CodeConflict); got != "/api/v1/errors/CONFLICT" {
+		t.Errorf("ErrorTypeURI(CONFLICT) = %q", got)
+	}
+}
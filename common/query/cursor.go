@@ -0,0 +1,80 @@
+package query
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// cursor is the payload behind the opaque cursor string: the sort it was issued for and the
+// sort values of the last item returned.
+type cursor struct {
+	Sort   string `json:"s"`
+	Values []any  `json:"v"`
+}
+
+func encodeCursor(sort []SortField, values map[string]any) string {
+	c := cursor{Sort: sortSignature(sort), Values: make([]any, len(sort))}
+	for i, s := range sort {
+		v := values[s.Field]
+		if t, ok := v.(time.Time); ok {
+			v = t.UTC().Format(time.RFC3339Nano)
+		}
+		c.Values[i] = v
+	}
+
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodeCursor validates raw against the current sort and converts its values back to the
+// field types of schema.
+func decodeCursor(raw string, sort []SortField, schema Schema) ([]any, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, errors.New("cursor is malformed")
+	}
+
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, errors.New("cursor is malformed")
+	}
+	if c.Sort != sortSignature(sort) || len(c.Values) != len(sort) {
+		return nil, errors.New("cursor was issued for a different sort")
+	}
+
+	values := make([]any, len(sort))
+	for i, s := range sort {
+		v, err := cursorValue(schema.Fields[s.Field], c.Values[i])
+		if err != nil {
+			return nil, fmt.Errorf("cursor value for %s: %w", s.Field, err)
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+func cursorValue(field Field, v any) (any, error) {
+	switch field.Type {
+	case Int:
+		n, ok := v.(float64)
+		if !ok {
+			return nil, errors.New("not a number")
+		}
+		return int64(n), nil
+	case Time:
+		s, ok := v.(string)
+		if !ok {
+			return nil, errors.New("not a time")
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, errors.New("not a time")
+		}
+		return t, nil
+	default:
+		return v, nil
+	}
+}
@@ -0,0 +1,71 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/olivere/elastic/v7"
+)
+
+// ElasticQuery renders the filters as a bool query of non-scoring filter clauses.
+// String fields are expected to be keyword fields.
+func (q *Query) ElasticQuery() *elastic.BoolQuery {
+	bq := elastic.NewBoolQuery()
+	for _, f := range q.Filters {
+		switch f.Op {
+		case OpEq:
+			bq.Filter(elastic.NewTermQuery(f.column, f.Value()))
+		case OpNe:
+			bq.MustNot(elastic.NewTermQuery(f.column, f.Value()))
+		case OpIn:
+			bq.Filter(elastic.NewTermsQuery(f.column, f.Values...))
+		case OpNin:
+			bq.MustNot(elastic.NewTermsQuery(f.column, f.Values...))
+		case OpContains:
+			bq.Filter(elastic.NewWildcardQuery(f.column, "*"+escapeWildcard(fmt.Sprint(f.Value()))+"*").CaseInsensitive(true))
+		case OpGt:
+			bq.Filter(elastic.NewRangeQuery(f.column).Gt(f.Value()))
+		case OpGte:
+			bq.Filter(elastic.NewRangeQuery(f.column).Gte(f.Value()))
+		case OpLt:
+			bq.Filter(elastic.NewRangeQuery(f.column).Lt(f.Value()))
+		case OpLte:
+			bq.Filter(elastic.NewRangeQuery(f.column).Lte(f.Value()))
+		}
+	}
+	return bq
+}
+
+// ElasticSearch applies the query, sort and paging to s. In cursor mode it uses search_after
+// instead of from, so deep pages stay cheap.
+func (q *Query) ElasticSearch(s *elastic.SearchService) *elastic.SearchService {
+	s = s.Query(q.ElasticQuery()).Size(q.FetchLimit()).TrackTotalHits(true)
+	for _, sf := range q.Sort {
+		s = s.SortBy(elastic.NewFieldSort(sf.column).Order(!sf.Desc))
+	}
+
+	if q.after != nil {
+		return s.SearchAfter(elasticSortValues(q.after)...)
+	}
+	return s.From(q.Offset())
+}
+
+// elasticSortValues converts times to epoch milliseconds, the form Elasticsearch returns
+// and expects for date sort values.
+func elasticSortValues(values []any) []any {
+	out := make([]any, len(values))
+	for i, v := range values {
+		if t, ok := v.(time.Time); ok {
+			v = t.UnixMilli()
+		}
+		out[i] = v
+	}
+	return out
+}
+
+var wildcardEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`)
+
+func escapeWildcard(s string) string {
+	return wildcardEscaper.Replace(s)
+}
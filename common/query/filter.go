@@ -0,0 +1,130 @@
+package query
+
+import (
+	"elastic-logger-app/common"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Op is a filter operator, written before the value as in "status=in:activated,banned".
+// A value without an operator means eq.
+type Op string
+
+const (
+	OpEq       Op = "eq"
+	OpNe       Op = "ne"
+	OpIn       Op = "in"
+	OpNin      Op = "nin"
+	OpGt       Op = "gt"
+	OpGte      Op = "gte"
+	OpLt       Op = "lt"
+	OpLte      Op = "lte"
+	OpContains Op = "contains"
+)
+
+var knownOps = map[Op]bool{
+	OpEq: true, OpNe: true, OpIn: true, OpNin: true,
+	OpGt: true, OpGte: true, OpLt: true, OpLte: true, OpContains: true,
+}
+
+// Filter is one typed filter expression. Values holds a single value except for in and nin.
+type Filter struct {
+	Field  string `json:"field"`
+	Op     Op     `json:"op"`
+	Values []any  `json:"values"`
+
+	column string
+}
+
+// Value returns the first value, which is the only one for single-value operators.
+func (f Filter) Value() any {
+	return f.Values[0]
+}
+
+func parseFilter(name, raw string, schema Schema) (Filter, *common.FieldError) {
+	field, ok := schema.Fields[name]
+	if !ok || len(field.Ops) == 0 {
+		fe := issue(name, "unknown", "", fmt.Sprintf("cannot filter by %s", name))
+		return Filter{}, &fe
+	}
+
+	op, value := OpEq, raw
+	if prefix, rest, found := strings.Cut(raw, ":"); found && knownOps[Op(prefix)] {
+		op, value = Op(prefix), rest
+	}
+	if !field.allows(op) {
+		fe := issue(name, "op", string(op), fmt.Sprintf("operator %s is not allowed on %s", op, name))
+		return Filter{}, &fe
+	}
+
+	parts := []string{value}
+	if op == OpIn || op == OpNin {
+		parts = strings.Split(value, ",")
+	}
+
+	values := make([]any, 0, len(parts))
+	for _, part := range parts {
+		v, err := parseValue(field, strings.TrimSpace(part))
+		if err != nil {
+			fe := issue(name, "type", part, err.Error())
+			return Filter{}, &fe
+		}
+		values = append(values, v)
+	}
+
+	return Filter{Field: name, Op: op, Values: values, column: schema.column(name)}, nil
+}
+
+// parseValue converts raw to the Go type of field.
+func parseValue(field Field, raw string) (any, error) {
+	switch field.Type {
+	case Int:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", raw)
+		}
+		return n, nil
+	case Float:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", raw)
+		}
+		return n, nil
+	case Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a boolean", raw)
+		}
+		return b, nil
+	case Time:
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			if t, err = time.Parse(time.DateOnly, raw); err != nil {
+				return nil, fmt.Errorf("%q is not an RFC 3339 time or a date", raw)
+			}
+		}
+		return t.UTC(), nil
+	default:
+		if len(field.Enum) > 0 && !contains(field.Enum, raw) {
+			return nil, fmt.Errorf("%q is not one of %s", raw, joinList(field.Enum))
+		}
+		return raw, nil
+	}
+}
+
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+// sortFilters orders filters by field so translated queries are deterministic.
+func sortFilters(filters []Filter) {
+	sort.SliceStable(filters, func(i, j int) bool { return filters[i].Field < filters[j].Field })
+}
@@ -0,0 +1,165 @@
+package query
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Slice evaluates q over items held in memory, the way MongoPageFilter and MongoFindOptions do
+// in Mongo: it keeps the items matching the filters, sorts them, skips to the page or past the
+// cursor and returns at most FetchLimit of them with the number of matches, ready for Page.
+// values returns the values of an item keyed by field name, as for Page.
+func Slice[T any](q *Query, items []T, values func(T) map[string]any) ([]T, int64) {
+	type row struct {
+		item   T
+		values map[string]any
+	}
+
+	var rows []row
+	for _, item := range items {
+		v := values(item)
+		if q.matches(v) {
+			rows = append(rows, row{item, v})
+		}
+	}
+	total := int64(len(rows))
+
+	sort.SliceStable(rows, func(i, j int) bool { return q.compare(rows[i].values, rows[j].values) < 0 })
+
+	start := min(q.Offset(), len(rows))
+	if q.after != nil {
+		start = sort.Search(len(rows), func(i int) bool { return q.afterCursor(rows[i].values) })
+	}
+	end := min(start+q.FetchLimit(), len(rows))
+
+	page := make([]T, 0, end-start)
+	for _, r := range rows[start:end] {
+		page = append(page, r.item)
+	}
+	return page, total
+}
+
+func (q *Query) matches(values map[string]any) bool {
+	for _, f := range q.Filters {
+		if !filterMatches(f, values[f.Field]) {
+			return false
+		}
+	}
+	return true
+}
+
+func filterMatches(f Filter, v any) bool {
+	switch f.Op {
+	case OpIn, OpNin:
+		in := false
+		for _, want := range f.Values {
+			if compareValues(v, want) == 0 {
+				in = true
+				break
+			}
+		}
+		return in == (f.Op == OpIn)
+	case OpContains:
+		s, _ := v.(string)
+		return strings.Contains(strings.ToLower(s), strings.ToLower(f.Value().(string)))
+	}
+
+	c := compareValues(v, f.Value())
+	switch f.Op {
+	case OpEq:
+		return c == 0
+	case OpNe:
+		return c != 0
+	case OpGt:
+		return c > 0
+	case OpGte:
+		return c >= 0
+	case OpLt:
+		return c < 0
+	case OpLte:
+		return c <= 0
+	}
+	return false
+}
+
+// compare orders two items by the sort of q.
+func (q *Query) compare(a, b map[string]any) int {
+	for _, s := range q.Sort {
+		if c := compareValues(a[s.Field], b[s.Field]); c != 0 {
+			if s.Desc {
+				return -c
+			}
+			return c
+		}
+	}
+	return 0
+}
+
+// afterCursor reports whether an item sorts after the last item of the previous page.
+func (q *Query) afterCursor(values map[string]any) bool {
+	last := make(map[string]any, len(q.Sort))
+	for i, s := range q.Sort {
+		last[s.Field] = q.after[i]
+	}
+	return q.compare(values, last) > 0
+}
+
+// compareValues orders two values of the same field type; nil sorts first. Integers of any
+// width compare as int64, as filters and cursors carry them.
+func compareValues(a, b any) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+
+	switch x := a.(type) {
+	case string:
+		return strings.Compare(x, b.(string))
+	case int:
+		return compareOrdered(int64(x), asInt64(b))
+	case int32:
+		return compareOrdered(int64(x), asInt64(b))
+	case int64:
+		return compareOrdered(x, asInt64(b))
+	case float64:
+		return compareOrdered(x, b.(float64))
+	case bool:
+		return compareOrdered(boolRank(x), boolRank(b.(bool)))
+	case time.Time:
+		return x.Compare(b.(time.Time))
+	}
+	return 0
+}
+
+func compareOrdered[T int64 | float64 | int](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func asInt64(v any) int64 {
+	switch n := v.(type) {
+	case int:
+		return int64(n)
+	case int32:
+		return int64(n)
+	default:
+		return n.(int64)
+	}
+}
+
+func boolRank(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,91 @@
+package query
+
+import (
+	"regexp"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoFilter renders the filters as a Mongo filter document. Use it for CountDocuments.
+func (q *Query) MongoFilter() bson.M {
+	filter := bson.M{}
+	var and []bson.M
+
+	for _, f := range q.Filters {
+		cond := mongoCondition(f)
+		if existing, ok := filter[f.column]; ok {
+			// Several filters on one field (e.g. a gte and a lt) are combined with $and.
+			and = append(and, bson.M{f.column: existing}, bson.M{f.column: cond})
+			delete(filter, f.column)
+			continue
+		}
+		filter[f.column] = cond
+	}
+
+	if len(and) > 0 {
+		filter["$and"] = and
+	}
+	return filter
+}
+
+// MongoPageFilter is MongoFilter plus the cursor condition in cursor mode.
+func (q *Query) MongoPageFilter() bson.M {
+	filter := q.MongoFilter()
+	if q.after == nil {
+		return filter
+	}
+
+	keyset := mongoKeyset(q.Sort, q.after)
+	if len(filter) == 0 {
+		return keyset
+	}
+	return bson.M{"$and": bson.A{filter, keyset}}
+}
+
+// MongoFindOptions sets the sort, skip and limit for one page.
+func (q *Query) MongoFindOptions() *options.FindOptions {
+	sort := bson.D{}
+	for _, s := range q.Sort {
+		dir := 1
+		if s.Desc {
+			dir = -1
+		}
+		sort = append(sort, bson.E{Key: s.column, Value: dir})
+	}
+
+	return options.Find().
+		SetSort(sort).
+		SetSkip(int64(q.Offset())).
+		SetLimit(int64(q.FetchLimit()))
+}
+
+func mongoCondition(f Filter) any {
+	switch f.Op {
+	case OpEq:
+		return f.Value()
+	case OpIn, OpNin:
+		return bson.M{"$" + string(f.Op): f.Values}
+	case OpContains:
+		return bson.M{"$regex": regexp.QuoteMeta(f.Value().(string)), "$options": "i"}
+	default:
+		return bson.M{"$" + string(f.Op): f.Value()}
+	}
+}
+
+func mongoKeyset(sort []SortField, after []any) bson.M {
+	ors := bson.A{}
+	for i, s := range sort {
+		cond := bson.M{}
+		for j := 0; j < i; j++ {
+			cond[sort[j].column] = after[j]
+		}
+		cmp := "$gt"
+		if s.Desc {
+			cmp = "$lt"
+		}
+		cond[s.column] = bson.M{cmp: after[i]}
+		ors = append(ors, cond)
+	}
+	return bson.M{"$or": ors}
+}
@@ -0,0 +1,112 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MySQLWhere renders the filters as a WHERE clause (without the keyword) and its arguments.
+// Use it for the COUNT query; it returns "1 = 1" when there are no filters. Column names come
+// from the schema whitelist, never from the request.
+func (q *Query) MySQLWhere() (string, []any) {
+	var (
+		conds []string
+		args  []any
+	)
+
+	for _, f := range q.Filters {
+		cond, fargs := mysqlCondition(f)
+		conds = append(conds, cond)
+		args = append(args, fargs...)
+	}
+
+	if len(conds) == 0 {
+		return "1 = 1", nil
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// MySQLPage renders the tail of a SELECT for one page: WHERE (filters and cursor), ORDER BY
+// and LIMIT/OFFSET, e.g. db.Query("SELECT id, name FROM account "+tail, args...).
+// It fetches FetchLimit rows so Page can tell whether more exist.
+func (q *Query) MySQLPage() (string, []any) {
+	where, args := q.MySQLWhere()
+
+	if q.after != nil {
+		keyset, kargs := mysqlKeyset(q.Sort, q.after)
+		where = where + " AND " + keyset
+		args = append(args, kargs...)
+	}
+
+	order := make([]string, len(q.Sort))
+	for i, s := range q.Sort {
+		dir := "ASC"
+		if s.Desc {
+			dir = "DESC"
+		}
+		order[i] = fmt.Sprintf("`%s` %s", s.column, dir)
+	}
+
+	tail := "WHERE " + where
+	if len(order) > 0 {
+		tail += " ORDER BY " + strings.Join(order, ", ")
+	}
+	tail += " LIMIT ? OFFSET ?"
+	args = append(args, q.FetchLimit(), q.Offset())
+
+	return tail, args
+}
+
+func mysqlCondition(f Filter) (string, []any) {
+	col := "`" + f.column + "`"
+	switch f.Op {
+	case OpIn, OpNin:
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(f.Values)), ", ")
+		not := ""
+		if f.Op == OpNin {
+			not = "NOT "
+		}
+		return fmt.Sprintf("%s %sIN (%s)", col, not, placeholders), f.Values
+	case OpContains:
+		// Lowered on both sides so the match does not depend on the column collation.
+		return "LOWER(" + col + `) LIKE ? ESCAPE '\\'`, []any{"%" + escapeLike(strings.ToLower(fmt.Sprint(f.Value()))) + "%"}
+	default:
+		return fmt.Sprintf("%s %s ?", col, sqlOperators[f.Op]), []any{f.Value()}
+	}
+}
+
+var sqlOperators = map[Op]string{
+	OpEq: "=", OpNe: "<>", OpGt: ">", OpGte: ">=", OpLt: "<", OpLte: "<=",
+}
+
+// mysqlKeyset renders "rows after the cursor" for a multi-column sort:
+// (a > ?) OR (a = ? AND b > ?) OR ...
+func mysqlKeyset(sort []SortField, after []any) (string, []any) {
+	var (
+		ors  []string
+		args []any
+	)
+
+	for i, s := range sort {
+		var ands []string
+		for j := 0; j < i; j++ {
+			ands = append(ands, fmt.Sprintf("`%s` = ?", sort[j].column))
+			args = append(args, after[j])
+		}
+		cmp := ">"
+		if s.Desc {
+			cmp = "<"
+		}
+		ands = append(ands, fmt.Sprintf("`%s` %s ?", s.column, cmp))
+		args = append(args, after[i])
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+
+	return "(" + strings.Join(ors, " OR ") + ")", args
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
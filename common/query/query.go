@@ -0,0 +1,230 @@
+// Package query parses list parameters (paging, sorting and filters) against a per-endpoint
+// whitelist and translates them into MySQL clauses, Mongo filters and Elasticsearch queries.
+//
+// A list request looks like:
+//
+//	GET /accounts?limit=20&sort=-created_at,name&status=in:activated,banned&created_at=gte:2025-01-01T00:00:00Z
+//
+// Paging is page based (page, limit) unless the client sends the opaque cursor returned in
+// next_cursor, in which case results continue after the last item of the previous page.
+//
+// The contains operator is allowed on String fields only and matches case-insensitively in
+// every store.
+package query
+
+import (
+	"elastic-logger-app/common"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Reserved parameter names; every other parameter is a filter.
+const (
+	ParamPage   = "page"
+	ParamLimit  = "limit"
+	ParamCursor = "cursor"
+	ParamSort   = "sort"
+)
+
+// FieldType decides how filter and cursor values are parsed.
+type FieldType int
+
+const (
+	String FieldType = iota
+	Int
+	Float
+	Bool
+	Time
+)
+
+// Field describes a field clients may sort or filter on.
+type Field struct {
+	Type FieldType
+	// Column is the name in storage; it defaults to the parameter name.
+	Column string
+	// Sortable allows the field in the sort parameter.
+	Sortable bool
+	// Ops lists the filter operators allowed on the field; empty means not filterable.
+	Ops []Op
+	// Enum restricts string values to this set when not empty.
+	Enum []string
+}
+
+func (f Field) allows(op Op) bool {
+	for _, allowed := range f.Ops {
+		if allowed == op {
+			return true
+		}
+	}
+	return false
+}
+
+// Schema is the whitelist of one list endpoint.
+type Schema struct {
+	Fields map[string]Field
+	// Key is a unique field appended to every sort as a tiebreaker, so cursors are stable.
+	Key string
+	// DefaultSort is used when the request has no sort parameter, e.g. "-created_at".
+	DefaultSort  string
+	DefaultLimit int
+	MaxLimit     int
+}
+
+// Validate reports mistakes in the schema itself, as opposed to the request.
+func (s Schema) Validate() error {
+	for name, f := range s.Fields {
+		if f.allows(OpContains) && f.Type != String {
+			return fmt.Errorf("query: operator %s needs a String field, %s is not one", OpContains, name)
+		}
+	}
+	return nil
+}
+
+func (s Schema) column(name string) string {
+	if col := s.Fields[name].Column; col != "" {
+		return col
+	}
+	return name
+}
+
+// Query is a parsed list request.
+type Query struct {
+	Page    int
+	Limit   int
+	Sort    []SortField
+	Filters []Filter
+
+	schema Schema
+	// after holds the sort values of the last item of the previous page in cursor mode.
+	after []any
+}
+
+// FromGin parses the query string of c against schema.
+func FromGin(c *gin.Context, schema Schema) (*Query, error) {
+	return Parse(c.Request.URL.Query(), schema)
+}
+
+// Parse parses values against schema. All problems are reported at once as a single
+// VALIDATION_FAILED AppError with one FieldError per offending parameter. An invalid schema
+// is a plain error, i.e. a server error.
+func Parse(values url.Values, schema Schema) (*Query, error) {
+	if err := schema.Validate(); err != nil {
+		return nil, err
+	}
+	if schema.DefaultLimit <= 0 {
+		schema.DefaultLimit = 20
+	}
+	if schema.MaxLimit <= 0 {
+		schema.MaxLimit = 100
+	}
+
+	q := &Query{Page: 1, Limit: schema.DefaultLimit, schema: schema}
+	var issues []common.FieldError
+
+	if raw := values.Get(ParamLimit); raw != "" {
+		n, err := strconv.Atoi(raw)
+		switch {
+		case err != nil:
+			issues = append(issues, issue(ParamLimit, "number", "", "limit must be a number"))
+		case n < 1 || n > schema.MaxLimit:
+			issues = append(issues, issue(ParamLimit, "range", fmt.Sprintf("1-%d", schema.MaxLimit),
+				fmt.Sprintf("limit must be between 1 and %d", schema.MaxLimit)))
+		default:
+			q.Limit = n
+		}
+	}
+
+	if raw := values.Get(ParamPage); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			issues = append(issues, issue(ParamPage, "min", "1", "page must be a number of at least 1"))
+		} else {
+			q.Page = n
+		}
+	}
+
+	rawSort := values.Get(ParamSort)
+	if rawSort == "" {
+		rawSort = schema.DefaultSort
+	}
+	sort, sortIssues := parseSort(rawSort, schema)
+	q.Sort = sort
+	issues = append(issues, sortIssues...)
+
+	for name, raws := range values {
+		switch name {
+		case ParamPage, ParamLimit, ParamCursor, ParamSort:
+			continue
+		}
+		for _, raw := range raws {
+			f, fi := parseFilter(name, raw, schema)
+			if fi != nil {
+				issues = append(issues, *fi)
+				continue
+			}
+			q.Filters = append(q.Filters, f)
+		}
+	}
+
+	if raw := values.Get(ParamCursor); raw != "" && len(sortIssues) == 0 {
+		after, err := decodeCursor(raw, q.Sort, schema)
+		if err != nil {
+			issues = append(issues, issue(ParamCursor, "cursor", "", err.Error()))
+		} else {
+			q.after = after
+			q.Page = 0
+		}
+	}
+
+	if len(issues) > 0 {
+		return nil, common.NewErrorFromCode(common.CodeValidationFailed, nil).
+			WithReason("invalid list query parameters").WithDetail("fields", issues)
+	}
+
+	sortFilters(q.Filters)
+	return q, nil
+}
+
+// Offset is the number of items to skip in page mode; it is zero in cursor mode.
+func (q *Query) Offset() int {
+	if q.Page <= 1 {
+		return 0
+	}
+	return (q.Page - 1) * q.Limit
+}
+
+// FetchLimit is the number of items to load: one more than Limit, to learn whether more exist.
+func (q *Query) FetchLimit() int {
+	return q.Limit + 1
+}
+
+// UsesCursor reports whether the request continues from a cursor.
+func (q *Query) UsesCursor() bool {
+	return q.after != nil
+}
+
+// Page trims items loaded with FetchLimit to the requested size and builds the Paging for
+// them. sortValues returns the values of the sort fields for an item, keyed by field name,
+// and is used to encode the next cursor.
+func Page[T any](q *Query, items []T, total int64, sortValues func(T) map[string]any) ([]T, common.Paging) {
+	paging := common.Paging{Page: q.Page, Limit: q.Limit, Total: total}
+
+	if len(items) > q.Limit {
+		items = items[:q.Limit]
+		paging.HasMore = true
+		paging.NextCursor = encodeCursor(q.Sort, sortValues(items[len(items)-1]))
+	}
+	return items, paging
+}
+
+func issue(field, rule, param, message string) common.FieldError {
+	return common.FieldError{Field: field, Rule: rule, Param: param, Message: message}
+}
+
+func joinList(values []string) string {
+	return strings.Join(values, ",")
+}
@@ -0,0 +1,207 @@
+package query
+
+import (
+	"elastic-logger-app/common"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var testSchema = Schema{
+	Fields: map[string]Field{
+		"id":         {Type: String, Column: "_id", Ops: []Op{OpEq, OpIn}},
+		"name":       {Type: String, Sortable: true, Ops: []Op{OpEq, OpContains}},
+		"status":     {Type: String, Ops: []Op{OpEq, OpIn}, Enum: []string{"activated", "banned"}},
+		"created_at": {Type: Time, Sortable: true, Ops: []Op{OpGte, OpLt}},
+	},
+	Key:         "id",
+	DefaultSort: "-created_at",
+}
+
+func mustParse(t *testing.T, raw string) *Query {
+	t.Helper()
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := Parse(values, testSchema)
+	if err != nil {
+		t.Fatalf("Parse(%q) = %v", raw, err)
+	}
+	return q
+}
+
+func TestParse(t *testing.T) {
+	q := mustParse(t, "page=2&limit=10&sort=name&status=in:activated,banned&created_at=gte:2025-01-01T00:00:00Z")
+
+	if q.Page != 2 || q.Limit != 10 || q.Offset() != 10 {
+		t.Fatalf("page %d limit %d offset %d", q.Page, q.Limit, q.Offset())
+	}
+	if got := sortSignature(q.Sort); got != "name,id" {
+		t.Fatalf("sort = %q, want name with the key tiebreaker", got)
+	}
+	if len(q.Filters) != 2 {
+		t.Fatalf("filters = %+v", q.Filters)
+	}
+	if f := q.Filters[0]; f.Field != "created_at" || f.Op != OpGte || !f.Value().(time.Time).Equal(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("created_at filter = %+v", f)
+	}
+	if f := q.Filters[1]; f.Op != OpIn || !reflect.DeepEqual(f.Values, []any{"activated", "banned"}) {
+		t.Fatalf("status filter = %+v", f)
+	}
+}
+
+func TestParseReportsEveryProblem(t *testing.T) {
+	values, _ := url.ParseQuery("limit=1000&sort=password&status=deleted&role=admin&created_at=eq:2025-01-01")
+	_, err := Parse(values, testSchema)
+
+	apperr := common.AsAppError(err)
+	if apperr.ErrorCode != common.CodeValidationFailed {
+		t.Fatalf("code = %s, want %s", apperr.ErrorCode, common.CodeValidationFailed)
+	}
+	fields := apperr.Details["fields"].([]common.FieldError)
+	rules := map[string]string{}
+	for _, f := range fields {
+		rules[f.Field] = f.Rule
+	}
+	want := map[string]string{"limit": "range", "sort": "sortable", "status": "type", "role": "unknown", "created_at": "op"}
+	if !reflect.DeepEqual(rules, want) {
+		t.Fatalf("issues = %v, want %v", rules, want)
+	}
+}
+
+func TestMySQLPage(t *testing.T) {
+	q := mustParse(t, "limit=5&sort=name&status=in:activated,banned&name=contains:A_b")
+
+	tail, args := q.MySQLPage()
+	wantTail := "WHERE LOWER(`name`) LIKE ? ESCAPE '\\\\' AND `status` IN (?, ?) ORDER BY `name` ASC, `_id` ASC LIMIT ? OFFSET ?"
+	if tail != wantTail {
+		t.Fatalf("tail =\n%s\nwant\n%s", tail, wantTail)
+	}
+	if want := []any{`%a\_b%`, "activated", "banned", 6, 0}; !reflect.DeepEqual(args, want) {
+		t.Fatalf("args = %v, want %v", args, want)
+	}
+}
+
+func TestMongoFilter(t *testing.T) {
+	q := mustParse(t, "status=activated&created_at=gte:2025-01-01&created_at=lt:2025-02-01")
+
+	filter := q.MongoFilter()
+	if filter["status"] != "activated" {
+		t.Fatalf("status condition = %v", filter["status"])
+	}
+	if and, ok := filter["$and"].([]bson.M); !ok || len(and) != 2 {
+		t.Fatalf("two created_at conditions were not combined with $and: %v", filter)
+	}
+}
+
+func TestContainsIgnoresCase(t *testing.T) {
+	q := mustParse(t, "name=contains:Al.")
+
+	regex := q.MongoFilter()["name"].(bson.M)
+	if regex["$regex"] != `Al\.` || regex["$options"] != "i" {
+		t.Fatalf("mongo condition = %v", regex)
+	}
+
+	src, err := q.ElasticQuery().Source()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wildcard := src.(map[string]any)["bool"].(map[string]any)["filter"].(map[string]any)["wildcard"].(map[string]any)["name"].(map[string]any)
+	if wildcard["case_insensitive"] != true {
+		t.Fatalf("elastic condition = %v", wildcard)
+	}
+}
+
+func TestSchemaRejectsContainsOnNonStringField(t *testing.T) {
+	schema := Schema{Fields: map[string]Field{"age": {Type: Int, Ops: []Op{OpEq, OpContains}}}, Key: "age"}
+	if err := schema.Validate(); err == nil {
+		t.Fatal("contains on an Int field was accepted")
+	}
+
+	values, _ := url.ParseQuery("age=contains:1")
+	_, err := Parse(values, schema)
+	if err == nil || common.AsAppError(err).StatusCode() != http.StatusInternalServerError {
+		t.Fatalf("parse with an invalid schema = %v, want a server error", err)
+	}
+	if err := testSchema.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	q := mustParse(t, "limit=2&sort=-created_at")
+	created := time.Date(2025, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	type item struct {
+		id      string
+		created time.Time
+	}
+	items, paging := Page(q, []item{{"a", created}, {"b", created}, {"c", created}}, 10,
+		func(i item) map[string]any { return map[string]any{"id": i.id, "created_at": i.created} })
+	if len(items) != 2 || !paging.HasMore || paging.NextCursor == "" {
+		t.Fatalf("items %d paging %+v", len(items), paging)
+	}
+
+	next := mustParse(t, "limit=2&sort=-created_at&cursor="+paging.NextCursor)
+	if !next.UsesCursor() || next.Offset() != 0 {
+		t.Fatal("cursor was not applied")
+	}
+	if want := []any{created, "b"}; !reflect.DeepEqual(next.after, want) {
+		t.Fatalf("after = %v, want %v", next.after, want)
+	}
+
+	values, _ := url.ParseQuery("sort=name&cursor=" + paging.NextCursor)
+	if _, err := Parse(values, testSchema); err == nil {
+		t.Fatal("cursor issued for another sort was accepted")
+	}
+}
+
+func TestSlice(t *testing.T) {
+	type item struct {
+		id, name, status string
+		created          time.Time
+	}
+	day := func(d int) time.Time { return time.Date(2025, 1, d, 0, 0, 0, 0, time.UTC) }
+	items := []item{
+		{"a", "Alice", "activated", day(1)},
+		{"b", "Bob", "banned", day(2)},
+		{"c", "Carol", "activated", day(3)},
+		{"d", "alfred", "activated", day(3)},
+		{"e", "Eve", "activated", day(5)},
+	}
+	values := func(i item) map[string]any {
+		return map[string]any{"id": i.id, "name": i.name, "status": i.status, "created_at": i.created}
+	}
+	ids := func(items []item) string {
+		var out []string
+		for _, i := range items {
+			out = append(out, i.id)
+		}
+		return strings.Join(out, ",")
+	}
+
+	q := mustParse(t, "limit=2&status=activated&created_at=lt:2025-01-05T00:00:00Z")
+	page, total := Slice(q, items, values)
+	if total != 3 || ids(page) != "c,d,a" {
+		t.Fatalf("page = %s, total = %d", ids(page), total)
+	}
+
+	page, paging := Page(q, page, total, values)
+	next := mustParse(t, "limit=2&status=activated&created_at=lt:2025-01-05T00:00:00Z&cursor="+paging.NextCursor)
+	if page, _ := Slice(next, items, values); ids(page) != "a" {
+		t.Fatalf("next page = %s", ids(page))
+	}
+
+	if page, total := Slice(mustParse(t, "name=contains:AL&sort=name"), items, values); total != 2 || ids(page) != "a,d" {
+		t.Fatalf("contains page = %s, total = %d", ids(page), total)
+	}
+	if page, _ := Slice(mustParse(t, "limit=2&page=3"), items, values); ids(page) != "a" {
+		t.Fatalf("third page = %s", ids(page))
+	}
+}
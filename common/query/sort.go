@@ -0,0 +1,62 @@
+package query
+
+import (
+	"elastic-logger-app/common"
+	"fmt"
+	"strings"
+)
+
+// SortField is one key of a sort, e.g. "-created_at" is {Field: "created_at", Desc: true}.
+type SortField struct {
+	Field string `json:"field"`
+	Desc  bool   `json:"desc"`
+
+	column string
+}
+
+// parseSort parses a comma-separated sort such as "-created_at,name" and appends the
+// schema key as a tiebreaker.
+func parseSort(raw string, schema Schema) ([]SortField, []common.FieldError) {
+	var (
+		sort   []SortField
+		issues []common.FieldError
+		seen   = map[string]bool{}
+	)
+
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		desc := strings.HasPrefix(part, "-")
+		name := strings.TrimLeft(part, "+-")
+		if f, ok := schema.Fields[name]; !ok || !f.Sortable {
+			issues = append(issues, issue(ParamSort, "sortable", name, fmt.Sprintf("cannot sort by %s", name)))
+			continue
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		sort = append(sort, SortField{Field: name, Desc: desc, column: schema.column(name)})
+	}
+
+	if schema.Key != "" && !seen[schema.Key] {
+		sort = append(sort, SortField{Field: schema.Key, column: schema.column(schema.Key)})
+	}
+	return sort, issues
+}
+
+// sortSignature renders sort in the syntax of the sort parameter; cursors are tied to it.
+func sortSignature(sort []SortField) string {
+	parts := make([]string, len(sort))
+	for i, s := range sort {
+		if s.Desc {
+			parts[i] = "-" + s.Field
+		} else {
+			parts[i] = s.Field
+		}
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,46 @@
+package rabbitmq
+
+import (
+	"github.com/streadway/amqp"
+)
+
+// Connection opens channels on a broker. Publishers and consumers only depend on this, so they
+// run on a streadway connection (NewConnection) as well as on the in-memory broker of
+// common/rabbitmqtest.
+type Connection interface {
+	Channel() (Channel, error)
+}
+
+// Channel is the part of *amqp.Channel used by publishers and consumers.
+type Channel interface {
+	ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error
+	QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+	QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error
+	Qos(prefetchCount, prefetchSize int, global bool) error
+	Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error)
+	Cancel(consumer string, noWait bool) error
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+	Confirm(noWait bool) error
+	NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation
+	Close() error
+}
+
+var _ Channel = (*amqp.Channel)(nil)
+
+type amqpConnection struct {
+	conn *amqp.Connection
+}
+
+// NewConnection adapts a streadway connection, e.g. from configs.ConnectRabbitMQ. Closing
+// the connection stays with the caller.
+func NewConnection(conn *amqp.Connection) Connection {
+	return amqpConnection{conn: conn}
+}
+
+func (c amqpConnection) Channel() (Channel, error) {
+	ch, err := c.conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
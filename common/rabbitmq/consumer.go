@@ -0,0 +1,334 @@
+package rabbitmq
+
+import (
+	"context"
+	"elastic-logger-app/common"
+	"elastic-logger-app/common/logger"
+	"elastic-logger-app/common/metrics"
+	"elastic-logger-app/common/tracing"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/streadway/amqp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// HandlerFunc processes a single delivery. Returning an error schedules the message for retry.
+type HandlerFunc func(ctx context.Context, d amqp.Delivery) error
+
+// ConsumerConfig controls how a Consumer reads from its queue and retries failures.
+type ConsumerConfig struct {
+	// Exchange is the topic exchange the work queue is bound to.
+	Exchange string
+	// Queue is the durable work queue; retry and dead-letter queues are derived from its name.
+	Queue string
+	// Prefetch is the maximum number of unacknowledged deliveries held by the consumer.
+	Prefetch int
+	// Workers is the number of goroutines processing deliveries concurrently.
+	Workers int
+	// MaxAttempts is the total number of times a message is handled before it is dead-lettered.
+	MaxAttempts int
+	// RetryDelays are the delays applied before each retry. When there are more retries than
+	// delays, the last delay is reused.
+	RetryDelays []time.Duration
+	// RequeueDelay is how long a message is held before it is requeued when it could not be
+	// moved to a retry or dead-letter queue, so a broker refusing publishes is not hit in a
+	// hot loop.
+	RequeueDelay time.Duration
+}
+
+// republishTimeout bounds the wait for the broker to confirm a retry or dead-letter publish.
+const republishTimeout = 30 * time.Second
+
+// DefaultConsumerConfig returns a config with increasing retry delays suitable for most consumers.
+func DefaultConsumerConfig(exchange, queue string) ConsumerConfig {
+	return ConsumerConfig{
+		Exchange:     exchange,
+		Queue:        queue,
+		Prefetch:     10,
+		Workers:      4,
+		MaxAttempts:  4,
+		RetryDelays:  []time.Duration{time.Second, 10 * time.Second, time.Minute},
+		RequeueDelay: 5 * time.Second,
+	}
+}
+
+type Consumer struct {
+	conn     Connection
+	cfg      ConsumerConfig
+	keys     []string
+	handlers map[string]HandlerFunc
+}
+
+func NewConsumer(conn Connection, cfg ConsumerConfig) *Consumer {
+	if cfg.Prefetch <= 0 {
+		cfg.Prefetch = 1
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 1
+	}
+	if cfg.MaxAttempts > 1 && len(cfg.RetryDelays) == 0 {
+		cfg.RetryDelays = []time.Duration{time.Second}
+	}
+	if cfg.RequeueDelay <= 0 {
+		cfg.RequeueDelay = time.Second
+	}
+
+	return &Consumer{
+		conn:     conn,
+		cfg:      cfg,
+		handlers: make(map[string]HandlerFunc),
+	}
+}
+
+// Handle registers h for messages whose routing key matches the topic pattern key.
+// The work queue is bound to every registered pattern when Run is called.
+func (c *Consumer) Handle(key string, h HandlerFunc) {
+	if _, exists := c.handlers[key]; !exists {
+		c.keys = append(c.keys, key)
+	}
+	c.handlers[key] = h
+}
+
+// Run declares the topology, starts the workers and blocks until ctx is cancelled
+// or the delivery channel is closed by the broker.
+func (c *Consumer) Run(ctx context.Context) error {
+	if len(c.handlers) == 0 {
+		return errors.New("rabbitmq: consumer has no handlers registered")
+	}
+
+	ch, err := c.conn.Channel()
+	if err != nil {
+		return fmt.Errorf("open consumer channel: %w", err)
+	}
+	defer ch.Close()
+
+	if err := declareTopology(ch, c.cfg, c.keys); err != nil {
+		return err
+	}
+
+	if err := ch.Qos(c.cfg.Prefetch, 0, false); err != nil {
+		return fmt.Errorf("set prefetch: %w", err)
+	}
+
+	// Retries and dead letters go out on their own channel in confirm mode, so a delivery is
+	// only acked once the broker holds its copy.
+	republisher, err := NewPublisher(c.conn, c.cfg.Exchange)
+	if err != nil {
+		return err
+	}
+	defer republisher.Close()
+
+	tag := c.cfg.Queue + "-" + common.GenUUID().String()
+	deliveries, err := ch.Consume(c.cfg.Queue, tag, false, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("consume %s: %w", c.cfg.Queue, err)
+	}
+
+	// Handlers run on a context that survives shutdown so the message in hand is finished and acked.
+	handlerCtx := context.WithoutCancel(ctx)
+
+	var wg sync.WaitGroup
+	for i := 0; i < c.cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range deliveries {
+				c.process(c.deliveryContext(handlerCtx, d), ctx.Done(), republisher, d)
+			}
+		}()
+	}
+
+	go func() {
+		<-ctx.Done()
+		// Cancelling the consumer closes deliveries; unacked prefetched messages are requeued by the broker.
+		if err := ch.Cancel(tag, false); err != nil {
+			logger.FromContext(ctx).Warn("cancel consumer", zap.String("consumer_tag", tag), zap.Error(err))
+		}
+	}()
+
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return nil
+	}
+	return fmt.Errorf("rabbitmq: deliveries for %s closed by broker", c.cfg.Queue)
+}
+
+// process runs the handler for d and acknowledges it only after it was handled
+// or safely moved to a retry or dead-letter queue. stop is closed when the consumer shuts down.
+func (c *Consumer) process(ctx context.Context, stop <-chan struct{}, p *Publisher, d amqp.Delivery) {
+	key := originalRoutingKey(d)
+	if d.Redelivered || retryCount(d) > 0 {
+		metrics.ObserveRedelivery(c.cfg.Queue)
+	}
+
+	h := c.handlerFor(key)
+	if h == nil {
+		logger.FromContext(ctx).Error("no handler registered, moving message to dead-letter queue")
+		c.settle(ctx, stop, d, metrics.OutcomeDeadLetter, c.deadLetter(ctx, p, d, key, fmt.Errorf("no handler registered for routing key %q", key)))
+		return
+	}
+
+	spanCtx, span := tracing.Start(tracing.ExtractAMQP(ctx, d.Headers), key+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemRabbitmq,
+			semconv.MessagingDestinationName(c.cfg.Queue),
+			semconv.MessagingRabbitmqDestinationRoutingKey(key),
+			semconv.MessagingMessageID(d.MessageId),
+		),
+	)
+	err := h(spanCtx, d)
+	tracing.End(span, err)
+
+	if err == nil {
+		c.settle(ctx, stop, d, metrics.OutcomeAck, nil)
+		return
+	}
+
+	attempt := retryCount(d) + 1
+	if attempt >= c.cfg.MaxAttempts {
+		logger.FromContext(ctx).Error("handler failed, moving message to dead-letter queue", zap.Int("attempt", attempt), zap.Error(err))
+		c.settle(ctx, stop, d, metrics.OutcomeDeadLetter, c.deadLetter(ctx, p, d, key, err))
+		return
+	}
+
+	logger.FromContext(ctx).Warn("handler failed, scheduling retry", zap.Int("attempt", attempt), zap.Error(err))
+	c.settle(ctx, stop, d, metrics.OutcomeRetry, c.retry(ctx, p, d, key, attempt))
+}
+
+// settle acknowledges d once it was handled or its republish was confirmed. If republishing
+// failed, d is held for RequeueDelay, or until the consumer stops, and then requeued so the
+// message is never lost.
+func (c *Consumer) settle(ctx context.Context, stop <-chan struct{}, d amqp.Delivery, outcome string, republishErr error) {
+	log := logger.FromContext(ctx)
+	if republishErr != nil {
+		metrics.ObserveConsume(c.cfg.Queue, metrics.OutcomeRequeue)
+		log.Error("republish message, requeueing after a delay", zap.Duration("delay", c.cfg.RequeueDelay), zap.Error(republishErr))
+
+		timer := time.NewTimer(c.cfg.RequeueDelay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-stop:
+		}
+		if err := d.Nack(false, true); err != nil {
+			log.Error("nack message", zap.Error(err))
+		}
+		return
+	}
+
+	metrics.ObserveConsume(c.cfg.Queue, outcome)
+	if err := d.Ack(false); err != nil {
+		log.Error("ack message", zap.Error(err))
+	}
+}
+
+// deliveryContext returns ctx with the request ID restored from the delivery and a logger
+// carrying the delivery's identifying fields.
+func (c *Consumer) deliveryContext(ctx context.Context, d amqp.Delivery) context.Context {
+	requestID, _ := d.Headers[common.HeaderRequestID].(string)
+	if requestID == "" {
+		requestID = d.CorrelationId
+	}
+	if requestID != "" {
+		ctx = common.ContextWithRequestID(ctx, requestID)
+	}
+
+	return logger.With(ctx,
+		zap.String("queue", c.cfg.Queue),
+		zap.String("message_id", d.MessageId),
+		zap.String("routing_key", originalRoutingKey(d)),
+		zap.Int("retry_count", retryCount(d)),
+	)
+}
+
+func (c *Consumer) handlerFor(key string) HandlerFunc {
+	if h, ok := c.handlers[key]; ok {
+		return h
+	}
+	for _, pattern := range c.keys {
+		if MatchTopic(pattern, key) {
+			return c.handlers[pattern]
+		}
+	}
+	return nil
+}
+
+func (c *Consumer) retry(ctx context.Context, p *Publisher, d amqp.Delivery, key string, attempt int) error {
+	level := attempt - 1
+	if level >= len(c.cfg.RetryDelays) {
+		level = len(c.cfg.RetryDelays) - 1
+	}
+
+	msg := republishing(d, key)
+	msg.Headers[HeaderRetryCount] = int32(attempt)
+	msg.Expiration = expiration(c.cfg.RetryDelays[level])
+
+	return republish(ctx, p, retryQueueName(c.cfg.Queue, level), msg)
+}
+
+func (c *Consumer) deadLetter(ctx context.Context, p *Publisher, d amqp.Delivery, key string, cause error) error {
+	msg := republishing(d, key)
+	msg.Headers[HeaderRetryCount] = int32(retryCount(d))
+	msg.Headers[HeaderFailureReason] = cause.Error()
+	msg.Headers[HeaderFailedAt] = time.Now().UTC().Format(time.RFC3339Nano)
+
+	return republish(ctx, p, deadLetterQueueName(c.cfg.Queue), msg)
+}
+
+func republish(ctx context.Context, p *Publisher, queue string, msg amqp.Publishing) error {
+	ctx, cancel := context.WithTimeout(ctx, republishTimeout)
+	defer cancel()
+	return p.Republish(ctx, queue, msg)
+}
+
+// republishing copies d into a new persistent message that remembers the original routing key.
+func republishing(d amqp.Delivery, key string) amqp.Publishing {
+	headers := amqp.Table{}
+	for k, v := range d.Headers {
+		headers[k] = v
+	}
+	headers[HeaderOriginalRoutingKey] = key
+
+	return amqp.Publishing{
+		Headers:         headers,
+		ContentType:     d.ContentType,
+		ContentEncoding: d.ContentEncoding,
+		DeliveryMode:    amqp.Persistent,
+		CorrelationId:   d.CorrelationId,
+		MessageId:       d.MessageId,
+		Timestamp:       d.Timestamp,
+		Type:            d.Type,
+		AppId:           d.AppId,
+		Body:            d.Body,
+	}
+}
+
+func originalRoutingKey(d amqp.Delivery) string {
+	if key, ok := d.Headers[HeaderOriginalRoutingKey].(string); ok && key != "" {
+		return key
+	}
+	return d.RoutingKey
+}
+
+func retryCount(d amqp.Delivery) int {
+	switch v := d.Headers[HeaderRetryCount].(type) {
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case int:
+		return v
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,267 @@
+package rabbitmq_test
+
+import (
+	"context"
+	"elastic-logger-app/common/rabbitmq"
+	"elastic-logger-app/common/rabbitmqtest"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// run starts c on the broker and returns a function that stops it and returns what Run did.
+func run(t *testing.T, b *rabbitmqtest.Broker, c *rabbitmq.Consumer, queue string) func() error {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- c.Run(ctx) }()
+
+	waitConsumers(t, b, queue, 1)
+	return func() error {
+		cancel()
+		return <-done
+	}
+}
+
+func waitConsumers(t *testing.T, b *rabbitmqtest.Broker, queue string, n int) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := b.WaitConsumers(ctx, queue, n); err != nil {
+		t.Fatalf("waiting for %d consumers on %s: %v", n, queue, err)
+	}
+}
+
+func waitIdle(t *testing.T, b *rabbitmqtest.Broker) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := b.WaitIdle(ctx); err != nil {
+		t.Fatalf("broker not idle: %v", err)
+	}
+}
+
+func testConfig() rabbitmq.ConsumerConfig {
+	cfg := rabbitmq.DefaultConsumerConfig("events", "work")
+	cfg.Workers = 1
+	cfg.MaxAttempts = 3
+	cfg.RetryDelays = []time.Duration{time.Second, 10 * time.Second}
+	return cfg
+}
+
+func TestConsumerRetriesThenDeadLetters(t *testing.T) {
+	b := rabbitmqtest.NewBroker()
+	var attempts atomic.Int32
+	c := rabbitmq.NewConsumer(b, testConfig())
+	c.Handle("account.*", func(ctx context.Context, d amqp.Delivery) error {
+		attempts.Add(1)
+		return errors.New("projection unavailable")
+	})
+	stop := run(t, b, c, "work")
+
+	p, err := rabbitmq.NewPublisher(b, "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if err := p.Publish(context.Background(), "account.created", []byte(`{"id":"1"}`), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	waitIdle(t, b)
+	if attempts.Load() != 1 || len(b.Messages("work.retry.1")) != 1 {
+		t.Fatalf("after first attempt: attempts = %d, retry.1 = %d", attempts.Load(), len(b.Messages("work.retry.1")))
+	}
+
+	// The first delay has not passed yet.
+	b.Advance(999 * time.Millisecond)
+	waitIdle(t, b)
+	if attempts.Load() != 1 {
+		t.Fatalf("retried before its delay: attempts = %d", attempts.Load())
+	}
+
+	b.Advance(time.Millisecond)
+	waitIdle(t, b)
+	if attempts.Load() != 2 || len(b.Messages("work.retry.2")) != 1 {
+		t.Fatalf("after second attempt: attempts = %d, retry.2 = %d", attempts.Load(), len(b.Messages("work.retry.2")))
+	}
+
+	b.Advance(10 * time.Second)
+	waitIdle(t, b)
+	dead := b.Messages("work.dlq")
+	if attempts.Load() != 3 || len(dead) != 1 {
+		t.Fatalf("after last attempt: attempts = %d, dlq = %d", attempts.Load(), len(dead))
+	}
+
+	h := dead[0].Headers
+	if h[rabbitmq.HeaderOriginalRoutingKey] != "account.created" || h[rabbitmq.HeaderRetryCount] != int32(2) ||
+		h[rabbitmq.HeaderFailureReason] != "projection unavailable" || string(dead[0].Body) != `{"id":"1"}` {
+		t.Fatalf("dead letter = %v %s", h, dead[0].Body)
+	}
+
+	if err := stop(); err != nil {
+		t.Fatalf("run after cancel: %v", err)
+	}
+}
+
+func TestConsumerSucceedsOnRetry(t *testing.T) {
+	b := rabbitmqtest.NewBroker()
+	var attempts atomic.Int32
+	var keys []string
+	c := rabbitmq.NewConsumer(b, testConfig())
+	c.Handle("account.#", func(ctx context.Context, d amqp.Delivery) error {
+		keys = append(keys, d.RoutingKey)
+		if attempts.Add(1) == 1 {
+			return errors.New("try again")
+		}
+		return nil
+	})
+	stop := run(t, b, c, "work")
+	defer stop()
+
+	p, err := rabbitmq.NewPublisher(b, "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if err := p.Publish(context.Background(), "account.email.changed", []byte(`{}`), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	waitIdle(t, b)
+	b.Advance(time.Second)
+	waitIdle(t, b)
+
+	if attempts.Load() != 2 || len(b.Messages("work.dlq")) != 0 || len(b.Messages("work.retry.1")) != 0 {
+		t.Fatalf("attempts = %d, dlq = %d", attempts.Load(), len(b.Messages("work.dlq")))
+	}
+	// The retry comes back through the default exchange; the routing key seen by handlers is
+	// the delivery's own, the original one is in a header.
+	if keys[0] != "account.email.changed" || keys[1] != "work" {
+		t.Fatalf("routing keys = %v", keys)
+	}
+}
+
+func TestConsumerRequeuesUnfinishedWorkOnShutdown(t *testing.T) {
+	b := rabbitmqtest.NewBroker()
+	cfg := testConfig()
+	cfg.Prefetch = 5
+
+	started, release := make(chan struct{}, 3), make(chan struct{})
+	c := rabbitmq.NewConsumer(b, cfg)
+	c.Handle("account.created", func(ctx context.Context, d amqp.Delivery) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	})
+	stop := run(t, b, c, "work")
+
+	p, err := rabbitmq.NewPublisher(b, "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	for range 3 {
+		if err := p.Publish(context.Background(), "account.created", []byte(`{}`), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	<-started
+	stopped := make(chan error, 1)
+	go func() { stopped <- stop() }()
+	waitConsumers(t, b, "work", 0)
+	close(release)
+	if err := <-stopped; err != nil {
+		t.Fatal(err)
+	}
+
+	// The message in hand was finished and acked; the prefetched ones went back to the queue.
+	ready := b.Messages("work")
+	if len(ready) != 2 || !ready[0].Redelivered || b.Unacked("work") != 0 {
+		t.Fatalf("ready = %d, unacked = %d", len(ready), b.Unacked("work"))
+	}
+}
+
+// eventually polls cond until it holds or a second has passed.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// publishBlocked publishes one message whose first handling waits for release and fails.
+func publishBlocked(t *testing.T, b *rabbitmqtest.Broker, cfg rabbitmq.ConsumerConfig, attempts *atomic.Int32) (release chan struct{}, stop func() error) {
+	t.Helper()
+	release = make(chan struct{})
+	c := rabbitmq.NewConsumer(b, cfg)
+	c.Handle("account.created", func(ctx context.Context, d amqp.Delivery) error {
+		if attempts.Add(1) == 1 {
+			<-release
+		}
+		return errors.New("projection unavailable")
+	})
+	stop = run(t, b, c, "work")
+
+	p, err := rabbitmq.NewPublisher(b, "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Close() })
+	if err := p.Publish(context.Background(), "account.created", []byte(`{}`), nil); err != nil {
+		t.Fatal(err)
+	}
+	return release, stop
+}
+
+func TestConsumerAcksOnlyAfterRetryIsConfirmed(t *testing.T) {
+	b := rabbitmqtest.NewBroker()
+	var attempts atomic.Int32
+	release, stop := publishBlocked(t, b, testConfig(), &attempts)
+	defer stop()
+
+	b.HoldConfirms()
+	close(release)
+	eventually(t, "the retry to be published", func() bool { return len(b.Messages("work.retry.1")) == 1 })
+	if b.Unacked("work") != 1 {
+		t.Fatalf("unacked = %d before the retry was confirmed, want 1", b.Unacked("work"))
+	}
+
+	b.ReleaseConfirms()
+	waitIdle(t, b)
+	if b.Unacked("work") != 0 || attempts.Load() != 1 {
+		t.Fatalf("unacked = %d, attempts = %d after the confirm", b.Unacked("work"), attempts.Load())
+	}
+}
+
+func TestConsumerHoldsMessageWhenRetryIsNacked(t *testing.T) {
+	b := rabbitmqtest.NewBroker()
+	cfg := testConfig()
+	cfg.RequeueDelay = 200 * time.Millisecond
+	var attempts atomic.Int32
+	release, stop := publishBlocked(t, b, cfg, &attempts)
+	defer stop()
+
+	b.NackPublishes()
+	close(release)
+	time.Sleep(50 * time.Millisecond)
+	if attempts.Load() != 1 || b.Unacked("work") != 1 || len(b.Messages("work.retry.1")) != 0 {
+		t.Fatalf("within the requeue delay: attempts = %d, unacked = %d, retry.1 = %d",
+			attempts.Load(), b.Unacked("work"), len(b.Messages("work.retry.1")))
+	}
+
+	b.AckPublishes()
+	eventually(t, "the requeued message to be retried", func() bool { return len(b.Messages("work.retry.1")) == 1 })
+	waitIdle(t, b)
+	if attempts.Load() != 2 || b.Unacked("work") != 0 {
+		t.Fatalf("attempts = %d, unacked = %d after the requeue", attempts.Load(), b.Unacked("work"))
+	}
+}
@@ -0,0 +1,146 @@
+package rabbitmq
+
+import (
+	"context"
+	"elastic-logger-app/common"
+	"elastic-logger-app/common/metrics"
+	"elastic-logger-app/common/tracing"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/streadway/amqp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type Publisher struct {
+	mu       sync.Mutex
+	ch       Channel
+	confirms chan amqp.Confirmation
+	exchange string
+	// published is the delivery tag of the last message sent: the broker numbers messages on a
+	// channel in confirm mode from 1.
+	published uint64
+}
+
+// NewPublisher opens a dedicated channel on conn in confirm mode and declares the topic
+// exchange messages are published to.
+func NewPublisher(conn Connection, exchange string) (*Publisher, error) {
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("open publisher channel: %w", err)
+	}
+
+	if err := ch.ExchangeDeclare(exchange, "topic", true, false, false, false, nil); err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("declare exchange %s: %w", exchange, err)
+	}
+
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("enable publisher confirms: %w", err)
+	}
+
+	return &Publisher{
+		ch:       ch,
+		confirms: ch.NotifyPublish(make(chan amqp.Confirmation, 1)),
+		exchange: exchange,
+	}, nil
+}
+
+// Publish sends a persistent JSON message to the exchange with a fresh message ID and waits
+// for the broker to confirm it. The request ID carried by ctx is copied into the message
+// headers and correlation ID.
+func (p *Publisher) Publish(ctx context.Context, routingKey string, body []byte, headers amqp.Table) error {
+	return p.PublishWithID(ctx, common.GenUUID().String(), routingKey, body, headers)
+}
+
+// PublishWithID is Publish with the message ID chosen by the caller, so a message sent again,
+// e.g. by the outbox relay, is recognized as a duplicate by consumers.
+func (p *Publisher) PublishWithID(ctx context.Context, messageID, routingKey string, body []byte, headers amqp.Table) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if headers == nil {
+		headers = amqp.Table{}
+	}
+
+	requestID := common.RequestIDFromContext(ctx)
+	if requestID != "" {
+		headers[common.HeaderRequestID] = requestID
+	}
+
+	ctx, span := tracing.Start(ctx, routingKey+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemRabbitmq,
+			semconv.MessagingDestinationName(p.exchange),
+			semconv.MessagingRabbitmqDestinationRoutingKey(routingKey),
+		),
+	)
+	tracing.InjectAMQP(ctx, headers)
+
+	err := p.publish(ctx, p.exchange, routingKey, amqp.Publishing{
+		Headers:       headers,
+		ContentType:   "application/json",
+		DeliveryMode:  amqp.Persistent,
+		MessageId:     messageID,
+		CorrelationId: requestID,
+		Timestamp:     time.Now().UTC(),
+		Body:          body,
+	})
+	tracing.End(span, err)
+	return err
+}
+
+// Republish sends msg unchanged to queue through the default exchange and waits for the
+// broker to confirm it. Consumers use it to move failed messages to their delay and
+// dead-letter queues without losing them.
+func (p *Publisher) Republish(ctx context.Context, queue string, msg amqp.Publishing) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return p.publish(ctx, "", queue, msg)
+}
+
+func (p *Publisher) publish(ctx context.Context, exchange, routingKey string, msg amqp.Publishing) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.ch.Publish(exchange, routingKey, false, false, msg); err != nil {
+		return err
+	}
+	p.published++
+	metrics.ObservePublish(exchange, routingKey)
+	return p.waitConfirm(ctx, p.published)
+}
+
+// waitConfirm blocks until the broker acks or nacks the message with delivery tag seq.
+// Confirmations for earlier messages, whose Publish gave up when its ctx ended, are dropped.
+func (p *Publisher) waitConfirm(ctx context.Context, seq uint64) error {
+	for {
+		select {
+		case confirm, ok := <-p.confirms:
+			if !ok {
+				return errors.New("rabbitmq: publisher channel closed before confirm")
+			}
+			metrics.ObserveConfirm(p.exchange, confirm.Ack)
+			if confirm.DeliveryTag < seq {
+				continue
+			}
+			if !confirm.Ack {
+				return fmt.Errorf("rabbitmq: broker nacked message %d", confirm.DeliveryTag)
+			}
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (p *Publisher) Close() error {
+	return p.ch.Close()
+}
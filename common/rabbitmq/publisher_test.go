@@ -0,0 +1,93 @@
+package rabbitmq_test
+
+import (
+	"context"
+	"elastic-logger-app/common"
+	"elastic-logger-app/common/rabbitmq"
+	"elastic-logger-app/common/rabbitmqtest"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+func TestPublisherDropsStaleConfirms(t *testing.T) {
+	b := rabbitmqtest.NewBroker()
+	p, err := rabbitmq.NewPublisher(b, "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	publish := func(timeout time.Duration) error {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		return p.Publish(ctx, "account.created", []byte(`{}`), nil)
+	}
+
+	// The first publish gives up before its confirm arrives, which comes in late.
+	b.HoldConfirms()
+	if err := publish(20 * time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("publish with a held confirm = %v", err)
+	}
+	b.ReleaseConfirms()
+
+	if err := publish(time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	// Had the second publish taken the late confirm as its own, its real confirm would now be
+	// waiting and this publish would succeed although its own confirm is held.
+	b.HoldConfirms()
+	if err := publish(20 * time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("publish with a held confirm after a stale one = %v", err)
+	}
+	b.ReleaseConfirms()
+}
+
+func TestPublisherCopiesRequestID(t *testing.T) {
+	b := rabbitmqtest.NewBroker()
+	p, err := rabbitmq.NewPublisher(b, "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	ch, err := b.Channel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ch.Close()
+	if _, err := ch.QueueDeclare("audit", true, false, false, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := ch.QueueBind("audit", "#", "events", false, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := common.ContextWithRequestID(context.Background(), "req-42")
+	if err := p.Publish(ctx, "account.created", []byte(`{}`), amqp.Table{"source": "test"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Publish(context.Background(), "account.deleted", []byte(`{}`), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	msgs := b.Messages("audit")
+	if len(msgs) != 2 {
+		t.Fatalf("got %d messages, want 2", len(msgs))
+	}
+	if got := msgs[0].Headers[common.HeaderRequestID]; got != "req-42" {
+		t.Errorf("header %s = %v, want req-42", common.HeaderRequestID, got)
+	}
+	if msgs[0].CorrelationId != "req-42" {
+		t.Errorf("correlation id = %q, want req-42", msgs[0].CorrelationId)
+	}
+	if msgs[0].Headers["source"] != "test" {
+		t.Errorf("caller headers were dropped: %v", msgs[0].Headers)
+	}
+	if _, ok := msgs[1].Headers[common.HeaderRequestID]; ok || msgs[1].CorrelationId != "" {
+		t.Errorf("message without a request ID carries one: %v", msgs[1])
+	}
+}
@@ -0,0 +1,95 @@
+package rabbitmq
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// Header keys used by the consumer framework to track retries and failures.
+const (
+	HeaderRetryCount         = "x-retry-count"
+	HeaderOriginalRoutingKey = "x-original-routing-key"
+	HeaderFailureReason      = "x-failure-reason"
+	HeaderFailedAt           = "x-failed-at"
+)
+
+// retryQueueName returns the delay queue used for the given retry level.
+func retryQueueName(queue string, level int) string {
+	return fmt.Sprintf("%s.retry.%d", queue, level+1)
+}
+
+// deadLetterQueueName returns the queue that collects messages which exhausted their attempts.
+func deadLetterQueueName(queue string) string {
+	return queue + ".dlq"
+}
+
+// declareTopology declares the work queue bound to the exchange, one delay queue per retry level
+// and the dead-letter queue.
+//
+// Delay queues have no consumer: messages sit there until their per-message TTL expires and are
+// then dead-lettered through the default exchange straight back to the work queue, so a retry
+// never reaches other queues bound to the same routing key. Every delay queue holds a single
+// delay, which avoids messages with a short TTL waiting behind ones with a longer TTL.
+func declareTopology(ch Channel, cfg ConsumerConfig, routingKeys []string) error {
+	if err := ch.ExchangeDeclare(cfg.Exchange, "topic", true, false, false, false, nil); err != nil {
+		return fmt.Errorf("declare exchange %s: %w", cfg.Exchange, err)
+	}
+
+	if _, err := ch.QueueDeclare(cfg.Queue, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("declare queue %s: %w", cfg.Queue, err)
+	}
+	for _, key := range routingKeys {
+		if err := ch.QueueBind(cfg.Queue, key, cfg.Exchange, false, nil); err != nil {
+			return fmt.Errorf("bind queue %s to %s: %w", cfg.Queue, key, err)
+		}
+	}
+
+	for level := range cfg.RetryDelays {
+		args := amqp.Table{
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": cfg.Queue,
+		}
+		if _, err := ch.QueueDeclare(retryQueueName(cfg.Queue, level), true, false, false, false, args); err != nil {
+			return fmt.Errorf("declare retry queue for %s: %w", cfg.Queue, err)
+		}
+	}
+
+	if _, err := ch.QueueDeclare(deadLetterQueueName(cfg.Queue), true, false, false, false, nil); err != nil {
+		return fmt.Errorf("declare dead-letter queue for %s: %w", cfg.Queue, err)
+	}
+
+	return nil
+}
+
+// MatchTopic reports whether a routing key matches a topic exchange binding pattern,
+// where "*" matches exactly one word and "#" matches zero or more words.
+func MatchTopic(pattern, key string) bool {
+	return matchWords(strings.Split(pattern, "."), strings.Split(key, "."))
+}
+
+func matchWords(pattern, words []string) bool {
+	if len(pattern) == 0 {
+		return len(words) == 0
+	}
+	switch pattern[0] {
+	case "#":
+		for i := 0; i <= len(words); i++ {
+			if matchWords(pattern[1:], words[i:]) {
+				return true
+			}
+		}
+		return false
+	case "*":
+		return len(words) > 0 && matchWords(pattern[1:], words[1:])
+	default:
+		return len(words) > 0 && pattern[0] == words[0] && matchWords(pattern[1:], words[1:])
+	}
+}
+
+// expiration formats a delay as a per-message TTL in milliseconds.
+func expiration(d time.Duration) string {
+	return fmt.Sprintf("%d", d.Milliseconds())
+}
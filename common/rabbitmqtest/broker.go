@@ -0,0 +1,327 @@
+// Package rabbitmqtest is an in-memory AMQP 0-9-1 broker implementing rabbitmq.Connection, so
+// publishers, consumers and their retry and dead-letter handling run in tests without RabbitMQ.
+//
+// It supports direct, fanout and topic exchanges plus the default exchange, durable queues,
+// publisher confirms, per-consumer prefetch, acks, nacks and rejects with or without requeue,
+// redelivery of unacknowledged messages when a channel closes, per-message and per-queue TTLs
+// and dead-lettering through x-dead-letter-exchange and x-dead-letter-routing-key, recorded in
+// the x-death header.
+//
+// Time only moves when the test calls Advance, so delay queues are deterministic. As in
+// RabbitMQ, a message expires only once it reaches the head of its queue. Mandatory and
+// immediate publishing, exclusive and auto-delete queues, queue deletion and flow control are
+// not supported.
+package rabbitmqtest
+
+import (
+	"context"
+	"elastic-logger-app/common/rabbitmq"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// Broker is the in-memory broker. Each call to Channel opens a new channel on it.
+type Broker struct {
+	mu        sync.Mutex
+	changed   *sync.Cond
+	now       time.Time
+	exchanges map[string]*exchange
+	queues    map[string]*queue
+	channels  map[*channel]bool
+	generated int
+	// holdConfirms keeps publisher confirmations back until ReleaseConfirms.
+	holdConfirms bool
+	// nackPublishes drops published messages and nacks them until AckPublishes.
+	nackPublishes bool
+}
+
+var _ rabbitmq.Connection = (*Broker)(nil)
+
+func NewBroker() *Broker {
+	b := &Broker{
+		now:       time.Now().UTC(),
+		exchanges: map[string]*exchange{},
+		queues:    map[string]*queue{},
+		channels:  map[*channel]bool{},
+	}
+	b.changed = sync.NewCond(&b.mu)
+	return b
+}
+
+func (b *Broker) Channel() (rabbitmq.Channel, error) {
+	c := &channel{b: b, consumers: map[string]*consumer{}, unacked: map[uint64]*pending{}, closing: make(chan struct{})}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.channels[c] = true
+	return c, nil
+}
+
+// Close closes every open channel, which requeues their unacknowledged messages.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	channels := make([]*channel, 0, len(b.channels))
+	for c := range b.channels {
+		channels = append(channels, c)
+	}
+	b.mu.Unlock()
+
+	for _, c := range channels {
+		c.shutdown()
+	}
+}
+
+// Now returns the broker clock.
+func (b *Broker) Now() time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.now
+}
+
+// Advance moves the broker clock forward by d, expiring and dead-lettering the messages whose
+// TTL has passed.
+func (b *Broker) Advance(d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.now = b.now.Add(d)
+	b.dispatch()
+}
+
+// HoldConfirms keeps back the confirmations of messages published from now on, as a slow
+// broker would; the messages themselves are routed as usual.
+func (b *Broker) HoldConfirms() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.holdConfirms = true
+}
+
+// ReleaseConfirms sends the held confirmations and stops holding new ones.
+func (b *Broker) ReleaseConfirms() {
+	b.mu.Lock()
+	b.holdConfirms = false
+	channels := make([]*channel, 0, len(b.channels))
+	for c := range b.channels {
+		channels = append(channels, c)
+	}
+	b.mu.Unlock()
+
+	for _, c := range channels {
+		c.releaseConfirms()
+	}
+}
+
+// NackPublishes makes the broker refuse messages published from now on, as one out of disk
+// space would: they are dropped, and channels in confirm mode get a nack for them.
+func (b *Broker) NackPublishes() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nackPublishes = true
+}
+
+// AckPublishes makes the broker accept published messages again.
+func (b *Broker) AckPublishes() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nackPublishes = false
+}
+
+// Queues returns the names of the declared queues, sorted.
+func (b *Broker) Queues() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	names := make([]string, 0, len(b.queues))
+	for name := range b.queues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Messages returns the messages ready in queue, head first, as deliveries that cannot be
+// acknowledged. It returns nil for an unknown queue.
+func (b *Broker) Messages(queue string) []amqp.Delivery {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	q, ok := b.queues[queue]
+	if !ok {
+		return nil
+	}
+	out := make([]amqp.Delivery, 0, len(q.ready))
+	for _, m := range q.ready {
+		out = append(out, m.delivery(nil, "", 0))
+	}
+	return out
+}
+
+// Unacked returns how many messages from queue are delivered and not yet acknowledged.
+func (b *Broker) Unacked(queue string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.unacked(queue)
+}
+
+// WaitConsumers blocks until queue exists with n consumers, e.g. 1 once a consumer started
+// and 0 once it was cancelled.
+func (b *Broker) WaitConsumers(ctx context.Context, queue string, n int) error {
+	return b.wait(ctx, func() bool {
+		q, ok := b.queues[queue]
+		return ok && len(q.consumers) == n
+	})
+}
+
+// WaitIdle blocks until no delivered message awaits an acknowledgement and every queue with a
+// consumer is empty. Messages waiting in queues nobody consumes, e.g. delay queues before
+// Advance or dead-letter queues, do not count.
+func (b *Broker) WaitIdle(ctx context.Context) error {
+	return b.wait(ctx, func() bool {
+		for name, q := range b.queues {
+			if b.unacked(name) > 0 || (len(q.consumers) > 0 && len(q.ready) > 0) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func (b *Broker) wait(ctx context.Context, done func() bool) error {
+	stop := context.AfterFunc(ctx, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.changed.Broadcast()
+	})
+	defer stop()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for !done() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		b.changed.Wait()
+	}
+	return nil
+}
+
+func (b *Broker) unacked(queue string) int {
+	n := 0
+	for c := range b.channels {
+		for _, p := range c.unacked {
+			if p.queue.name == queue {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// route enqueues a copy of msg in every queue bound to exchange with a matching key. The
+// default exchange "" routes to the queue named key. Unroutable messages are dropped.
+func (b *Broker) route(exchangeName, key string, msg amqp.Publishing) {
+	var targets []*queue
+	if exchangeName == "" {
+		if q, ok := b.queues[key]; ok {
+			targets = append(targets, q)
+		}
+	} else if ex, ok := b.exchanges[exchangeName]; ok {
+		targets = ex.route(b.queues, key)
+	}
+
+	for _, q := range targets {
+		m := &message{exchange: exchangeName, routingKey: key, msg: copyPublishing(msg)}
+		m.expiresAt = q.expiry(b.now, msg.Expiration)
+		q.ready = append(q.ready, m)
+	}
+}
+
+// dispatch expires messages at the head of every queue and hands ready messages to consumers
+// with room under their prefetch, until nothing moves. Dead-lettering may feed other queues,
+// hence the loop.
+func (b *Broker) dispatch() {
+	names := make([]string, 0, len(b.queues))
+	for name := range b.queues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for moved := true; moved; {
+		moved = false
+		for _, name := range names {
+			q := b.queues[name]
+			for len(q.ready) > 0 {
+				m := q.ready[0]
+				if m.expired(b.now) {
+					q.ready = q.ready[1:]
+					b.deadLetter(q, m, "expired")
+					moved = true
+					continue
+				}
+				cons := q.nextConsumer()
+				if cons == nil {
+					break
+				}
+				q.ready = q.ready[1:]
+				cons.deliver(m)
+				moved = true
+			}
+		}
+	}
+	b.changed.Broadcast()
+}
+
+// deadLetter republishes m to the dead-letter exchange of q, without its expiration and with
+// an x-death entry, or drops it when q has none.
+func (b *Broker) deadLetter(q *queue, m *message, reason string) {
+	exchangeName, ok := q.args["x-dead-letter-exchange"].(string)
+	if !ok {
+		return
+	}
+	key := m.routingKey
+	if k, ok := q.args["x-dead-letter-routing-key"].(string); ok {
+		key = k
+	}
+
+	msg := copyPublishing(m.msg)
+	msg.Expiration = ""
+	msg.Headers = withDeath(msg.Headers, q.name, reason, m.exchange, m.routingKey, b.now)
+	b.route(exchangeName, key, msg)
+}
+
+// withDeath records a dead-lettering in the x-death header the way RabbitMQ does: one entry per
+// queue and reason, most recent first, with a count.
+func withDeath(headers amqp.Table, queue, reason, exchangeName, key string, now time.Time) amqp.Table {
+	deaths, _ := headers["x-death"].([]interface{})
+
+	count := int64(1)
+	rest := make([]interface{}, 0, len(deaths))
+	for _, d := range deaths {
+		entry, ok := d.(amqp.Table)
+		if ok && entry["queue"] == queue && entry["reason"] == reason {
+			if n, ok := entry["count"].(int64); ok {
+				count = n + 1
+			}
+			continue
+		}
+		rest = append(rest, d)
+	}
+
+	entry := amqp.Table{
+		"count":        count,
+		"exchange":     exchangeName,
+		"queue":        queue,
+		"reason":       reason,
+		"routing-keys": []interface{}{key},
+		"time":         now,
+	}
+	headers["x-death"] = append([]interface{}{entry}, rest...)
+	return headers
+}
+
+// channelError is the error RabbitMQ closes a channel with.
+func channelError(code int, reason string) *amqp.Error {
+	return &amqp.Error{Code: code, Reason: reason, Server: true}
+}
@@ -0,0 +1,245 @@
+package rabbitmqtest
+
+import (
+	"context"
+	"elastic-logger-app/common/rabbitmq"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+func openChannel(t *testing.T, b *Broker) rabbitmq.Channel {
+	t.Helper()
+	ch, err := b.Channel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ch
+}
+
+func mustDeclare(t *testing.T, ch rabbitmq.Channel, queue string, args amqp.Table, exchange string, keys ...string) {
+	t.Helper()
+	if _, err := ch.QueueDeclare(queue, true, false, false, false, args); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		if err := ch.QueueBind(queue, key, exchange, false, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func publish(t *testing.T, ch rabbitmq.Channel, exchange, key string, msg amqp.Publishing) {
+	t.Helper()
+	if err := ch.Publish(exchange, key, false, false, msg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func receive(t *testing.T, deliveries <-chan amqp.Delivery) amqp.Delivery {
+	t.Helper()
+	select {
+	case d, ok := <-deliveries:
+		if !ok {
+			t.Fatal("deliveries closed")
+		}
+		return d
+	case <-time.After(time.Second):
+		t.Fatal("no delivery")
+	}
+	return amqp.Delivery{}
+}
+
+func bodies(deliveries []amqp.Delivery) []string {
+	out := []string{}
+	for _, d := range deliveries {
+		out = append(out, string(d.Body))
+	}
+	return out
+}
+
+func TestTopicRouting(t *testing.T) {
+	b := NewBroker()
+	ch := openChannel(t, b)
+	if err := ch.ExchangeDeclare("events", amqp.ExchangeTopic, true, false, false, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	mustDeclare(t, ch, "accounts", nil, "events", "account.*")
+	mustDeclare(t, ch, "audit", nil, "events", "#", "account.created")
+	mustDeclare(t, ch, "direct", nil, "")
+
+	publish(t, ch, "events", "account.created", amqp.Publishing{Body: []byte("created")})
+	publish(t, ch, "events", "account.profile.updated", amqp.Publishing{Body: []byte("updated")})
+	publish(t, ch, "", "direct", amqp.Publishing{Body: []byte("direct")})
+	publish(t, ch, "", "nobody", amqp.Publishing{Body: []byte("dropped")})
+
+	want := map[string][]string{
+		"accounts": {"created"},
+		"audit":    {"created", "updated"},
+		"direct":   {"direct"},
+	}
+	for queue, w := range want {
+		if got := bodies(b.Messages(queue)); len(got) != len(w) || got[0] != w[0] || got[len(got)-1] != w[len(w)-1] {
+			t.Errorf("%s = %v, want %v", queue, got, w)
+		}
+	}
+	if got := b.Messages("audit")[0]; got.Exchange != "events" || got.RoutingKey != "account.created" {
+		t.Errorf("delivery = %s/%s", got.Exchange, got.RoutingKey)
+	}
+}
+
+func TestAckNackAndRedelivery(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	b := NewBroker()
+	ch := openChannel(t, b)
+	mustDeclare(t, ch, "work", amqp.Table{"x-dead-letter-exchange": "", "x-dead-letter-routing-key": "work.dlq"}, "")
+	mustDeclare(t, ch, "work.dlq", nil, "")
+	for _, body := range []string{"a", "b", "c"} {
+		publish(t, ch, "", "work", amqp.Publishing{Body: []byte(body)})
+	}
+
+	if err := ch.Qos(1, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	deliveries, err := ch.Consume("work", "worker", false, false, false, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := receive(t, deliveries)
+	if b.Unacked("work") != 1 || len(b.Messages("work")) != 2 {
+		t.Fatalf("prefetch 1: unacked = %d, ready = %d", b.Unacked("work"), len(b.Messages("work")))
+	}
+	if err := a.Nack(false, true); err != nil {
+		t.Fatal(err)
+	}
+	again := receive(t, deliveries)
+	if string(again.Body) != "a" || !again.Redelivered || again.DeliveryTag == a.DeliveryTag {
+		t.Fatalf("requeued delivery = %s redelivered=%v tag=%d", again.Body, again.Redelivered, again.DeliveryTag)
+	}
+	if err := again.Ack(false); err != nil {
+		t.Fatal(err)
+	}
+	if err := again.Ack(false); err == nil {
+		t.Fatal("second ack of the same tag succeeded")
+	}
+
+	// The double ack closed the channel, which requeued b.
+	ch = openChannel(t, b)
+	deliveries, err = ch.Consume("work", "worker", false, false, false, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := receive(t, deliveries)
+	if string(first.Body) != "b" || !first.Redelivered {
+		t.Fatalf("after channel close got %s redelivered=%v", first.Body, first.Redelivered)
+	}
+	if err := first.Reject(false); err != nil {
+		t.Fatal(err)
+	}
+	if err := receive(t, deliveries).Ack(false); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.WaitIdle(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	dead := b.Messages("work.dlq")
+	if len(dead) != 1 || string(dead[0].Body) != "b" {
+		t.Fatalf("dead letters = %v", bodies(dead))
+	}
+	death := dead[0].Headers["x-death"].([]interface{})[0].(amqp.Table)
+	if death["queue"] != "work" || death["reason"] != "rejected" || death["count"] != int64(1) {
+		t.Fatalf("x-death = %v", death)
+	}
+}
+
+func TestDelayQueueDeadLettersOnExpiry(t *testing.T) {
+	b := NewBroker()
+	ch := openChannel(t, b)
+	mustDeclare(t, ch, "work", nil, "")
+	mustDeclare(t, ch, "work.retry", amqp.Table{"x-dead-letter-exchange": "", "x-dead-letter-routing-key": "work"}, "")
+	mustDeclare(t, ch, "work.slow", amqp.Table{"x-message-ttl": int32(5000), "x-dead-letter-exchange": "", "x-dead-letter-routing-key": "work"}, "")
+
+	publish(t, ch, "", "work.retry", amqp.Publishing{Body: []byte("long"), Expiration: "3000"})
+	publish(t, ch, "", "work.retry", amqp.Publishing{Body: []byte("short"), Expiration: "1000"})
+	publish(t, ch, "", "work.slow", amqp.Publishing{Body: []byte("queue ttl"), Expiration: "60000"})
+
+	b.Advance(time.Second)
+	if got := bodies(b.Messages("work")); len(got) != 0 {
+		t.Fatalf("short message expired behind a longer one: %v", got)
+	}
+
+	b.Advance(2 * time.Second)
+	if got := bodies(b.Messages("work")); len(got) != 2 || got[0] != "long" || got[1] != "short" {
+		t.Fatalf("work after 3s = %v", got)
+	}
+	if d := b.Messages("work")[0]; d.Expiration != "" || d.Headers["x-death"] == nil {
+		t.Fatalf("dead-lettered message kept its expiration %q or has no x-death", d.Expiration)
+	}
+
+	b.Advance(2 * time.Second)
+	if got := bodies(b.Messages("work")); len(got) != 3 || got[2] != "queue ttl" {
+		t.Fatalf("x-message-ttl did not cap the message TTL: %v", got)
+	}
+}
+
+func TestChannelErrors(t *testing.T) {
+	b := NewBroker()
+	ch := openChannel(t, b)
+
+	var amqpErr *amqp.Error
+	err := ch.Publish("missing", "key", false, false, amqp.Publishing{})
+	if !errors.As(err, &amqpErr) || amqpErr.Code != amqp.NotFound {
+		t.Fatalf("publish to a missing exchange: %v", err)
+	}
+	if _, err := ch.QueueDeclare("work", true, false, false, false, nil); err != amqp.ErrClosed {
+		t.Fatalf("channel still open after an error: %v", err)
+	}
+
+	ch = openChannel(t, b)
+	mustDeclare(t, ch, "work", amqp.Table{"x-message-ttl": int32(1000)}, "")
+	mustDeclare(t, ch, "work", amqp.Table{"x-message-ttl": int64(1000)}, "")
+	_, err = ch.QueueDeclare("work", true, false, false, false, nil)
+	if !errors.As(err, &amqpErr) || amqpErr.Code != amqp.PreconditionFailed {
+		t.Fatalf("inequivalent redeclare: %v", err)
+	}
+}
+
+func TestPublisherConfirms(t *testing.T) {
+	b := NewBroker()
+	ch := openChannel(t, b)
+	mustDeclare(t, ch, "work", nil, "")
+	if err := ch.Confirm(false); err != nil {
+		t.Fatal(err)
+	}
+	confirms := ch.NotifyPublish(make(chan amqp.Confirmation, 2))
+
+	publish(t, ch, "", "work", amqp.Publishing{Body: []byte("1")})
+	publish(t, ch, "", "work", amqp.Publishing{Body: []byte("2")})
+	for want := uint64(1); want <= 2; want++ {
+		if c := <-confirms; !c.Ack || c.DeliveryTag != want {
+			t.Fatalf("confirmation = %+v, want ack %d", c, want)
+		}
+	}
+
+	b.NackPublishes()
+	publish(t, ch, "", "work", amqp.Publishing{Body: []byte("3")})
+	if c := <-confirms; c.Ack || c.DeliveryTag != 3 {
+		t.Fatalf("confirmation = %+v, want nack 3", c)
+	}
+	b.AckPublishes()
+	if got := bodies(b.Messages("work")); len(got) != 2 {
+		t.Fatalf("queue = %v, the nacked message was routed", got)
+	}
+
+	if err := ch.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-confirms; ok {
+		t.Fatal("confirmations not closed with the channel")
+	}
+}
@@ -0,0 +1,504 @@
+package rabbitmqtest
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/streadway/amqp"
+)
+
+// channel is a channel on the Broker. Its deliveries use it as their Acknowledger.
+type channel struct {
+	b          *Broker
+	closed     bool
+	confirming bool
+	prefetch   int
+	nextTag    uint64
+	published  uint64
+	consumers  map[string]*consumer
+	unacked    map[uint64]*pending
+
+	// Confirmations are sent to the listeners in order by a goroutine, as the connection's
+	// reader does in the client, so a full listener does not block Publish. confirmMu guards
+	// the confirmations not sent yet, listenerMu the listeners; closing stops the sender.
+	confirmMu       sync.Mutex
+	backlog         []amqp.Confirmation
+	held            []amqp.Confirmation
+	sending         bool
+	listenerMu      sync.Mutex
+	listeners       []chan amqp.Confirmation
+	listenersClosed bool
+	closing         chan struct{}
+}
+
+// pending is a delivery awaiting its acknowledgement.
+type pending struct {
+	message  *message
+	queue    *queue
+	consumer *consumer
+	// handed is set once the delivery left the consumer's buffer for the application.
+	handed bool
+}
+
+// consumer feeds the deliveries the broker assigned to it, in order, to out.
+type consumer struct {
+	tag      string
+	ch       *channel
+	queue    *queue
+	autoAck  bool
+	prefetch int
+	inFlight int
+	buf      []amqp.Delivery
+	out      chan amqp.Delivery
+	wake     chan struct{}
+	done     chan struct{}
+	exited   chan struct{}
+}
+
+// deliver assigns m to cons. The caller holds the broker lock.
+func (cons *consumer) deliver(m *message) {
+	c := cons.ch
+	c.nextTag++
+	tag := c.nextTag
+	if !cons.autoAck {
+		c.unacked[tag] = &pending{message: m, queue: cons.queue, consumer: cons}
+		cons.inFlight++
+	}
+	cons.buf = append(cons.buf, m.delivery(c, cons.tag, tag))
+
+	select {
+	case cons.wake <- struct{}{}:
+	default:
+	}
+}
+
+// pump hands buffered deliveries to the application until the consumer is stopped, then closes
+// out. Deliveries it could not hand over stay unacknowledged for the canceller to requeue.
+func (cons *consumer) pump(mu *sync.Mutex) {
+	defer close(cons.exited)
+	defer close(cons.out)
+
+	for {
+		mu.Lock()
+		if len(cons.buf) == 0 {
+			mu.Unlock()
+			select {
+			case <-cons.wake:
+				continue
+			case <-cons.done:
+				return
+			}
+		}
+		d := cons.buf[0]
+		cons.buf = cons.buf[1:]
+		mu.Unlock()
+
+		select {
+		case cons.out <- d:
+			mu.Lock()
+			if p, ok := cons.ch.unacked[d.DeliveryTag]; ok {
+				p.handed = true
+			}
+			mu.Unlock()
+		case <-cons.done:
+			return
+		}
+	}
+}
+
+// do runs fn under the broker lock. An error from fn is a channel error: as in RabbitMQ, it
+// closes the channel.
+func (c *channel) do(fn func() error) error {
+	c.b.mu.Lock()
+	if c.closed {
+		c.b.mu.Unlock()
+		return amqp.ErrClosed
+	}
+	err := fn()
+	c.b.mu.Unlock()
+
+	if err != nil {
+		c.shutdown()
+	}
+	return err
+}
+
+func (c *channel) ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error {
+	return c.do(func() error {
+		if name == "" {
+			return channelError(amqp.AccessRefused, "ACCESS_REFUSED - operation not permitted on the default exchange")
+		}
+		switch kind {
+		case amqp.ExchangeDirect, amqp.ExchangeFanout, amqp.ExchangeTopic:
+		default:
+			return channelError(amqp.CommandInvalid, "COMMAND_INVALID - unknown exchange type '"+kind+"'")
+		}
+
+		if ex, ok := c.b.exchanges[name]; ok {
+			if ex.kind != kind {
+				return channelError(amqp.PreconditionFailed, fmt.Sprintf(
+					"PRECONDITION_FAILED - inequivalent arg 'type' for exchange '%s' in vhost '/': received '%s' but current is '%s'", name, kind, ex.kind))
+			}
+			return nil
+		}
+		c.b.exchanges[name] = &exchange{name: name, kind: kind}
+		return nil
+	})
+}
+
+func (c *channel) QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error) {
+	var declared amqp.Queue
+	err := c.do(func() error {
+		if name == "" {
+			c.b.generated++
+			name = fmt.Sprintf("amq.gen-%d", c.b.generated)
+		}
+
+		q, ok := c.b.queues[name]
+		if !ok {
+			q = &queue{name: name, args: copyTable(args)}
+			c.b.queues[name] = q
+		} else if !equalArgs(q.args, args) {
+			return channelError(amqp.PreconditionFailed, "PRECONDITION_FAILED - inequivalent arg for queue '"+name+"' in vhost '/'")
+		}
+		declared = amqp.Queue{Name: name, Messages: len(q.ready), Consumers: len(q.consumers)}
+		return nil
+	})
+	return declared, err
+}
+
+func (c *channel) QueueBind(name, key, exchangeName string, noWait bool, args amqp.Table) error {
+	return c.do(func() error {
+		if exchangeName == "" {
+			return channelError(amqp.AccessRefused, "ACCESS_REFUSED - operation not permitted on the default exchange")
+		}
+		if _, ok := c.b.queues[name]; !ok {
+			return channelError(amqp.NotFound, "NOT_FOUND - no queue '"+name+"' in vhost '/'")
+		}
+		ex, ok := c.b.exchanges[exchangeName]
+		if !ok {
+			return channelError(amqp.NotFound, "NOT_FOUND - no exchange '"+exchangeName+"' in vhost '/'")
+		}
+		ex.bind(name, key)
+		return nil
+	})
+}
+
+// Qos sets the prefetch of consumers started afterwards on the channel. The size and global
+// flag are ignored.
+func (c *channel) Qos(prefetchCount, prefetchSize int, global bool) error {
+	return c.do(func() error {
+		c.prefetch = prefetchCount
+		return nil
+	})
+}
+
+func (c *channel) Consume(queueName, tag string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error) {
+	var deliveries chan amqp.Delivery
+	err := c.do(func() error {
+		q, ok := c.b.queues[queueName]
+		if !ok {
+			return channelError(amqp.NotFound, "NOT_FOUND - no queue '"+queueName+"' in vhost '/'")
+		}
+		if tag == "" {
+			c.b.generated++
+			tag = fmt.Sprintf("ctag-%d", c.b.generated)
+		}
+		if _, ok := c.consumers[tag]; ok {
+			return channelError(amqp.NotAllowed, "NOT_ALLOWED - attempt to reuse consumer tag '"+tag+"'")
+		}
+
+		cons := &consumer{
+			tag:      tag,
+			ch:       c,
+			queue:    q,
+			autoAck:  autoAck,
+			prefetch: c.prefetch,
+			out:      make(chan amqp.Delivery),
+			wake:     make(chan struct{}, 1),
+			done:     make(chan struct{}),
+			exited:   make(chan struct{}),
+		}
+		c.consumers[tag] = cons
+		q.consumers = append(q.consumers, cons)
+		go cons.pump(&c.b.mu)
+
+		c.b.dispatch()
+		deliveries = cons.out
+		return nil
+	})
+	return deliveries, err
+}
+
+// Cancel stops the consumer and closes its deliveries. Messages it was assigned but never
+// handed to the application are requeued; those handed out can still be acknowledged.
+func (c *channel) Cancel(tag string, noWait bool) error {
+	c.b.mu.Lock()
+	if c.closed {
+		c.b.mu.Unlock()
+		return amqp.ErrClosed
+	}
+	cons, ok := c.consumers[tag]
+	if !ok {
+		c.b.mu.Unlock()
+		return nil
+	}
+	c.stop(cons)
+	c.b.mu.Unlock()
+
+	<-cons.exited
+
+	c.b.mu.Lock()
+	defer c.b.mu.Unlock()
+	c.requeue(c.pendingTags(func(p *pending) bool { return p.consumer == cons && !p.handed }))
+	c.b.dispatch()
+	return nil
+}
+
+func (c *channel) Publish(exchangeName, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	var seq uint64
+	var hold, nack bool
+	err := c.do(func() error {
+		if _, ok := c.b.exchanges[exchangeName]; !ok && exchangeName != "" {
+			return channelError(amqp.NotFound, "NOT_FOUND - no exchange '"+exchangeName+"' in vhost '/'")
+		}
+		if msg.Expiration != "" {
+			if ms, err := strconv.ParseInt(msg.Expiration, 10, 64); err != nil || ms < 0 {
+				return channelError(amqp.PreconditionFailed, "PRECONDITION_FAILED - invalid expiration '"+msg.Expiration+"'")
+			}
+		}
+
+		nack = c.b.nackPublishes
+		if !nack {
+			c.b.route(exchangeName, key, msg)
+			c.b.dispatch()
+		}
+		if c.confirming {
+			c.published++
+			seq = c.published
+			hold = c.b.holdConfirms
+		}
+		return nil
+	})
+	if err != nil || seq == 0 {
+		return err
+	}
+
+	c.confirmMu.Lock()
+	defer c.confirmMu.Unlock()
+	confirm := amqp.Confirmation{DeliveryTag: seq, Ack: !nack}
+	if hold {
+		c.held = append(c.held, confirm)
+	} else {
+		c.sendConfirms(confirm)
+	}
+	return nil
+}
+
+// releaseConfirms sends the held confirmations.
+func (c *channel) releaseConfirms() {
+	c.confirmMu.Lock()
+	defer c.confirmMu.Unlock()
+	c.sendConfirms(c.held...)
+	c.held = nil
+}
+
+// sendConfirms queues confirms for the sender, starting it if needed. The caller holds
+// confirmMu.
+func (c *channel) sendConfirms(confirms ...amqp.Confirmation) {
+	c.backlog = append(c.backlog, confirms...)
+	if !c.sending && len(c.backlog) > 0 {
+		c.sending = true
+		go c.sender()
+	}
+}
+
+// sender sends the backlog to the listeners until it is empty or the channel closes.
+func (c *channel) sender() {
+	for {
+		c.confirmMu.Lock()
+		if len(c.backlog) == 0 {
+			c.sending = false
+			c.confirmMu.Unlock()
+			return
+		}
+		confirm := c.backlog[0]
+		c.backlog = c.backlog[1:]
+		c.confirmMu.Unlock()
+
+		c.listenerMu.Lock()
+		for _, l := range c.listeners {
+			select {
+			case l <- confirm:
+			case <-c.closing:
+				c.listenerMu.Unlock()
+				return
+			}
+		}
+		c.listenerMu.Unlock()
+	}
+}
+
+func (c *channel) Confirm(noWait bool) error {
+	return c.do(func() error {
+		c.confirming = true
+		return nil
+	})
+}
+
+// NotifyPublish registers confirm for a confirmation of every message published in confirm
+// mode. It is closed with the channel.
+func (c *channel) NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation {
+	c.listenerMu.Lock()
+	defer c.listenerMu.Unlock()
+
+	if c.listenersClosed {
+		close(confirm)
+	} else {
+		c.listeners = append(c.listeners, confirm)
+	}
+	return confirm
+}
+
+func (c *channel) Close() error {
+	c.b.mu.Lock()
+	closed := c.closed
+	c.b.mu.Unlock()
+	if closed {
+		return amqp.ErrClosed
+	}
+
+	c.shutdown()
+	return nil
+}
+
+func (c *channel) Ack(tag uint64, multiple bool) error {
+	return c.settle(tag, multiple, func(p *pending) bool { return false })
+}
+
+func (c *channel) Nack(tag uint64, multiple bool, requeue bool) error {
+	return c.settle(tag, multiple, func(p *pending) bool {
+		if !requeue {
+			c.b.deadLetter(p.queue, p.message, "rejected")
+		}
+		return requeue
+	})
+}
+
+func (c *channel) Reject(tag uint64, requeue bool) error {
+	return c.Nack(tag, false, requeue)
+}
+
+// settle removes the deliveries up to tag (multiple) or tag alone from the unacknowledged ones
+// and requeues those for which after returns true.
+func (c *channel) settle(tag uint64, multiple bool, after func(p *pending) bool) error {
+	return c.do(func() error {
+		var tags []uint64
+		if multiple {
+			tags = c.pendingTags(func(p *pending) bool { return true })
+			tags = tags[:sort.Search(len(tags), func(i int) bool { return tag != 0 && tags[i] > tag })]
+		} else if _, ok := c.unacked[tag]; ok {
+			tags = []uint64{tag}
+		}
+		if len(tags) == 0 {
+			return channelError(amqp.PreconditionFailed, fmt.Sprintf("PRECONDITION_FAILED - unknown delivery tag %d", tag))
+		}
+
+		var requeue []uint64
+		for _, t := range tags {
+			p := c.unacked[t]
+			if after(p) {
+				requeue = append(requeue, t)
+				continue
+			}
+			delete(c.unacked, t)
+			p.consumer.inFlight--
+		}
+		c.requeue(requeue)
+		c.b.dispatch()
+		return nil
+	})
+}
+
+// pendingTags returns the tags of the unacknowledged deliveries selected by keep, in order.
+func (c *channel) pendingTags(keep func(p *pending) bool) []uint64 {
+	var tags []uint64
+	for t, p := range c.unacked {
+		if keep(p) {
+			tags = append(tags, t)
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i] < tags[j] })
+	return tags
+}
+
+// requeue puts the unacknowledged deliveries with the given tags, in order, back at the head
+// of their queues.
+func (c *channel) requeue(tags []uint64) {
+	byQueue := map[*queue][]*message{}
+	var queues []*queue
+	for _, t := range tags {
+		p := c.unacked[t]
+		delete(c.unacked, t)
+		p.consumer.inFlight--
+		if _, ok := byQueue[p.queue]; !ok {
+			queues = append(queues, p.queue)
+		}
+		byQueue[p.queue] = append(byQueue[p.queue], p.message)
+	}
+	for _, q := range queues {
+		q.requeue(byQueue[q])
+	}
+}
+
+// stop detaches cons from its channel and queue and stops its pump. The caller holds the
+// broker lock.
+func (c *channel) stop(cons *consumer) {
+	delete(c.consumers, cons.tag)
+	cons.queue.removeConsumer(cons)
+	close(cons.done)
+}
+
+// shutdown closes the channel: consumers are stopped, confirmation listeners closed and every
+// unacknowledged message requeued.
+func (c *channel) shutdown() {
+	c.b.mu.Lock()
+	if c.closed {
+		c.b.mu.Unlock()
+		return
+	}
+	c.closed = true
+	var stopped []*consumer
+	for _, cons := range c.consumers {
+		c.stop(cons)
+		stopped = append(stopped, cons)
+	}
+	c.b.mu.Unlock()
+
+	for _, cons := range stopped {
+		<-cons.exited
+	}
+
+	close(c.closing)
+	c.listenerMu.Lock()
+	c.listenersClosed = true
+	for _, l := range c.listeners {
+		close(l)
+	}
+	c.listeners = nil
+	c.listenerMu.Unlock()
+
+	// Confirmations not sent yet are lost with the channel, as with RabbitMQ.
+	c.confirmMu.Lock()
+	c.backlog = nil
+	c.held = nil
+	c.confirmMu.Unlock()
+
+	c.b.mu.Lock()
+	defer c.b.mu.Unlock()
+	c.requeue(c.pendingTags(func(p *pending) bool { return true }))
+	delete(c.b.channels, c)
+	c.b.dispatch()
+}
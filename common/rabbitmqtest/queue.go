@@ -0,0 +1,208 @@
+package rabbitmqtest
+
+import (
+	"elastic-logger-app/common/rabbitmq"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+type exchange struct {
+	name     string
+	kind     string
+	bindings []binding
+}
+
+type binding struct {
+	queue string
+	key   string
+}
+
+// route returns the queues bound to e that accept key, each once, in binding order.
+func (e *exchange) route(queues map[string]*queue, key string) []*queue {
+	var out []*queue
+	seen := map[string]bool{}
+	for _, b := range e.bindings {
+		if seen[b.queue] {
+			continue
+		}
+
+		var match bool
+		switch e.kind {
+		case amqp.ExchangeFanout:
+			match = true
+		case amqp.ExchangeTopic:
+			match = rabbitmq.MatchTopic(b.key, key)
+		default:
+			match = b.key == key
+		}
+		if q, ok := queues[b.queue]; ok && match {
+			seen[b.queue] = true
+			out = append(out, q)
+		}
+	}
+	return out
+}
+
+func (e *exchange) bind(queue, key string) {
+	for _, b := range e.bindings {
+		if b.queue == queue && b.key == key {
+			return
+		}
+	}
+	e.bindings = append(e.bindings, binding{queue: queue, key: key})
+}
+
+type queue struct {
+	name      string
+	args      amqp.Table
+	ready     []*message
+	consumers []*consumer
+	next      int
+}
+
+// expiry returns when a message published now with the given expiration expires in q: the
+// earlier of the per-message TTL and the x-message-ttl of the queue. It is zero without either.
+func (q *queue) expiry(now time.Time, expiration string) time.Time {
+	ttl := int64(-1)
+	if ms, err := strconv.ParseInt(expiration, 10, 64); err == nil {
+		ttl = ms
+	}
+	if ms, ok := toInt64(q.args["x-message-ttl"]); ok && (ttl < 0 || ms < ttl) {
+		ttl = ms
+	}
+	if ttl < 0 {
+		return time.Time{}
+	}
+	return now.Add(time.Duration(ttl) * time.Millisecond)
+}
+
+// nextConsumer picks, round-robin, a consumer of q with room under its prefetch.
+func (q *queue) nextConsumer() *consumer {
+	for i := range q.consumers {
+		cons := q.consumers[(q.next+i)%len(q.consumers)]
+		if cons.prefetch == 0 || cons.inFlight < cons.prefetch {
+			q.next = (q.next + i + 1) % len(q.consumers)
+			return cons
+		}
+	}
+	return nil
+}
+
+func (q *queue) removeConsumer(cons *consumer) {
+	for i, c := range q.consumers {
+		if c == cons {
+			q.consumers = append(q.consumers[:i], q.consumers[i+1:]...)
+			q.next = 0
+			return
+		}
+	}
+}
+
+// requeue puts messages back at the head of q, in order, flagged as redelivered.
+func (q *queue) requeue(messages []*message) {
+	for _, m := range messages {
+		m.redelivered = true
+	}
+	q.ready = append(append([]*message{}, messages...), q.ready...)
+}
+
+// message is a publishing held by a queue.
+type message struct {
+	exchange    string
+	routingKey  string
+	msg         amqp.Publishing
+	redelivered bool
+	// expiresAt is zero when the message does not expire.
+	expiresAt time.Time
+}
+
+func (m *message) expired(now time.Time) bool {
+	return !m.expiresAt.IsZero() && !now.Before(m.expiresAt)
+}
+
+func (m *message) delivery(ack amqp.Acknowledger, consumerTag string, tag uint64) amqp.Delivery {
+	p := copyPublishing(m.msg)
+	return amqp.Delivery{
+		Acknowledger:    ack,
+		Headers:         p.Headers,
+		ContentType:     p.ContentType,
+		ContentEncoding: p.ContentEncoding,
+		DeliveryMode:    p.DeliveryMode,
+		Priority:        p.Priority,
+		CorrelationId:   p.CorrelationId,
+		ReplyTo:         p.ReplyTo,
+		Expiration:      p.Expiration,
+		MessageId:       p.MessageId,
+		Timestamp:       p.Timestamp,
+		Type:            p.Type,
+		UserId:          p.UserId,
+		AppId:           p.AppId,
+		ConsumerTag:     consumerTag,
+		DeliveryTag:     tag,
+		Redelivered:     m.redelivered,
+		Exchange:        m.exchange,
+		RoutingKey:      m.routingKey,
+		Body:            p.Body,
+	}
+}
+
+// copyPublishing copies msg with its own headers and body, so neither the publisher nor a
+// handler can change a message held by the broker.
+func copyPublishing(msg amqp.Publishing) amqp.Publishing {
+	msg.Headers = copyTable(msg.Headers)
+	msg.Body = append([]byte(nil), msg.Body...)
+	return msg
+}
+
+func copyTable(t amqp.Table) amqp.Table {
+	out := amqp.Table{}
+	for k, v := range t {
+		out[k] = v
+	}
+	return out
+}
+
+// equalArgs compares declaration arguments the way RabbitMQ checks equivalence, ignoring the
+// integer type of numbers.
+func equalArgs(a, b amqp.Table) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, va := range a {
+		vb, ok := b[k]
+		if !ok {
+			return false
+		}
+		na, okA := toInt64(va)
+		nb, okB := toInt64(vb)
+		if okA && okB {
+			if na != nb {
+				return false
+			}
+			continue
+		}
+		if !reflect.DeepEqual(va, vb) {
+			return false
+		}
+	}
+	return true
+}
+
+func toInt64(v any) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int16:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case float64:
+		return int64(n), true
+	}
+	return 0, false
+}
@@ -0,0 +1,31 @@
+package common
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Recovery turns a panic in a handler into a 500 response rendered by ResponseError,
+// replacing gin.Recovery so panics get the same error format as everything else. The panic
+// value and the panicking stack travel on the AppError, so ResponseError logs it once.
+func Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if r := recover(); r != nil {
+				if r == http.ErrAbortHandler {
+					panic(r)
+				}
+
+				ResponseError(c, NewErrorFromCode(CodeInternal, nil).
+					WithReason("panic recovered while handling the request").
+					WithInner(fmt.Errorf("panic: %v", r)).
+					WithStack())
+				c.Abort()
+			}
+		}()
+
+		c.Next()
+	}
+}
@@ -0,0 +1,80 @@
+package common
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func recoveryRouter(handler gin.HandlerFunc, after *bool) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestID(), Recovery())
+	r.GET("/", handler, func(c *gin.Context) { *after = true })
+	return r
+}
+
+func TestRecovery(t *testing.T) {
+	t.Run("panic becomes an internal error", func(t *testing.T) {
+		core, logs := observer.New(zapcore.DebugLevel)
+		defer zap.ReplaceGlobals(zap.New(core))()
+
+		var after bool
+		r := recoveryRouter(func(c *gin.Context) { panic("boom") }, &after)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if w.Code != http.StatusInternalServerError {
+			t.Fatalf("status = %d, want 500", w.Code)
+		}
+		var body struct {
+			Success bool     `json:"success"`
+			Error   AppError `json:"error"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Success || body.Error.ErrorCode != CodeInternal || body.Error.ErrorID == "" {
+			t.Fatalf("body = %s", w.Body)
+		}
+		if body.Error.ReasonField != "" || body.Error.File != "" {
+			t.Fatalf("panic details leaked: %s", w.Body)
+		}
+		if after {
+			t.Fatal("handlers after the panic ran")
+		}
+
+		entries := logs.FilterLevelExact(zapcore.ErrorLevel).All()
+		if len(entries) != 1 {
+			t.Fatalf("panic logged %d times at error level, want once", len(entries))
+		}
+		fields := entries[0].ContextMap()
+		if inner, _ := fields["inner"].(string); inner != "panic: boom" {
+			t.Errorf("inner = %v, want the panic value", fields["inner"])
+		}
+		if stack, _ := fields["stack"].(string); !strings.Contains(stack, "TestRecovery") {
+			t.Errorf("stack does not reach the panicking handler:\n%s", stack)
+		}
+	})
+
+	t.Run("abort handler is re-raised", func(t *testing.T) {
+		var after bool
+		r := recoveryRouter(func(c *gin.Context) { panic(http.ErrAbortHandler) }, &after)
+
+		defer func() {
+			if got := recover(); got != http.ErrAbortHandler {
+				t.Fatalf("recovered %v, want http.ErrAbortHandler", got)
+			}
+		}()
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		t.Fatal("ServeHTTP returned without panicking")
+	})
+}
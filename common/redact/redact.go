@@ -0,0 +1,72 @@
+// Package redact masks credentials in connection strings and sensitive log fields.
+package redact
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Mask replaces every redacted value.
+const Mask = "****"
+
+// DefaultKeys are the field and query parameter names treated as sensitive.
+var DefaultKeys = []string{"password", "passwd", "pwd", "secret", "token", "authorization", "api_key", "apikey", "cookie"}
+
+// IsSensitiveKey reports whether key names a secret: it equals one of keys or ends with
+// "_"+key, so "mysql_password" and "Access-Token" match "password" and "token".
+func IsSensitiveKey(key string, keys []string) bool {
+	k := strings.ReplaceAll(strings.ToLower(key), "-", "_")
+	for _, s := range keys {
+		if k == s || strings.HasSuffix(k, "_"+s) {
+			return true
+		}
+	}
+	return false
+}
+
+// URL masks the password in the userinfo of raw and the values of sensitive query parameters.
+// It handles mongodb://, mongodb+srv://, amqp://, amqps:// and http(s) URLs. Strings that do
+// not parse as URLs are masked entirely, since they may still hold a credential.
+func URL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" {
+		return Mask
+	}
+
+	if u.User != nil {
+		if _, hasPassword := u.User.Password(); hasPassword {
+			u.User = url.UserPassword(u.User.Username(), Mask)
+		}
+	}
+
+	if u.RawQuery != "" {
+		q := u.Query()
+		for key := range q {
+			if IsSensitiveKey(key, DefaultKeys) {
+				q.Set(key, Mask)
+			}
+		}
+		u.RawQuery = q.Encode()
+	}
+
+	// url.URL escapes the mask; keep the output readable.
+	return strings.ReplaceAll(u.String(), url.QueryEscape(Mask), Mask)
+}
+
+// MySQLDSN masks the password in a go-sql-driver DSN such as user:pass@tcp(host:3306)/db.
+func MySQLDSN(dsn string) string {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return Mask
+	}
+	if cfg.Passwd != "" {
+		cfg.Passwd = Mask
+	}
+	return cfg.FormatDSN()
+}
@@ -0,0 +1,64 @@
+package common
+
+import (
+	"context"
+	"elastic-logger-app/common/logger"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// HeaderRequestID carries the request identifier over HTTP. The same name is used
+// for the AMQP header so one ID traces a request end-to-end.
+const HeaderRequestID = "X-Request-ID"
+
+const maxRequestIDLength = 128
+
+type requestIDContextKey struct{}
+
+// ContextWithRequestID returns a copy of ctx carrying the request ID, with the
+// context logger stamped with it as well.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	ctx = context.WithValue(ctx, requestIDContextKey{}, id)
+	return logger.With(ctx, zap.String("request_id", id))
+}
+
+// RequestIDFromContext returns the request ID stored in ctx, or "" when there is none.
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDContextKey{}).(string)
+	return id
+}
+
+// RequestID accepts the caller's X-Request-ID or generates a UUIDv7, stores it in the
+// request context and echoes it in the response.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(HeaderRequestID)
+		if !validRequestID(id) {
+			id = GenUUID().String()
+		}
+
+		c.Request = c.Request.WithContext(ContextWithRequestID(c.Request.Context(), id))
+		c.Header(HeaderRequestID, id)
+
+		c.Next()
+	}
+}
+
+// validRequestID rejects empty, oversized or non-printable IDs so clients cannot inject
+// arbitrary content into logs and headers.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if r > unicode.MaxASCII || !unicode.IsPrint(r) || unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,87 @@
+package common
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func serveWithRequestID(t *testing.T, header string, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestID())
+	r.GET("/", handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if header != "" {
+		req.Header.Set(HeaderRequestID, header)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{"accepts caller id", "req-123", true},
+		{"generates when missing", "", false},
+		{"rejects spaces", "req 123", false},
+		{"rejects control characters", "req\x01", false},
+		{"rejects non-ascii", "réq", false},
+		{"rejects oversized", strings.Repeat("a", maxRequestIDLength+1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			w := serveWithRequestID(t, tt.header, func(c *gin.Context) {
+				seen = RequestIDFromContext(c.Request.Context())
+			})
+
+			echoed := w.Header().Get(HeaderRequestID)
+			if seen == "" || echoed != seen {
+				t.Fatalf("context id = %q, response header = %q", seen, echoed)
+			}
+			if tt.keep && seen != tt.header {
+				t.Fatalf("id = %q, want caller's %q", seen, tt.header)
+			}
+			if !tt.keep && seen == tt.header {
+				t.Fatalf("invalid caller id %q was kept", tt.header)
+			}
+		})
+	}
+}
+
+func TestResponseErrorStampsRequestIDOnACopy(t *testing.T) {
+	shared := NewNotFoundError("account not found", "missing")
+
+	for _, id := range []string{"req-1", "req-2"} {
+		w := serveWithRequestID(t, id, func(c *gin.Context) {
+			ResponseError(c, shared)
+		})
+
+		var body struct {
+			Error struct {
+				ErrorID string `json:"error_id"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Error.ErrorID != id {
+			t.Fatalf("error_id = %q, want %q", body.Error.ErrorID, id)
+		}
+	}
+	if shared.ErrorID != "" {
+		t.Fatalf("shared error was stamped with %q", shared.ErrorID)
+	}
+}
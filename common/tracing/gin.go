@@ -0,0 +1,25 @@
+package tracing
+
+import (
+	"elastic-logger-app/common/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// GinMiddleware starts a server span per request, named after the route template,
+// and stamps the trace ID on the request's logger.
+func GinMiddleware(service string) []gin.HandlerFunc {
+	return []gin.HandlerFunc{
+		otelgin.Middleware(service),
+		func(c *gin.Context) {
+			if sc := trace.SpanContextFromContext(c.Request.Context()); sc.IsValid() {
+				ctx := logger.With(c.Request.Context(), zap.String("trace_id", sc.TraceID().String()))
+				c.Request = c.Request.WithContext(ctx)
+			}
+			c.Next()
+		},
+	}
+}
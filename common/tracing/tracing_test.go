@@ -0,0 +1,139 @@
+package tracing_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"elastic-logger-app/common/rabbitmq"
+	"elastic-logger-app/common/rabbitmqtest"
+	"elastic-logger-app/common/tracing"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// fakeDB answers every statement without a database.
+type fakeDB struct{}
+
+func (fakeDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+
+func (fakeDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return nil, nil
+}
+
+func (fakeDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return nil, nil
+}
+
+func (fakeDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return &sql.Row{}
+}
+
+func spanNamed(t *testing.T, spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+	t.Helper()
+	for _, s := range spans {
+		if s.Name == name {
+			return s
+		}
+	}
+	t.Fatalf("no span %q among %d spans", name, len(spans))
+	return tracetest.SpanStub{}
+}
+
+func TestTraceCrossesHTTPDatabaseAndAMQP(t *testing.T) {
+	provider, exporter := tracing.InitInMemory()
+	t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+
+	b := rabbitmqtest.NewBroker()
+	publisher, err := rabbitmq.NewPublisher(b, "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer publisher.Close()
+
+	cfg := rabbitmq.DefaultConsumerConfig("events", "projection")
+	cfg.Workers = 1
+	consumer := rabbitmq.NewConsumer(b, cfg)
+	var headers amqp.Table
+	consumer.Handle("account.created", func(ctx context.Context, d amqp.Delivery) error {
+		headers = d.Headers
+		return nil
+	})
+	ctx, stop := context.WithTimeout(context.Background(), 5*time.Second)
+	defer stop()
+	done := make(chan error, 1)
+	go func() { done <- consumer.Run(ctx) }()
+	if err := b.WaitConsumers(ctx, "projection", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(tracing.GinMiddleware("test")...)
+	db := tracing.WrapDBTX(fakeDB{})
+	r.POST("/accounts", func(c *gin.Context) {
+		ctx := c.Request.Context()
+		if _, err := db.ExecContext(ctx, "-- name: CreateAccount :execresult\nINSERT INTO account (id) VALUES (?)", "1"); err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		if err := publisher.Publish(ctx, "account.created", []byte(`{"id":"1"}`), nil); err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.Status(http.StatusCreated)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/accounts", nil))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d", w.Code)
+	}
+	if err := b.WaitIdle(ctx); err != nil {
+		t.Fatal(err)
+	}
+	stop()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	spans := exporter.GetSpans()
+	var server tracetest.SpanStub
+	for _, s := range spans {
+		if s.SpanKind == trace.SpanKindServer {
+			server = s
+		}
+	}
+	if !server.SpanContext.IsValid() {
+		t.Fatalf("no server span among %d spans", len(spans))
+	}
+	query := spanNamed(t, spans, "mysql CreateAccount")
+	publish := spanNamed(t, spans, "account.created publish")
+	process := spanNamed(t, spans, "account.created process")
+
+	childOf := func(child, parent tracetest.SpanStub) bool {
+		return child.Parent.SpanID() == parent.SpanContext.SpanID() &&
+			child.SpanContext.TraceID() == parent.SpanContext.TraceID()
+	}
+	if !childOf(query, server) || query.SpanKind != trace.SpanKindClient {
+		t.Errorf("query span is not a client child of the request: %+v", query.Parent)
+	}
+	if !childOf(publish, server) || publish.SpanKind != trace.SpanKindProducer {
+		t.Errorf("publish span is not a producer child of the request: %+v", publish.Parent)
+	}
+	// The consumer runs in another goroutine with nothing but the message headers to go on.
+	if !childOf(process, publish) || process.SpanKind != trace.SpanKindConsumer || !process.Parent.IsRemote() {
+		t.Errorf("process span is not a remote child of the publish span: %+v", process.Parent)
+	}
+	if tp, _ := headers["traceparent"].(string); tp == "" {
+		t.Errorf("message headers carry no traceparent: %v", headers)
+	}
+}
@@ -0,0 +1,59 @@
+package common
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+type txContextKey struct{}
+
+// ContextWithTx returns a copy of ctx carrying tx, so repositories called with it
+// join the surrounding transaction instead of using their own connection.
+func ContextWithTx(ctx context.Context, tx *sql.Tx) context.Context {
+	return context.WithValue(ctx, txContextKey{}, tx)
+}
+
+// TxFromContext returns the transaction stored in ctx, if any.
+func TxFromContext(ctx context.Context) (*sql.Tx, bool) {
+	tx, ok := ctx.Value(txContextKey{}).(*sql.Tx)
+	return tx, ok
+}
+
+// WithTx runs fn in a transaction on db and commits it when fn returns nil. fn gets a ctx
+// carrying the transaction; when ctx already carries one, fn joins it and the caller that
+// began it decides whether it commits.
+func WithTx(ctx context.Context, db *sql.DB, fn func(ctx context.Context) error) error {
+	if _, ok := TxFromContext(ctx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(ContextWithTx(ctx, tx)); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+// Transactor runs functions in transactions on one database, for use cases that must not
+// depend on database/sql.
+type Transactor struct {
+	db *sql.DB
+}
+
+func NewTransactor(db *sql.DB) Transactor {
+	return Transactor{db: db}
+}
+
+// InTx is WithTx on the transactor's database.
+func (t Transactor) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return WithTx(ctx, t.db, fn)
+}
@@ -1,21 +1,72 @@
 package configs
 
 import (
-	"log"
+	"elastic-logger-app/common/logger"
+	"elastic-logger-app/common/redact"
+	"net/http"
 
 	"github.com/olivere/elastic/v7"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.uber.org/zap"
 )
 
-// var ESClient *elastic.Client
+// elasticClientOptions translates the Elasticsearch section of config into client options.
+func elasticClientOptions(config *Config) ([]elastic.ClientOptionFunc, error) {
+	cfg := config.Elastic
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	tlsConfig, err := cfg.TLS.Build()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+	// Every request to the cluster gets a client span through the instrumented transport.
+	httpClient := &http.Client{Transport: otelhttp.NewTransport(transport)}
+
+	opts := []elastic.ClientOptionFunc{
+		elastic.SetURL(cfg.URLs...),
+		elastic.SetSniff(false),
+		elastic.SetHttpClient(httpClient),
+		elastic.SetGzip(cfg.Gzip),
+		elastic.SetHealthcheck(cfg.HealthcheckInterval > 0),
+		elastic.SetRetrier(elastic.NewBackoffRetrier(
+			elastic.NewExponentialBackoff(cfg.RetryInitialBackoff, cfg.RetryMaxBackoff),
+		)),
+	}
+	if cfg.HealthcheckInterval > 0 {
+		opts = append(opts, elastic.SetHealthcheckInterval(cfg.HealthcheckInterval))
+	}
+
+	switch {
+	case cfg.APIKey != "":
+		opts = append(opts, elastic.SetHeaders(http.Header{"Authorization": {"ApiKey " + cfg.APIKey.Reveal()}}))
+	case cfg.Username != "":
+		opts = append(opts, elastic.SetBasicAuth(cfg.Username, cfg.Password.Reveal()))
+	}
+
+	return opts, nil
+}
 
 func ConnectElasticsearch(config *Config) *elastic.Client {
-	client, err := elastic.NewClient(elastic.SetURL(config.ELASTIC_URL), elastic.SetSniff(false))
+	log := logger.Named("configs")
+
+	opts, err := elasticClientOptions(config)
 	if err != nil {
-		log.Fatal("Failed to connect to Elasticsearch:", err)
+		log.Fatal("Invalid Elasticsearch options", zap.Error(err))
 	}
 
-	// ESClient = client
-	log.Println("Connected to Elasticsearch")
+	client, err := elastic.NewClient(opts...)
+	if err != nil {
+		log.Fatal("Failed to connect to Elasticsearch", zap.Error(err))
+	}
+
+	urls := make([]string, len(config.Elastic.URLs))
+	for i, u := range config.Elastic.URLs {
+		urls[i] = redact.URL(u)
+	}
+	log.Info("Connected to Elasticsearch", zap.Strings("urls", urls))
 
 	return client
 }
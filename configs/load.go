@@ -0,0 +1,346 @@
+package configs
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultConfigFile is read when present and no -config flag or CONFIG_FILE is given.
+const DefaultConfigFile = "config.yaml"
+
+// Options locate the file sources. Zero values use CONFIG_FILE or config.yaml, and .env.
+type Options struct {
+	// ConfigFile is the base YAML file. Next to it, config.<profile>.yaml is applied when present.
+	ConfigFile string
+	// EnvFile is the dotenv file. Variables already set in the environment take precedence.
+	EnvFile string
+	// Environ returns the environment; it defaults to os.Environ.
+	Environ func() []string
+}
+
+// Load builds the configuration from args (command-line flags, without the program name).
+// Sources are applied in increasing order of precedence:
+//
+//  1. Defaults
+//  2. the YAML file and its profile overlay (config.yaml, then config.<profile>.yaml)
+//  3. the .env file
+//  4. environment variables
+//  5. flags, one per key (e.g. -mysql.host db -http.port 9000), plus -profile as a shortcut
+//
+// Missing files are skipped. The result is validated and every problem is reported at once.
+func Load(args []string) (*Config, error) {
+	fset := flag.NewFlagSet("config", flag.ContinueOnError)
+	opts, flags := RegisterFlags(fset)
+	if err := fset.Parse(args); err != nil {
+		return nil, err
+	}
+	return LoadWith(*opts, flags)
+}
+
+// FlagValues are the raw values of the per-key flags that were given, keyed by dotted path.
+type FlagValues map[string]string
+
+// RegisterFlags adds -config, -env-file, -profile and one flag per configuration key to fset.
+// The returned values are filled when fset is parsed.
+func RegisterFlags(fset *flag.FlagSet) (*Options, FlagValues) {
+	opts := &Options{}
+	values := FlagValues{}
+
+	fset.StringVar(&opts.ConfigFile, "config", "", "YAML config file (default $CONFIG_FILE or "+DefaultConfigFile+")")
+	fset.StringVar(&opts.EnvFile, "env-file", ".env", "dotenv file")
+	fset.Func("profile", "shortcut for -app.profile", func(v string) error {
+		values["app.profile"] = v
+		return nil
+	})
+
+	for _, leaf := range leaves(reflect.ValueOf(&Config{}).Elem(), "") {
+		path := leaf.path
+		usage := "sets " + path
+		if leaf.env != "" {
+			usage += " (env " + leaf.env + ")"
+		}
+		fset.Func(path, usage, func(v string) error {
+			values[path] = v
+			return nil
+		})
+	}
+
+	return opts, values
+}
+
+// LoadWith is Load with already parsed options and flag values.
+func LoadWith(opts Options, flags FlagValues) (*Config, error) {
+	if opts.Environ == nil {
+		opts.Environ = os.Environ
+	}
+	environ := envMap(opts.Environ())
+
+	configFile, required := opts.ConfigFile, true
+	if configFile == "" {
+		configFile, required = environ["CONFIG_FILE"], true
+	}
+	if configFile == "" {
+		configFile, required = DefaultConfigFile, false
+	}
+
+	dotenv, err := readDotenv(opts.EnvFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// The profile overlay depends on the profile, which any source may set, so the layers are
+	// applied once to learn it and again with the overlay in place.
+	cfg, problems, err := build(configFile, required, "", dotenv, environ, flags)
+	if err != nil {
+		return nil, err
+	}
+	if overlay := profileFile(configFile, cfg.App.Profile); fileExists(overlay) {
+		cfg, problems, err = build(configFile, required, overlay, dotenv, environ, flags)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	problems = append(problems, cfg.validate()...)
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	return cfg, nil
+}
+
+func build(configFile string, required bool, overlay string, dotenv, environ map[string]string, flags FlagValues) (*Config, []string, error) {
+	cfg := Defaults()
+
+	if err := readYAML(configFile, required, &cfg); err != nil {
+		return nil, nil, err
+	}
+	if overlay != "" {
+		if err := readYAML(overlay, true, &cfg); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var problems []string
+	for _, leaf := range leaves(reflect.ValueOf(&cfg).Elem(), "") {
+		if leaf.env != "" {
+			for _, src := range []struct {
+				name   string
+				values map[string]string
+			}{{".env", dotenv}, {"env", environ}} {
+				v, source, ok, err := lookupEnv(src.values, leaf.env)
+				if err != nil {
+					problems = append(problems, fmt.Sprintf("%s %v", src.name, err))
+					continue
+				}
+				if ok {
+					problems = appendSetError(problems, leaf, v, src.name+" "+source)
+				}
+			}
+		}
+		if v, ok := flags[leaf.path]; ok {
+			problems = appendSetError(problems, leaf, v, "flag -"+leaf.path)
+		}
+	}
+
+	return &cfg, problems, nil
+}
+
+// lookupEnv returns the value of key, or the trimmed content of the file named by key_FILE
+// (as used by Docker secrets). Setting both is an error.
+func lookupEnv(values map[string]string, key string) (value, source string, ok bool, err error) {
+	v, hasValue := values[key]
+	path, hasFile := values[key+"_FILE"]
+
+	switch {
+	case hasValue && hasFile:
+		return "", "", false, fmt.Errorf("%s and %s_FILE are both set; use one", key, key)
+	case hasFile:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", "", false, fmt.Errorf("%s_FILE: %w", key, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), key + "_FILE", true, nil
+	default:
+		return v, key, hasValue, nil
+	}
+}
+
+func appendSetError(problems []string, l leaf, raw, source string) []string {
+	if err := setValue(l.value, raw); err != nil {
+		return append(problems, fmt.Sprintf("%s: %v", source, err))
+	}
+	return problems
+}
+
+func readYAML(path string, required bool, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && !required {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	return nil
+}
+
+func readDotenv(path string) (map[string]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	values, err := godotenv.Read(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// profileFile returns the overlay for profile next to base: config.yaml -> config.prod.yaml.
+func profileFile(base, profile string) string {
+	if profile == "" {
+		return ""
+	}
+	ext := ".yaml"
+	if strings.HasSuffix(base, ".yml") {
+		ext = ".yml"
+	}
+	return strings.TrimSuffix(base, ext) + "." + profile + ext
+}
+
+func fileExists(path string) bool {
+	if path == "" {
+		return false
+	}
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func envMap(environ []string) map[string]string {
+	m := make(map[string]string, len(environ))
+	for _, kv := range environ {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			m[k] = v
+		}
+	}
+	return m
+}
+
+// leaf is a settable configuration value with its dotted path and tags.
+type leaf struct {
+	path   string
+	env    string
+	secret bool
+	value  reflect.Value
+}
+
+// leaves lists the non-struct fields of v depth first, in declaration order.
+func leaves(v reflect.Value, prefix string) []leaf {
+	var out []leaf
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Struct {
+			nested := leaves(fv, path)
+			if prefix := f.Tag.Get("envprefix"); prefix != "" {
+				for j := range nested {
+					if nested[j].env != "" {
+						nested[j].env = prefix + nested[j].env
+					}
+				}
+			}
+			out = append(out, nested...)
+			continue
+		}
+		out = append(out, leaf{path: path, env: f.Tag.Get("env"), secret: fv.Type() == secretType, value: fv})
+	}
+	return out
+}
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	secretType   = reflect.TypeOf(Secret(""))
+)
+
+// setValue parses raw into v according to its type.
+func setValue(v reflect.Value, raw string) error {
+	raw = strings.TrimSpace(raw)
+
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration", raw)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", raw)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", raw)
+		}
+		v.SetInt(n)
+	case reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a non-negative integer", raw)
+		}
+		v.SetUint(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", raw)
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported config type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package configs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func environ(kv ...string) func() []string {
+	return func() []string { return kv }
+}
+
+func TestLoadLayers(t *testing.T) {
+	dir := t.TempDir()
+	configFile := writeFile(t, dir, "config.yaml", "http:\n  port: \"1000\"\nmysql:\n  host: yaml-host\n  user: yaml-user\n  database: yaml-db\nidempotency:\n  key_ttl: 1h\n")
+	writeFile(t, dir, "config.test.yaml", "mysql:\n  database: overlay-db\n")
+	envFile := writeFile(t, dir, ".env", "APP_PROFILE=test\nMYSQL_HOST=dotenv-host\nMYSQL_USER=dotenv-user\n")
+
+	cfg, err := LoadWith(Options{
+		ConfigFile: configFile,
+		EnvFile:    envFile,
+		Environ:    environ("MYSQL_HOST=env-host"),
+	}, FlagValues{"mysql.host": "flag-host"})
+	if err != nil {
+		t.Fatalf("LoadWith: %v", err)
+	}
+
+	checks := []struct{ name, got, want string }{
+		{"default", cfg.Mongo.Database, "myapp"},
+		{"yaml", cfg.HTTP.Port, "1000"},
+		{"profile overlay", cfg.MySQL.Database, "overlay-db"},
+		{".env over yaml", cfg.MySQL.User, "dotenv-user"},
+		{"flag over env", cfg.MySQL.Host, "flag-host"},
+		{"profile from .env", cfg.App.Profile, ProfileTest},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, c.got, c.want)
+		}
+	}
+	if cfg.Idempotency.KeyTTL != time.Hour {
+		t.Errorf("yaml duration: got %s, want 1h", cfg.Idempotency.KeyTTL)
+	}
+}
+
+func TestLoadReportsAllProblems(t *testing.T) {
+	_, err := LoadWith(Options{Environ: environ(
+		"APP_PROFILE=prod",
+		"RABBITMQ_PREFETCH=lots",
+		"LOG_LEVEL=loud",
+		"MONGODB_WRITE_CONCERN=0",
+	)}, FlagValues{"http.port": "0"})
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("err = %v, want *ValidationError", err)
+	}
+
+	joined := strings.Join(verr.Problems, "\n")
+	for _, want := range []string{
+		"env RABBITMQ_PREFETCH",
+		"log.level",
+		"http.port",
+		"mongo.write_concern",
+		"mysql.password still has its development default",
+	} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("problems do not mention %q:\n%s", want, joined)
+		}
+	}
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	cfg := Defaults()
+	cfg.MySQL.Password = Secret("s3cr3t")
+
+	var b strings.Builder
+	if err := cfg.Print(&b); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), "s3cr3t") || strings.Contains(b.String(), "guest:guest") {
+		t.Fatalf("secret leaked:\n%s", b.String())
+	}
+	if cfg.MySQL.Password.Reveal() != "s3cr3t" {
+		t.Fatal("Print modified the config")
+	}
+}
+
+func TestLoadReadsSecretFiles(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := writeFile(t, dir, "mysql_password", "from-file\n")
+
+	cfg, err := LoadWith(Options{Environ: environ("MYSQL_PASSWORD_FILE=" + secretFile)}, nil)
+	if err != nil {
+		t.Fatalf("LoadWith: %v", err)
+	}
+	if got := cfg.MySQL.Password.Reveal(); got != "from-file" {
+		t.Fatalf("password = %q, want the trimmed file content", got)
+	}
+
+	_, err = LoadWith(Options{Environ: environ("MYSQL_PASSWORD=x", "MYSQL_PASSWORD_FILE="+secretFile)}, nil)
+	if err == nil || !strings.Contains(err.Error(), "both set") {
+		t.Fatalf("err = %v, want a conflict between MYSQL_PASSWORD and MYSQL_PASSWORD_FILE", err)
+	}
+}
+
+func TestSecretNeverFormats(t *testing.T) {
+	s := Secret("s3cr3t")
+	for _, got := range []string{fmt.Sprint(s), fmt.Sprintf("%v %s %+v %#v", s, s, s, s)} {
+		if strings.Contains(got, "s3cr3t") {
+			t.Fatalf("secret leaked: %q", got)
+		}
+	}
+	if raw, _ := json.Marshal(struct{ P Secret }{s}); strings.Contains(string(raw), "s3cr3t") {
+		t.Fatalf("secret leaked in JSON: %s", raw)
+	}
+}
+
+func TestLoadNestedTLSEnv(t *testing.T) {
+	cfg, err := LoadWith(Options{Environ: environ(
+		"ELASTICSEARCH_URL=http://es1:9200, http://es2:9200",
+		"ELASTICSEARCH_TLS_ENABLED=true",
+		"MONGODB_MAX_POOL_SIZE=7",
+	)}, nil)
+	if err != nil {
+		t.Fatalf("LoadWith: %v", err)
+	}
+
+	if len(cfg.Elastic.URLs) != 2 || cfg.Elastic.URLs[1] != "http://es2:9200" {
+		t.Errorf("elastic urls = %v", cfg.Elastic.URLs)
+	}
+	if !cfg.Elastic.TLS.Enabled || cfg.Mongo.TLS.Enabled {
+		t.Errorf("TLS env prefixes were not applied per section: elastic %v, mongo %v", cfg.Elastic.TLS.Enabled, cfg.Mongo.TLS.Enabled)
+	}
+	if cfg.Mongo.MaxPoolSize != 7 {
+		t.Errorf("mongo max pool size = %d, want 7", cfg.Mongo.MaxPoolSize)
+	}
+}
+
+func TestMySQLDSNCarriesTimeouts(t *testing.T) {
+	cfg := Defaults()
+	dsn := MySQLDSN(&cfg)
+	for _, want := range []string{"timeout=5s", "readTimeout=30s", "writeTimeout=30s", "parseTime=true"} {
+		if !strings.Contains(dsn, want) {
+			t.Errorf("DSN %q does not contain %q", dsn, want)
+		}
+	}
+}
+
+func TestMongoClientOptionsUseReadPreference(t *testing.T) {
+	cfg := Defaults()
+	cfg.Mongo.ReadPreference = "secondaryPreferred"
+
+	opts, err := mongoClientOptions(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.ReadPreference == nil || opts.ReadPreference.Mode() != readpref.SecondaryPreferredMode {
+		t.Fatalf("read preference = %v, want secondaryPreferred", opts.ReadPreference)
+	}
+}
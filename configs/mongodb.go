@@ -2,22 +2,83 @@ package configs
 
 import (
 	"context"
-	"log"
+	"elastic-logger-app/common/logger"
+	"elastic-logger-app/common/metrics"
+	"elastic-logger-app/common/redact"
+	"strconv"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
+	"go.uber.org/zap"
 )
 
+// mongoClientOptions applies the URI and then the pool, timeout, read/write and TLS settings,
+// so explicit configuration wins over options embedded in the URI.
+func mongoClientOptions(config *Config) (*options.ClientOptions, error) {
+	cfg := config.Mongo
+
+	mode, err := readpref.ModeFromString(cfg.ReadPreference)
+	if err != nil {
+		return nil, err
+	}
+	rp, err := readpref.New(mode)
+	if err != nil {
+		return nil, err
+	}
+
+	wc := writeconcern.Majority()
+	if cfg.WriteConcern != "majority" {
+		w, err := strconv.Atoi(cfg.WriteConcern)
+		if err != nil {
+			return nil, err
+		}
+		wc = &writeconcern.WriteConcern{W: w}
+	}
+
+	opts := options.Client().
+		ApplyURI(cfg.URI.Reveal()).
+		SetMaxPoolSize(cfg.MaxPoolSize).
+		SetMinPoolSize(cfg.MinPoolSize).
+		SetMaxConnIdleTime(cfg.MaxConnIdleTime).
+		SetConnectTimeout(cfg.ConnectTimeout).
+		SetServerSelectionTimeout(cfg.ServerSelectionTimeout).
+		SetReadPreference(rp).
+		SetWriteConcern(wc).
+		SetMonitor(otelmongo.NewMonitor()).
+		SetPoolMonitor(metrics.MongoPoolMonitor())
+
+	tlsConfig, err := cfg.TLS.Build()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		opts.SetTLSConfig(tlsConfig)
+	}
+
+	return opts, nil
+}
+
 func ConnectMongodb(ctx context.Context, config *Config) *mongo.Client {
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(config.MONGODB_URI))
+	log := logger.Named("configs")
+	log.Info("Connecting to MongoDB", zap.String("uri", redact.URL(config.Mongo.URI.Reveal())))
+
+	opts, err := mongoClientOptions(config)
+	if err != nil {
+		log.Fatal("Invalid MongoDB options", zap.Error(err))
+	}
+
+	client, err := mongo.Connect(ctx, opts)
 	if err != nil {
-		log.Fatal(err)
+		log.Fatal("Can't create MongoDB client", zap.Error(err))
 	}
 
-	// Ping to verify connection works
-	if err := client.Ping(ctx, readpref.Primary()); err != nil {
-		log.Fatal("Can't connect to MongoDB: ", err)
+	// Ping with the configured read preference, so a deployment that only serves reads from
+	// secondaries is not reported unreachable while its primary is down.
+	if err := client.Ping(ctx, opts.ReadPreference); err != nil {
+		log.Fatal("Can't connect to MongoDB", zap.Error(err))
 	}
 
 	return client
@@ -2,30 +2,50 @@ package configs
 
 import (
 	"database/sql"
-	"fmt"
-	"log"
+	"elastic-logger-app/common/logger"
+	"elastic-logger-app/common/redact"
+	"net"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
+	"go.uber.org/zap"
 )
 
-func ConnectMysql(config *Config) *sql.DB {
-	dbHost := config.MYSQL_HOST
-	dbPort := config.MYSQL_PORT
-	dbUser := config.MYSQL_USER
-	dbPassword := config.MYSQL_PASSWORD
-	dbName := config.MYSQL_DATABASE
+// MySQLDSN builds the driver DSN from config, including the connection timeouts.
+func MySQLDSN(config *Config) string {
+	cfg := mysql.NewConfig()
+	cfg.Net = "tcp"
+	cfg.Addr = net.JoinHostPort(config.MySQL.Host, config.MySQL.Port)
+	cfg.User = config.MySQL.User
+	cfg.Passwd = config.MySQL.Password.Reveal()
+	cfg.DBName = config.MySQL.Database
+	cfg.MultiStatements = true
+	cfg.ParseTime = true
+	cfg.Loc = time.Local
+	cfg.Timeout = config.MySQL.DialTimeout
+	cfg.ReadTimeout = config.MySQL.ReadTimeout
+	cfg.WriteTimeout = config.MySQL.WriteTimeout
+	return cfg.FormatDSN()
+}
 
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?multiStatements=True&parseTime=True&loc=Local", dbUser, dbPassword, dbHost, dbPort, dbName)
-	log.Println("Connecting to MySQL with DSN:", dsn)
+func ConnectMysql(config *Config) *sql.DB {
+	dsn := MySQLDSN(config)
+	log := logger.Named("configs")
+	log.Info("Connecting to MySQL", zap.String("dsn", redact.MySQLDSN(dsn)))
 
 	db, err := sql.Open("mysql", dsn)
 	if err != nil {
-		log.Fatal("Open DSN failed: ", err)
+		log.Fatal("Open DSN failed", zap.Error(err))
 	}
 
+	db.SetMaxOpenConns(config.MySQL.MaxOpenConns)
+	db.SetMaxIdleConns(config.MySQL.MaxIdleConns)
+	db.SetConnMaxLifetime(config.MySQL.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(config.MySQL.ConnMaxIdleTime)
+
 	// Ping to verify connection works
 	if err := db.Ping(); err != nil {
-		log.Fatal("Ping MySQL failed: ", err)
+		log.Fatal("Ping MySQL failed", zap.Error(err))
 	}
 
 	return db
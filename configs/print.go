@@ -0,0 +1,17 @@
+package configs
+
+import (
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Print writes the effective configuration as YAML; Secret values are masked.
+func (c *Config) Print(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(c); err != nil {
+		return err
+	}
+	return enc.Close()
+}
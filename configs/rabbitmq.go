@@ -1,15 +1,46 @@
 package configs
 
 import (
-	"log"
+	"elastic-logger-app/common/logger"
+	"elastic-logger-app/common/redact"
 
 	"github.com/streadway/amqp"
+	"go.uber.org/zap"
 )
 
+// amqpConfig translates the RabbitMQ section of config into dial settings. TLS is used for
+// amqps:// URLs; the TLS section only adds a CA bundle or relaxes verification.
+func amqpConfig(config *Config) (amqp.Config, error) {
+	cfg := config.RabbitMQ
+
+	dial := amqp.Config{
+		Vhost:     cfg.VHost,
+		Heartbeat: cfg.Heartbeat,
+		Locale:    "en_US",
+		Dial:      amqp.DefaultDial(cfg.DialTimeout),
+	}
+
+	tlsConfig, err := cfg.TLS.Build()
+	if err != nil {
+		return amqp.Config{}, err
+	}
+	dial.TLSClientConfig = tlsConfig
+
+	return dial, nil
+}
+
 func ConnectRabbitMQ(config *Config) *amqp.Connection {
-	conn, err := amqp.Dial(config.RABBITMQ_URL)
+	log := logger.Named("configs")
+	log.Info("Connecting to RabbitMQ", zap.String("url", redact.URL(config.RabbitMQ.URL.Reveal())))
+
+	dial, err := amqpConfig(config)
+	if err != nil {
+		log.Fatal("Invalid RabbitMQ options", zap.Error(err))
+	}
+
+	conn, err := amqp.DialConfig(config.RabbitMQ.URL.Reveal(), dial)
 	if err != nil {
-		log.Fatalf("Failed to connect to RabbitMQ: %v", err)
+		log.Fatal("Failed to connect to RabbitMQ", zap.Error(err))
 	}
 	return conn
 }
@@ -25,7 +56,7 @@ func DeclareExchange(ch *amqp.Channel, name string) {
 		nil,     // args
 	)
 	if err != nil {
-		log.Fatalf("Failed to declare exchange: %v", err)
+		logger.Named("configs").Fatal("Failed to declare exchange", zap.String("exchange", name), zap.Error(err))
 	}
 }
 
@@ -39,7 +70,7 @@ func DeclareQueue(ch *amqp.Channel, name string) amqp.Queue {
 		nil,   // arguments
 	)
 	if err != nil {
-		log.Fatalf("Failed to declare a queue: %v", err)
+		logger.Named("configs").Fatal("Failed to declare a queue", zap.String("queue", name), zap.Error(err))
 	}
 	return q
 }
@@ -0,0 +1,35 @@
+package configs
+
+import "elastic-logger-app/common/redact"
+
+// Secret is a configuration value that must never be printed or logged. Every formatting path
+// (fmt verbs, JSON, YAML, zap reflection) renders it as redact.Mask; Reveal returns the value.
+type Secret string
+
+// Reveal returns the real value, for handing to the client that needs it.
+func (s Secret) Reveal() string {
+	return string(s)
+}
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return redact.Mask
+}
+
+func (s Secret) GoString() string {
+	return `configs.Secret("` + s.String() + `")`
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + s.String() + `"`), nil
+}
+
+func (s Secret) MarshalYAML() (any, error) {
+	return s.String(), nil
+}
+
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
@@ -0,0 +1,155 @@
+package configs
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.uber.org/zap/zapcore"
+)
+
+// ValidationError lists every problem found in a configuration.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// validate checks every field and returns all problems rather than stopping at the first.
+func (c *Config) validate() []string {
+	var p problems
+
+	p.check(oneOf(c.App.Profile, ProfileDev, ProfileTest, ProfileProd), "app.profile must be dev, test or prod, got %q", c.App.Profile)
+
+	p.check(validPort(c.HTTP.Port), "http.port must be a port number, got %q", c.HTTP.Port)
+	p.check(oneOf(c.HTTP.ErrorFormat, "envelope", "problem"), "http.error_format must be envelope or problem, got %q", c.HTTP.ErrorFormat)
+	p.check(c.HTTP.MaxBodyBytes > 0, "http.max_body_bytes must be positive")
+
+	p.check(c.MySQL.Host != "", "mysql.host is required")
+	p.check(validPort(c.MySQL.Port), "mysql.port must be a port number, got %q", c.MySQL.Port)
+	p.check(c.MySQL.User != "", "mysql.user is required")
+	p.check(c.MySQL.Database != "", "mysql.database is required")
+	p.check(c.MySQL.MaxOpenConns >= 0, "mysql.max_open_conns must not be negative")
+	p.check(c.MySQL.MaxIdleConns >= 0, "mysql.max_idle_conns must not be negative")
+	p.check(c.MySQL.MaxOpenConns == 0 || c.MySQL.MaxIdleConns <= c.MySQL.MaxOpenConns, "mysql.max_idle_conns must not exceed mysql.max_open_conns")
+	p.check(c.MySQL.ConnMaxLifetime >= 0 && c.MySQL.ConnMaxIdleTime >= 0, "mysql connection lifetimes must not be negative")
+	p.check(c.MySQL.DialTimeout >= 0 && c.MySQL.ReadTimeout >= 0 && c.MySQL.WriteTimeout >= 0, "mysql timeouts must not be negative")
+
+	p.check(oneOf(c.Migrations.SchemaCheck, SchemaCheckFail, SchemaCheckWarn, SchemaCheckOff), "migrations.schema_check must be fail, warn or off, got %q", c.Migrations.SchemaCheck)
+	p.check(c.Migrations.LockTimeout >= time.Second, "migrations.lock_timeout must be at least 1s")
+
+	p.check(hasScheme(c.Mongo.URI.Reveal(), "mongodb", "mongodb+srv"), "mongo.uri must be a mongodb:// or mongodb+srv:// URI")
+	p.check(c.Mongo.Database != "", "mongo.database is required")
+	p.check(c.Mongo.MaxPoolSize == 0 || c.Mongo.MinPoolSize <= c.Mongo.MaxPoolSize, "mongo.min_pool_size must not exceed mongo.max_pool_size")
+	p.check(c.Mongo.ConnectTimeout >= 0 && c.Mongo.ServerSelectionTimeout >= 0 && c.Mongo.MaxConnIdleTime >= 0, "mongo timeouts must not be negative")
+	_, rpErr := readpref.ModeFromString(c.Mongo.ReadPreference)
+	p.check(rpErr == nil, "mongo.read_preference must be primary, primaryPreferred, secondary, secondaryPreferred or nearest, got %q", c.Mongo.ReadPreference)
+	p.check(validWriteConcern(c.Mongo.WriteConcern), "mongo.write_concern must be majority or a number of at least 1, got %q", c.Mongo.WriteConcern)
+	p.checkTLS("mongo.tls", c.Mongo.TLS)
+
+	p.check(len(c.Elastic.URLs) > 0, "elastic.urls needs at least one URL")
+	for _, u := range c.Elastic.URLs {
+		p.check(hasScheme(u, "http", "https"), "elastic.urls must be http:// or https:// URLs, got %q", u)
+	}
+	p.check(c.Elastic.Password == "" || c.Elastic.Username != "", "elastic.username is required with elastic.password")
+	p.check(c.Elastic.HealthcheckInterval >= 0, "elastic.healthcheck_interval must not be negative")
+	p.check(c.Elastic.RetryInitialBackoff >= 0 && c.Elastic.RetryMaxBackoff >= c.Elastic.RetryInitialBackoff, "elastic.retry_max_backoff must be at least elastic.retry_initial_backoff")
+	p.checkTLS("elastic.tls", c.Elastic.TLS)
+
+	p.check(hasScheme(c.RabbitMQ.URL.Reveal(), "amqp", "amqps"), "rabbitmq.url must be an amqp:// or amqps:// URL")
+	p.check(c.RabbitMQ.Exchange != "", "rabbitmq.exchange is required")
+	p.check(c.RabbitMQ.Prefetch > 0, "rabbitmq.prefetch must be positive")
+	p.check(c.RabbitMQ.Workers > 0, "rabbitmq.workers must be positive")
+	p.check(c.RabbitMQ.Heartbeat >= 0 && c.RabbitMQ.DialTimeout >= 0, "rabbitmq timeouts must not be negative")
+	p.checkTLS("rabbitmq.tls", c.RabbitMQ.TLS)
+
+	p.check(c.Idempotency.MessageTTL > 0, "idempotency.message_ttl must be positive")
+	p.check(c.Idempotency.KeyTTL > 0, "idempotency.key_ttl must be positive")
+
+	_, levelErr := zapcore.ParseLevel(c.Log.Level)
+	p.check(levelErr == nil, "log.level must be debug, info, warn, error, dpanic, panic or fatal, got %q", c.Log.Level)
+	p.check(oneOf(c.Log.Format, "console", "json"), "log.format must be console or json, got %q", c.Log.Format)
+	p.check(!c.Log.ElasticEnabled || c.Log.ElasticIndex != "", "log.elastic_index is required when log.elastic_enabled is set")
+
+	p.check(oneOf(c.Tracing.Exporter, "none", "otlp", "stdout"), "tracing.exporter must be none, otlp or stdout, got %q", c.Tracing.Exporter)
+	p.check(c.Tracing.Exporter != "otlp" || c.Tracing.OTLPEndpoint != "", "tracing.otlp_endpoint is required when tracing.exporter is otlp")
+	p.check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio must be between 0 and 1")
+
+	if c.App.Profile == ProfileProd {
+		p.check(!c.Errors.ExposeLocation, "errors.expose_location must be off in the prod profile")
+		p.check(!c.Errors.ExposeReason, "errors.expose_reason must be off in the prod profile")
+		for _, path := range c.DefaultSecrets() {
+			p.add("%s still has its development default; set it explicitly in the prod profile", path)
+		}
+	}
+
+	return p
+}
+
+// DefaultSecrets returns the paths of secret fields still holding their value from Defaults.
+func (c *Config) DefaultSecrets() []string {
+	defaults := Defaults()
+	current := leaves(reflect.ValueOf(c).Elem(), "")
+	var paths []string
+	for i, d := range leaves(reflect.ValueOf(&defaults).Elem(), "") {
+		if d.secret && d.value.String() != "" && d.value.String() == current[i].value.String() {
+			paths = append(paths, d.path)
+		}
+	}
+	return paths
+}
+
+type problems []string
+
+func (p *problems) check(ok bool, format string, args ...any) {
+	if !ok {
+		p.add(format, args...)
+	}
+}
+
+func (p *problems) add(format string, args ...any) {
+	*p = append(*p, fmt.Sprintf(format, args...))
+}
+
+func (p *problems) checkTLS(path string, t TLSConfig) {
+	if t.CAFile != "" {
+		_, err := os.Stat(t.CAFile)
+		p.check(err == nil, "%s.ca_file: %v", path, err)
+	}
+	p.check(!t.InsecureSkipVerify || t.Enabled, "%s.insecure_skip_verify has no effect unless %s.enabled is set", path, path)
+}
+
+func validWriteConcern(v string) bool {
+	if v == "majority" {
+		return true
+	}
+	// w: 0 makes writes fire-and-forget, so errors such as duplicate keys are never seen.
+	n, err := strconv.Atoi(v)
+	return err == nil && n >= 1
+}
+
+func oneOf(v string, allowed ...string) bool {
+	for _, a := range allowed {
+		if v == a {
+			return true
+		}
+	}
+	return false
+}
+
+func validPort(v string) bool {
+	n, err := strconv.Atoi(v)
+	return err == nil && n > 0 && n <= 65535
+}
+
+func hasScheme(raw string, schemes ...string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && u.Host != "" && oneOf(u.Scheme, schemes...)
+}
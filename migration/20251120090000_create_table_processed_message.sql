@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE processed_message (
+    consumer VARCHAR(128) NOT NULL,
+    message_id VARCHAR(128) NOT NULL,
+    processed_at DATETIME(3) NOT NULL,
+    expires_at DATETIME(3) NOT NULL,
+    PRIMARY KEY (consumer, message_id),
+    INDEX idx_processed_message_expires_at (expires_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS processed_message;
+-- +goose StatementEnd
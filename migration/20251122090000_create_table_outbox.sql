@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE outbox (
+    seq BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    message_id VARCHAR(128) NOT NULL UNIQUE,
+    routing_key VARCHAR(255) NOT NULL,
+    body MEDIUMBLOB NOT NULL,
+    headers JSON NOT NULL,
+    created_at DATETIME(3) NOT NULL,
+    sent_at DATETIME(3) NULL,
+    INDEX idx_outbox_sent_at (sent_at, seq)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS outbox;
+-- +goose StatementEnd
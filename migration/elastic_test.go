@@ -0,0 +1,172 @@
+package migration
+
+import (
+	"context"
+	"elastic-logger-app/common/elastictest"
+	"testing"
+	"time"
+
+	"github.com/olivere/elastic/v7"
+)
+
+func TestElasticRunnerMovesLogsBehindAlias(t *testing.T) {
+	ctx := context.Background()
+	srv := elastictest.NewServer(t)
+	client := srv.Client()
+
+	// The log sink created app-logs through dynamic mapping before migrations existed.
+	for _, msg := range []string{"first", "second"} {
+		if _, err := client.Index().Index("app-logs").BodyJson(map[string]string{"message": msg, "level": "info"}).Do(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runner, err := NewElasticRunner(client, "app-logs", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runner.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	aliases, err := client.Aliases().Alias("app-logs").Do(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := aliases.IndicesByAlias("app-logs"); len(got) != 1 || got[0] != "app-logs-v1" {
+		t.Fatalf("app-logs points at %v", got)
+	}
+	if n := len(srv.Documents("app-logs-v1")); n != 2 {
+		t.Fatalf("app-logs-v1 has %d documents, want 2", n)
+	}
+
+	// New entries go through the alias into the versioned index.
+	if _, err := client.Index().Index("app-logs").BodyJson(map[string]string{"message": "third", "level": "warn"}).Do(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := client.Count("app-logs").Query(elastic.NewTermQuery("level", "warn")).Do(ctx); err != nil || n != 1 {
+		t.Fatalf("count = %d, %v", n, err)
+	}
+
+	current, latest, err := runner.Check(ctx)
+	if err != nil || current != latest {
+		t.Fatalf("check = %d/%d, %v", current, latest, err)
+	}
+	if results, err := runner.Up(ctx); err != nil || len(results) != 0 {
+		t.Fatalf("second up = %v, %v", results, err)
+	}
+}
+
+func TestElasticStoreLockExcludesOtherOwners(t *testing.T) {
+	ctx := context.Background()
+	client := elastictest.NewServer(t).Client()
+
+	first := NewElasticStore(client, time.Second)
+	if err := first.Lock(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	second := NewElasticStore(client, 50*time.Millisecond)
+	second.owner = "other"
+	if err := second.Lock(ctx); err == nil {
+		t.Fatal("second owner took a held lock")
+	}
+	if err := second.Unlock(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := first.Unlock(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Lock(ctx); err != nil {
+		t.Fatalf("lock after release: %v", err)
+	}
+}
+
+func TestReindexBehindAliasBlocksWritesToTheSource(t *testing.T) {
+	ctx := context.Background()
+	srv := elastictest.NewServer(t)
+	client := srv.Client()
+
+	if _, err := client.CreateIndex("logs-v0").BodyString(`{"aliases": {"logs": {"is_write_index": true}}}`).Do(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Index().Index("logs").BodyJson(map[string]string{"message": "first"}).Do(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := reindexBehindAlias(ctx, client, "logs", "logs-v1", logsMappingV1); err != nil {
+		t.Fatal(err)
+	}
+	// A late writer still holding the old index cannot add documents the copy missed.
+	if _, err := client.Index().Index("logs-v0").BodyJson(map[string]string{"message": "late"}).Do(ctx); err == nil {
+		t.Fatal("the old index accepted a write after the swap")
+	}
+	if n := len(srv.Documents("logs")); n != 1 {
+		t.Fatalf("logs has %d documents, want 1", n)
+	}
+}
+
+func TestReindexBehindAliasLiftsBlockOnFailure(t *testing.T) {
+	ctx := context.Background()
+	client := elastictest.NewServer(t).Client()
+
+	if _, err := client.Index().Index("logs").BodyJson(map[string]string{"message": "first"}).Do(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// A target nobody can write to makes the copy fail.
+	if _, err := client.CreateIndex("logs-v1").BodyString(`{"settings": {"index.blocks.write": true}}`).Do(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := reindexBehindAlias(ctx, client, "logs", "logs-v1", logsMappingV1); err == nil {
+		t.Fatal("reindex into a blocked index succeeded")
+	}
+	if _, err := client.Index().Index("logs").BodyJson(map[string]string{"message": "second"}).Do(ctx); err != nil {
+		t.Fatalf("logs still blocked after the failed step: %v", err)
+	}
+}
+
+func TestElasticStoreRenewsLease(t *testing.T) {
+	ctx := context.Background()
+	client := elastictest.NewServer(t).Client()
+
+	first := NewElasticStore(client, time.Second)
+	first.lease = 150 * time.Millisecond
+	if err := first.Lock(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Well past the first lease: only renewals keep the lock held.
+	time.Sleep(3 * first.lease)
+	second := NewElasticStore(client, 50*time.Millisecond)
+	second.owner = "other"
+	if err := second.Lock(ctx); err == nil {
+		t.Fatal("second owner took a lease that was being renewed")
+	}
+
+	if err := first.Unlock(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestElasticStoreUnlockReportsLostLease(t *testing.T) {
+	ctx := context.Background()
+	client := elastictest.NewServer(t).Client()
+
+	first := NewElasticStore(client, time.Second)
+	first.lease = 150 * time.Millisecond
+	if err := first.Lock(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// Another migrator overwrites the lease, as after a pause longer than the lease.
+	taken := elasticLockDocument{Owner: "other", LockedAt: time.Now().UTC(), ExpiresAt: time.Now().UTC().Add(time.Hour)}
+	if _, err := client.Index().Index(ElasticStateIndex).Id(elasticLockID).BodyJson(taken).Refresh("true").Do(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(first.lease)
+	if err := first.Unlock(ctx); err == nil {
+		t.Fatal("unlock after losing the lease reported no error")
+	}
+}
@@ -0,0 +1,45 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// lockName is the MySQL advisory lock held while migrations run.
+const lockName = "elastic-logger-app.migrate"
+
+// MySQLLocker is a goose session locker based on GET_LOCK, so only one process migrates at a
+// time. The lock belongs to the connection and is released if the process dies.
+type MySQLLocker struct {
+	name    string
+	timeout time.Duration
+}
+
+func NewMySQLLocker(name string, timeout time.Duration) *MySQLLocker {
+	return &MySQLLocker{name: name, timeout: timeout}
+}
+
+func (l *MySQLLocker) SessionLock(ctx context.Context, conn *sql.Conn) error {
+	var acquired sql.NullInt64
+	err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", l.name, int(l.timeout.Seconds())).Scan(&acquired)
+	if err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	if !acquired.Valid || acquired.Int64 != 1 {
+		return fmt.Errorf("acquire migration lock: another migration has held %q for more than %s", l.name, l.timeout)
+	}
+	return nil
+}
+
+func (l *MySQLLocker) SessionUnlock(ctx context.Context, conn *sql.Conn) error {
+	var released sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT RELEASE_LOCK(?)", l.name).Scan(&released); err != nil {
+		return fmt.Errorf("release migration lock: %w", err)
+	}
+	if !released.Valid || released.Int64 != 1 {
+		return fmt.Errorf("release migration lock: %q was not held by this session", l.name)
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+// Package migration versions the schema of every data store the app uses.
+//
+// MySQL migrations are embedded SQL files run with goose. They follow goose's SQL format and
+// are named <timestamp>_<description>.sql. Seed files (names ending in _seed.sql) are embedded
+// too but never applied by the runner; they are loaded by hand with make gs-seed.
+//
+// Mongo and Elasticsearch migrations are Go-coded Steps applied by a Runner, which records them
+// in a state collection or index of the migrated store. Mongo steps come from the modules that
+// own the collections; Elasticsearch steps (ElasticSteps) cover the shared log index.
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/olivere/elastic/v7"
+	"github.com/pressly/goose/v3"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+//go:embed *.sql
+var SQL embed.FS
+
+// Stores that can be migrated, in the order "migrate up" applies them.
+const (
+	StoreMySQL   = "mysql"
+	StoreMongo   = "mongo"
+	StoreElastic = "elastic"
+)
+
+// Stores lists every store name.
+var Stores = []string{StoreMySQL, StoreMongo, StoreElastic}
+
+// IsStore reports whether name is one of Stores.
+func IsStore(name string) bool {
+	return slices.Contains(Stores, name)
+}
+
+// Target is one store's migrations, as run by "migrate" and inspected by the startup check.
+type Target interface {
+	Name() string
+	Run(ctx context.Context, command string, out io.Writer) error
+	Check(ctx context.Context) (current, latest int64, err error)
+}
+
+// MySQL returns the goose provider as a Target.
+func MySQL(p *goose.Provider) Target {
+	return mysqlTarget{p}
+}
+
+type mysqlTarget struct {
+	provider *goose.Provider
+}
+
+func (t mysqlTarget) Name() string {
+	return StoreMySQL
+}
+
+func (t mysqlTarget) Run(ctx context.Context, command string, out io.Writer) error {
+	return Run(ctx, t.provider, command, out)
+}
+
+func (t mysqlTarget) Check(ctx context.Context) (int64, int64, error) {
+	return Check(ctx, t.provider)
+}
+
+// NewMongoRunner returns the Runner applying steps to the Mongo read models in db.
+func NewMongoRunner(db *mongo.Database, lockTimeout time.Duration, steps []Step) (*Runner, error) {
+	return NewRunner(StoreMongo, NewMongoStore(db, lockTimeout), steps)
+}
+
+// NewElasticRunner returns the Runner for the Elasticsearch indices; logsAlias is the index
+// name the log sink writes to.
+func NewElasticRunner(client *elastic.Client, logsAlias string, lockTimeout time.Duration) (*Runner, error) {
+	return NewRunner(StoreElastic, NewElasticStore(client, lockTimeout), ElasticSteps(client, logsAlias))
+}
+
+// Commands accepted by Run.
+const (
+	CommandUp     = "up"
+	CommandDown   = "down"
+	CommandStatus = "status"
+	CommandRedo   = "redo"
+)
+
+// IsCommand reports whether Run accepts command.
+func IsCommand(command string) bool {
+	switch command {
+	case CommandUp, CommandDown, CommandStatus, CommandRedo:
+		return true
+	}
+	return false
+}
+
+// NewMySQLProvider returns a goose provider for the embedded migrations. Runs are serialized
+// across processes with a MySQL advisory lock held for up to lockTimeout.
+func NewMySQLProvider(db *sql.DB, lockTimeout time.Duration) (*goose.Provider, error) {
+	seeds, err := seedFiles(SQL)
+	if err != nil {
+		return nil, err
+	}
+
+	return goose.NewProvider(goose.DialectMySQL, db, SQL,
+		goose.WithDisableGlobalRegistry(true),
+		goose.WithExcludeNames(seeds),
+		goose.WithSessionLocker(NewMySQLLocker(lockName, lockTimeout)),
+	)
+}
+
+func seedFiles(fsys fs.FS) ([]string, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	var seeds []string
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), "_seed.sql") {
+			seeds = append(seeds, e.Name())
+		}
+	}
+	return seeds, nil
+}
+
+// Run executes a migrate subcommand with the goose provider and reports what it did to out.
+func Run(ctx context.Context, p *goose.Provider, command string, out io.Writer) error {
+	switch command {
+	case CommandUp:
+		results, err := p.Up(ctx)
+		printResults(out, results)
+		if len(results) == 0 && err == nil {
+			fmt.Fprintln(out, "schema is up to date")
+		}
+		return err
+	case CommandDown:
+		result, err := p.Down(ctx)
+		printResults(out, []*goose.MigrationResult{result})
+		return err
+	case CommandRedo:
+		result, err := p.Down(ctx)
+		printResults(out, []*goose.MigrationResult{result})
+		if err != nil {
+			return err
+		}
+		result, err = p.UpByOne(ctx)
+		printResults(out, []*goose.MigrationResult{result})
+		return err
+	case CommandStatus:
+		return printStatus(ctx, p, out)
+	default:
+		return fmt.Errorf("unknown migrate command %q (want %s, %s, %s or %s)", command, CommandUp, CommandDown, CommandStatus, CommandRedo)
+	}
+}
+
+func printResults(out io.Writer, results []*goose.MigrationResult) {
+	for _, r := range results {
+		if r == nil {
+			continue
+		}
+		status := "OK"
+		if r.Error != nil {
+			status = "FAILED: " + r.Error.Error()
+		}
+		fmt.Fprintf(out, "%-4s %s (%s) %s\n", r.Direction, r.Source.Path, r.Duration.Round(time.Millisecond), status)
+	}
+}
+
+func printStatus(ctx context.Context, p *goose.Provider, out io.Writer) error {
+	statuses, err := p.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tSTATE\tAPPLIED AT\tFILE")
+	for _, s := range statuses {
+		applied := "-"
+		if !s.AppliedAt.IsZero() {
+			applied = s.AppliedAt.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Source.Version, s.State, applied, s.Source.Path)
+	}
+	return w.Flush()
+}
+
+// ErrSchemaBehind is returned by Check and Runner.Check when migrations are pending.
+var ErrSchemaBehind = errors.New("schema is behind the migrations built into this binary")
+
+// Check compares the database with the embedded migrations. It returns the current and
+// latest versions, and ErrSchemaBehind when any migration is pending.
+func Check(ctx context.Context, p *goose.Provider) (current, latest int64, err error) {
+	current, latest, err = p.GetVersions(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	pending, err := p.HasPending(ctx)
+	if err != nil {
+		return current, latest, err
+	}
+	if pending {
+		return current, latest, ErrSchemaBehind
+	}
+	return current, latest, nil
+}
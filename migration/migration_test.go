@@ -0,0 +1,54 @@
+package migration
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+func TestSeedFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"20250101000000_create_table_user.sql": {},
+		"20250102000000_user_seed.sql":         {},
+		"README.md":                            {},
+	}
+
+	seeds, err := seedFiles(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seeds) != 1 || seeds[0] != "20250102000000_user_seed.sql" {
+		t.Fatalf("seeds = %v", seeds)
+	}
+}
+
+func TestNewMySQLProviderLoadsEmbeddedMigrations(t *testing.T) {
+	// sql.Open does not connect, so the provider can be built without a server.
+	db, err := sql.Open("mysql", "user:pass@tcp(127.0.0.1:1)/db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	p, err := NewMySQLProvider(db, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sources := p.ListSources()
+	if len(sources) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+	for i, s := range sources {
+		if strings.HasSuffix(s.Path, "_seed.sql") {
+			t.Errorf("seed file %s is applied by the runner", s.Path)
+		}
+		if i > 0 && s.Version <= sources[i-1].Version {
+			t.Errorf("versions out of order: %d after %d", s.Version, sources[i-1].Version)
+		}
+	}
+}
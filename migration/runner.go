@@ -0,0 +1,307 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// Step is one versioned migration written in Go, for the stores goose does not handle
+// (Mongo collections, Elasticsearch indices). Versions must be unique and positive; once a
+// step is released, its version and behaviour must not change.
+type Step struct {
+	Version     int64
+	Description string
+	Up          func(ctx context.Context) error
+	// Down reverts Up. A nil Down marks the step as irreversible.
+	Down func(ctx context.Context) error
+}
+
+// AppliedStep is the record a Store keeps for an applied step.
+type AppliedStep struct {
+	Version     int64
+	Description string
+	AppliedAt   time.Time
+}
+
+// Store records which steps of a Runner were applied and keeps concurrent runs apart.
+type Store interface {
+	Applied(ctx context.Context) ([]AppliedStep, error)
+	Record(ctx context.Context, step AppliedStep) error
+	Remove(ctx context.Context, version int64) error
+	// Lock waits until no other process runs migrations on the store, and fails after the
+	// store's lock timeout.
+	Lock(ctx context.Context) error
+	Unlock(ctx context.Context) error
+}
+
+// ErrIrreversible is returned by Down when the latest applied step has no Down.
+var ErrIrreversible = errors.New("migration cannot be rolled back")
+
+// Runner applies Go-coded steps in version order and records them in a Store.
+type Runner struct {
+	name  string
+	store Store
+	steps []Step
+}
+
+func NewRunner(name string, store Store, steps []Step) (*Runner, error) {
+	sorted := make([]Step, len(steps))
+	copy(sorted, steps)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+
+	for i, s := range sorted {
+		if s.Version <= 0 {
+			return nil, fmt.Errorf("%s migration %q: version must be positive", name, s.Description)
+		}
+		if i > 0 && s.Version == sorted[i-1].Version {
+			return nil, fmt.Errorf("%s migration %d is defined twice", name, s.Version)
+		}
+		if s.Up == nil {
+			return nil, fmt.Errorf("%s migration %d has no Up", name, s.Version)
+		}
+	}
+
+	return &Runner{name: name, store: store, steps: sorted}, nil
+}
+
+func (r *Runner) Name() string {
+	return r.name
+}
+
+// StepResult reports one step applied or rolled back.
+type StepResult struct {
+	Step      Step
+	Direction string
+	Duration  time.Duration
+	Error     error
+}
+
+// StepStatus is the state of one step as shown by "migrate status".
+type StepStatus struct {
+	Step      Step
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Up applies every pending step in version order and stops at the first failure.
+func (r *Runner) Up(ctx context.Context) (results []StepResult, err error) {
+	unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { err = errors.Join(err, unlock()) }()
+
+	applied, err := r.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range r.steps {
+		if _, ok := applied[s.Version]; ok {
+			continue
+		}
+		result := r.apply(ctx, s, "up", s.Up)
+		results = append(results, result)
+		if result.Error != nil {
+			return results, fmt.Errorf("%s migration %d: %w", r.name, s.Version, result.Error)
+		}
+		record := AppliedStep{Version: s.Version, Description: s.Description, AppliedAt: time.Now().UTC()}
+		if err := r.store.Record(ctx, record); err != nil {
+			return results, fmt.Errorf("%s migration %d: record: %w", r.name, s.Version, err)
+		}
+	}
+	return results, nil
+}
+
+// Down rolls back the latest applied step.
+func (r *Runner) Down(ctx context.Context) (result *StepResult, err error) {
+	unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { err = errors.Join(err, unlock()) }()
+
+	return r.down(ctx)
+}
+
+// Redo rolls back the latest applied step and applies it again.
+func (r *Runner) Redo(ctx context.Context) (results []StepResult, err error) {
+	unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { err = errors.Join(err, unlock()) }()
+
+	down, err := r.down(ctx)
+	if down != nil {
+		results = append(results, *down)
+	}
+	if err != nil {
+		return results, err
+	}
+
+	up := r.apply(ctx, down.Step, "up", down.Step.Up)
+	results = append(results, up)
+	if up.Error != nil {
+		return results, fmt.Errorf("%s migration %d: %w", r.name, down.Step.Version, up.Error)
+	}
+	record := AppliedStep{Version: down.Step.Version, Description: down.Step.Description, AppliedAt: time.Now().UTC()}
+	if err := r.store.Record(ctx, record); err != nil {
+		return results, fmt.Errorf("%s migration %d: record: %w", r.name, down.Step.Version, err)
+	}
+	return results, nil
+}
+
+func (r *Runner) down(ctx context.Context) (*StepResult, error) {
+	applied, err := r.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var latest *Step
+	for i := len(r.steps) - 1; i >= 0; i-- {
+		if _, ok := applied[r.steps[i].Version]; ok {
+			latest = &r.steps[i]
+			break
+		}
+	}
+	if latest == nil {
+		return nil, fmt.Errorf("%s: no migration to roll back", r.name)
+	}
+	if latest.Down == nil {
+		return nil, fmt.Errorf("%s migration %d: %w", r.name, latest.Version, ErrIrreversible)
+	}
+
+	result := r.apply(ctx, *latest, "down", latest.Down)
+	if result.Error != nil {
+		return &result, fmt.Errorf("%s migration %d: %w", r.name, latest.Version, result.Error)
+	}
+	if err := r.store.Remove(ctx, latest.Version); err != nil {
+		return &result, fmt.Errorf("%s migration %d: remove record: %w", r.name, latest.Version, err)
+	}
+	return &result, nil
+}
+
+// Status lists every known step with the time it was applied, if it was.
+func (r *Runner) Status(ctx context.Context) ([]StepStatus, error) {
+	applied, err := r.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]StepStatus, 0, len(r.steps))
+	for _, s := range r.steps {
+		at, ok := applied[s.Version]
+		statuses = append(statuses, StepStatus{Step: s, Applied: ok, AppliedAt: at})
+	}
+	return statuses, nil
+}
+
+// Check returns the highest applied and the latest known versions, and ErrSchemaBehind when
+// any step is pending.
+func (r *Runner) Check(ctx context.Context) (current, latest int64, err error) {
+	statuses, err := r.Status(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	pending := false
+	for _, s := range statuses {
+		latest = s.Step.Version
+		if s.Applied {
+			current = s.Step.Version
+		} else {
+			pending = true
+		}
+	}
+	if pending {
+		return current, latest, ErrSchemaBehind
+	}
+	return current, latest, nil
+}
+
+// Run executes a migrate subcommand and reports what it did to out.
+func (r *Runner) Run(ctx context.Context, command string, out io.Writer) error {
+	switch command {
+	case CommandUp:
+		results, err := r.Up(ctx)
+		printStepResults(out, results)
+		if len(results) == 0 && err == nil {
+			fmt.Fprintln(out, "schema is up to date")
+		}
+		return err
+	case CommandDown:
+		result, err := r.Down(ctx)
+		if result != nil {
+			printStepResults(out, []StepResult{*result})
+		}
+		return err
+	case CommandRedo:
+		results, err := r.Redo(ctx)
+		printStepResults(out, results)
+		return err
+	case CommandStatus:
+		statuses, err := r.Status(ctx)
+		if err != nil {
+			return err
+		}
+		return printStepStatus(out, statuses)
+	default:
+		return fmt.Errorf("unknown migrate command %q (want %s, %s, %s or %s)", command, CommandUp, CommandDown, CommandStatus, CommandRedo)
+	}
+}
+
+func (r *Runner) lock(ctx context.Context) (func() error, error) {
+	if err := r.store.Lock(ctx); err != nil {
+		return nil, fmt.Errorf("%s: %w", r.name, err)
+	}
+	// Unlock even when ctx was cancelled mid-run, or the next run waits for the lock.
+	return func() error { return r.store.Unlock(context.WithoutCancel(ctx)) }, nil
+}
+
+func (r *Runner) applied(ctx context.Context) (map[int64]time.Time, error) {
+	records, err := r.store.Applied(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: read applied migrations: %w", r.name, err)
+	}
+
+	applied := make(map[int64]time.Time, len(records))
+	for _, rec := range records {
+		applied[rec.Version] = rec.AppliedAt
+	}
+	return applied, nil
+}
+
+func (r *Runner) apply(ctx context.Context, s Step, direction string, fn func(ctx context.Context) error) StepResult {
+	start := time.Now()
+	err := fn(ctx)
+	return StepResult{Step: s, Direction: direction, Duration: time.Since(start), Error: err}
+}
+
+func printStepResults(out io.Writer, results []StepResult) {
+	for _, r := range results {
+		status := "OK"
+		if r.Error != nil {
+			status = "FAILED: " + r.Error.Error()
+		}
+		fmt.Fprintf(out, "%-4s %d %s (%s) %s\n", r.Direction, r.Step.Version, r.Step.Description, r.Duration.Round(time.Millisecond), status)
+	}
+}
+
+func printStepStatus(out io.Writer, statuses []StepStatus) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tSTATE\tAPPLIED AT\tDESCRIPTION")
+	for _, s := range statuses {
+		state, applied := "pending", "-"
+		if s.Applied {
+			state, applied = "applied", s.AppliedAt.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Step.Version, state, applied, s.Step.Description)
+	}
+	return w.Flush()
+}
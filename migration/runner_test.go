@@ -0,0 +1,229 @@
+package migration
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memoryStore is a Store kept in a map, which also checks that Lock and Unlock pair up.
+type memoryStore struct {
+	applied map[int64]AppliedStep
+	locked  bool
+	unlocks int
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{applied: map[int64]AppliedStep{}}
+}
+
+func (s *memoryStore) Applied(ctx context.Context) ([]AppliedStep, error) {
+	var out []AppliedStep
+	for _, a := range s.applied {
+		out = append(out, a)
+	}
+	return out, nil
+}
+
+func (s *memoryStore) Record(ctx context.Context, step AppliedStep) error {
+	s.applied[step.Version] = step
+	return nil
+}
+
+func (s *memoryStore) Remove(ctx context.Context, version int64) error {
+	delete(s.applied, version)
+	return nil
+}
+
+func (s *memoryStore) Lock(ctx context.Context) error {
+	if s.locked {
+		return errors.New("already locked")
+	}
+	s.locked = true
+	return nil
+}
+
+func (s *memoryStore) Unlock(ctx context.Context) error {
+	s.locked = false
+	s.unlocks++
+	return nil
+}
+
+// recorder builds steps that append "up N" / "down N" to a log.
+type recorder struct {
+	log []string
+}
+
+func (r *recorder) step(version int64, reversible bool) Step {
+	s := Step{
+		Version:     version,
+		Description: "step",
+		Up: func(ctx context.Context) error {
+			r.log = append(r.log, "up "+strconv.FormatInt(version, 10))
+			return nil
+		},
+	}
+	if reversible {
+		s.Down = func(ctx context.Context) error {
+			r.log = append(r.log, "down "+strconv.FormatInt(version, 10))
+			return nil
+		}
+	}
+	return s
+}
+
+func TestNewRunnerValidatesSteps(t *testing.T) {
+	r := &recorder{}
+	cases := map[string][]Step{
+		"zero version": {r.step(0, true)},
+		"duplicate":    {r.step(1, true), r.step(1, true)},
+		"missing up":   {{Version: 1}},
+	}
+	for name, steps := range cases {
+		if _, err := NewRunner("test", newMemoryStore(), steps); err == nil {
+			t.Errorf("%s: want error", name)
+		}
+	}
+}
+
+func TestRunnerUpAppliesPendingInOrder(t *testing.T) {
+	r := &recorder{}
+	store := newMemoryStore()
+	store.applied[2] = AppliedStep{Version: 2}
+
+	runner, err := NewRunner("test", store, []Step{r.step(3, true), r.step(1, true), r.step(2, true)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := runner.Up(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(r.log, ","); got != "up 1,up 3" {
+		t.Fatalf("log = %s", got)
+	}
+	if len(results) != 2 || len(store.applied) != 3 {
+		t.Fatalf("results = %d, applied = %d", len(results), len(store.applied))
+	}
+	if store.locked || store.unlocks != 1 {
+		t.Fatalf("lock not released: locked=%v unlocks=%d", store.locked, store.unlocks)
+	}
+
+	if _, _, err := runner.Check(context.Background()); err != nil {
+		t.Fatalf("check after up: %v", err)
+	}
+}
+
+func TestRunnerUpStopsAtFailure(t *testing.T) {
+	r := &recorder{}
+	store := newMemoryStore()
+	failing := Step{Version: 2, Up: func(ctx context.Context) error { return errors.New("boom") }}
+
+	runner, err := NewRunner("test", store, []Step{r.step(1, true), failing, r.step(3, true)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := runner.Up(context.Background()); err == nil {
+		t.Fatal("want error")
+	}
+	if _, ok := store.applied[2]; ok || len(store.applied) != 1 {
+		t.Fatalf("applied = %v", store.applied)
+	}
+	if store.locked {
+		t.Fatal("lock not released after failure")
+	}
+
+	current, latest, err := runner.Check(context.Background())
+	if !errors.Is(err, ErrSchemaBehind) || current != 1 || latest != 3 {
+		t.Fatalf("check = %d, %d, %v", current, latest, err)
+	}
+}
+
+func TestRunnerDownAndRedo(t *testing.T) {
+	r := &recorder{}
+	store := newMemoryStore()
+	store.applied[1] = AppliedStep{Version: 1}
+	store.applied[2] = AppliedStep{Version: 2}
+
+	runner, err := NewRunner("test", store, []Step{r.step(1, true), r.step(2, true)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := runner.Redo(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runner.Down(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(r.log, ","); got != "down 2,up 2,down 2" {
+		t.Fatalf("log = %s", got)
+	}
+	if _, ok := store.applied[2]; ok || len(store.applied) != 1 {
+		t.Fatalf("applied = %v", store.applied)
+	}
+}
+
+func TestRunnerDownIrreversible(t *testing.T) {
+	r := &recorder{}
+	store := newMemoryStore()
+	store.applied[1] = AppliedStep{Version: 1}
+
+	runner, err := NewRunner("test", store, []Step{r.step(1, false)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := runner.Down(context.Background()); !errors.Is(err, ErrIrreversible) {
+		t.Fatalf("err = %v", err)
+	}
+	if len(store.applied) != 1 {
+		t.Fatal("irreversible step was removed")
+	}
+}
+
+func TestRunnerStatusOutput(t *testing.T) {
+	r := &recorder{}
+	store := newMemoryStore()
+	store.applied[1] = AppliedStep{Version: 1, AppliedAt: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)}
+
+	runner, err := NewRunner("test", store, []Step{r.step(1, true), r.step(2, true)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := runner.Run(context.Background(), CommandStatus, &out); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	want := []string{
+		"VERSION STATE APPLIED AT DESCRIPTION",
+		"1 applied 2025-01-02T03:04:05Z step",
+		"2 pending - step",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("status output:\n%s", out.String())
+	}
+	for i, line := range lines {
+		if got := strings.Join(strings.Fields(line), " "); got != want[i] {
+			t.Errorf("line %d = %q, want %q", i, got, want[i])
+		}
+	}
+}
+
+func TestElasticStepsAreValid(t *testing.T) {
+	steps := ElasticSteps(nil, "app-logs")
+	if _, err := NewRunner(StoreElastic, newMemoryStore(), steps); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(steps[0].Description, "app-logs-v1") {
+		t.Fatalf("description = %q", steps[0].Description)
+	}
+}
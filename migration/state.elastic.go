@@ -0,0 +1,188 @@
+package migration
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/olivere/elastic/v7"
+)
+
+// ElasticStateIndex holds one document per applied step, keyed by version, plus the lease
+// document taken while migrations run.
+const ElasticStateIndex = "elastic-logger-app-migrations"
+
+// elasticLockID is the id of the lease document in the state index.
+const elasticLockID = "lock"
+
+type elasticStateDocument struct {
+	Version     int64     `json:"version"`
+	Description string    `json:"description"`
+	AppliedAt   time.Time `json:"applied_at"`
+}
+
+type elasticLockDocument struct {
+	Owner     string    `json:"owner"`
+	LockedAt  time.Time `json:"locked_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ElasticStore keeps the applied steps in the ElasticStateIndex index.
+type ElasticStore struct {
+	client    *elastic.Client
+	owner     string
+	timeout   time.Duration
+	lease     time.Duration
+	heartbeat *heartbeat
+}
+
+func NewElasticStore(client *elastic.Client, lockTimeout time.Duration) *ElasticStore {
+	return &ElasticStore{client: client, owner: lockOwner(), timeout: lockTimeout, lease: lockLease}
+}
+
+func (s *ElasticStore) Applied(ctx context.Context) ([]AppliedStep, error) {
+	exists, err := s.client.IndexExists(ElasticStateIndex).Do(ctx)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	res, err := s.client.Search(ElasticStateIndex).
+		Query(elastic.NewExistsQuery("version")).
+		Sort("version", true).
+		Size(10000).
+		Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make([]AppliedStep, 0, len(res.Hits.Hits))
+	for _, hit := range res.Hits.Hits {
+		var doc elasticStateDocument
+		if err := json.Unmarshal(hit.Source, &doc); err != nil {
+			return nil, err
+		}
+		applied = append(applied, AppliedStep{Version: doc.Version, Description: doc.Description, AppliedAt: doc.AppliedAt})
+	}
+	return applied, nil
+}
+
+func (s *ElasticStore) Record(ctx context.Context, step AppliedStep) error {
+	doc := elasticStateDocument{Version: step.Version, Description: step.Description, AppliedAt: step.AppliedAt}
+	_, err := s.client.Index().
+		Index(ElasticStateIndex).
+		Id(strconv.FormatInt(step.Version, 10)).
+		BodyJson(doc).
+		Refresh("wait_for").
+		Do(ctx)
+	return err
+}
+
+func (s *ElasticStore) Remove(ctx context.Context, version int64) error {
+	_, err := s.client.Delete().
+		Index(ElasticStateIndex).
+		Id(strconv.FormatInt(version, 10)).
+		Refresh("wait_for").
+		Do(ctx)
+	if elastic.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// Lock takes the lease document: created when missing, or replaced when expired using its
+// sequence number, so two migrators cannot both win. The state index is created on first use.
+func (s *ElasticStore) Lock(ctx context.Context) error {
+	err := waitForLock(ctx, s.timeout, func() (bool, error) {
+		now := time.Now().UTC()
+		lease := elasticLockDocument{Owner: s.owner, LockedAt: now, ExpiresAt: now.Add(s.lease)}
+
+		current, err := s.client.Get().Index(ElasticStateIndex).Id(elasticLockID).Do(ctx)
+		switch {
+		case elastic.IsNotFound(err):
+			_, err = s.client.Index().Index(ElasticStateIndex).Id(elasticLockID).OpType("create").BodyJson(lease).Do(ctx)
+		case err != nil:
+			return false, err
+		default:
+			var held elasticLockDocument
+			if err := json.Unmarshal(current.Source, &held); err != nil {
+				return false, err
+			}
+			if now.Before(held.ExpiresAt) {
+				return false, nil
+			}
+			_, err = s.client.Index().Index(ElasticStateIndex).Id(elasticLockID).
+				IfSeqNo(*current.SeqNo).IfPrimaryTerm(*current.PrimaryTerm).
+				BodyJson(lease).Do(ctx)
+		}
+		if elastic.IsConflict(err) {
+			return false, nil
+		}
+		return err == nil, err
+	})
+	if err != nil {
+		return err
+	}
+
+	s.heartbeat = startHeartbeat(ctx, s.lease/3, s.renew)
+	return nil
+}
+
+// renew extends the lease if this store still holds it.
+func (s *ElasticStore) renew(ctx context.Context) (bool, error) {
+	current, err := s.client.Get().Index(ElasticStateIndex).Id(elasticLockID).Do(ctx)
+	if elastic.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return true, err
+	}
+
+	var held elasticLockDocument
+	if err := json.Unmarshal(current.Source, &held); err != nil {
+		return true, err
+	}
+	if held.Owner != s.owner {
+		return false, nil
+	}
+
+	held.ExpiresAt = time.Now().UTC().Add(s.lease)
+	_, err = s.client.Index().Index(ElasticStateIndex).Id(elasticLockID).
+		IfSeqNo(*current.SeqNo).IfPrimaryTerm(*current.PrimaryTerm).
+		BodyJson(held).Do(ctx)
+	// A conflict is settled at the next tick, which sees who wrote in between.
+	return true, err
+}
+
+func (s *ElasticStore) Unlock(ctx context.Context) error {
+	lost := s.heartbeat.stop()
+	s.heartbeat = nil
+	return errors.Join(lost, s.unlock(ctx))
+}
+
+func (s *ElasticStore) unlock(ctx context.Context) error {
+	current, err := s.client.Get().Index(ElasticStateIndex).Id(elasticLockID).Do(ctx)
+	if elastic.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var held elasticLockDocument
+	if err := json.Unmarshal(current.Source, &held); err != nil {
+		return err
+	}
+	if held.Owner != s.owner {
+		return nil
+	}
+
+	_, err = s.client.Delete().Index(ElasticStateIndex).Id(elasticLockID).
+		IfSeqNo(*current.SeqNo).IfPrimaryTerm(*current.PrimaryTerm).
+		Do(ctx)
+	if elastic.IsNotFound(err) || elastic.IsConflict(err) {
+		return nil
+	}
+	return err
+}
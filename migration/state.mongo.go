@@ -0,0 +1,199 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// mongoStateCollection holds one document per applied step, keyed by version.
+	mongoStateCollection = "schema_migrations"
+	// mongoLockCollection holds the lease taken while migrations run.
+	mongoLockCollection = "schema_migrations_lock"
+)
+
+// lockLease bounds how long a lock held by a crashed migrator blocks the next one. Neither
+// Mongo nor Elasticsearch release locks when a client dies, unlike MySQL's GET_LOCK. A live
+// migrator renews its lease every third of it, so long steps keep the lock.
+const lockLease = 15 * time.Minute
+
+// lockPollInterval is how often a waiting migrator retries the lock.
+const lockPollInterval = time.Second
+
+type mongoStateDocument struct {
+	Version     int64     `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"applied_at"`
+}
+
+// MongoStore keeps the applied steps in the schema_migrations collection of the database
+// being migrated.
+type MongoStore struct {
+	state     *mongo.Collection
+	locks     *mongo.Collection
+	owner     string
+	timeout   time.Duration
+	lease     time.Duration
+	heartbeat *heartbeat
+}
+
+func NewMongoStore(db *mongo.Database, lockTimeout time.Duration) *MongoStore {
+	return &MongoStore{
+		state:   db.Collection(mongoStateCollection),
+		locks:   db.Collection(mongoLockCollection),
+		owner:   lockOwner(),
+		timeout: lockTimeout,
+		lease:   lockLease,
+	}
+}
+
+func (s *MongoStore) Applied(ctx context.Context) ([]AppliedStep, error) {
+	cur, err := s.state.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []mongoStateDocument
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	applied := make([]AppliedStep, 0, len(docs))
+	for _, d := range docs {
+		applied = append(applied, AppliedStep{Version: d.Version, Description: d.Description, AppliedAt: d.AppliedAt})
+	}
+	return applied, nil
+}
+
+func (s *MongoStore) Record(ctx context.Context, step AppliedStep) error {
+	doc := mongoStateDocument{Version: step.Version, Description: step.Description, AppliedAt: step.AppliedAt}
+	_, err := s.state.ReplaceOne(ctx, bson.M{"_id": step.Version}, doc, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (s *MongoStore) Remove(ctx context.Context, version int64) error {
+	_, err := s.state.DeleteOne(ctx, bson.M{"_id": version})
+	return err
+}
+
+// Lock takes the lease document. The upsert only matches a missing or expired lease; a live
+// one makes the insert fail on the _id index, which means another migrator holds it.
+func (s *MongoStore) Lock(ctx context.Context) error {
+	err := waitForLock(ctx, s.timeout, func() (bool, error) {
+		now := time.Now().UTC()
+		_, err := s.locks.UpdateOne(ctx,
+			bson.M{"_id": lockName, "expires_at": bson.M{"$lt": now}},
+			bson.M{"$set": bson.M{"owner": s.owner, "locked_at": now, "expires_at": now.Add(s.lease)}},
+			options.Update().SetUpsert(true),
+		)
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		return err == nil, err
+	})
+	if err != nil {
+		return err
+	}
+
+	s.heartbeat = startHeartbeat(ctx, s.lease/3, func(ctx context.Context) (bool, error) {
+		res, err := s.locks.UpdateOne(ctx,
+			bson.M{"_id": lockName, "owner": s.owner},
+			bson.M{"$set": bson.M{"expires_at": time.Now().UTC().Add(s.lease)}},
+		)
+		if err != nil {
+			return true, err
+		}
+		return res.MatchedCount == 1, nil
+	})
+	return nil
+}
+
+func (s *MongoStore) Unlock(ctx context.Context) error {
+	lost := s.heartbeat.stop()
+	s.heartbeat = nil
+	_, err := s.locks.DeleteOne(ctx, bson.M{"_id": lockName, "owner": s.owner})
+	return errors.Join(lost, err)
+}
+
+// waitForLock calls try until it acquires the lock, fails, or timeout passes.
+func waitForLock(ctx context.Context, timeout time.Duration, try func() (bool, error)) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		acquired, err := try()
+		if err != nil {
+			return fmt.Errorf("acquire migration lock: %w", err)
+		}
+		if acquired {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("acquire migration lock: another migration has held %q for more than %s", lockName, timeout)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("acquire migration lock: %w", ctx.Err())
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+// heartbeat renews a lease in the background while its holder runs.
+type heartbeat struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+	lost   bool
+}
+
+// startHeartbeat calls renew every interval until stop. renew reports whether the lease is
+// still held; errors are retried at the next tick, as the lease outlives a few of them.
+func startHeartbeat(ctx context.Context, interval time.Duration, renew func(ctx context.Context) (bool, error)) *heartbeat {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	h := &heartbeat{cancel: cancel, done: make(chan struct{})}
+
+	go func() {
+		defer close(h.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				held, err := renew(ctx)
+				if err == nil && !held {
+					h.lost = true
+					return
+				}
+			}
+		}
+	}()
+	return h
+}
+
+// stop ends the renewals and reports whether the lease was lost on the way, i.e. another
+// migrator may have run at the same time. It is a no-op on nil.
+func (h *heartbeat) stop() error {
+	if h == nil {
+		return nil
+	}
+	h.cancel()
+	<-h.done
+	if h.lost {
+		return fmt.Errorf("migration lock %q expired and was taken by another migrator while this one ran", lockName)
+	}
+	return nil
+}
+
+// lockOwner identifies this process in lease documents, so it only releases its own lock.
+func lockOwner() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s:%d:%d", host, os.Getpid(), time.Now().UnixNano())
+}
@@ -0,0 +1,157 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/olivere/elastic/v7"
+)
+
+// logsMappingV1 replaces the dynamic mapping the log sink got by default. Unknown string fields
+// become keywords instead of text plus keyword, which keeps the mapping small.
+const logsMappingV1 = `{
+	"mappings": {
+		"dynamic_templates": [
+			{"strings_as_keywords": {"match_mapping_type": "string", "mapping": {"type": "keyword", "ignore_above": 1024}}}
+		],
+		"properties": {
+			"@timestamp": {"type": "date"},
+			"level": {"type": "keyword"},
+			"logger": {"type": "keyword"},
+			"caller": {"type": "keyword"},
+			"message": {"type": "text"},
+			"stacktrace": {"type": "text", "index": false},
+			"trace_id": {"type": "keyword"},
+			"method": {"type": "keyword"},
+			"path": {"type": "keyword"},
+			"route": {"type": "keyword"},
+			"status": {"type": "integer"},
+			"latency": {"type": "float"},
+			"client_ip": {"type": "ip"},
+			"size": {"type": "long"},
+			"errors": {"type": "text"}
+		}
+	}
+}`
+
+// ElasticSteps are the migrations of the Elasticsearch indices. logsAlias is the name the log
+// sink writes to (log.elastic_index); after these steps it is an alias over a versioned index.
+func ElasticSteps(client *elastic.Client, logsAlias string) []Step {
+	return []Step{
+		{
+			Version:     1,
+			Description: "logs: move to " + logsAlias + "-v1 with an explicit mapping behind the " + logsAlias + " alias",
+			Up: func(ctx context.Context) error {
+				return reindexBehindAlias(ctx, client, logsAlias, logsAlias+"-v1", logsMappingV1)
+			},
+			// The original index is deleted by the swap, so there is nothing to go back to.
+			Down: nil,
+		},
+	}
+}
+
+// reindexBehindAlias creates target with body, copies the documents currently reachable through
+// alias into it and points alias at target, as the write index.
+//
+// alias may be an existing alias, a concrete index created by dynamic mapping (which the swap
+// replaces) or missing. The swap is a single atomic alias update. So that no document written
+// during the copy is left behind, the old indices are write-blocked first: writes through alias
+// fail until the swap instead of being lost. The block is lifted again if the step fails; old
+// indices the swap only detaches stay read-only.
+func reindexBehindAlias(ctx context.Context, client *elastic.Client, alias, target, body string) (err error) {
+	sources, concrete, err := resolveAlias(ctx, client, alias)
+	if err != nil {
+		return err
+	}
+
+	exists, err := client.IndexExists(target).Do(ctx)
+	if err != nil {
+		return fmt.Errorf("check index %s: %w", target, err)
+	}
+	if !exists {
+		if _, err := client.CreateIndex(target).BodyString(body).Do(ctx); err != nil {
+			return fmt.Errorf("create index %s: %w", target, err)
+		}
+	}
+
+	var blocked []string
+	defer func() {
+		if err != nil {
+			for _, src := range blocked {
+				if unblockErr := setWriteBlock(context.WithoutCancel(ctx), client, src, false); unblockErr != nil {
+					err = errors.Join(err, unblockErr)
+				}
+			}
+		}
+	}()
+
+	actions := []elastic.AliasAction{}
+	for _, src := range sources {
+		if src == target {
+			continue
+		}
+		if err := setWriteBlock(ctx, client, src, true); err != nil {
+			return err
+		}
+		blocked = append(blocked, src)
+
+		res, err := client.Reindex().
+			SourceIndex(src).
+			DestinationIndex(target).
+			WaitForCompletion(true).
+			Refresh("true").
+			Do(ctx)
+		if err != nil {
+			return fmt.Errorf("reindex %s into %s: %w", src, target, err)
+		}
+		// Documents that could not be written are reported in the response, not as an error.
+		if len(res.Failures) > 0 {
+			return fmt.Errorf("reindex %s into %s: %d documents failed, first %s with status %d",
+				src, target, len(res.Failures), res.Failures[0].Id, res.Failures[0].Status)
+		}
+
+		if concrete {
+			actions = append(actions, elastic.NewAliasRemoveIndexAction(src))
+		} else {
+			actions = append(actions, elastic.NewAliasRemoveAction(alias).Index(src))
+		}
+	}
+	actions = append(actions, elastic.NewAliasAddAction(alias).Index(target).IsWriteIndex(true))
+
+	if _, err := client.Alias().Action(actions...).Do(ctx); err != nil {
+		return fmt.Errorf("point alias %s at %s: %w", alias, target, err)
+	}
+	return nil
+}
+
+// setWriteBlock sets or lifts index.blocks.write on index.
+func setWriteBlock(ctx context.Context, client *elastic.Client, index string, block bool) error {
+	_, err := client.IndexPutSettings(index).BodyJson(map[string]any{"index.blocks.write": block}).Do(ctx)
+	if err != nil {
+		return fmt.Errorf("set write block of %s to %t: %w", index, block, err)
+	}
+	return nil
+}
+
+// resolveAlias returns the indices behind name, and whether name is itself a concrete index.
+func resolveAlias(ctx context.Context, client *elastic.Client, name string) ([]string, bool, error) {
+	res, err := client.Aliases().Alias(name).Do(ctx)
+	switch {
+	case err == nil:
+		if indices := res.IndicesByAlias(name); len(indices) > 0 {
+			return indices, false, nil
+		}
+	case !elastic.IsNotFound(err):
+		return nil, false, fmt.Errorf("resolve alias %s: %w", name, err)
+	}
+
+	exists, err := client.IndexExists(name).Do(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("check index %s: %w", name, err)
+	}
+	if exists {
+		return []string{name}, true, nil
+	}
+	return nil, false, nil
+}
@@ -0,0 +1,24 @@
+package migration
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// CreateIndex returns a Step func creating model on coll. It is idempotent: Mongo accepts an
+// identical index that already exists.
+func CreateIndex(coll *mongo.Collection, model mongo.IndexModel) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		_, err := coll.Indexes().CreateOne(ctx, model)
+		return err
+	}
+}
+
+// DropIndex returns a Step func dropping the index called name from coll.
+func DropIndex(coll *mongo.Collection, name string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		_, err := coll.Indexes().DropOne(ctx, name)
+		return err
+	}
+}
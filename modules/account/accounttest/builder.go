@@ -0,0 +1,55 @@
+// Package accounttest wires the account usecases to the in-memory repositories, for tests of
+// the usecases and of the HTTP flow that need neither MySQL nor Mongo.
+package accounttest
+
+import (
+	"context"
+	"elastic-logger-app/common/outbox"
+	accountmemoryrepo "elastic-logger-app/modules/account/infras/memoryrepo"
+	accountcommands "elastic-logger-app/modules/account/usecase/commands"
+	accountprojections "elastic-logger-app/modules/account/usecase/projections"
+	accountqueries "elastic-logger-app/modules/account/usecase/queries"
+)
+
+// Builder satisfies accountcommands.Builder, accountqueries.Builder and
+// accountprojections.Builder with repositories over one in-memory Store. Commands write their
+// events to Outbox.
+type Builder struct {
+	Store  *accountmemoryrepo.Store
+	Outbox *outbox.MemoryStore
+}
+
+func NewBuilder() Builder {
+	return Builder{Store: accountmemoryrepo.NewStore(), Outbox: outbox.NewMemoryStore()}
+}
+
+func (b Builder) BuildAccountCommandRepo() accountcommands.AccountCommandRepo {
+	return accountmemoryrepo.NewAccountCommandRepo(b.Store)
+}
+
+func (b Builder) BuildAccountQueryRepo() accountqueries.AccountQueryRepo {
+	return accountmemoryrepo.NewAccountQueryRepo(b.Store)
+}
+
+func (b Builder) BuildAccountSource() accountprojections.AccountSource {
+	return accountmemoryrepo.NewAccountCommandRepo(b.Store)
+}
+
+func (b Builder) BuildAccountProjection() accountprojections.AccountProjection {
+	return accountmemoryrepo.NewAccountProjection(b.Store)
+}
+
+func (b Builder) BuildTransactor() accountcommands.Transactor {
+	return noTx{}
+}
+
+func (b Builder) BuildAccountOutbox() accountcommands.AccountOutbox {
+	return b.Outbox
+}
+
+// noTx runs functions as they are: the in-memory repositories have no transactions.
+type noTx struct{}
+
+func (noTx) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
@@ -0,0 +1,392 @@
+package accounttest
+
+import (
+	"context"
+	"elastic-logger-app/common"
+	"elastic-logger-app/common/query"
+	accountdomain "elastic-logger-app/modules/account/domain"
+	accountcommands "elastic-logger-app/modules/account/usecase/commands"
+	accountqueries "elastic-logger-app/modules/account/usecase/queries"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Repos is one pair of account repositories under contract test, backed by empty stores.
+type Repos struct {
+	Commands accountcommands.AccountCommandRepo
+	Queries  accountqueries.AccountQueryRepo
+	// Sync makes accounts created through Commands visible to Queries, e.g. by rebuilding the
+	// Mongo projection from MySQL. It is nil when they are visible at once.
+	Sync func(ctx context.Context) error
+}
+
+// RepoFactory returns repositories over empty stores. It is called once per contract case and
+// releases what it opened with t.Cleanup.
+type RepoFactory func(t *testing.T) Repos
+
+// RunRepoContract runs the behaviour every implementation of the account repositories must
+// share, so the in-memory ones stay a faithful stand-in for MySQL and Mongo.
+func RunRepoContract(t *testing.T, newRepos RepoFactory) {
+	cases := []struct {
+		name string
+		run  func(t *testing.T, r contractRepos)
+	}{
+		{"create then get by id", contractCreateThenGet},
+		{"duplicate email", contractDuplicateEmail},
+		{"get missing id", contractGetMissing},
+		{"get by email", contractGetByEmail},
+		{"list with filters", contractListFilters},
+		{"list pages with cursor", contractListCursor},
+		{"update status", contractUpdateStatus},
+		{"concurrent creates", contractConcurrentCreates},
+		{"concurrent updates", contractConcurrentUpdates},
+		{"updates racing creates", contractUpdatesRacingCreates},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.run(t, contractRepos{Repos: newRepos(t), t: t})
+		})
+	}
+}
+
+// contractRepos adds failing helpers to Repos.
+type contractRepos struct {
+	Repos
+	t *testing.T
+}
+
+func newContractAccount(t *testing.T, name, email string, status accountdomain.Status) *accountdomain.Account {
+	t.Helper()
+	a, err := accountdomain.NewAccount(common.GenUUID().String(), name, email, "contract-password", status, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func (r contractRepos) create(accounts ...*accountdomain.Account) {
+	r.t.Helper()
+	for _, a := range accounts {
+		if err := r.Commands.Create(context.Background(), a); err != nil {
+			r.t.Fatalf("create %s: %v", a.GetEmail(), err)
+		}
+	}
+}
+
+func (r contractRepos) sync() {
+	r.t.Helper()
+	if r.Sync == nil {
+		return
+	}
+	if err := r.Sync(context.Background()); err != nil {
+		r.t.Fatalf("sync: %v", err)
+	}
+}
+
+func (r contractRepos) list(raw string) ([]accountqueries.AccountDTO, common.Paging) {
+	r.t.Helper()
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	q, err := query.Parse(values, accountqueries.AccountListSchema)
+	if err != nil {
+		r.t.Fatalf("parse %q: %v", raw, err)
+	}
+	items, paging, err := r.Queries.List(context.Background(), q)
+	if err != nil {
+		r.t.Fatalf("list %q: %v", raw, err)
+	}
+	return items, paging
+}
+
+func ids(items []accountqueries.AccountDTO) []string {
+	out := make([]string, len(items))
+	for i, a := range items {
+		out[i] = a.Id
+	}
+	return out
+}
+
+func contractCreateThenGet(t *testing.T, r contractRepos) {
+	a := newContractAccount(t, "Ann", "ann@example.com", accountdomain.StatusActivated)
+	before := time.Now().Add(-time.Minute)
+	r.create(a)
+	r.sync()
+
+	got, err := r.Queries.GetByID(context.Background(), a.GetID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Id != a.GetID() || got.Name != "Ann" || got.Email != "ann@example.com" || got.Status != "activated" {
+		t.Fatalf("got %+v", got)
+	}
+	if got.CreatedAt.Before(before) || got.CreatedAt.After(time.Now().Add(time.Minute)) {
+		t.Fatalf("created_at %v was not stamped on create", got.CreatedAt)
+	}
+}
+
+func contractDuplicateEmail(t *testing.T, r contractRepos) {
+	first := newContractAccount(t, "Ann", "ann@example.com", accountdomain.StatusActivated)
+	r.create(first)
+
+	for _, email := range []string{"ann@example.com", "ANN@Example.com"} {
+		err := r.Commands.Create(context.Background(), newContractAccount(t, "Other", email, accountdomain.StatusActivated))
+		if !errors.Is(err, accountdomain.ErrEmailTaken) {
+			t.Errorf("create with %s: err = %v, want ErrEmailTaken", email, err)
+		}
+	}
+
+	r.sync()
+	if items, paging := r.list(""); paging.Total != 1 || items[0].Id != first.GetID() || items[0].Name != "Ann" {
+		t.Fatalf("accounts after duplicates = %+v", items)
+	}
+}
+
+func contractGetMissing(t *testing.T, r contractRepos) {
+	_, err := r.Queries.GetByID(context.Background(), common.GenUUID().String())
+	if !errors.Is(err, accountdomain.ErrNotFound) {
+		t.Fatalf("err = %v, want ErrNotFound", err)
+	}
+}
+
+func contractGetByEmail(t *testing.T, r contractRepos) {
+	ann := newContractAccount(t, "Ann", "ann@example.com", accountdomain.StatusActivated)
+	r.create(ann, newContractAccount(t, "Bob", "bob@example.com", accountdomain.StatusActivated))
+	r.sync()
+
+	items, paging := r.list("email=ann@example.com")
+	if paging.Total != 1 || len(items) != 1 || items[0].Id != ann.GetID() {
+		t.Fatalf("items = %+v", items)
+	}
+	if items, _ := r.list("email=nobody@example.com"); len(items) != 0 {
+		t.Fatalf("unknown email matched %+v", items)
+	}
+}
+
+func contractListFilters(t *testing.T, r contractRepos) {
+	alice := newContractAccount(t, "alice", "alice@example.com", accountdomain.StatusActivated)
+	albert := newContractAccount(t, "albert", "albert@example.com", accountdomain.StatusBanned)
+	carol := newContractAccount(t, "carol", "carol@example.com", accountdomain.StatusActivated)
+	r.create(alice, albert, carol)
+	r.sync()
+
+	cases := []struct {
+		raw  string
+		want []string
+	}{
+		{"sort=name", []string{albert.GetID(), alice.GetID(), carol.GetID()}},
+		{"sort=-name&status=activated", []string{carol.GetID(), alice.GetID()}},
+		{"sort=name&status=ne:activated", []string{albert.GetID()}},
+		{"sort=name&name=contains:AL", []string{albert.GetID(), alice.GetID()}},
+		{"sort=name&id=in:" + alice.GetID() + "," + carol.GetID(), []string{alice.GetID(), carol.GetID()}},
+		{"created_at=lt:2000-01-01", nil},
+	}
+	for _, tc := range cases {
+		items, paging := r.list(tc.raw)
+		if got := ids(items); strings.Join(got, ",") != strings.Join(tc.want, ",") || paging.Total != int64(len(tc.want)) {
+			t.Errorf("%s: got %v (total %d), want %v", tc.raw, got, paging.Total, tc.want)
+		}
+	}
+}
+
+func contractListCursor(t *testing.T, r contractRepos) {
+	var want []string
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		a := newContractAccount(t, name, name+"@example.com", accountdomain.StatusActivated)
+		r.create(a)
+		want = append(want, a.GetID())
+	}
+	r.sync()
+
+	var got []string
+	items, paging := r.list("sort=name&limit=2")
+	for {
+		if paging.Total != 5 {
+			t.Fatalf("total = %d", paging.Total)
+		}
+		got = append(got, ids(items)...)
+		if !paging.HasMore {
+			break
+		}
+		if len(got) > len(want) {
+			t.Fatalf("cursor does not end: %v", got)
+		}
+		items, paging = r.list("sort=name&limit=2&cursor=" + paging.NextCursor)
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("pages = %v, want %v", got, want)
+	}
+
+	if items, _ := r.list("sort=name&limit=2&page=3"); len(items) != 1 || items[0].Id != want[4] {
+		t.Fatalf("page 3 = %v", ids(items))
+	}
+}
+
+func contractUpdateStatus(t *testing.T, r contractRepos) {
+	a := newContractAccount(t, "Ann", "ann@example.com", accountdomain.StatusActivated)
+	r.create(a)
+
+	// Setting the status it already has is not a missing account, although MySQL reports no
+	// affected rows for it.
+	for _, status := range []accountdomain.Status{accountdomain.StatusBanned, accountdomain.StatusBanned} {
+		got, err := r.Commands.UpdateStatus(context.Background(), a.GetID(), status)
+		if err != nil {
+			t.Fatalf("update to %s: %v", status, err)
+		}
+		if got.GetID() != a.GetID() || got.GetStatus() != status || got.GetEmail() != "ann@example.com" || got.GetCreatedAt().IsZero() {
+			t.Fatalf("updated account = %+v", got)
+		}
+	}
+
+	r.sync()
+	if items, _ := r.list("status=banned"); len(items) != 1 || items[0].Id != a.GetID() || items[0].Name != "Ann" {
+		t.Fatalf("banned accounts = %+v", items)
+	}
+
+	_, err := r.Commands.UpdateStatus(context.Background(), common.GenUUID().String(), accountdomain.StatusBanned)
+	if !errors.Is(err, accountdomain.ErrNotFound) {
+		t.Fatalf("update missing account: err = %v, want ErrNotFound", err)
+	}
+}
+
+// contractConcurrentCreates races writers on one email, where exactly one may win, and on
+// distinct emails, where none may be lost.
+func contractConcurrentCreates(t *testing.T, r contractRepos) {
+	const writers = 8
+
+	errs := make([]error, writers)
+	var wg sync.WaitGroup
+	for i := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a := newContractAccount(t, "racer", "race@example.com", accountdomain.StatusActivated)
+			errs[i] = r.Commands.Create(context.Background(), a)
+		}()
+	}
+	wg.Wait()
+
+	won := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			won++
+		case !errors.Is(err, accountdomain.ErrEmailTaken):
+			t.Fatalf("racing create: %v", err)
+		}
+	}
+	if won != 1 {
+		t.Fatalf("%d creates with the same email succeeded", won)
+	}
+
+	for i := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a := newContractAccount(t, "writer", fmt.Sprintf("writer%d@example.com", i), accountdomain.StatusActivated)
+			errs[i] = r.Commands.Create(context.Background(), a)
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		t.Fatal(err)
+	}
+
+	r.sync()
+	if _, paging := r.list("name=writer"); paging.Total != writers {
+		t.Fatalf("%d of %d concurrent accounts were stored", paging.Total, writers)
+	}
+}
+
+// contractConcurrentUpdates races status changes on one account, which must all succeed and
+// leave it with one of the written statuses and its other fields intact, and on distinct
+// accounts, where none may be lost.
+func contractConcurrentUpdates(t *testing.T, r contractRepos) {
+	const writers = 8
+
+	shared := newContractAccount(t, "shared", "shared@example.com", accountdomain.StatusActivated)
+	r.create(shared)
+	own := make([]*accountdomain.Account, writers)
+	for i := range own {
+		own[i] = newContractAccount(t, "own", fmt.Sprintf("own%d@example.com", i), accountdomain.StatusActivated)
+		r.create(own[i])
+	}
+
+	errs := make([]error, 2*writers)
+	var wg sync.WaitGroup
+	for i := range writers {
+		status := accountdomain.StatusBanned
+		if i%2 == 0 {
+			status = accountdomain.StatusActivated
+		}
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = r.Commands.UpdateStatus(context.Background(), shared.GetID(), status)
+		}()
+		go func() {
+			defer wg.Done()
+			_, errs[writers+i] = r.Commands.UpdateStatus(context.Background(), own[i].GetID(), accountdomain.StatusBanned)
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		t.Fatal(err)
+	}
+
+	r.sync()
+	got, err := r.Queries.GetByID(context.Background(), shared.GetID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "shared" || got.Email != "shared@example.com" || (got.Status != "activated" && got.Status != "banned") {
+		t.Fatalf("shared account after racing updates = %+v", got)
+	}
+	if _, paging := r.list("name=own&status=banned"); paging.Total != writers {
+		t.Fatalf("%d of %d concurrent updates were stored", paging.Total, writers)
+	}
+}
+
+// contractUpdatesRacingCreates changes the status of accounts while they are being created.
+// An update may find no account yet, but once one succeeded the account must keep its status.
+func contractUpdatesRacingCreates(t *testing.T, r contractRepos) {
+	const accounts = 8
+
+	errs := make([]error, 2*accounts)
+	var wg sync.WaitGroup
+	for i := range accounts {
+		a := newContractAccount(t, "racing", fmt.Sprintf("racing%d@example.com", i), accountdomain.StatusActivated)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			errs[i] = r.Commands.Create(context.Background(), a)
+		}()
+		go func() {
+			defer wg.Done()
+			deadline := time.Now().Add(5 * time.Second)
+			for {
+				_, err := r.Commands.UpdateStatus(context.Background(), a.GetID(), accountdomain.StatusBanned)
+				if !errors.Is(err, accountdomain.ErrNotFound) || time.Now().After(deadline) {
+					errs[accounts+i] = err
+					return
+				}
+				time.Sleep(time.Millisecond)
+			}
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		t.Fatal(err)
+	}
+
+	r.sync()
+	if _, paging := r.list("name=racing&status=banned"); paging.Total != accounts {
+		t.Fatalf("%d of %d accounts kept the status set while they were created", paging.Total, accounts)
+	}
+}
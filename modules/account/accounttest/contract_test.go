@@ -0,0 +1,99 @@
+package accounttest
+
+import (
+	"context"
+	"database/sql"
+	"elastic-logger-app/builder"
+	"elastic-logger-app/common"
+	"elastic-logger-app/migration"
+	accountprojections "elastic-logger-app/modules/account/usecase/projections"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Set both variables to run the contract against real stores as well, e.g. a local mysqld and
+// mongod. They must point at throwaway instances: every case deletes all accounts in MySQL.
+const (
+	envContractMySQL = "ACCOUNT_CONTRACT_MYSQL_DSN"
+	envContractMongo = "ACCOUNT_CONTRACT_MONGO_URI"
+)
+
+func TestRepoContractMemory(t *testing.T) {
+	RunRepoContract(t, func(t *testing.T) Repos {
+		b := NewBuilder()
+		rebuild := accountprojections.NewAccountProjectionWithBuilder(b).Rebuild
+		return Repos{
+			Commands: b.BuildAccountCommandRepo(),
+			Queries:  b.BuildAccountQueryRepo(),
+			Sync: func(ctx context.Context) error {
+				_, err := rebuild.Handle(ctx, accountprojections.DefaultRebuildBatchSize)
+				return err
+			},
+		}
+	})
+}
+
+func TestRepoContractStores(t *testing.T) {
+	dsn, uri := os.Getenv(envContractMySQL), os.Getenv(envContractMongo)
+	if dsn == "" || uri == "" {
+		t.Skipf("set %s and %s to run the contract against MySQL and Mongo", envContractMySQL, envContractMongo)
+	}
+
+	db := openContractMySQL(t, dsn)
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = client.Disconnect(context.Background()) })
+
+	RunRepoContract(t, func(t *testing.T) Repos {
+		ctx := context.Background()
+		if _, err := db.ExecContext(ctx, "DELETE FROM account"); err != nil {
+			t.Fatal(err)
+		}
+		database := "account_contract_" + common.GenUUID().String()[:8]
+		t.Cleanup(func() { _ = client.Database(database).Drop(context.Background()) })
+
+		b := builder.NewAccountBuilder(db, client, database)
+		rebuild := accountprojections.NewAccountProjectionWithBuilder(b).Rebuild
+		return Repos{
+			Commands: b.BuildAccountCommandRepo(),
+			Queries:  b.BuildAccountQueryRepo(),
+			Sync: func(ctx context.Context) error {
+				_, err := rebuild.Handle(ctx, accountprojections.DefaultRebuildBatchSize)
+				return err
+			},
+		}
+	})
+}
+
+// openContractMySQL connects to dsn and applies the migrations.
+func openContractMySQL(t *testing.T, dsn string) *sql.DB {
+	t.Helper()
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ParseTime = true
+
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	provider, err := migration.NewMySQLProvider(db, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := migration.Run(context.Background(), provider, migration.CommandUp, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
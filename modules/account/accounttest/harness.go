@@ -0,0 +1,92 @@
+package accounttest
+
+import (
+	"bytes"
+	server "elastic-logger-app/api"
+	"elastic-logger-app/common/module"
+	"elastic-logger-app/configs"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Harness serves the full router, with its middlewares and error handling, over httptest with
+// the account module on memory repositories.
+type Harness struct {
+	t       *testing.T
+	Server  *httptest.Server
+	Builder Builder
+}
+
+// NewHarness starts the server for the duration of the test.
+func NewHarness(t *testing.T) *Harness {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	b := NewBuilder()
+	registry, err := module.NewRegistry(NewModule(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := configs.Defaults()
+	handler, err := server.InitServer(&config, nil, nil, nil, registry).Handler()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return &Harness{t: t, Server: srv, Builder: b}
+}
+
+// Response is a response read in full.
+type Response struct {
+	Status int
+	Body   []byte
+}
+
+// Decode unmarshals the body into v, failing the test when it is not JSON.
+func (r Response) Decode(t *testing.T, v any) {
+	t.Helper()
+	if err := json.Unmarshal(r.Body, v); err != nil {
+		t.Fatalf("response is not JSON: %v: %s", err, r.Body)
+	}
+}
+
+// Do sends a request to path, relative to the server root. A non-nil body is sent as JSON.
+func (h *Harness) Do(method, path string, body any) Response {
+	h.t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			h.t.Fatal(err)
+		}
+		reader = bytes.NewReader(raw)
+	}
+
+	req, err := http.NewRequest(method, h.Server.URL+path, reader)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := h.Server.Client().Do(req)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	return Response{Status: resp.StatusCode, Body: raw}
+}
@@ -0,0 +1,139 @@
+package accounttest
+
+import (
+	"net/http"
+	"testing"
+)
+
+type envelope[T any] struct {
+	Success bool `json:"success"`
+	Data    T    `json:"data"`
+	Error   struct {
+		ErrorCode string `json:"error_code"`
+	} `json:"error"`
+}
+
+type account struct {
+	Id     string `json:"id"`
+	Name   string `json:"name"`
+	Email  string `json:"email"`
+	Status string `json:"status"`
+}
+
+var ann = map[string]string{"name": "Ann", "email": "ann@example.com", "password": "correct-horse"}
+
+func TestCreateThenGetAccount(t *testing.T) {
+	h := NewHarness(t)
+
+	resp := h.Do(http.MethodPost, "/api/v1/accounts", ann)
+	if resp.Status != http.StatusOK {
+		t.Fatalf("create = %d: %s", resp.Status, resp.Body)
+	}
+	var created envelope[struct {
+		Id string `json:"id"`
+	}]
+	resp.Decode(t, &created)
+
+	resp = h.Do(http.MethodGet, "/api/v1/accounts/"+created.Data.Id, nil)
+	if resp.Status != http.StatusOK {
+		t.Fatalf("get = %d: %s", resp.Status, resp.Body)
+	}
+	var got envelope[account]
+	resp.Decode(t, &got)
+	want := account{Id: created.Data.Id, Name: "Ann", Email: "ann@example.com", Status: "activated"}
+	if got.Data != want {
+		t.Fatalf("got %+v, want %+v", got.Data, want)
+	}
+
+	resp = h.Do(http.MethodGet, "/api/v1/accounts?email=ann@example.com", nil)
+	var list envelope[[]account]
+	resp.Decode(t, &list)
+	if resp.Status != http.StatusOK || len(list.Data) != 1 || list.Data[0] != want {
+		t.Fatalf("list = %d: %s", resp.Status, resp.Body)
+	}
+}
+
+func TestCreateAccountErrors(t *testing.T) {
+	h := NewHarness(t)
+	if resp := h.Do(http.MethodPost, "/api/v1/accounts", ann); resp.Status != http.StatusOK {
+		t.Fatalf("create = %d: %s", resp.Status, resp.Body)
+	}
+
+	cases := []struct {
+		name   string
+		body   map[string]string
+		status int
+		code   string
+	}{
+		{"taken email", map[string]string{"name": "Other", "email": "ANN@example.com", "password": "correct-horse"}, http.StatusConflict, "ACCOUNT_EMAIL_TAKEN"},
+		{"invalid body", map[string]string{"name": "Ann", "email": "not-an-email"}, http.StatusBadRequest, "VALIDATION_FAILED"},
+	}
+	for _, tc := range cases {
+		resp := h.Do(http.MethodPost, "/api/v1/accounts", tc.body)
+		var got envelope[any]
+		resp.Decode(t, &got)
+		if resp.Status != tc.status || got.Error.ErrorCode != tc.code {
+			t.Errorf("%s: %d %s", tc.name, resp.Status, resp.Body)
+		}
+	}
+	if n := h.Builder.Store.Len(); n != 1 {
+		t.Fatalf("store has %d accounts", n)
+	}
+}
+
+func TestGetMissingAccount(t *testing.T) {
+	h := NewHarness(t)
+
+	resp := h.Do(http.MethodGet, "/api/v1/accounts/missing", nil)
+	var got envelope[any]
+	resp.Decode(t, &got)
+	if resp.Status != http.StatusNotFound || got.Error.ErrorCode != "ACCOUNT_NOT_FOUND" {
+		t.Fatalf("get = %d: %s", resp.Status, resp.Body)
+	}
+}
+
+func TestChangeAccountStatus(t *testing.T) {
+	h := NewHarness(t)
+	resp := h.Do(http.MethodPost, "/api/v1/accounts", ann)
+	var created envelope[struct {
+		Id string `json:"id"`
+	}]
+	resp.Decode(t, &created)
+
+	resp = h.Do(http.MethodPatch, "/api/v1/accounts/"+created.Data.Id+"/status", map[string]string{"status": "banned"})
+	if resp.Status != http.StatusOK {
+		t.Fatalf("change status = %d: %s", resp.Status, resp.Body)
+	}
+	var got envelope[account]
+	h.Do(http.MethodGet, "/api/v1/accounts/"+created.Data.Id, nil).Decode(t, &got)
+	if got.Data.Status != "banned" || got.Data.Email != "ann@example.com" {
+		t.Fatalf("after the change: %+v", got.Data)
+	}
+	// The create and the status change each leave an event for the relay.
+	events := h.Builder.Outbox.Pending()
+	if len(events) != 2 || events[0].RoutingKey != "account.created" || events[1].RoutingKey != "account.status_changed" {
+		t.Fatalf("outbox = %+v", events)
+	}
+
+	cases := []struct {
+		name   string
+		id     string
+		status string
+		code   int
+		error  string
+	}{
+		{"missing account", "missing", "banned", http.StatusNotFound, "ACCOUNT_NOT_FOUND"},
+		{"unknown status", created.Data.Id, "deleted", http.StatusBadRequest, "VALIDATION_FAILED"},
+	}
+	for _, tc := range cases {
+		resp := h.Do(http.MethodPatch, "/api/v1/accounts/"+tc.id+"/status", map[string]string{"status": tc.status})
+		var got envelope[any]
+		resp.Decode(t, &got)
+		if resp.Status != tc.code || got.Error.ErrorCode != tc.error {
+			t.Errorf("%s: %d %s", tc.name, resp.Status, resp.Body)
+		}
+	}
+	if n := len(h.Builder.Outbox.Pending()); n != 2 {
+		t.Fatalf("failed commands left events: outbox has %d", n)
+	}
+}
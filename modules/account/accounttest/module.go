@@ -0,0 +1,41 @@
+package accounttest
+
+import (
+	"elastic-logger-app/common/module"
+	accounthttp "elastic-logger-app/modules/account/infras/http"
+	accountcommands "elastic-logger-app/modules/account/usecase/commands"
+	accountqueries "elastic-logger-app/modules/account/usecase/queries"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Module is the account module over the in-memory repositories of its Builder. It needs no
+// infrastructure and serves the same routes as the real one, without idempotency keys.
+type Module struct {
+	Builder  Builder
+	commands accountcommands.Commands
+	queries  accountqueries.Queries
+}
+
+func NewModule(b Builder) *Module {
+	return &Module{Builder: b}
+}
+
+func (m *Module) Name() string {
+	return "account"
+}
+
+func (m *Module) Needs() []module.Need {
+	return nil
+}
+
+func (m *Module) Init(deps module.Deps) error {
+	m.commands = accountcommands.NewAccountCmdWithBuilder(m.Builder)
+	m.queries = accountqueries.NewAccountQueryWithBuilder(m.Builder)
+	return nil
+}
+
+func (m *Module) Routes(api *gin.RouterGroup) {
+	passthrough := func(c *gin.Context) { c.Next() }
+	accounthttp.NewAccountHTTP(m.commands, m.queries, passthrough).Routes(api)
+}
@@ -34,7 +34,11 @@ func (a *Account) GetStatus() Status {
 	return a.status
 }
 
+// GetCreatedAt returns the creation time, or the zero time for an account not stamped yet.
 func (a *Account) GetCreatedAt() time.Time {
+	if a.createdAt == nil {
+		return time.Time{}
+	}
 	return *a.createdAt
 }
 
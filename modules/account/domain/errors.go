@@ -0,0 +1,30 @@
+package accountdomain
+
+import (
+	"elastic-logger-app/common"
+	"errors"
+	"net/http"
+)
+
+var (
+	// ErrEmailTaken is returned by repositories when another account already uses the email.
+	ErrEmailTaken = errors.New("account email already taken")
+	// ErrNotFound is returned by repositories when no account has the requested ID.
+	ErrNotFound = errors.New("account not found")
+)
+
+const (
+	CodeEmailTaken   common.ErrorCode = "ACCOUNT_EMAIL_TAKEN"
+	CodeNotFound     common.ErrorCode = "ACCOUNT_NOT_FOUND"
+	CodeCreateFailed common.ErrorCode = "ACCOUNT_CREATE_FAILED"
+	CodeUpdateFailed common.ErrorCode = "ACCOUNT_UPDATE_FAILED"
+)
+
+func init() {
+	common.RegisterErrorCodes(
+		common.CatalogEntry{Code: CodeEmailTaken, Status: http.StatusConflict, Message: "An account with email {email} already exists.", Description: "The email is already registered to another account."},
+		common.CatalogEntry{Code: CodeNotFound, Status: http.StatusNotFound, Message: "The account was not found.", Description: "No account exists with the given ID."},
+		common.CatalogEntry{Code: CodeCreateFailed, Status: http.StatusInternalServerError, Message: "The account could not be created.", Description: "Storing the new account failed."},
+		common.CatalogEntry{Code: CodeUpdateFailed, Status: http.StatusInternalServerError, Message: "The account could not be updated.", Description: "Storing the change to the account failed."},
+	)
+}
@@ -0,0 +1,17 @@
+package accountdomain
+
+import "time"
+
+// Routing keys of the events published about accounts.
+const (
+	EventAccountCreated       = "account.created"
+	EventAccountStatusChanged = "account.status_changed"
+)
+
+// AccountEvent is the body of every account event. It only identifies the account:
+// consumers read its current state from the write model, so late or repeated events do not
+// roll it back.
+type AccountEvent struct {
+	AccountID  string    `json:"account_id"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
@@ -1,9 +1,26 @@
 -- name: CreateAccount :execresult
-INSERT INTO account (id, name, email, password, status)
-VALUES (?, ?, ?, ?, ?);
+INSERT INTO account (id, name, email, password, status, created_at)
+VALUES (?, ?, ?, ?, ?, COALESCE(sqlc.narg(created_at), CURRENT_TIMESTAMP));
 
 -- name: GetAccountByEmail :one
 SELECT id, name, email, password, status, created_at
 FROM account
 WHERE email = ? LIMIT 1;
 
+
+-- name: ListAccountsAfter :many
+SELECT id, name, email, password, status, created_at
+FROM account
+WHERE id > ?
+ORDER BY id
+LIMIT ?;
+
+-- name: GetAccountByID :one
+SELECT id, name, email, password, status, created_at
+FROM account
+WHERE id = ? LIMIT 1;
+
+-- name: UpdateAccountStatus :execresult
+UPDATE account
+SET status = ?
+WHERE id = ?;
@@ -11,16 +11,17 @@ import (
 )
 
 const createAccount = `-- name: CreateAccount :execresult
-INSERT INTO account (id, name, email, password, status)
-VALUES (?, ?, ?, ?, ?)
+INSERT INTO account (id, name, email, password, status, created_at)
+VALUES (?, ?, ?, ?, ?, COALESCE(?, CURRENT_TIMESTAMP))
 `
 
 type CreateAccountParams struct {
-	ID       string `json:"id"`
-	Name     string `json:"name"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
-	Status   int    `json:"status"`
+	ID        string       `json:"id"`
+	Name      string       `json:"name"`
+	Email     string       `json:"email"`
+	Password  string       `json:"password"`
+	Status    int          `json:"status"`
+	CreatedAt sql.NullTime `json:"created_at"`
 }
 
 func (q *Queries) CreateAccount(ctx context.Context, arg CreateAccountParams) (sql.Result, error) {
@@ -30,6 +31,7 @@ func (q *Queries) CreateAccount(ctx context.Context, arg CreateAccountParams) (s
 		arg.Email,
 		arg.Password,
 		arg.Status,
+		arg.CreatedAt,
 	)
 }
 
@@ -52,3 +54,81 @@ func (q *Queries) GetAccountByEmail(ctx context.Context, email string) (Account,
 	)
 	return i, err
 }
+
+const listAccountsAfter = `-- name: ListAccountsAfter :many
+SELECT id, name, email, password, status, created_at
+FROM account
+WHERE id > ?
+ORDER BY id
+LIMIT ?
+`
+
+type ListAccountsAfterParams struct {
+	ID    string `json:"id"`
+	Limit int32  `json:"limit"`
+}
+
+func (q *Queries) ListAccountsAfter(ctx context.Context, arg ListAccountsAfterParams) ([]Account, error) {
+	rows, err := q.db.QueryContext(ctx, listAccountsAfter, arg.ID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Account{}
+	for rows.Next() {
+		var i Account
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Email,
+			&i.Password,
+			&i.Status,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getAccountByID = `-- name: GetAccountByID :one
+SELECT id, name, email, password, status, created_at
+FROM account
+WHERE id = ? LIMIT 1
+`
+
+func (q *Queries) GetAccountByID(ctx context.Context, id string) (Account, error) {
+	row := q.db.QueryRowContext(ctx, getAccountByID, id)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Email,
+		&i.Password,
+		&i.Status,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const updateAccountStatus = `-- name: UpdateAccountStatus :execresult
+UPDATE account
+SET status = ?
+WHERE id = ?
+`
+
+type UpdateAccountStatusParams struct {
+	Status int    `json:"status"`
+	ID     string `json:"id"`
+}
+
+func (q *Queries) UpdateAccountStatus(ctx context.Context, arg UpdateAccountStatusParams) (sql.Result, error) {
+	return q.db.ExecContext(ctx, updateAccountStatus, arg.Status, arg.ID)
+}
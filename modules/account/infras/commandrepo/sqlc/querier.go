@@ -12,6 +12,9 @@ import (
 type Querier interface {
 	CreateAccount(ctx context.Context, arg CreateAccountParams) (sql.Result, error)
 	GetAccountByEmail(ctx context.Context, email string) (Account, error)
+	GetAccountByID(ctx context.Context, id string) (Account, error)
+	ListAccountsAfter(ctx context.Context, arg ListAccountsAfterParams) ([]Account, error)
+	UpdateAccountStatus(ctx context.Context, arg UpdateAccountStatusParams) (sql.Result, error)
 }
 
 var _ Querier = (*Queries)(nil)
@@ -3,12 +3,23 @@ package accountcommandrepo
 import (
 	"context"
 	"database/sql"
+	"elastic-logger-app/common"
+	"elastic-logger-app/common/tracing"
 	accountdomain "elastic-logger-app/modules/account/domain"
 	"elastic-logger-app/modules/account/infras/commandrepo/sqlc"
+	"errors"
+	"fmt"
+	"strings"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 )
 
+// mysqlErrDuplicateEntry is the MySQL error number for a unique key violation.
+const mysqlErrDuplicateEntry = 1062
+
+// emailUniqueKey is the name MySQL gives the inline UNIQUE index on account.email.
+const emailUniqueKey = "email"
+
 type accountCommandRepo struct {
 	db    *sql.DB
 	store *sqlc.Queries
@@ -17,17 +28,105 @@ type accountCommandRepo struct {
 func NewAccountCommandRepo(db *sql.DB) *accountCommandRepo {
 	return &accountCommandRepo{
 		db:    db,
-		store: sqlc.New(db),
+		store: sqlc.New(tracing.WrapDBTX(db)),
+	}
+}
+
+// queries returns the sqlc store bound to the transaction carried by ctx, if any.
+func (r *accountCommandRepo) queries(ctx context.Context) *sqlc.Queries {
+	if tx, ok := common.TxFromContext(ctx); ok {
+		return sqlc.New(tracing.WrapDBTX(tx))
 	}
+	return r.store
 }
 
 func (r *accountCommandRepo) Create(ctx context.Context, entity *accountdomain.Account) error {
-	_, err := r.store.CreateAccount(ctx, sqlc.CreateAccountParams{
+	_, err := r.queries(ctx).CreateAccount(ctx, sqlc.CreateAccountParams{
 		ID:       entity.GetID(),
 		Name:     entity.GetName(),
 		Email:    entity.GetEmail(),
 		Password: entity.GetPassword(),
 		Status:   int(entity.GetStatus()),
+		// Unstamped accounts get the column default.
+		CreatedAt: sql.NullTime{Time: entity.GetCreatedAt(), Valid: !entity.GetCreatedAt().IsZero()},
 	})
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrDuplicateEntry &&
+		duplicateKey(mysqlErr.Message) == emailUniqueKey {
+		return fmt.Errorf("%w: %s", accountdomain.ErrEmailTaken, mysqlErr.Message)
+	}
 	return err
 }
+
+// duplicateKey returns the index named by a duplicate entry message, "Duplicate entry 'v'
+// for key 'k'". MySQL 8.0.19+ qualifies the key with its table ("account.email"); the
+// qualifier is dropped so both forms compare equal.
+func duplicateKey(message string) string {
+	// The entry value is user input and may itself contain the marker, so take the last one.
+	const marker = " for key '"
+	i := strings.LastIndex(message, marker)
+	if i < 0 {
+		return ""
+	}
+	key := strings.TrimSuffix(message[i+len(marker):], "'")
+	if i := strings.LastIndexByte(key, '.'); i >= 0 {
+		key = key[i+1:]
+	}
+	return key
+}
+
+// UpdateStatus sets the status of the account with id and returns the account as stored.
+func (r *accountCommandRepo) UpdateStatus(ctx context.Context, id string, status accountdomain.Status) (*accountdomain.Account, error) {
+	q := r.queries(ctx)
+	if _, err := q.UpdateAccountStatus(ctx, sqlc.UpdateAccountStatusParams{Status: int(status), ID: id}); err != nil {
+		return nil, err
+	}
+
+	// Rows affected is 0 both for a missing account and for an unchanged status, so the
+	// account is read back to tell them apart.
+	row, err := q.GetAccountByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", accountdomain.ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toAccount(row), nil
+}
+
+// GetByID returns the account with id, or accountdomain.ErrNotFound.
+func (r *accountCommandRepo) GetByID(ctx context.Context, id string) (*accountdomain.Account, error) {
+	row, err := r.queries(ctx).GetAccountByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", accountdomain.ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toAccount(row), nil
+}
+
+// ListAfter returns up to limit accounts with an id greater than afterID, in id order, so
+// callers can walk the whole table in batches.
+func (r *accountCommandRepo) ListAfter(ctx context.Context, afterID string, limit int) ([]*accountdomain.Account, error) {
+	rows, err := r.queries(ctx).ListAccountsAfter(ctx, sqlc.ListAccountsAfterParams{
+		ID:    afterID,
+		Limit: int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	accounts := make([]*accountdomain.Account, 0, len(rows))
+	for _, row := range rows {
+		accounts = append(accounts, toAccount(row))
+	}
+	return accounts, nil
+}
+
+func toAccount(row sqlc.Account) *accountdomain.Account {
+	createdAt := row.CreatedAt
+	entity, _ := accountdomain.NewAccount(row.ID, row.Name, row.Email, row.Password, accountdomain.Status(row.Status), &createdAt)
+	return entity
+}
@@ -0,0 +1,21 @@
+package accountcommandrepo
+
+import "testing"
+
+func TestDuplicateKey(t *testing.T) {
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{"Duplicate entry 'a@b.co' for key 'email'", "email"},
+		{"Duplicate entry 'a@b.co' for key 'account.email'", "email"},
+		{"Duplicate entry '0192' for key 'account.PRIMARY'", "PRIMARY"},
+		{"Duplicate entry 'x for key 'email'' for key 'PRIMARY'", "PRIMARY"},
+		{"Deadlock found when trying to get lock", ""},
+	}
+	for _, tt := range tests {
+		if got := duplicateKey(tt.message); got != tt.want {
+			t.Errorf("duplicateKey(%q) = %q, want %q", tt.message, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package accountconsumer
+
+import (
+	"context"
+	"elastic-logger-app/common/idempotency"
+	"elastic-logger-app/common/rabbitmq"
+	accountdomain "elastic-logger-app/modules/account/domain"
+	accountprojections "elastic-logger-app/modules/account/usecase/projections"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/streadway/amqp"
+)
+
+// ProjectionQueue is the queue the account read model is kept up to date from.
+const ProjectionQueue = "account.projection"
+
+// NewProjectionConsumer returns the consumer of cfg.Queue that projects every account event
+// into the read model. guard acknowledges the events the relay published twice without
+// projecting them again; events that keep failing end in the dead-letter queue.
+func NewProjectionConsumer(conn rabbitmq.Connection, cfg rabbitmq.ConsumerConfig, guard *idempotency.Guard, projections accountprojections.Projections) *rabbitmq.Consumer {
+	c := rabbitmq.NewConsumer(conn, cfg)
+	c.Handle("account.#", guard.Wrap(handleAccountEvent(projections)))
+	return c
+}
+
+func handleAccountEvent(projections accountprojections.Projections) rabbitmq.HandlerFunc {
+	return func(ctx context.Context, d amqp.Delivery) error {
+		var event accountdomain.AccountEvent
+		if err := json.Unmarshal(d.Body, &event); err != nil {
+			return fmt.Errorf("decode account event: %w", err)
+		}
+		if event.AccountID == "" {
+			return errors.New("account event without account_id")
+		}
+		return projections.Sync.Handle(ctx, event.AccountID)
+	}
+}
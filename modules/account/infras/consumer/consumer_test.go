@@ -0,0 +1,145 @@
+package accountconsumer_test
+
+import (
+	"context"
+	"elastic-logger-app/common/idempotency"
+	"elastic-logger-app/common/outbox"
+	"elastic-logger-app/common/rabbitmq"
+	"elastic-logger-app/common/rabbitmqtest"
+	"elastic-logger-app/modules/account/accounttest"
+	accountdomain "elastic-logger-app/modules/account/domain"
+	accountconsumer "elastic-logger-app/modules/account/infras/consumer"
+	accountprojections "elastic-logger-app/modules/account/usecase/projections"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryMessageStore is an idempotency.MessageStore kept in a map.
+type memoryMessageStore struct {
+	mu        sync.Mutex
+	processed map[string]bool
+}
+
+func (s *memoryMessageStore) Process(ctx context.Context, consumer, messageID string, fn func(ctx context.Context) error) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.processed[consumer+"/"+messageID] {
+		return true, nil
+	}
+	if err := fn(ctx); err != nil {
+		return false, err
+	}
+	s.processed[consumer+"/"+messageID] = true
+	return false, nil
+}
+
+type fixture struct {
+	broker    *rabbitmqtest.Broker
+	builder   accounttest.Builder
+	guard     *idempotency.Guard
+	publisher *rabbitmq.Publisher
+}
+
+// start runs the projection consumer on a broker until the test ends. Failed events are
+// dead-lettered on their first failure.
+func start(t *testing.T) *fixture {
+	t.Helper()
+	f := &fixture{broker: rabbitmqtest.NewBroker(), builder: accounttest.NewBuilder()}
+	f.guard = idempotency.NewGuard(&memoryMessageStore{processed: map[string]bool{}}, accountconsumer.ProjectionQueue)
+
+	cfg := rabbitmq.DefaultConsumerConfig("events", accountconsumer.ProjectionQueue)
+	cfg.Workers = 1
+	cfg.MaxAttempts = 1
+	c := accountconsumer.NewProjectionConsumer(f.broker, cfg, f.guard, accountprojections.NewAccountProjectionWithBuilder(f.builder))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- c.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("consumer: %v", err)
+		}
+	})
+	f.wait(t, func(ctx context.Context) error {
+		return f.broker.WaitConsumers(ctx, accountconsumer.ProjectionQueue, 1)
+	})
+
+	p, err := rabbitmq.NewPublisher(f.broker, "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Close() })
+	f.publisher = p
+	return f
+}
+
+func (f *fixture) wait(t *testing.T, until func(ctx context.Context) error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := until(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProjectionConsumerProjectsRelayedEvents(t *testing.T) {
+	f := start(t)
+	ctx := context.Background()
+
+	// Written to MySQL and the outbox only, as when the direct projection failed.
+	createdAt := time.Now().UTC().Truncate(time.Second)
+	a, _ := accountdomain.NewAccount("acc-1", "Ann", "ann@example.com", "hash", accountdomain.StatusBanned, &createdAt)
+	if err := f.builder.BuildAccountCommandRepo().Create(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.builder.Outbox.Add(ctx, accountdomain.EventAccountCreated, []byte(`{"account_id":"acc-1"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	relay := outbox.NewRelay(f.builder.Outbox, f.publisher, outbox.DefaultRelayConfig())
+	if _, err := relay.RelayOnce(ctx); err != nil {
+		t.Fatal(err)
+	}
+	f.wait(t, f.broker.WaitIdle)
+
+	got, err := f.builder.BuildAccountQueryRepo().GetByID(ctx, "acc-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != "banned" || got.Email != "ann@example.com" {
+		t.Fatalf("projected %+v", got)
+	}
+
+	// A relay that stopped before marking the event sent publishes it again.
+	if err := f.publisher.PublishWithID(ctx, "event-1", accountdomain.EventAccountCreated, []byte(`{"account_id":"acc-1"}`), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.publisher.PublishWithID(ctx, "event-1", accountdomain.EventAccountCreated, []byte(`{"account_id":"acc-1"}`), nil); err != nil {
+		t.Fatal(err)
+	}
+	f.wait(t, f.broker.WaitIdle)
+	if stats := f.guard.Stats(); stats.Hits != 1 || stats.Misses != 2 {
+		t.Fatalf("guard stats = %+v, want the repeated event skipped", stats)
+	}
+}
+
+func TestProjectionConsumerDeadLettersMalformedEvents(t *testing.T) {
+	f := start(t)
+
+	for _, body := range []string{`not json`, `{"occurred_at":"2025-01-01T00:00:00Z"}`} {
+		if err := f.publisher.Publish(context.Background(), accountdomain.EventAccountStatusChanged, []byte(body), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.wait(t, f.broker.WaitIdle)
+
+	dead := f.broker.Messages(accountconsumer.ProjectionQueue + ".dlq")
+	if len(dead) != 2 {
+		t.Fatalf("dead letters = %d, want 2", len(dead))
+	}
+	if reason := dead[1].Headers[rabbitmq.HeaderFailureReason]; reason != "account event without account_id" {
+		t.Fatalf("failure reason = %v", reason)
+	}
+}
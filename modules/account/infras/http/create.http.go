@@ -10,7 +10,7 @@ import (
 func (s *accountHttp) handleCreateAccount() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var dto accountcommands.CreateAccountCmdDTO
-		if err := ctx.BindJSON(&dto); err != nil {
+		if err := common.BindJSON(ctx, &dto); err != nil {
 			common.ResponseError(ctx, err)
 			return
 		}
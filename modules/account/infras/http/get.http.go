@@ -0,0 +1,19 @@
+package accounthttp
+
+import (
+	"elastic-logger-app/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *accountHttp) handleGetAccount() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		resp, err := s.query.GetAccount.Handle(ctx, ctx.Param("id"))
+		if err != nil {
+			common.ResponseError(ctx, err)
+			return
+		}
+
+		common.ResponseSuccess(ctx, resp)
+	}
+}
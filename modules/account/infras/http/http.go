@@ -10,18 +10,24 @@ import (
 type accountHttp struct {
 	cmd   accountcommands.Commands
 	query accountqueries.Queries
+	// idempotent guards non-idempotent routes with the Idempotency-Key header.
+	idempotent gin.HandlerFunc
 }
 
-func NewAccountHTTP(cmd accountcommands.Commands, query accountqueries.Queries) *accountHttp {
+func NewAccountHTTP(cmd accountcommands.Commands, query accountqueries.Queries, idempotent gin.HandlerFunc) *accountHttp {
 	return &accountHttp{
-		cmd:   cmd,
-		query: query,
+		cmd:        cmd,
+		query:      query,
+		idempotent: idempotent,
 	}
 }
 
 func (s *accountHttp) Routes(g *gin.RouterGroup) {
 	acc_route := g.Group("/accounts")
 	{
-		acc_route.POST("", s.handleCreateAccount())
+		acc_route.POST("", s.idempotent, s.handleCreateAccount())
+		acc_route.GET("", s.handleListAccounts())
+		acc_route.GET("/:id", s.handleGetAccount())
+		acc_route.PATCH("/:id/status", s.handleChangeAccountStatus())
 	}
 }
@@ -0,0 +1,27 @@
+package accounthttp
+
+import (
+	"elastic-logger-app/common"
+	"elastic-logger-app/common/query"
+	accountqueries "elastic-logger-app/modules/account/usecase/queries"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *accountHttp) handleListAccounts() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		q, err := query.FromGin(ctx, accountqueries.AccountListSchema)
+		if err != nil {
+			common.ResponseError(ctx, err)
+			return
+		}
+
+		items, paging, err := s.query.ListAccounts.Handle(ctx, q)
+		if err != nil {
+			common.ResponseError(ctx, err)
+			return
+		}
+
+		common.ResponseGetWithPagination(ctx, items, paging, q.Filters)
+	}
+}
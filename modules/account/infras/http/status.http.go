@@ -0,0 +1,25 @@
+package accounthttp
+
+import (
+	"elastic-logger-app/common"
+	accountcommands "elastic-logger-app/modules/account/usecase/commands"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *accountHttp) handleChangeAccountStatus() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var dto accountcommands.ChangeAccountStatusCmdDTO
+		if err := common.BindJSON(ctx, &dto); err != nil {
+			common.ResponseError(ctx, err)
+			return
+		}
+
+		if err := s.cmd.ChangeAccountStatus.Handle(ctx, ctx.Param("id"), &dto); err != nil {
+			common.ResponseError(ctx, err)
+			return
+		}
+
+		common.ResponseUpdated(ctx)
+	}
+}
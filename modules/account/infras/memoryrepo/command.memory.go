@@ -0,0 +1,107 @@
+package accountmemoryrepo
+
+import (
+	"context"
+	accountdomain "elastic-logger-app/modules/account/domain"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type accountCommandRepo struct {
+	store *Store
+}
+
+func NewAccountCommandRepo(store *Store) *accountCommandRepo {
+	return &accountCommandRepo{store: store}
+}
+
+func (r *accountCommandRepo) Create(ctx context.Context, entity *accountdomain.Account) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.accounts[entity.GetID()]; ok {
+		return fmt.Errorf("duplicate account id %s", entity.GetID())
+	}
+	for _, a := range s.accounts {
+		// The email column uses a case-insensitive collation.
+		if strings.EqualFold(a.GetEmail(), entity.GetEmail()) {
+			return fmt.Errorf("%w: %s", accountdomain.ErrEmailTaken, entity.GetEmail())
+		}
+	}
+
+	// Like the column default, the clock only stamps accounts created without a time.
+	createdAt := entity.GetCreatedAt()
+	if createdAt.IsZero() {
+		createdAt = s.now()
+	}
+	stored, _ := accountdomain.NewAccount(entity.GetID(), entity.GetName(), entity.GetEmail(), entity.GetPassword(), entity.GetStatus(), &createdAt)
+	s.accounts[stored.GetID()] = stored
+	return nil
+}
+
+// UpdateStatus sets the status of the account with id and returns the account as stored.
+func (r *accountCommandRepo) UpdateStatus(ctx context.Context, id string, status accountdomain.Status) (*accountdomain.Account, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.accounts[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", accountdomain.ErrNotFound, id)
+	}
+	// Stored accounts are shared with callers, so the update replaces rather than mutates.
+	createdAt := a.GetCreatedAt()
+	updated, _ := accountdomain.NewAccount(a.GetID(), a.GetName(), a.GetEmail(), a.GetPassword(), status, &createdAt)
+	s.accounts[id] = updated
+	return updated, nil
+}
+
+// GetByID returns the account with id, or accountdomain.ErrNotFound.
+func (r *accountCommandRepo) GetByID(ctx context.Context, id string) (*accountdomain.Account, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	a, ok := s.accounts[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", accountdomain.ErrNotFound, id)
+	}
+	return a, nil
+}
+
+// ListAfter returns up to limit accounts with an id greater than afterID, in id order.
+func (r *accountCommandRepo) ListAfter(ctx context.Context, afterID string, limit int) ([]*accountdomain.Account, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	accounts := make([]*accountdomain.Account, 0, len(s.accounts))
+	for _, a := range s.accounts {
+		if a.GetID() > afterID {
+			accounts = append(accounts, a)
+		}
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].GetID() < accounts[j].GetID() })
+	if len(accounts) > limit {
+		accounts = accounts[:limit]
+	}
+	return accounts, nil
+}
@@ -0,0 +1,55 @@
+package accountmemoryrepo
+
+import (
+	"context"
+	accountdomain "elastic-logger-app/modules/account/domain"
+	"time"
+)
+
+// projectedAccount is an account in the view, stamped like the Mongo documents.
+type projectedAccount struct {
+	account     *accountdomain.Account
+	projectedAt time.Time
+}
+
+// accountProjection writes the view of the Store, as the Mongo projection writes the read
+// model.
+type accountProjection struct {
+	store *Store
+}
+
+func NewAccountProjection(store *Store) *accountProjection {
+	return &accountProjection{store: store}
+}
+
+func (p *accountProjection) Upsert(ctx context.Context, accounts []*accountdomain.Account, at time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s := p.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range accounts {
+		s.view[a.GetID()] = projectedAccount{account: a, projectedAt: at}
+	}
+	return nil
+}
+
+func (p *accountProjection) DeleteProjectedBefore(ctx context.Context, at time.Time) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	s := p.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var removed int64
+	for id, pa := range s.view {
+		if pa.projectedAt.Before(at) {
+			delete(s.view, id)
+			removed++
+		}
+	}
+	return removed, nil
+}
@@ -0,0 +1,66 @@
+package accountmemoryrepo
+
+import (
+	"context"
+	"elastic-logger-app/common"
+	"elastic-logger-app/common/query"
+	accountdomain "elastic-logger-app/modules/account/domain"
+	accountqueries "elastic-logger-app/modules/account/usecase/queries"
+)
+
+type accountQueryRepo struct {
+	store *Store
+}
+
+func NewAccountQueryRepo(store *Store) *accountQueryRepo {
+	return &accountQueryRepo{store: store}
+}
+
+func toDTO(a *accountdomain.Account) accountqueries.AccountDTO {
+	return accountqueries.AccountDTO{
+		Id:        a.GetID(),
+		Name:      a.GetName(),
+		Email:     a.GetEmail(),
+		Status:    a.GetStatus().String(),
+		CreatedAt: a.GetCreatedAt(),
+	}
+}
+
+func sortValues(a accountqueries.AccountDTO) map[string]any {
+	return map[string]any{"id": a.Id, "name": a.Name, "email": a.Email, "status": a.Status, "created_at": a.CreatedAt}
+}
+
+func (r *accountQueryRepo) List(ctx context.Context, q *query.Query) ([]accountqueries.AccountDTO, common.Paging, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, common.Paging{}, err
+	}
+
+	s := r.store
+	s.mu.RLock()
+	all := make([]accountqueries.AccountDTO, 0, len(s.view))
+	for _, pa := range s.view {
+		all = append(all, toDTO(pa.account))
+	}
+	s.mu.RUnlock()
+
+	items, total := query.Slice(q, all, sortValues)
+	items, paging := query.Page(q, items, total, sortValues)
+	return items, paging, nil
+}
+
+func (r *accountQueryRepo) GetByID(ctx context.Context, id string) (*accountqueries.AccountDTO, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	pa, ok := s.view[id]
+	if !ok {
+		return nil, accountdomain.ErrNotFound
+	}
+	dto := toDTO(pa.account)
+	return &dto, nil
+}
@@ -0,0 +1,45 @@
+// Package accountmemoryrepo keeps accounts in memory for tests. It implements the command and
+// query repositories and the projection with the semantics of the MySQL and Mongo ones: emails
+// are unique regardless of case, missing accounts are accountdomain.ErrNotFound, and created_at
+// is set on insert. As with MySQL and Mongo, the query repository reads a view of its own that
+// only the projection writes, so an account created through the command repository is not
+// readable until it is projected.
+package accountmemoryrepo
+
+import (
+	accountdomain "elastic-logger-app/modules/account/domain"
+	"sync"
+	"time"
+)
+
+// Store holds the accounts shared by the repositories created from it: the write model and
+// the projected view. It is safe for concurrent use.
+type Store struct {
+	mu       sync.RWMutex
+	accounts map[string]*accountdomain.Account
+	view     map[string]projectedAccount
+	// now stamps created_at like the column default of the account table.
+	now func() time.Time
+}
+
+func NewStore() *Store {
+	return &Store{
+		accounts: map[string]*accountdomain.Account{},
+		view:     map[string]projectedAccount{},
+		now:      func() time.Time { return time.Now().UTC().Truncate(time.Second) },
+	}
+}
+
+// SetClock replaces the clock stamping created_at, so tests control the order of accounts.
+func (s *Store) SetClock(now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = now
+}
+
+// Len returns the number of accounts in the write model.
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.accounts)
+}
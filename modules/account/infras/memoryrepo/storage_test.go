@@ -0,0 +1,157 @@
+package accountmemoryrepo
+
+import (
+	"context"
+	"elastic-logger-app/common/query"
+	accountdomain "elastic-logger-app/modules/account/domain"
+	accountqueries "elastic-logger-app/modules/account/usecase/queries"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func newAccount(t *testing.T, id, email string, status accountdomain.Status) *accountdomain.Account {
+	t.Helper()
+	a, err := accountdomain.NewAccount(id, "name "+id, email, "secret-password", status, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+// project copies the write model of store into its view, as a rebuild does.
+func project(t *testing.T, store *Store, at time.Time) {
+	t.Helper()
+	ctx := context.Background()
+	accounts, err := NewAccountCommandRepo(store).ListAfter(ctx, "", store.Len())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewAccountProjection(store).Upsert(ctx, accounts, at); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreateRejectsTakenEmailIgnoringCase(t *testing.T) {
+	ctx := context.Background()
+	repo := NewAccountCommandRepo(NewStore())
+
+	if err := repo.Create(ctx, newAccount(t, "a", "ann@example.com", accountdomain.StatusActivated)); err != nil {
+		t.Fatal(err)
+	}
+	err := repo.Create(ctx, newAccount(t, "b", "Ann@Example.com", accountdomain.StatusActivated))
+	if !errors.Is(err, accountdomain.ErrEmailTaken) {
+		t.Fatalf("err = %v, want ErrEmailTaken", err)
+	}
+}
+
+func TestGetByID(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore()
+	created := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	store.SetClock(func() time.Time { return created })
+
+	if err := NewAccountCommandRepo(store).Create(ctx, newAccount(t, "a", "ann@example.com", accountdomain.StatusBanned)); err != nil {
+		t.Fatal(err)
+	}
+
+	queries := NewAccountQueryRepo(store)
+	project(t, store, created)
+	got, err := queries.GetByID(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := accountqueries.AccountDTO{Id: "a", Name: "name a", Email: "ann@example.com", Status: "banned", CreatedAt: created}
+	if *got != want {
+		t.Fatalf("got %+v, want %+v", *got, want)
+	}
+
+	if _, err := queries.GetByID(ctx, "missing"); !errors.Is(err, accountdomain.ErrNotFound) {
+		t.Fatalf("err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestList(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore()
+	day := 0
+	store.SetClock(func() time.Time {
+		day++
+		return time.Date(2025, 1, day, 0, 0, 0, 0, time.UTC)
+	})
+
+	commands := NewAccountCommandRepo(store)
+	for _, a := range []*accountdomain.Account{
+		newAccount(t, "a", "a@example.com", accountdomain.StatusActivated),
+		newAccount(t, "b", "b@example.com", accountdomain.StatusBanned),
+		newAccount(t, "c", "c@example.com", accountdomain.StatusActivated),
+		newAccount(t, "d", "d@example.com", accountdomain.StatusActivated),
+	} {
+		if err := commands.Create(ctx, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	project(t, store, time.Now())
+	values, _ := url.ParseQuery("status=activated&limit=2")
+	q, err := query.Parse(values, accountqueries.AccountListSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, paging, err := NewAccountQueryRepo(store).List(ctx, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].Id != "d" || items[1].Id != "c" {
+		t.Fatalf("items = %+v", items)
+	}
+	if paging.Total != 3 || !paging.HasMore || paging.NextCursor == "" {
+		t.Fatalf("paging = %+v", paging)
+	}
+}
+
+func TestListAfter(t *testing.T) {
+	ctx := context.Background()
+	repo := NewAccountCommandRepo(NewStore())
+	for _, id := range []string{"c", "a", "b"} {
+		if err := repo.Create(ctx, newAccount(t, id, id+"@example.com", accountdomain.StatusActivated)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := repo.ListAfter(ctx, "a", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].GetID() != "b" {
+		t.Fatalf("got %d accounts starting with %v", len(got), got)
+	}
+}
+
+func TestQueriesReadTheProjectedView(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore()
+	queries, projection := NewAccountQueryRepo(store), NewAccountProjection(store)
+
+	if err := NewAccountCommandRepo(store).Create(ctx, newAccount(t, "a", "ann@example.com", accountdomain.StatusActivated)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := queries.GetByID(ctx, "a"); !errors.Is(err, accountdomain.ErrNotFound) {
+		t.Fatalf("unprojected account: err = %v, want ErrNotFound", err)
+	}
+
+	first := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	project(t, store, first)
+	if _, err := queries.GetByID(ctx, "a"); err != nil {
+		t.Fatalf("projected account: %v", err)
+	}
+
+	removed, err := projection.DeleteProjectedBefore(ctx, first.Add(time.Second))
+	if err != nil || removed != 1 {
+		t.Fatalf("removed %d, %v", removed, err)
+	}
+	if _, err := queries.GetByID(ctx, "a"); !errors.Is(err, accountdomain.ErrNotFound) {
+		t.Fatalf("removed account: err = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,45 @@
+package accountqueryrepo
+
+import (
+	"elastic-logger-app/migration"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoSteps are the migrations of the account read model in db. Versions are shared with the
+// other modules' Mongo steps and must stay unique.
+func MongoSteps(db *mongo.Database) []migration.Step {
+	accounts := db.Collection(accountCollection)
+
+	return []migration.Step{
+		{
+			Version:     1,
+			Description: "accounts: unique index on email",
+			Up: migration.CreateIndex(accounts, mongo.IndexModel{
+				Keys:    bson.D{{Key: "email", Value: 1}},
+				Options: options.Index().SetName("email_unique").SetUnique(true),
+			}),
+			Down: migration.DropIndex(accounts, "email_unique"),
+		},
+		{
+			Version:     2,
+			Description: "accounts: index on status and created_at for the list endpoint",
+			Up: migration.CreateIndex(accounts, mongo.IndexModel{
+				Keys:    bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}},
+				Options: options.Index().SetName("status_created_at"),
+			}),
+			Down: migration.DropIndex(accounts, "status_created_at"),
+		},
+		{
+			Version:     3,
+			Description: "accounts: index on created_at for the default sort",
+			Up: migration.CreateIndex(accounts, mongo.IndexModel{
+				Keys:    bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}},
+				Options: options.Index().SetName("created_at"),
+			}),
+			Down: migration.DropIndex(accounts, "created_at"),
+		},
+	}
+}
@@ -0,0 +1,25 @@
+package accountqueryrepo
+
+import (
+	"context"
+	"elastic-logger-app/migration"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestMongoStepsAreValid(t *testing.T) {
+	// Connect does not dial, and the collection handles are only used when a step runs.
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://127.0.0.1:1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+
+	db := client.Database("test")
+	if _, err := migration.NewMongoRunner(db, time.Second, MongoSteps(db)); err != nil {
+		t.Fatal(err)
+	}
+}
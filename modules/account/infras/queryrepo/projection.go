@@ -0,0 +1,55 @@
+package accountqueryrepo
+
+import (
+	"context"
+	accountdomain "elastic-logger-app/modules/account/domain"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// accountProjection is an account document as written by the projection, stamped with the
+// time of the run that wrote it so a rebuild can find documents it did not touch.
+type accountProjection struct {
+	accountDocument `bson:",inline"`
+	ProjectedAt     time.Time `bson:"projected_at"`
+}
+
+// Upsert writes accounts into the read model in one bulk request.
+func (r *AccountQueryRepo) Upsert(ctx context.Context, accounts []*accountdomain.Account, at time.Time) error {
+	if len(accounts) == 0 {
+		return nil
+	}
+
+	models := make([]mongo.WriteModel, 0, len(accounts))
+	for _, a := range accounts {
+		doc := accountProjection{
+			accountDocument: accountDocument{
+				ID:        a.GetID(),
+				Name:      a.GetName(),
+				Email:     a.GetEmail(),
+				Status:    a.GetStatus().String(),
+				CreatedAt: a.GetCreatedAt(),
+			},
+			ProjectedAt: at,
+		}
+		models = append(models, mongo.NewReplaceOneModel().SetFilter(bson.M{"_id": doc.ID}).SetReplacement(doc).SetUpsert(true))
+	}
+
+	_, err := r.coll.BulkWrite(ctx, models)
+	return err
+}
+
+// DeleteProjectedBefore removes documents not written since at, i.e. accounts that no
+// longer exist in the write model.
+func (r *AccountQueryRepo) DeleteProjectedBefore(ctx context.Context, at time.Time) (int64, error) {
+	res, err := r.coll.DeleteMany(ctx, bson.M{"$or": bson.A{
+		bson.M{"projected_at": bson.M{"$lt": at}},
+		bson.M{"projected_at": bson.M{"$exists": false}},
+	}})
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
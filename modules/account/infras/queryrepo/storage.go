@@ -1,13 +1,88 @@
 package accountqueryrepo
 
-import "go.mongodb.org/mongo-driver/mongo"
+import (
+	"context"
+	"elastic-logger-app/common"
+	"elastic-logger-app/common/query"
+	accountdomain "elastic-logger-app/modules/account/domain"
+	accountqueries "elastic-logger-app/modules/account/usecase/queries"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// accountCollection holds the account read model.
+const accountCollection = "accounts"
+
+type accountDocument struct {
+	ID        string    `bson:"_id"`
+	Name      string    `bson:"name"`
+	Email     string    `bson:"email"`
+	Status    string    `bson:"status"`
+	CreatedAt time.Time `bson:"created_at"`
+}
+
+func (d accountDocument) toDTO() accountqueries.AccountDTO {
+	return accountqueries.AccountDTO{
+		Id:        d.ID,
+		Name:      d.Name,
+		Email:     d.Email,
+		Status:    d.Status,
+		CreatedAt: d.CreatedAt,
+	}
+}
 
 type AccountQueryRepo struct {
 	mongo *mongo.Client
+	coll  *mongo.Collection
 }
 
-func NewAccountQueryRepo(mongo *mongo.Client) *AccountQueryRepo {
+func NewAccountQueryRepo(mongo *mongo.Client, database string) *AccountQueryRepo {
 	return &AccountQueryRepo{
 		mongo: mongo,
+		coll:  mongo.Database(database).Collection(accountCollection),
+	}
+}
+
+func (r *AccountQueryRepo) List(ctx context.Context, q *query.Query) ([]accountqueries.AccountDTO, common.Paging, error) {
+	total, err := r.coll.CountDocuments(ctx, q.MongoFilter())
+	if err != nil {
+		return nil, common.Paging{}, err
+	}
+
+	cur, err := r.coll.Find(ctx, q.MongoPageFilter(), q.MongoFindOptions())
+	if err != nil {
+		return nil, common.Paging{}, err
+	}
+
+	var docs []accountDocument
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, common.Paging{}, err
 	}
+
+	items := make([]accountqueries.AccountDTO, len(docs))
+	for i, d := range docs {
+		items[i] = d.toDTO()
+	}
+
+	items, paging := query.Page(q, items, total, func(a accountqueries.AccountDTO) map[string]any {
+		return map[string]any{"id": a.Id, "name": a.Name, "email": a.Email, "created_at": a.CreatedAt}
+	})
+	return items, paging, nil
+}
+
+func (r *AccountQueryRepo) GetByID(ctx context.Context, id string) (*accountqueries.AccountDTO, error) {
+	var doc accountDocument
+	err := r.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, accountdomain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	dto := doc.toDTO()
+	return &dto, nil
 }
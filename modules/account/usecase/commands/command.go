@@ -3,24 +3,70 @@ package accountcommands
 import (
 	"context"
 	accountdomain "elastic-logger-app/modules/account/domain"
+	accountprojections "elastic-logger-app/modules/account/usecase/projections"
+	"encoding/json"
+	"time"
 )
 
 type Commands struct {
-	CreateAccount *createAccountHandler
+	CreateAccount       *createAccountHandler
+	ChangeAccountStatus *changeAccountStatusHandler
 }
 
 type Builder interface {
 	BuildAccountCommandRepo() AccountCommandRepo
+	BuildAccountProjection() accountprojections.AccountProjection
+	BuildTransactor() Transactor
+	BuildAccountOutbox() AccountOutbox
 }
 
 func NewAccountCmdWithBuilder(b Builder) Commands {
 	return Commands{
 		CreateAccount: NewCreateAccountHandler(
+			b.BuildTransactor(),
 			b.BuildAccountCommandRepo(),
+			b.BuildAccountOutbox(),
+			b.BuildAccountProjection(),
+		),
+		ChangeAccountStatus: NewChangeAccountStatusHandler(
+			b.BuildTransactor(),
+			b.BuildAccountCommandRepo(),
+			b.BuildAccountOutbox(),
+			b.BuildAccountProjection(),
 		),
 	}
 }
 
 type AccountCommandRepo interface {
 	Create(ctx context.Context, entity *accountdomain.Account) error
+	// UpdateStatus returns the account as stored after the change, or
+	// accountdomain.ErrNotFound.
+	UpdateStatus(ctx context.Context, id string, status accountdomain.Status) (*accountdomain.Account, error)
+}
+
+// AccountReadModel is the part of the account projection commands write through, so the
+// query side sees their changes without waiting for a rebuild.
+type AccountReadModel interface {
+	Upsert(ctx context.Context, accounts []*accountdomain.Account, at time.Time) error
+}
+
+// Transactor runs fn so that the writes made with its ctx, to the command repository and the
+// outbox, commit or roll back together.
+type Transactor interface {
+	InTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// AccountOutbox records the events of a command in its transaction, for the relay to publish
+// once it committed.
+type AccountOutbox interface {
+	Add(ctx context.Context, routingKey string, body []byte) error
+}
+
+// addEvent records the event named routingKey about the account with the given ID.
+func addEvent(ctx context.Context, outbox AccountOutbox, routingKey, accountID string) error {
+	body, err := json.Marshal(accountdomain.AccountEvent{AccountID: accountID, OccurredAt: time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+	return outbox.Add(ctx, routingKey, body)
 }
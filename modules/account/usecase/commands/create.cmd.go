@@ -3,22 +3,34 @@ package accountcommands
 import (
 	"context"
 	"elastic-logger-app/common"
+	"elastic-logger-app/common/logger"
+	"elastic-logger-app/common/tracing"
 	accountdomain "elastic-logger-app/modules/account/domain"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
 )
 
 type CreateAccountCmdDTO struct {
-	Name     string `json:"name"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Name     string `json:"name" binding:"required,max=255"`
+	Email    string `json:"email" binding:"required,email,max=255"`
+	Password string `json:"password" binding:"required,min=8,max=72"`
 }
 
 type createAccountHandler struct {
+	tx          Transactor
 	commandrepo AccountCommandRepo
+	outbox      AccountOutbox
+	readmodel   AccountReadModel
 }
 
-func NewCreateAccountHandler(cmdRepo AccountCommandRepo) *createAccountHandler {
+func NewCreateAccountHandler(tx Transactor, cmdRepo AccountCommandRepo, outbox AccountOutbox, readModel AccountReadModel) *createAccountHandler {
 	return &createAccountHandler{
+		tx:          tx,
 		commandrepo: cmdRepo,
+		outbox:      outbox,
+		readmodel:   readModel,
 	}
 }
 
@@ -26,19 +38,45 @@ type ResponseCreateAccountDTO struct {
 	Id string `json:"id"`
 }
 
-func (h *createAccountHandler) Handle(ctx context.Context, dto *CreateAccountCmdDTO) (*ResponseCreateAccountDTO, error) {
+func (h *createAccountHandler) Handle(ctx context.Context, dto *CreateAccountCmdDTO) (resp *ResponseCreateAccountDTO, err error) {
+	ctx, span := tracing.Start(ctx, "createAccountHandler.Handle")
+	defer func() { tracing.End(span, err) }()
+
 	accid := common.GenUUID()
+	// Stamped here rather than by the column default so the read model gets the same time.
+	// DATETIME keeps whole seconds.
+	createdAt := time.Now().UTC().Truncate(time.Second)
 	entity, _ := accountdomain.NewAccount(
 		accid.String(),
 		dto.Name,
 		dto.Email,
 		dto.Password,
 		accountdomain.StatusActivated,
-		nil,
+		&createdAt,
 	)
 
-	if err := h.commandrepo.Create(ctx, entity); err != nil {
-		return nil, common.NewInternalServerError("cannot create new account", "cannot get insert account into db")
+	// The account.created event commits with the account, so it is published if and only if
+	// the account exists.
+	err = h.tx.InTx(ctx, func(ctx context.Context) error {
+		if err := h.commandrepo.Create(ctx, entity); err != nil {
+			return err
+		}
+		return addEvent(ctx, h.outbox, accountdomain.EventAccountCreated, accid.String())
+	})
+	if err != nil {
+		if errors.Is(err, accountdomain.ErrEmailTaken) {
+			return nil, common.NewErrorFromCode(accountdomain.CodeEmailTaken, map[string]any{"email": dto.Email}).WithInner(err)
+		}
+		logger.FromContext(ctx).Named("account").Error("cannot insert account", zap.String("account_id", accid.String()), zap.Error(err))
+		return nil, common.NewErrorFromCode(accountdomain.CodeCreateFailed, nil).
+			WithReason("cannot insert account into db").WithInner(err)
+	}
+
+	// The account exists once MySQL has it. A failed projection only delays it on the query
+	// side until the next `projection rebuild`, so it does not fail the request.
+	if err := h.readmodel.Upsert(ctx, []*accountdomain.Account{entity}, time.Now().UTC().Truncate(time.Millisecond)); err != nil {
+		logger.FromContext(ctx).Named("account").Warn("cannot project created account, run projection rebuild",
+			zap.String("account_id", accid.String()), zap.Error(err))
 	}
 
 	response := &ResponseCreateAccountDTO{
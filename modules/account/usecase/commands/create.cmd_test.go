@@ -0,0 +1,122 @@
+package accountcommands
+
+import (
+	"context"
+	"elastic-logger-app/common"
+	accountdomain "elastic-logger-app/modules/account/domain"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+type recordingRepo struct {
+	created []*accountdomain.Account
+}
+
+func (r *recordingRepo) Create(ctx context.Context, entity *accountdomain.Account) error {
+	r.created = append(r.created, entity)
+	return nil
+}
+
+func (r *recordingRepo) UpdateStatus(ctx context.Context, id string, status accountdomain.Status) (*accountdomain.Account, error) {
+	return nil, accountdomain.ErrNotFound
+}
+
+type recordingReadModel struct {
+	projected []*accountdomain.Account
+	err       error
+}
+
+func (r *recordingReadModel) Upsert(ctx context.Context, accounts []*accountdomain.Account, at time.Time) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.projected = append(r.projected, accounts...)
+	return nil
+}
+
+// recordingTx runs functions directly and counts them, standing in for a MySQL transaction.
+type recordingTx struct {
+	runs int
+}
+
+func (t *recordingTx) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	t.runs++
+	return fn(ctx)
+}
+
+type recordingOutbox struct {
+	keys   []string
+	bodies [][]byte
+	err    error
+}
+
+func (o *recordingOutbox) Add(ctx context.Context, routingKey string, body []byte) error {
+	if o.err != nil {
+		return o.err
+	}
+	o.keys = append(o.keys, routingKey)
+	o.bodies = append(o.bodies, body)
+	return nil
+}
+
+func TestCreateAccountProjectsTheAccount(t *testing.T) {
+	repo, readModel := &recordingRepo{}, &recordingReadModel{}
+	h := NewCreateAccountHandler(&recordingTx{}, repo, &recordingOutbox{}, readModel)
+
+	resp, err := h.Handle(context.Background(), &CreateAccountCmdDTO{Name: "Ann", Email: "ann@example.com", Password: "secret-password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(readModel.projected) != 1 || readModel.projected[0].GetID() != resp.Id {
+		t.Fatalf("projected %d accounts, want the created one", len(readModel.projected))
+	}
+	// The read model must show the created_at stored in MySQL.
+	if created := repo.created[0].GetCreatedAt(); created.IsZero() || !readModel.projected[0].GetCreatedAt().Equal(created) {
+		t.Fatalf("created_at = %v, projected %v", created, readModel.projected[0].GetCreatedAt())
+	}
+}
+
+func TestCreateAccountSucceedsWhenProjectionFails(t *testing.T) {
+	repo := &recordingRepo{}
+	h := NewCreateAccountHandler(&recordingTx{}, repo, &recordingOutbox{}, &recordingReadModel{err: errors.New("mongo unavailable")})
+
+	if _, err := h.Handle(context.Background(), &CreateAccountCmdDTO{Name: "Ann", Email: "ann@example.com", Password: "secret-password"}); err != nil {
+		t.Fatalf("err = %v, want the account created anyway", err)
+	}
+	if len(repo.created) != 1 {
+		t.Fatalf("created %d accounts", len(repo.created))
+	}
+}
+
+func TestCreateAccountWritesTheEventInItsTransaction(t *testing.T) {
+	tx, outbox := &recordingTx{}, &recordingOutbox{}
+	h := NewCreateAccountHandler(tx, &recordingRepo{}, outbox, &recordingReadModel{})
+
+	resp, err := h.Handle(context.Background(), &CreateAccountCmdDTO{Name: "Ann", Email: "ann@example.com", Password: "secret-password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var event accountdomain.AccountEvent
+	if tx.runs != 1 || len(outbox.keys) != 1 || outbox.keys[0] != accountdomain.EventAccountCreated {
+		t.Fatalf("transactions = %d, events = %v", tx.runs, outbox.keys)
+	}
+	if err := json.Unmarshal(outbox.bodies[0], &event); err != nil || event.AccountID != resp.Id {
+		t.Fatalf("event = %s, err = %v", outbox.bodies[0], err)
+	}
+}
+
+func TestCreateAccountFailsWhenTheEventCannotBeWritten(t *testing.T) {
+	readModel := &recordingReadModel{}
+	h := NewCreateAccountHandler(&recordingTx{}, &recordingRepo{}, &recordingOutbox{err: errors.New("outbox full")}, readModel)
+
+	_, err := h.Handle(context.Background(), &CreateAccountCmdDTO{Name: "Ann", Email: "ann@example.com", Password: "secret-password"})
+	var appErr *common.AppError
+	if !errors.As(err, &appErr) || appErr.ErrorCode != accountdomain.CodeCreateFailed {
+		t.Fatalf("err = %v, want %s", err, accountdomain.CodeCreateFailed)
+	}
+	if len(readModel.projected) != 0 {
+		t.Fatal("projected an account whose transaction failed")
+	}
+}
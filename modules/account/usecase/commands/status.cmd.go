@@ -0,0 +1,62 @@
+package accountcommands
+
+import (
+	"context"
+	"elastic-logger-app/common"
+	"elastic-logger-app/common/logger"
+	"elastic-logger-app/common/tracing"
+	accountdomain "elastic-logger-app/modules/account/domain"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type ChangeAccountStatusCmdDTO struct {
+	Status string `json:"status" binding:"required,oneof=activated banned"`
+}
+
+type changeAccountStatusHandler struct {
+	tx          Transactor
+	commandrepo AccountCommandRepo
+	outbox      AccountOutbox
+	readmodel   AccountReadModel
+}
+
+func NewChangeAccountStatusHandler(tx Transactor, cmdRepo AccountCommandRepo, outbox AccountOutbox, readModel AccountReadModel) *changeAccountStatusHandler {
+	return &changeAccountStatusHandler{
+		tx:          tx,
+		commandrepo: cmdRepo,
+		outbox:      outbox,
+		readmodel:   readModel,
+	}
+}
+
+func (h *changeAccountStatusHandler) Handle(ctx context.Context, id string, dto *ChangeAccountStatusCmdDTO) (err error) {
+	ctx, span := tracing.Start(ctx, "changeAccountStatusHandler.Handle")
+	defer func() { tracing.End(span, err) }()
+
+	var entity *accountdomain.Account
+	err = h.tx.InTx(ctx, func(ctx context.Context) error {
+		var err error
+		if entity, err = h.commandrepo.UpdateStatus(ctx, id, accountdomain.Enum(dto.Status)); err != nil {
+			return err
+		}
+		return addEvent(ctx, h.outbox, accountdomain.EventAccountStatusChanged, id)
+	})
+	if err != nil {
+		if errors.Is(err, accountdomain.ErrNotFound) {
+			return common.NewErrorFromCode(accountdomain.CodeNotFound, nil).WithDetail("account_id", id).WithInner(err)
+		}
+		logger.FromContext(ctx).Named("account").Error("cannot update account status", zap.String("account_id", id), zap.Error(err))
+		return common.NewErrorFromCode(accountdomain.CodeUpdateFailed, nil).
+			WithReason("cannot update account status in db").WithInner(err)
+	}
+
+	// As on create, a failed projection only delays the change on the query side.
+	if err := h.readmodel.Upsert(ctx, []*accountdomain.Account{entity}, time.Now().UTC().Truncate(time.Millisecond)); err != nil {
+		logger.FromContext(ctx).Named("account").Warn("cannot project account status, run projection rebuild",
+			zap.String("account_id", id), zap.Error(err))
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package accountprojections
+
+import (
+	"context"
+	accountdomain "elastic-logger-app/modules/account/domain"
+	"time"
+)
+
+type Projections struct {
+	Rebuild *rebuildHandler
+	Sync    *syncHandler
+}
+
+type Builder interface {
+	BuildAccountSource() AccountSource
+	BuildAccountProjection() AccountProjection
+}
+
+func NewAccountProjectionWithBuilder(b Builder) Projections {
+	return Projections{
+		Rebuild: NewRebuildHandler(b.BuildAccountSource(), b.BuildAccountProjection()),
+		Sync:    NewSyncHandler(b.BuildAccountSource(), b.BuildAccountProjection()),
+	}
+}
+
+// AccountSource reads the write model (MySQL).
+type AccountSource interface {
+	// ListAfter returns up to limit accounts with an id greater than afterID, in id order.
+	ListAfter(ctx context.Context, afterID string, limit int) ([]*accountdomain.Account, error)
+	// GetByID returns the account with id, or accountdomain.ErrNotFound.
+	GetByID(ctx context.Context, id string) (*accountdomain.Account, error)
+}
+
+// AccountProjection writes the read model (Mongo accounts).
+type AccountProjection interface {
+	Upsert(ctx context.Context, accounts []*accountdomain.Account, at time.Time) error
+	DeleteProjectedBefore(ctx context.Context, at time.Time) (int64, error)
+}
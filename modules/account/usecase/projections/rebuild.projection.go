@@ -0,0 +1,78 @@
+package accountprojections
+
+import (
+	"context"
+	"elastic-logger-app/common/logger"
+	"elastic-logger-app/common/tracing"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DefaultRebuildBatchSize is the number of accounts read and written per round-trip.
+const DefaultRebuildBatchSize = 500
+
+type rebuildHandler struct {
+	source     AccountSource
+	projection AccountProjection
+}
+
+func NewRebuildHandler(source AccountSource, projection AccountProjection) *rebuildHandler {
+	return &rebuildHandler{
+		source:     source,
+		projection: projection,
+	}
+}
+
+type RebuildResult struct {
+	Projected int
+	Removed   int64
+}
+
+// Handle rewrites the account read model from the write model. Documents are replaced in
+// place, so the list and get endpoints keep serving during the rebuild; documents of
+// accounts that no longer exist are removed at the end.
+func (h *rebuildHandler) Handle(ctx context.Context, batchSize int) (result *RebuildResult, err error) {
+	ctx, span := tracing.Start(ctx, "rebuildHandler.Handle")
+	defer func() { tracing.End(span, err) }()
+
+	if batchSize <= 0 {
+		batchSize = DefaultRebuildBatchSize
+	}
+
+	log := logger.FromContext(ctx).Named("account")
+	// Truncated to the millisecond, the precision Mongo stores, so the final sweep compares
+	// exactly against what was written.
+	startedAt := time.Now().UTC().Truncate(time.Millisecond)
+	result = &RebuildResult{}
+
+	afterID := ""
+	for {
+		accounts, err := h.source.ListAfter(ctx, afterID, batchSize)
+		if err != nil {
+			return result, fmt.Errorf("read accounts after %q: %w", afterID, err)
+		}
+		if len(accounts) == 0 {
+			break
+		}
+
+		if err := h.projection.Upsert(ctx, accounts, startedAt); err != nil {
+			return result, fmt.Errorf("write accounts after %q: %w", afterID, err)
+		}
+		result.Projected += len(accounts)
+		afterID = accounts[len(accounts)-1].GetID()
+		log.Info("projected accounts", zap.Int("projected", result.Projected))
+
+		if len(accounts) < batchSize {
+			break
+		}
+	}
+
+	removed, err := h.projection.DeleteProjectedBefore(ctx, startedAt)
+	if err != nil {
+		return result, fmt.Errorf("remove stale accounts: %w", err)
+	}
+	result.Removed = removed
+	return result, nil
+}
@@ -0,0 +1,49 @@
+package accountprojections
+
+import (
+	"context"
+	"elastic-logger-app/common/logger"
+	"elastic-logger-app/common/tracing"
+	accountdomain "elastic-logger-app/modules/account/domain"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type syncHandler struct {
+	source     AccountSource
+	projection AccountProjection
+}
+
+func NewSyncHandler(source AccountSource, projection AccountProjection) *syncHandler {
+	return &syncHandler{
+		source:     source,
+		projection: projection,
+	}
+}
+
+// Handle copies the account with accountID as the write model has it now into the read
+// model. It is run for every account event, so it reads the account rather than trusting the
+// event: a late or repeated event then projects the current state, not an older one.
+func (h *syncHandler) Handle(ctx context.Context, accountID string) (err error) {
+	ctx, span := tracing.Start(ctx, "syncHandler.Handle")
+	defer func() { tracing.End(span, err) }()
+
+	account, err := h.source.GetByID(ctx, accountID)
+	if errors.Is(err, accountdomain.ErrNotFound) {
+		// Events commit with the account, so it was removed since; the next rebuild drops it
+		// from the read model.
+		logger.FromContext(ctx).Named("account").Info("account of the event no longer exists", zap.String("account_id", accountID))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read account %s: %w", accountID, err)
+	}
+
+	if err := h.projection.Upsert(ctx, []*accountdomain.Account{account}, time.Now().UTC().Truncate(time.Millisecond)); err != nil {
+		return fmt.Errorf("project account %s: %w", accountID, err)
+	}
+	return nil
+}
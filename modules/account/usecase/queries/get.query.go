@@ -0,0 +1,38 @@
+package accountqueries
+
+import (
+	"context"
+	"elastic-logger-app/common"
+	"elastic-logger-app/common/logger"
+	"elastic-logger-app/common/tracing"
+	accountdomain "elastic-logger-app/modules/account/domain"
+	"errors"
+
+	"go.uber.org/zap"
+)
+
+type getAccountHandler struct {
+	queryrepo AccountQueryRepo
+}
+
+func NewGetAccountHandler(queryRepo AccountQueryRepo) *getAccountHandler {
+	return &getAccountHandler{
+		queryrepo: queryRepo,
+	}
+}
+
+func (h *getAccountHandler) Handle(ctx context.Context, id string) (resp *AccountDTO, err error) {
+	ctx, span := tracing.Start(ctx, "getAccountHandler.Handle")
+	defer func() { tracing.End(span, err) }()
+
+	resp, err = h.queryrepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, accountdomain.ErrNotFound) {
+			return nil, common.NewErrorFromCode(accountdomain.CodeNotFound, nil).WithDetail("account_id", id).WithInner(err)
+		}
+		logger.FromContext(ctx).Named("account").Error("cannot get account", zap.String("account_id", id), zap.Error(err))
+		return nil, common.NewInternalServerError("cannot get account", "cannot query accounts read model").WithInner(err)
+	}
+
+	return resp, nil
+}
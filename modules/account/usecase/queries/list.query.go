@@ -0,0 +1,55 @@
+package accountqueries
+
+import (
+	"context"
+	"elastic-logger-app/common"
+	"elastic-logger-app/common/logger"
+	"elastic-logger-app/common/query"
+	"elastic-logger-app/common/tracing"
+	accountdomain "elastic-logger-app/modules/account/domain"
+
+	"go.uber.org/zap"
+)
+
+// AccountListSchema is the whitelist of sort and filter fields for GET /accounts.
+var AccountListSchema = query.Schema{
+	Fields: map[string]query.Field{
+		"id": {Type: query.String, Ops: []query.Op{query.OpEq, query.OpIn}},
+		"name": {Type: query.String, Sortable: true,
+			Ops: []query.Op{query.OpEq, query.OpContains}},
+		"email": {Type: query.String, Sortable: true,
+			Ops: []query.Op{query.OpEq, query.OpContains}},
+		"status": {Type: query.String,
+			Ops:  []query.Op{query.OpEq, query.OpNe, query.OpIn, query.OpNin},
+			Enum: []string{accountdomain.StatusActivated.String(), accountdomain.StatusBanned.String()}},
+		"created_at": {Type: query.Time, Sortable: true,
+			Ops: []query.Op{query.OpGt, query.OpGte, query.OpLt, query.OpLte}},
+	},
+	Key:          "id",
+	DefaultSort:  "-created_at",
+	DefaultLimit: 20,
+	MaxLimit:     100,
+}
+
+type listAccountsHandler struct {
+	queryrepo AccountQueryRepo
+}
+
+func NewListAccountsHandler(queryRepo AccountQueryRepo) *listAccountsHandler {
+	return &listAccountsHandler{
+		queryrepo: queryRepo,
+	}
+}
+
+func (h *listAccountsHandler) Handle(ctx context.Context, q *query.Query) (items []AccountDTO, paging common.Paging, err error) {
+	ctx, span := tracing.Start(ctx, "listAccountsHandler.Handle")
+	defer func() { tracing.End(span, err) }()
+
+	items, paging, err = h.queryrepo.List(ctx, q)
+	if err != nil {
+		logger.FromContext(ctx).Named("account").Error("cannot list accounts", zap.Error(err))
+		return nil, common.Paging{}, common.NewInternalServerError("cannot list accounts", "cannot query accounts read model").WithInner(err)
+	}
+
+	return items, paging, nil
+}
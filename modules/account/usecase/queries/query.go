@@ -1,6 +1,15 @@
 package accountqueries
 
+import (
+	"context"
+	"elastic-logger-app/common"
+	"elastic-logger-app/common/query"
+	"time"
+)
+
 type Queries struct {
+	ListAccounts *listAccountsHandler
+	GetAccount   *getAccountHandler
 }
 
 type Builder interface {
@@ -8,8 +17,23 @@ type Builder interface {
 }
 
 func NewAccountQueryWithBuilder(b Builder) Queries {
-	return Queries{}
+	repo := b.BuildAccountQueryRepo()
+	return Queries{
+		ListAccounts: NewListAccountsHandler(repo),
+		GetAccount:   NewGetAccountHandler(repo),
+	}
+}
+
+// AccountDTO is the read model of an account; it never carries the password.
+type AccountDTO struct {
+	Id        string    `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 type AccountQueryRepo interface {
+	List(ctx context.Context, q *query.Query) ([]AccountDTO, common.Paging, error)
+	GetByID(ctx context.Context, id string) (*AccountDTO, error)
 }